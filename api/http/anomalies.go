@@ -0,0 +1,59 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+)
+
+func (mc *ManagementController) GetAnomalies(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.GetAnomalies(ctx, id.Tenant)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, res)
+	case reporting.ErrAnomalyReportNotFound:
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+	case reporting.ErrAnomaliesRestricted:
+		renderError(c,
+			http.StatusForbidden,
+			err,
+		)
+	default:
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
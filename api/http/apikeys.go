@@ -0,0 +1,192 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+const (
+	ctxKeyAPIKey = "reporting.apikey"
+
+	paramAPIKeyID = "id"
+)
+
+// authMiddleware authenticates the management API requests either with
+// a reporting API key or, by default, with the user JWT
+func authMiddleware(r reporting.App) gin.HandlerFunc {
+	jwtMiddleware := identity.Middleware()
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !model.IsAPIKey(token) {
+			jwtMiddleware(c)
+			return
+		}
+
+		ctx := c.Request.Context()
+		apiKey, err := r.AuthenticateAPIKey(ctx, token)
+		if err != nil {
+			if err != reporting.ErrInvalidAPIKey {
+				log.FromContext(ctx).Errorf("failed to authenticate API key: %s", err)
+				err = errors.New("internal error")
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="ReportingAPIKey"`)
+			renderError(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+
+		ctx = identity.WithContext(ctx, &identity.Identity{
+			Subject: apiKey.ID,
+			Tenant:  apiKey.TenantID,
+		})
+		ctx = log.WithContext(ctx, log.FromContext(ctx).F(log.Ctx{
+			"api_key_id": apiKey.ID,
+			"tenant_id":  apiKey.TenantID,
+		}))
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(ctxKeyAPIKey, true)
+	}
+}
+
+// readOnly rejects the requests authenticated with an API key
+func readOnly(c *gin.Context) bool {
+	if c.GetBool(ctxKeyAPIKey) {
+		renderError(c,
+			http.StatusForbidden,
+			errors.New("API keys grant read-only access"),
+		)
+		return false
+	}
+	return true
+}
+
+func (mc *ManagementController) CreateAPIKey(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	var req model.APIKeyRequest
+	err := bindJSON(c, &req)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	apiKey, key, err := mc.reporting.CreateAPIKey(ctx, id.Tenant, req.Name, req.ExpiresAt)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		*model.APIKey
+		Key string `json:"key"`
+	}{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+func (mc *ManagementController) GetAPIKeys(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.GetAPIKeys(ctx, id.Tenant)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (mc *ManagementController) DeleteAPIKey(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	err := mc.reporting.DeleteAPIKey(ctx, id.Tenant, c.Param(paramAPIKeyID))
+	if err == reporting.ErrAPIKeyNotFound {
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// apiKeysStore keeps the API keys by hash, as the store does;
+// the lookups of 'failing' fail
+type apiKeysStore struct {
+	store.Store
+	keys    map[string]*model.APIKey
+	failing string
+}
+
+func (s apiKeysStore) GetAPIKeyByHash(ctx context.Context,
+	hash string) (*model.APIKey, error) {
+	if hash == s.failing {
+		return nil, errors.New("connection refused")
+	}
+	return s.keys[hash], nil
+}
+
+func TestAuthMiddlewareAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	newKey := func(tid string, expiresAt *time.Time) (*model.APIKey, string) {
+		apiKey, key, err := model.NewAPIKey(tid, "ci", expiresAt)
+		assert.NoError(t, err)
+		return apiKey, key
+	}
+
+	valid, validKey := newKey("tenant", nil)
+	expired, expiredKey := newKey("tenant", &past)
+	noTenant, noTenantKey := newKey("", nil)
+	_, revokedKey := newKey("tenant", nil)
+	_, failingKey := newKey("tenant", nil)
+	// a document stored under another key's hash
+	_, mismatchKey := newKey("tenant", nil)
+	other, _ := newKey("other", nil)
+
+	s := apiKeysStore{
+		keys: map[string]*model.APIKey{
+			valid.Hash:                    valid,
+			expired.Hash:                  expired,
+			noTenant.Hash:                 noTenant,
+			model.HashAPIKey(mismatchKey): other,
+		},
+		failing: model.HashAPIKey(failingKey),
+	}
+	app := reporting.NewApp(s, nil)
+
+	testCases := map[string]struct {
+		token string
+
+		status int
+		err    string
+	}{
+		"ok": {
+			token:  validKey,
+			status: http.StatusOK,
+		},
+		"error, expired": {
+			token:  expiredKey,
+			status: http.StatusUnauthorized,
+			err:    reporting.ErrInvalidAPIKey.Error(),
+		},
+		"error, revoked": {
+			token:  revokedKey,
+			status: http.StatusUnauthorized,
+			err:    reporting.ErrInvalidAPIKey.Error(),
+		},
+		"error, no tenant": {
+			token:  noTenantKey,
+			status: http.StatusUnauthorized,
+			err:    reporting.ErrInvalidAPIKey.Error(),
+		},
+		"error, other key's tenant": {
+			token:  mismatchKey,
+			status: http.StatusUnauthorized,
+			err:    reporting.ErrInvalidAPIKey.Error(),
+		},
+		"error, malformed": {
+			token:  model.APIKeyPrefix + "foo",
+			status: http.StatusUnauthorized,
+			err:    reporting.ErrInvalidAPIKey.Error(),
+		},
+		"error, store": {
+			token:  failingKey,
+			status: http.StatusUnauthorized,
+			err:    "internal error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", authMiddleware(app), func(c *gin.Context) {
+				id := identity.FromContext(c.Request.Context())
+				c.JSON(http.StatusOK, id)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			if tc.status != http.StatusOK {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				var res Error
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, tc.err, res.Err)
+				return
+			}
+			var id identity.Identity
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &id))
+			assert.Equal(t, valid.TenantID, id.Tenant)
+			assert.Equal(t, valid.ID, id.Subject)
+		})
+	}
+}
+
+func TestAPIKeyReadOnly(t *testing.T) {
+	valid, key, err := model.NewAPIKey("tenant", "ci", nil)
+	assert.NoError(t, err)
+	app := reporting.NewApp(apiKeysStore{
+		keys: map[string]*model.APIKey{valid.Hash: valid},
+	}, nil)
+	router := NewRouter(app)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, URIManagement+"/"+URIAPIKeys, nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
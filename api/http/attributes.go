@@ -0,0 +1,116 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+const (
+	paramAttributeScope = "scope"
+	paramAttributeName  = "name"
+	paramTag            = "tag"
+)
+
+func (mc *ManagementController) SetAttributeMetadata(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	var req model.AttributeMetadataRequest
+	err := bindJSON(c, &req)
+	meta := &model.AttributeMetadata{
+		TenantID:    id.Tenant,
+		Scope:       c.Param(paramAttributeScope),
+		Name:        c.Param(paramAttributeName),
+		DisplayName: req.DisplayName,
+		Tag:         req.Tag,
+		Description: req.Description,
+		Aliases:     req.Aliases,
+	}
+	if err == nil {
+		err = meta.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	if err := mc.reporting.SetAttributeMetadata(ctx, meta); err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
+
+func (mc *ManagementController) DeleteAttributeMetadata(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	err := mc.reporting.DeleteAttributeMetadata(ctx, id.Tenant,
+		c.Param(paramAttributeScope), c.Param(paramAttributeName))
+	if err == reporting.ErrAttributeMetadataNotFound {
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
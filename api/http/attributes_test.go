@@ -0,0 +1,137 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// userToken returns an (unsigned) user JWT of the tenant 'tid'
+func userToken(tid string) string {
+	claims, _ := json.Marshal(map[string]interface{}{
+		"sub":           "user",
+		"mender.tenant": tid,
+		"mender.user":   true,
+	})
+	return "Bearer x." + base64.RawURLEncoding.EncodeToString(claims) + ".x"
+}
+
+// metadataStore keeps the tenant attribute metadata, and maps
+// the attributes of 'attrs'
+type metadataStore struct {
+	store.Store
+	metadata []model.AttributeMetadata
+	attrs    []string
+}
+
+func (s *metadataStore) GetAttributesMetadata(ctx context.Context,
+	tid string) ([]model.AttributeMetadata, error) {
+	return s.metadata, nil
+}
+
+func (s *metadataStore) PutAttributeMetadata(ctx context.Context,
+	meta *model.AttributeMetadata) error {
+	s.metadata = append(s.metadata, *meta)
+	return nil
+}
+
+func (s *metadataStore) GetDevIndex(ctx context.Context,
+	tid string) (map[string]interface{}, error) {
+	props := map[string]interface{}{}
+	for _, a := range s.attrs {
+		parts := strings.SplitN(a, "/", 2)
+		props[model.ToAttr(parts[0], parts[1], model.TypeStr)] = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{"properties": props},
+	}, nil
+}
+
+func TestSetAttributeMetadataAliases(t *testing.T) {
+	testCases := map[string]struct {
+		uri     string
+		aliases []string
+
+		status int
+	}{
+		"ok": {
+			uri:     "inventory/mac",
+			aliases: []string{"hw_address"},
+			status:  http.StatusOK,
+		},
+		"error, duplicate": {
+			uri:     "inventory/mac",
+			aliases: []string{"hw_address", "inventory/hw_address"},
+			status:  http.StatusBadRequest,
+		},
+		"error, alias of another attribute": {
+			uri:     "inventory/mac",
+			aliases: []string{"host"},
+			status:  http.StatusBadRequest,
+		},
+		"error, attribute": {
+			uri:     "inventory/mac",
+			aliases: []string{"device_type"},
+			status:  http.StatusBadRequest,
+		},
+		"error, attribute is an alias": {
+			uri:    "inventory/host",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := &metadataStore{
+				metadata: []model.AttributeMetadata{{
+					TenantID: "tenant",
+					Scope:    "inventory",
+					Name:     "hostname",
+					Aliases:  []string{"host"},
+				}},
+				attrs: []string{"inventory/mac", "inventory/device_type"},
+			}
+			router := NewRouter(reporting.NewApp(s, nil))
+
+			body, _ := json.Marshal(model.AttributeMetadataRequest{Aliases: tc.aliases})
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPut,
+				URIManagement+"/devices/attributes/"+tc.uri,
+				strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", userToken("tenant"))
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+			if tc.status == http.StatusOK {
+				assert.Len(t, s.metadata, 2)
+			} else {
+				assert.Len(t, s.metadata, 1)
+			}
+		})
+	}
+}
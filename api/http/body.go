@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	ctxKeyStrictJSON = "reporting.strict_json"
+
+	defaultSearchBodyLimit = 1 << 20
+	defaultBatchBodyLimit  = 4 << 20
+)
+
+var (
+	ErrRequestTooLarge      = errors.New("request body too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type, expected " + gin.MIMEJSON)
+)
+
+// bodyMiddleware accepts only the JSON request bodies of at most 'limit'
+// bytes; the larger ones are rejected while bound, see bindJSON, as the
+// content length isn't known upfront for the chunked requests
+func bodyMiddleware(limit int64, strict bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength != 0 && c.ContentType() != gin.MIMEJSON {
+			renderError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+			c.Abort()
+			return
+		}
+		if limit > 0 {
+			if c.Request.ContentLength > limit {
+				renderError(c, http.StatusRequestEntityTooLarge, ErrRequestTooLarge)
+				c.Abort()
+				return
+			}
+			c.Request.Body = &limitedBody{ReadCloser: c.Request.Body, n: limit}
+		}
+		c.Set(ctxKeyStrictJSON, strict)
+	}
+}
+
+// limitedBody fails the reads past 'n' bytes
+type limitedBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, ErrRequestTooLarge
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
+
+// bindJSON decodes the JSON request body, rejecting the unknown
+// fields if the strict parsing is enabled for the endpoint
+func bindJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("missing request body")
+	}
+	dec := json.NewDecoder(c.Request.Body)
+	if c.GetBool(ctxKeyStrictJSON) {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(obj)
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyMiddleware(t *testing.T) {
+	uri := URIInternal + "/" + strings.Replace(URIInventorySearchInternal, ":tenant_id", "foo", 1)
+
+	testCases := map[string]struct {
+		uri         string
+		contentType string
+		body        io.Reader
+		length      int64
+		strict      bool
+
+		status int
+		code   string
+	}{
+		"error, content type": {
+			contentType: "text/plain",
+			body:        strings.NewReader(`{"page": 1}`),
+			status:      http.StatusUnsupportedMediaType,
+			code:        ErrCodeUnsupportedMedia,
+		},
+		"error, content length": {
+			contentType: "application/json",
+			body:        strings.NewReader(`{"filters": [` + strings.Repeat(" ", 128) + `]}`),
+			status:      http.StatusRequestEntityTooLarge,
+			code:        ErrCodeRequestTooLarge,
+		},
+		"error, chunked": {
+			contentType: "application/json; charset=utf-8",
+			body:        strings.NewReader(`{"filters": [` + strings.Repeat(" ", 128) + `]}`),
+			length:      -1,
+			status:      http.StatusRequestEntityTooLarge,
+			code:        ErrCodeRequestTooLarge,
+		},
+		"error, chunked, tasks": {
+			uri:         URIInternal + "/" + URITasksInternal,
+			contentType: "application/json",
+			body:        strings.NewReader(`{"type": "` + strings.Repeat(" ", 128) + `"}`),
+			length:      -1,
+			status:      http.StatusRequestEntityTooLarge,
+			code:        ErrCodeRequestTooLarge,
+		},
+		"error, content type, tasks": {
+			uri:         URIInternal + "/" + URITasksInternal,
+			contentType: "text/plain",
+			body:        strings.NewReader(`{}`),
+			status:      http.StatusUnsupportedMediaType,
+			code:        ErrCodeUnsupportedMedia,
+		},
+		"error, strict unknown field": {
+			contentType: "application/json",
+			body:        strings.NewReader(`{"pgae": 1}`),
+			strict:      true,
+			status:      http.StatusBadRequest,
+			code:        ErrCodeRequestInvalid,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router := NewRouter(nil, WithBodyLimits(64, 64), WithStrictJSON(tc.strict))
+
+			reqURI := uri
+			if tc.uri != "" {
+				reqURI = tc.uri
+			}
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, reqURI, tc.body)
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.length != 0 {
+				req.ContentLength = tc.length
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			var res Error
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, tc.code, res.Code)
+		})
+	}
+}
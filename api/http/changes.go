@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+const (
+	hdrCheckpoint = "X-Men-Checkpoint"
+
+	paramCheckpoint = "checkpoint"
+	paramLimit      = "limit"
+)
+
+// HarvestChanges streams, as NDJSON, the devices changed since the
+// checkpoint; the checkpoint to resume from is in the X-Men-Checkpoint
+// header, so that e.g. SIEM or CMDB systems can sync the fleet state.
+// The feed has two limits the consumers must account for, with a
+// periodic full sync, e.g. through the devices search:
+//   - the deleted devices aren't reported, there are no tombstones;
+//   - the checkpoint is the update time of the last device, so a
+//     device whose write becomes searchable more than 5 seconds after
+//     its update time (e.g. a slow or retried bulk write) may be
+//     skipped, if a later change was harvested in the meantime
+func (mc *ManagementController) HarvestChanges(c *gin.Context) {
+	params := model.ChangesParams{
+		Checkpoint: c.Query(paramCheckpoint),
+		Limit:      model.DefaultChangesLimit,
+	}
+	var err error
+	if l := c.Query(paramLimit); l != "" {
+		params.Limit, err = strconv.Atoi(l)
+	}
+	if err == nil {
+		err = params.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "invalid parameters"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.HarvestChanges(ctx, &params)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.Header(hdrCheckpoint, res.Checkpoint)
+	renderNDJSON(c, len(res.Devices), func(i int) interface{} {
+		return res.Devices[i]
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+const (
+	mimeNDJSON = "application/x-ndjson"
+
+	// flush the NDJSON stream every so many devices
+	ndjsonFlushEvery = 100
+)
+
+// wantsNDJSON tells if the client accepts NDJSON rather than JSON
+func wantsNDJSON(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, mimeNDJSON) == mimeNDJSON
+}
+
+// wantsMsgPack tells if the client accepts MessagePack rather than
+// JSON, both the registered and the legacy "x-" media types
+func wantsMsgPack(c *gin.Context) bool {
+	switch c.NegotiateFormat(gin.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK) {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return true
+	}
+	return false
+}
+
+// renderMsgPack renders the body as MessagePack, with the field
+// names of the JSON representation; it's more compact and cheaper
+// to encode and decode for the high-volume internal consumers
+func renderMsgPack(c *gin.Context, body interface{}) {
+	c.Render(http.StatusOK, render.MsgPack{Data: body})
+}
+
+// renderNDJSON streams the items, one JSON document per line
+func renderNDJSON(c *gin.Context, n int, item func(i int) interface{}) {
+	c.Header("Content-Type", mimeNDJSON)
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	for i := 0; i < n; i++ {
+		if err := enc.Encode(item(i)); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if (i+1)%ndjsonFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+	}
+}
+
+// renderJSONWithETag renders the JSON body tagged with the hash of its
+// content and of the paging headers; since the results only change on
+// the index refresh, polling clients sending the tag back in the
+// If-None-Match header get a 304 instead of the same body again.
+// Only the GET and HEAD responses are conditional: the tag of a POST
+// response would identify the body of the request, not a resource
+func renderJSONWithETag(c *gin.Context, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		c.Data(http.StatusOK, gin.MIMEJSON+"; charset=utf-8", b)
+		return
+	}
+
+	h := sha256.New()
+	_, _ = h.Write(b)
+	for _, hdr := range []string{hdrTotalCount, hdrNextCursor, "Link"} {
+		_, _ = h.Write([]byte("\n" + strings.Join(c.Writer.Header().Values(hdr), ",")))
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, gin.MIMEJSON+"; charset=utf-8", b)
+}
+
+// etagMatches tells if any of the If-None-Match tags matches,
+// using the weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipMiddleware compresses the response bodies for
+// the clients accepting the gzip content encoding
+func gzipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			return
+		}
+
+		c.Header("Vary", "Accept-Encoding")
+		w := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer w.close()
+
+		c.Next()
+	}
+}
+
+// gzipWriter starts compressing on the first body write, so that
+// responses without a body (e.g. 204) are left untouched
+type gzipWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if w.gz == nil {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	return w.gz.Write(b)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNDJSONGzip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", gzipMiddleware(), func(c *gin.Context) {
+		items := []string{"a", "b"}
+		renderNDJSON(c, len(items), func(i int) interface{} {
+			return map[string]string{"id": items[i]}
+		})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, mimeNDJSON, w.Header().Get("Content-Type"))
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"a\"}\n{\"id\":\"b\"}\n", string(body))
+}
+
+func TestJSONETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		c.Header(hdrTotalCount, c.Query("total"))
+		renderJSONWithETag(c, []string{"a", "b"})
+	}
+	router.GET("/", handler)
+	router.POST("/", handler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/?total=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["a","b"]`, w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", `"foo", W/`+etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	// the paging headers are part of the tag
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/?total=3", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// the POST responses aren't conditional
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/?total=2", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `["a","b"]`, w.Body.String())
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+func TestMsgPack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		if wantsMsgPack(c) {
+			renderMsgPack(c, []invDeviceV1{{ID: "foo"}})
+			return
+		}
+		c.JSON(http.StatusOK, []invDeviceV1{{ID: "foo"}})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/msgpack")
+
+	body := w.Body.Bytes()
+	// a one element array of a map, with the JSON field names
+	assert.Equal(t, byte(0x91), body[0])
+	assert.Equal(t, byte(0x80), body[1]&0xf0)
+	assert.Contains(t, string(body), "\xa2id\xa3foo")
+
+	// JSON by default
+	w = httptest.NewRecorder()
+	req.Header.Del("Accept")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), gin.MIMEJSON)
+}
@@ -0,0 +1,171 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/mendersoftware/go-lib-micro/requestid"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// the stable, machine-readable error codes of the API error responses;
+// clients should branch on these rather than on the messages
+const (
+	ErrCodeRequestInvalid     = "request.invalid"
+	ErrCodeRequestTooLarge    = "request.too_large"
+	ErrCodeUnsupportedMedia   = "request.unsupported_media_type"
+	ErrCodeQueryInvalid       = "query.invalid"
+	ErrCodeQueryInvalidOp     = "query.invalid_operator"
+	ErrCodeQueryInvalidValue  = "query.invalid_value"
+	ErrCodeQueryInvalidCursor = "query.invalid_cursor"
+	ErrCodeUnauthorized       = "auth.unauthorized"
+	ErrCodeInvalidAPIKey      = "auth.invalid_api_key"
+	ErrCodeForbidden          = "auth.forbidden"
+	ErrCodeNotFound           = "resource.not_found"
+	ErrCodeConflict           = "resource.conflict"
+	ErrCodeUnknownService     = "reindex.unknown_service"
+	ErrCodeFeatureDisabled    = "feature.disabled"
+	ErrCodeStoreUnavailable   = "store.unavailable"
+	ErrCodeServiceUnavailable = "service.unavailable"
+	ErrCodeInternal           = "internal"
+)
+
+var (
+	// codes of the well-known errors, matched by cause, and the HTTP
+	// status they map to, if any (0 keeps the handler status); a slice
+	// rather than a map, as not all the causes are hashable
+	errorCodes = []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{model.ErrArrayNotSupported, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrArrayRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrStrRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrNumRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrBoolRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrCIDRRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrSizeRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrFuzzyRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrDateRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrNotIPAttribute, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrNotNestedAttribute, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrElemMatchRequired, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrCollapseMultiValued, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrTooManyBuckets, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{model.ErrInvalidCursor, ErrCodeQueryInvalidCursor, http.StatusBadRequest},
+		{model.ErrFeatureUnsupported, ErrCodeQueryInvalid, http.StatusBadRequest},
+		{reporting.ErrInvalidAPIKey, ErrCodeInvalidAPIKey, 0},
+		{reporting.ErrAPIKeyNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrAttributeMetadataNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrAttributeAliasConflict, ErrCodeRequestInvalid, http.StatusBadRequest},
+		{reporting.ErrUnknownService, ErrCodeUnknownService, 0},
+		{reporting.ErrAggregationNotNumeric, ErrCodeQueryInvalidValue, http.StatusBadRequest},
+		{reporting.ErrAttributeHidden, ErrCodeQueryInvalid, http.StatusBadRequest},
+		{reporting.ErrDevicesNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrDeviceNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrAnomalyReportNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrTaskNotFound, ErrCodeNotFound, 0},
+		{reporting.ErrTaskFinished, ErrCodeConflict, 0},
+		// the searches overload is transient, whichever the endpoint
+		{reporting.ErrSearchQueueFull, ErrCodeStoreUnavailable, http.StatusServiceUnavailable},
+		{reporting.ErrFeatureDisabled, ErrCodeFeatureDisabled, http.StatusForbidden},
+		// the limit is hit while binding, reported as a malformed body
+		{ErrRequestTooLarge, ErrCodeRequestTooLarge, http.StatusRequestEntityTooLarge},
+		{ErrUnsupportedMediaType, ErrCodeUnsupportedMedia, http.StatusUnsupportedMediaType},
+	}
+
+	// fallback codes, by HTTP status
+	statusErrorCodes = map[int]string{
+		http.StatusBadRequest:            ErrCodeRequestInvalid,
+		http.StatusUnauthorized:          ErrCodeUnauthorized,
+		http.StatusForbidden:             ErrCodeForbidden,
+		http.StatusNotFound:              ErrCodeNotFound,
+		http.StatusConflict:              ErrCodeConflict,
+		http.StatusRequestEntityTooLarge: ErrCodeRequestTooLarge,
+		http.StatusUnsupportedMediaType:  ErrCodeUnsupportedMedia,
+		http.StatusServiceUnavailable:    ErrCodeServiceUnavailable,
+		http.StatusInternalServerError:   ErrCodeInternal,
+	}
+)
+
+// Error is the API error response, the go-lib-micro
+// rest.Error extended with the error code
+type Error struct {
+	Err       string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (err Error) Error() string {
+	return err.Err
+}
+
+// renderError renders the error response, with the error code
+// and the status derived from the error cause and the status
+func renderError(c *gin.Context, status int, err error) {
+	code, status := errorCode(status, err)
+	if code == ErrCodeStoreUnavailable && status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", "1")
+	}
+	renderErrorCode(c, status, code, err)
+}
+
+// renderErrorCode renders the error response with an explicit code
+func renderErrorCode(c *gin.Context, status int, code string, err error) {
+	_ = c.Error(err)
+	c.JSON(status, Error{
+		Err:       err.Error(),
+		Code:      code,
+		RequestID: requestid.FromContext(c.Request.Context()),
+	})
+}
+
+// errorCode returns the error code, and the response status: the status
+// of the well-known error, if any, or the given one
+func errorCode(status int, err error) (string, int) {
+	cause := errors.Cause(err)
+	for _, e := range errorCodes {
+		if cause == e.err {
+			if e.status != 0 {
+				status = e.status
+			}
+			return e.code, status
+		}
+	}
+
+	switch cause := cause.(type) {
+	case validation.Errors:
+		// the filter operator is validated as the "type" field
+		if _, ok := cause["type"]; ok {
+			return ErrCodeQueryInvalidOp, status
+		}
+		return ErrCodeQueryInvalid, status
+	case net.Error:
+		return ErrCodeStoreUnavailable, status
+	}
+
+	if code, ok := statusErrorCodes[status]; ok {
+		return code, status
+	}
+	return ErrCodeInternal, status
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+func TestErrorCode(t *testing.T) {
+	testCases := map[string]struct {
+		status int
+		err    error
+		code   string
+		// the response status, if not the given one
+		resStatus int
+	}{
+		"invalid operator": {
+			status: http.StatusBadRequest,
+			err: errors.Wrap(model.FilterPredicate{
+				Scope:     "inventory",
+				Attribute: "foo",
+				Type:      "$like",
+				Value:     "bar",
+			}.Validate(), "malformed request body"),
+			code: ErrCodeQueryInvalidOp,
+		},
+		"invalid query": {
+			status: http.StatusBadRequest,
+			err:    validation.Errors{"scope": errors.New("cannot be blank")},
+			code:   ErrCodeQueryInvalid,
+		},
+		"invalid value": {
+			status:    http.StatusInternalServerError,
+			err:       errors.Wrap(model.ErrStrRequired, "failed to build query"),
+			code:      ErrCodeQueryInvalidValue,
+			resStatus: http.StatusBadRequest,
+		},
+		"invalid cursor": {
+			status:    http.StatusInternalServerError,
+			err:       errors.Wrap(model.ErrInvalidCursor, "failed to build query"),
+			code:      ErrCodeQueryInvalidCursor,
+			resStatus: http.StatusBadRequest,
+		},
+		"searches overload": {
+			status:    http.StatusInternalServerError,
+			err:       errors.Wrap(reporting.ErrSearchQueueFull, "failed to count devices"),
+			code:      ErrCodeStoreUnavailable,
+			resStatus: http.StatusServiceUnavailable,
+		},
+		"fallback to status": {
+			status: http.StatusUnauthorized,
+			err:    errors.New("tenant claim not present in JWT"),
+			code:   ErrCodeUnauthorized,
+		},
+		"internal": {
+			status: http.StatusTeapot,
+			err:    errors.New("foo"),
+			code:   ErrCodeInternal,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			code, status := errorCode(tc.status, tc.err)
+			assert.Equal(t, tc.code, code)
+			if tc.resStatus != 0 {
+				assert.Equal(t, tc.resStatus, status)
+			} else {
+				assert.Equal(t, tc.status, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// featureMiddleware rejects the requests of the tenants
+// the feature isn't enabled for
+func featureMiddleware(r reporting.App, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var tid string
+		if id := identity.FromContext(ctx); id != nil {
+			tid = id.Tenant
+		}
+
+		enabled, err := r.FeatureEnabled(ctx, tid, name)
+		if err != nil {
+			renderError(c, http.StatusInternalServerError, err)
+			c.Abort()
+			return
+		} else if !enabled {
+			renderError(c,
+				http.StatusForbidden,
+				errors.Wrap(reporting.ErrFeatureDisabled, name),
+			)
+			c.Abort()
+			return
+		}
+	}
+}
+
+// GetFeatures returns the tenant's feature flags, the defaults included
+func (ic *InternalController) GetFeatures(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+
+	res, err := ic.reporting.GetTenantFeatures(ctx, tid)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// SetFeatures replaces the tenant's feature flags
+func (ic *InternalController) SetFeatures(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	var flags model.FeatureFlags
+	err := bindJSON(c, &flags)
+	if err == nil {
+		err = flags.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if err := ic.reporting.SetTenantFeatures(ctx, tid, flags); err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -15,17 +15,36 @@
 package http
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mendersoftware/go-lib-micro/identity"
-	"github.com/mendersoftware/go-lib-micro/rest.utils"
+	"github.com/mendersoftware/go-lib-micro/log"
 
 	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
 )
 
+const (
+	healthCheckTimeout = 5 * time.Second
+
+	healthStatusWarning = "warning"
+
+	// hdrEventID identifies the event triggering a reindex,
+	// so that its redeliveries are skipped
+	hdrEventID = "X-Men-Event-ID"
+)
+
+// HealthWarnings reports a degraded, but available, service
+type HealthWarnings struct {
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings"`
+}
+
 // InternalController contains internal end-points
 type InternalController struct {
 	reporting reporting.App
@@ -43,35 +62,296 @@ func (h InternalController) Alive(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// Health responds to GET /health
+func (h InternalController) Health(c *gin.Context) {
+	ctx := c.Request.Context()
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	warnings, err := h.reporting.HealthCheck(ctx)
+	if err != nil {
+		renderError(c,
+			http.StatusServiceUnavailable,
+			err,
+		)
+		return
+	}
+	if len(warnings) > 0 {
+		c.JSON(http.StatusOK, HealthWarnings{
+			Status:   healthStatusWarning,
+			Warnings: warnings,
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (mc *InternalController) Search(c *gin.Context) {
 	tid := c.Param("tenant_id")
 
 	ctx := c.Request.Context()
 
 	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+	// internal callers are trusted with the redacted attributes
+	ctx = reporting.WithRedactedAttributes(ctx)
 
 	params, err := parseSearchParams(c)
 
 	if err != nil {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusBadRequest,
 			errors.Wrap(err, "malformed request body"),
 		)
 		return
 	}
 
-	res, total, err := mc.reporting.InventorySearchDevices(ctx, params)
+	res, err := mc.reporting.InventorySearchDevices(ctx, params)
 	if err != nil {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusInternalServerError,
 			err,
 		)
 		return
 	}
 
-	pageLinkHdrs(c, params.Page, params.PerPage, total)
+	paginationHdrs(c, params, res)
+	if wantsMsgPack(c) {
+		renderMsgPack(c, toV1Devices(res.Devices))
+		return
+	}
+	renderDevicesV1(c, toV1Devices(res.Devices))
+}
+
+func (ic *InternalController) Aggregate(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+
+	params, err := parseAggregateParams(c)
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	res, err := ic.reporting.AggregateDevices(ctx, params)
+	if errors.Cause(err) == reporting.ErrAggregationNotNumeric {
+		renderError(c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// RawSearch executes an allow-listed raw ES query within a single tenant;
+// meant for debugging data issues
+func (ic *InternalController) RawSearch(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+
+	var query model.RawQuery
+	if err := bindJSON(c, &query); err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	if err := query.Validate(); err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	}
+
+	res, err := ic.reporting.RawSearch(ctx, tid, query)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// StorageUsage reports the per-tenant storage usage, for all the
+// tenants or the one in the 'tenant_id' query parameter
+func (ic *InternalController) StorageUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	res, err := ic.reporting.GetStorageUsage(ctx, c.Query("tenant_id"))
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// StatusResponse reports the capabilities of the service dependencies
+type StatusResponse struct {
+	Store       *model.Capabilities      `json:"store"`
+	Maintenance *model.MaintenanceReport `json:"maintenance,omitempty"`
+}
+
+// Status responds to GET /status; the maintenance report is
+// left out if it can't be fetched
+func (h InternalController) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	maintenance, err := h.reporting.GetMaintenanceReport(ctx)
+	if err != nil {
+		log.FromContext(ctx).Warnf("status: %s", err)
+	}
+	c.JSON(http.StatusOK, StatusResponse{
+		Store:       h.reporting.GetCapabilities(),
+		Maintenance: maintenance,
+	})
+}
+
+// TenantsStats reports the known tenants, with their device counts,
+// last index activity and mapping fields usage
+func (ic *InternalController) TenantsStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	res, err := ic.reporting.GetTenantsStats(ctx)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// QuotaAlerts lists the tenants approaching their quotas,
+// as of the last quota check
+func (ic *InternalController) QuotaAlerts(c *gin.Context) {
+	c.JSON(http.StatusOK, ic.reporting.GetQuotaAlerts())
+}
+
+// MappingDryRun reports how the attributes would be mapped in the
+// tenant's devices index, so that new attribute sets can be
+// validated before the devices start reporting them
+func (ic *InternalController) MappingDryRun(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+
+	var params model.MappingDryRunParams
+	err := bindJSON(c, &params)
+	if err == nil {
+		err = params.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	res, err := ic.reporting.DryRunMapping(ctx, tid, params.Attributes)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// MappingConflicts lists the tenant's attributes quarantined on
+// indexing, because of their values conflicting with the mapping
+func (ic *InternalController) MappingConflicts(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+
+	res, err := ic.reporting.GetMappingConflicts(ctx, tid)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// MappingDiff compares the searchable attributes of two tenants
+func (ic *InternalController) MappingDiff(c *gin.Context) {
+	tid := c.Param("tenant_id")
+	other := c.Param("other_tenant_id")
+
+	ctx := c.Request.Context()
+
+	res, err := ic.reporting.DiffTenantsMappings(ctx, tid, other)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// MappingRefresh re-derives the tenant's attribute mapping state from
+// the devices index, optionally pruning the orphaned attribute metadata
+func (ic *InternalController) MappingRefresh(c *gin.Context) {
+	tid := c.Param("tenant_id")
+
+	ctx := c.Request.Context()
+	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+
+	prune, err := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "invalid prune parameter"),
+		)
+		return
+	}
+
+	res, err := ic.reporting.ReconcileMapping(ctx, tid, prune)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
 
-	c.Header(hdrTotalCount, strconv.Itoa(total))
 	c.JSON(http.StatusOK, res)
 }
 
@@ -83,6 +363,9 @@ func (ic *InternalController) Reindex(c *gin.Context) {
 
 	ctx := c.Request.Context()
 	ctx = identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+	if eventID := c.GetHeader(hdrEventID); eventID != "" {
+		ctx = reporting.WithEventID(ctx, eventID)
+	}
 
 	err := ic.reporting.Reindex(ctx, tid, did, service)
 
@@ -91,14 +374,14 @@ func (ic *InternalController) Reindex(c *gin.Context) {
 		c.Status(http.StatusAccepted)
 	case reporting.ErrUnknownService:
 		if err != nil {
-			rest.RenderError(c,
+			renderError(c,
 				http.StatusBadRequest,
 				err,
 			)
 			return
 		}
 	default:
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusInternalServerError,
 			err,
 		)
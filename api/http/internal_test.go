@@ -15,11 +15,17 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
 )
 
 func TestStatus(t *testing.T) {
@@ -31,3 +37,44 @@ func TestStatus(t *testing.T) {
 
 	assert.Equal(t, http.StatusNoContent, w.Code)
 }
+
+// queryApp builds the search queries, failing as the app does on the
+// filter values rejected only while building
+type queryApp struct {
+	reporting.App
+}
+
+func (queryApp) InventorySearchDevices(ctx context.Context,
+	params *model.SearchParams) (*model.SearchResult, error) {
+	if _, err := model.BuildQuery(*params, model.Settings{}); err != nil {
+		return nil, err
+	}
+	return &model.SearchResult{Devices: []model.InvDevice{}}, nil
+}
+
+func TestSearchInvalidValue(t *testing.T) {
+	uri := URIInternal + "/" + strings.Replace(URIInventorySearchInternal, ":tenant_id", "foo", 1)
+
+	testCases := map[string]string{
+		"$size":   `{"scope": "inventory", "attribute": "mac", "type": "$size", "value": "foo"}`,
+		"$fuzzy":  `{"scope": "inventory", "attribute": "mac", "type": "$fuzzy", "value": 1}`,
+		"$on_day": `{"scope": "system", "attribute": "created_ts", "type": "$on_day", "value": "foo"}`,
+	}
+
+	for name, filter := range testCases {
+		t.Run(name, func(t *testing.T) {
+			router := NewRouter(queryApp{})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, uri,
+				strings.NewReader(`{"filters": [`+filter+`]}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var res Error
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, ErrCodeQueryInvalidValue, res.Code)
+		})
+	}
+}
@@ -20,10 +20,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/sirupsen/logrus"
+
+	"github.com/mendersoftware/reporting/model"
 )
 
-const typeHTTP = "http"
+const (
+	typeHTTP = "http"
+
+	// the search params fingerprint, logged to group
+	// the requests by query shape
+	ctxKeyQueryFingerprint = "reporting.query_fingerprint"
+)
 
 func routerLogger(logger logrus.FieldLogger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -52,6 +61,16 @@ func routerLogger(logger logrus.FieldLogger) gin.HandlerFunc {
 			"method":       method,
 			"path":         path,
 		})
+		if fingerprint := c.GetString(ctxKeyQueryFingerprint); fingerprint != "" {
+			entry = entry.WithField("query_fingerprint", fingerprint)
+		}
+		if id, verified := requestIdentity(c); id != nil {
+			entry = entry.WithFields(logrus.Fields{
+				"tenant_id":         id.Tenant,
+				"subject":           id.Subject,
+				"identity_verified": verified,
+			})
+		}
 
 		if len(c.Errors) > 0 {
 			entry.Error(c.Errors.ByType(gin.ErrorTypePrivate).String())
@@ -67,3 +86,23 @@ func routerLogger(logger logrus.FieldLogger) gin.HandlerFunc {
 		}
 	}
 }
+
+// requestIdentity returns the identity of the request, for the access
+// log, and if it's verified: the one set by the authentication
+// middleware, if any, otherwise the claims of the Authorization JWT,
+// not verified, as the requests failing the authentication, or not
+// requiring one, are logged too
+func requestIdentity(c *gin.Context) (*identity.Identity, bool) {
+	if id := identity.FromContext(c.Request.Context()); id != nil {
+		return id, true
+	}
+	token, err := identity.ExtractJWTFromHeader(c.Request)
+	if err != nil || model.IsAPIKey(token) {
+		return nil, false
+	}
+	id, err := identity.ExtractIdentity(token)
+	if err != nil {
+		return nil, false
+	}
+	return &id, false
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterLoggerIdentity(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	logger.Formatter = &logrus.JSONFormatter{}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(routerLogger(logger))
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusUnauthorized)
+	})
+
+	claims := base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"sub": "user", "mender.tenant": "tenant"}`))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer header."+claims+".signature")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "tenant", entry["tenant_id"])
+	assert.Equal(t, "user", entry["subject"])
+	assert.Equal(t, false, entry["identity_verified"])
+
+	// no identity without a JWT
+	out.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	entry = nil
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.NotContains(t, entry, "tenant_id")
+}
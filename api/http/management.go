@@ -23,7 +23,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mendersoftware/go-lib-micro/identity"
-	"github.com/mendersoftware/go-lib-micro/rest.utils"
 
 	"github.com/mendersoftware/reporting/app/reporting"
 	"github.com/mendersoftware/reporting/model"
@@ -31,6 +30,16 @@ import (
 
 const (
 	hdrTotalCount = "X-Total-Count"
+	hdrNextCursor = "X-Next-Cursor"
+	hdrGroupCount = "X-Group-Count"
+
+	paramPeriod       = "period"
+	defaultPeriodDays = 7
+	maxPeriodDays     = 365
+)
+
+var (
+	errScriptFiltersInternal = errors.New("script filters are only available in the internal API")
 )
 
 type ManagementController struct {
@@ -45,9 +54,12 @@ func NewManagementController(r reporting.App) *ManagementController {
 
 func (mc *ManagementController) Search(c *gin.Context) {
 	params, err := parseSearchParams(c)
+	if err == nil && len(params.ScriptFilters) > 0 {
+		err = errScriptFiltersInternal
+	}
 
 	if err != nil {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusBadRequest,
 			errors.Wrap(err, "malformed request body"),
 		)
@@ -58,50 +70,182 @@ func (mc *ManagementController) Search(c *gin.Context) {
 
 	id := identity.FromContext(ctx)
 	if id == nil || id.Tenant == "" {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusUnauthorized,
 			errors.New("tenant claim not present in JWT"),
 		)
 		return
 	}
 
-	res, total, err := mc.reporting.InventorySearchDevices(ctx, params)
+	res, err := mc.reporting.InventorySearchDevices(ctx, params)
 	if err != nil {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusInternalServerError,
 			err,
 		)
 		return
 	}
 
-	pageLinkHdrs(c, params.Page, params.PerPage, total)
-
-	c.Header(hdrTotalCount, strconv.Itoa(total))
-	c.JSON(http.StatusOK, res)
+	paginationHdrs(c, params, res)
+	renderDevicesV1(c, toV1Devices(res.Devices))
 }
 
 func parseSearchParams(c *gin.Context) (*model.SearchParams, error) {
 	var searchParams model.SearchParams
 
-	err := c.ShouldBindJSON(&searchParams)
+	err := bindJSON(c, &searchParams)
 	if err != nil {
 		return nil, err
 	}
 
+	setSearchParamsDefaults(&searchParams)
+
+	if err := searchParams.Validate(); err != nil {
+		return nil, err
+	}
+	c.Set(ctxKeyQueryFingerprint, searchParams.Fingerprint())
+
+	return &searchParams, nil
+}
+
+func setSearchParamsDefaults(searchParams *model.SearchParams) {
 	if searchParams.Page < 1 {
 		searchParams.Page = 1
 	}
 	if searchParams.PerPage < 1 {
 		searchParams.PerPage = 20
 	}
+}
 
-	if err := searchParams.Validate(); err != nil {
+func (mc *ManagementController) SearchBatch(c *gin.Context) {
+	params, err := parseBatchSearchParams(c)
+	for i := 0; err == nil && i < len(params); i++ {
+		if len(params[i].ScriptFilters) > 0 {
+			err = errScriptFiltersInternal
+		}
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.InventorySearchDevicesBatch(ctx, params)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, toV1BatchResults(res))
+}
+
+func parseBatchSearchParams(c *gin.Context) (model.BatchSearchParams, error) {
+	var params model.BatchSearchParams
+
+	err := bindJSON(c, &params)
+	if err != nil {
 		return nil, err
 	}
 
-	return &searchParams, nil
+	for i := range params {
+		setSearchParamsDefaults(&params[i])
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+func (mc *ManagementController) Aggregate(c *gin.Context) {
+	params, err := parseAggregateParams(c)
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.AggregateDevices(ctx, params)
+	if errors.Cause(err) == reporting.ErrAggregationNotNumeric {
+		renderError(c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
 }
 
+func parseAggregateParams(c *gin.Context) (*model.AggregateParams, error) {
+	var aggregateParams model.AggregateParams
+
+	err := bindJSON(c, &aggregateParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := aggregateParams.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &aggregateParams, nil
+}
+
+// paginationHdrs sets the standard pagination headers: the total count,
+// the next page cursor, and the page links, unless paging by cursor
+// or sampling; and the group count, if requested
+func paginationHdrs(c *gin.Context, params *model.SearchParams, res *model.SearchResult) {
+	if params.Cursor == "" && params.Sample == 0 {
+		pageLinkHdrs(c, params.Page, params.PerPage, res.TotalCount)
+	}
+	c.Header(hdrTotalCount, strconv.Itoa(res.TotalCount))
+	if res.NextCursor != "" {
+		c.Header(hdrNextCursor, res.NextCursor)
+	}
+	if res.GroupCount != nil {
+		c.Header(hdrGroupCount, strconv.Itoa(*res.GroupCount))
+	}
+}
+
+// pageLinkHdrs sets the RFC 5988 Link header, with the first, prev
+// (also as "previous", for compatibility), next and last page links
 func pageLinkHdrs(c *gin.Context, page, perPage, total int) {
 	url := &url.URL{
 		Path:     c.Request.URL.Path,
@@ -119,15 +263,26 @@ func pageLinkHdrs(c *gin.Context, page, perPage, total int) {
 	if page > 1 {
 		query.Set("page", fmt.Sprintf("%d", page-1))
 		url.RawQuery = query.Encode()
-		Link = fmt.Sprintf(`%s, <%s>;rel="previous"`, Link, url.String())
+		Link = fmt.Sprintf(`%s, <%s>;rel="prev", <%s>;rel="previous"`,
+			Link, url.String(), url.String())
 	}
 
 	// Next page
-	if total > (perPage*page - 1) {
+	if total > perPage*page {
 		query.Set("page", fmt.Sprintf("%d", page+1))
 		url.RawQuery = query.Encode()
 		Link = fmt.Sprintf(`%s, <%s>;rel="next"`, Link, url.String())
+	}
 
+	// Last page
+	if perPage > 0 {
+		last := (total + perPage - 1) / perPage
+		if last < 1 {
+			last = 1
+		}
+		query.Set("page", fmt.Sprintf("%d", last))
+		url.RawQuery = query.Encode()
+		Link = fmt.Sprintf(`%s, <%s>;rel="last"`, Link, url.String())
 	}
 	c.Header("Link", Link)
 }
@@ -137,7 +292,7 @@ func (mc *ManagementController) SearchAttrs(c *gin.Context) {
 
 	id := identity.FromContext(ctx)
 	if id == nil || id.Tenant == "" {
-		rest.RenderError(c,
+		renderError(c,
 			http.StatusUnauthorized,
 			errors.New("tenant claim not present in JWT"),
 		)
@@ -146,7 +301,55 @@ func (mc *ManagementController) SearchAttrs(c *gin.Context) {
 
 	res, err := mc.reporting.GetSearchableInvAttrs(ctx, id.Tenant)
 	if err != nil {
-		rest.RenderError(c,
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	if tag := c.Query(paramTag); tag != "" {
+		tagged := []model.InvFilterAttr{}
+		for _, a := range res {
+			if a.Tag == tag {
+				tagged = append(tagged, a)
+			}
+		}
+		res = tagged
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (mc *ManagementController) ArtifactAdoption(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	period := defaultPeriodDays
+	if p := c.Query(paramPeriod); p != "" {
+		var err error
+		period, err = strconv.Atoi(p)
+		if err != nil || period < 1 || period > maxPeriodDays {
+			renderError(c,
+				http.StatusBadRequest,
+				errors.Errorf("invalid period, must be a number of days between 1 and %d",
+					maxPeriodDays),
+			)
+			return
+		}
+	}
+
+	res, err := mc.reporting.GetArtifactAdoption(ctx, period)
+	if err != nil {
+		renderError(c,
 			http.StatusInternalServerError,
 			err,
 		)
@@ -0,0 +1,109 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+func TestPageLinkHdrs(t *testing.T) {
+	testCases := map[string]struct {
+		page  int
+		total int
+		link  string
+	}{
+		"first page": {
+			page:  1,
+			total: 25,
+			link: `</search?page=1&per_page=10>;rel="first", ` +
+				`</search?page=2&per_page=10>;rel="next", ` +
+				`</search?page=3&per_page=10>;rel="last"`,
+		},
+		"middle page": {
+			page:  2,
+			total: 25,
+			link: `</search?page=1&per_page=10>;rel="first", ` +
+				`</search?page=1&per_page=10>;rel="prev", ` +
+				`</search?page=1&per_page=10>;rel="previous", ` +
+				`</search?page=3&per_page=10>;rel="next", ` +
+				`</search?page=3&per_page=10>;rel="last"`,
+		},
+		"full last page": {
+			page:  2,
+			total: 20,
+			link: `</search?page=1&per_page=10>;rel="first", ` +
+				`</search?page=1&per_page=10>;rel="prev", ` +
+				`</search?page=1&per_page=10>;rel="previous", ` +
+				`</search?page=2&per_page=10>;rel="last"`,
+		},
+		"no results": {
+			page:  1,
+			total: 0,
+			link: `</search?page=1&per_page=10>;rel="first", ` +
+				`</search?page=1&per_page=10>;rel="last"`,
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/search", nil)
+
+			pageLinkHdrs(c, tc.page, 10, tc.total)
+			assert.Equal(t, tc.link, w.Header().Get("Link"))
+		})
+	}
+}
+
+func TestToV1Devices(t *testing.T) {
+	devs := toV1Devices([]model.InvDevice{
+		{ID: "foo", Stale: true},
+		{ID: "bar"},
+	})
+
+	b, err := json.Marshal(devs)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"id": "foo", "updated_ts": "0001-01-01T00:00:00Z", "stale": true},
+		{"id": "bar", "updated_ts": "0001-01-01T00:00:00Z"}
+	]`, string(b))
+}
+
+func TestToV1BatchResults(t *testing.T) {
+	res := toV1BatchResults([]model.BatchSearchResult{
+		{Devices: []model.InvDevice{{ID: "foo", Stale: true}}, TotalCount: 1},
+		{Devices: []model.InvDevice{}, Error: "search failed"},
+	})
+
+	b, err := json.Marshal(res)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{
+			"devices": [{"id": "foo", "updated_ts": "0001-01-01T00:00:00Z", "stale": true}],
+			"total_count": 1
+		},
+		{"devices": [], "total_count": 0, "error": "search failed"}
+	]`, string(b))
+}
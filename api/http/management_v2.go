@@ -0,0 +1,257 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// SearchResponseV2 is the v2 search contract: the devices with their
+// typed fields, and the paging metadata in the body instead of headers
+type SearchResponseV2 struct {
+	Devices []model.InvDevice         `json:"devices"`
+	Facets  []model.DeviceAggregation `json:"facets,omitempty"`
+	Meta    SearchMetaV2              `json:"meta"`
+}
+
+// TableResponseV2 is the table mode search contract: the devices
+// as rows of the requested columns
+type TableResponseV2 struct {
+	Columns []model.TableHeader `json:"columns"`
+	Rows    []model.TableRow    `json:"rows"`
+	Meta    SearchMetaV2        `json:"meta"`
+}
+
+type SearchMetaV2 struct {
+	TotalCount int    `json:"total_count"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	GroupCount *int   `json:"group_count,omitempty"`
+}
+
+// invDeviceV1 is the v1 device representation, without the typed
+// fields introduced with the v2 contract
+type invDeviceV1 struct {
+	ID             model.DeviceID         `json:"id"`
+	Attributes     model.DeviceAttributes `json:"attributes,omitempty"`
+	UpdatedTs      time.Time              `json:"updated_ts"`
+	Score          *float64               `json:"score,omitempty"`
+	CollapsedCount *int                   `json:"collapsed_count,omitempty"`
+	MatchedFilters []string               `json:"matched_filters,omitempty"`
+	Stale          bool                   `json:"stale,omitempty"`
+}
+
+// toV1Devices adapts the devices to the v1 search contract
+func toV1Devices(devs []model.InvDevice) []invDeviceV1 {
+	ret := make([]invDeviceV1, len(devs))
+	for i, d := range devs {
+		ret[i] = invDeviceV1{
+			ID:             d.ID,
+			Attributes:     d.Attributes,
+			UpdatedTs:      d.UpdatedTs,
+			Score:          d.Score,
+			CollapsedCount: d.CollapsedCount,
+			MatchedFilters: d.MatchedFilters,
+			Stale:          d.Stale,
+		}
+	}
+	return ret
+}
+
+// batchSearchResultV1 is the v1 batch search result,
+// with the devices in the v1 representation
+type batchSearchResultV1 struct {
+	Devices    []invDeviceV1             `json:"devices"`
+	TotalCount int                       `json:"total_count"`
+	Facets     []model.DeviceAggregation `json:"facets,omitempty"`
+	GroupCount *int                      `json:"group_count,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+}
+
+// toV1BatchResults adapts the batch search results to the v1 contract
+func toV1BatchResults(res []model.BatchSearchResult) []batchSearchResultV1 {
+	ret := make([]batchSearchResultV1, len(res))
+	for i, r := range res {
+		ret[i] = batchSearchResultV1{
+			Devices:    toV1Devices(r.Devices),
+			TotalCount: r.TotalCount,
+			Facets:     r.Facets,
+			GroupCount: r.GroupCount,
+			Error:      r.Error,
+		}
+	}
+	return ret
+}
+
+// renderDevicesV1 renders the devices as a JSON array, or as NDJSON
+func renderDevicesV1(c *gin.Context, devs []invDeviceV1) {
+	if wantsNDJSON(c) {
+		renderNDJSON(c, len(devs), func(i int) interface{} {
+			return devs[i]
+		})
+		return
+	}
+	renderJSONWithETag(c, devs)
+}
+
+func (mc *ManagementController) SearchV2(c *gin.Context) {
+	params, err := parseSearchParams(c)
+	if err == nil && len(params.ScriptFilters) > 0 {
+		err = errScriptFiltersInternal
+	}
+
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	res, err := mc.reporting.InventorySearchDevices(ctx, params)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	meta := SearchMetaV2{
+		TotalCount: res.TotalCount,
+		PerPage:    params.PerPage,
+		NextCursor: res.NextCursor,
+		GroupCount: res.GroupCount,
+	}
+	if params.Cursor == "" {
+		meta.Page = params.Page
+	}
+
+	// NDJSON streams only the devices, the metadata goes to the headers
+	paginationHdrs(c, params, res)
+	if wantsNDJSON(c) {
+		renderNDJSON(c, len(res.Devices), func(i int) interface{} {
+			return res.Devices[i]
+		})
+		return
+	}
+
+	renderJSONWithETag(c, SearchResponseV2{
+		Devices: res.Devices,
+		Facets:  res.Facets,
+		Meta:    meta,
+	})
+}
+
+func parseTableParams(c *gin.Context) (*model.TableParams, error) {
+	var params model.TableParams
+
+	err := bindJSON(c, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	setSearchParamsDefaults(&params.SearchParams)
+
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if len(params.ScriptFilters) > 0 {
+		return nil, errScriptFiltersInternal
+	}
+	c.Set(ctxKeyQueryFingerprint, params.Fingerprint())
+
+	return &params, nil
+}
+
+// SearchTable is the table mode search: the devices are projected
+// into rows of the requested columns, e.g. for the UI tables
+func (mc *ManagementController) SearchTable(c *gin.Context) {
+	params, err := parseTableParams(c)
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	params.Selection()
+	res, err := mc.reporting.InventorySearchDevices(ctx, &params.SearchParams)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	headers, rows := params.BuildTable(res.Devices)
+
+	meta := SearchMetaV2{
+		TotalCount: res.TotalCount,
+		PerPage:    params.PerPage,
+		NextCursor: res.NextCursor,
+		GroupCount: res.GroupCount,
+	}
+	if params.Cursor == "" {
+		meta.Page = params.Page
+	}
+
+	// NDJSON streams only the rows, the metadata goes to the headers
+	paginationHdrs(c, &params.SearchParams, res)
+	if wantsNDJSON(c) {
+		renderNDJSON(c, len(rows), func(i int) interface{} {
+			return rows[i]
+		})
+		return
+	}
+
+	renderJSONWithETag(c, TableResponseV2{
+		Columns: headers,
+		Rows:    rows,
+		Meta:    meta,
+	})
+}
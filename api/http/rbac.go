@@ -0,0 +1,53 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+)
+
+// hdrRBACGroups lists the device groups the user is restricted to,
+// set by the API gateway for the group-restricted users
+const hdrRBACGroups = "X-MEN-RBAC-Inventory-Groups"
+
+// hdrRBACRedacted grants the user the real values of the redacted
+// attributes, set by the API gateway for the users with the permission
+const hdrRBACRedacted = "X-MEN-RBAC-Inventory-Redacted"
+
+// rbacMiddleware restricts the requests to the device groups
+// listed in the RBAC header, if any, and grants the real values
+// of the redacted attributes to the permitted users
+func rbacMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var groups []string
+		for _, g := range strings.Split(c.GetHeader(hdrRBACGroups), ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groups = append(groups, g)
+			}
+		}
+		if len(groups) > 0 {
+			ctx = reporting.WithAllowedGroups(ctx, groups)
+		}
+		if strings.EqualFold(c.GetHeader(hdrRBACRedacted), "true") {
+			ctx = reporting.WithRedactedValues(ctx)
+		}
+		c.Request = c.Request.WithContext(ctx)
+	}
+}
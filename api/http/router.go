@@ -18,10 +18,10 @@ import (
 	"context"
 
 	"github.com/gin-gonic/gin"
-	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
 
 	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
 )
 
 // API URL used by the HTTP router
@@ -29,15 +29,81 @@ const (
 	URIInternal   = "/api/internal/v1/reporting"
 	URIManagement = "/api/management/v1/reporting"
 
+	URIManagementV2 = "/api/management/v2/reporting"
+
 	URILiveliness              = "/alive"
+	URIHealth                  = "/health"
+	URIStatus                  = "/status"
 	URIInventorySearch         = "devices/search"
 	URIInventorySearchAttrs    = "devices/search/attributes"
+	URIInventorySearchBatch    = "devices/search/batch"
+	URIInventorySearchTable    = "devices/search/table"
+	URIReportAdoption          = "devices/reports/adoption"
+	URIInventoryAggregate      = "devices/aggregate"
+	URIInventoryCompare        = "devices/compare"
+	URIInventoryAnomalies      = "devices/anomalies"
+	URIInventoryChanges        = "devices/changes"
+	URIAPIKeys                 = "api_keys"
+	URIAPIKey                  = "api_keys/:id"
+	URIAttributeMetadata       = "devices/attributes/:scope/:name"
+	URIDeviceTags              = "devices/:device_id/tags"
+	URIDeviceTag               = "devices/:device_id/tags/:name"
 	URIInventorySearchInternal = "inventory/tenants/:tenant_id/search"
+	URIRawSearchInternal       = "inventory/tenants/:tenant_id/search/raw"
+	URIAggregateInternal       = "inventory/tenants/:tenant_id/aggregate"
+	URIMappingDryRunInternal   = "inventory/tenants/:tenant_id/mapping/dry_run"
+	URIMappingRefreshInternal  = "inventory/tenants/:tenant_id/mapping/refresh"
+	URIConflictsInternal       = "inventory/tenants/:tenant_id/mapping/conflicts"
+	URIMappingDiffInternal     = "inventory/tenants/:tenant_id/mapping/diff/:other_tenant_id"
 	URIReindexInternal         = "tenants/:tenant_id/devices/:device_id/reindex"
+	URIStorageUsageInternal    = "usage"
+	URITenantsInternal         = "tenants"
+	URIFeaturesInternal        = "tenants/:tenant_id/features"
+	URIQuotaAlertsInternal     = "quotas/alerts"
+	URITasksInternal           = "tasks"
+	URITaskInternal            = "tasks/:id"
 )
 
+// RouterOption configures the router
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	searchBodyLimit int64
+	batchBodyLimit  int64
+	strictJSON      bool
+}
+
+// WithBodyLimits sets the maximum request body size, in bytes, of the
+// search endpoints, and of the batch search one; the search limit applies
+// to the other JSON endpoints too; 0 disables the limit
+func WithBodyLimits(search, batch int64) RouterOption {
+	return func(conf *routerConfig) {
+		conf.searchBodyLimit = search
+		conf.batchBodyLimit = batch
+	}
+}
+
+// WithStrictJSON rejects the search request bodies with unknown fields
+func WithStrictJSON(strict bool) RouterOption {
+	return func(conf *routerConfig) {
+		conf.strictJSON = strict
+	}
+}
+
 // NewRouter returns the gin router
-func NewRouter(reporting reporting.App) *gin.Engine {
+func NewRouter(reporting reporting.App, opts ...RouterOption) *gin.Engine {
+	conf := &routerConfig{
+		searchBodyLimit: defaultSearchBodyLimit,
+		batchBodyLimit:  defaultBatchBodyLimit,
+	}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	searchBody := bodyMiddleware(conf.searchBodyLimit, conf.strictJSON)
+	batchBody := bodyMiddleware(conf.batchBodyLimit, conf.strictJSON)
+	// the strict parsing applies to the search request bodies only
+	jsonBody := bodyMiddleware(conf.searchBodyLimit, false)
+
 	gin.SetMode(gin.ReleaseMode)
 	gin.DisableConsoleColor()
 
@@ -51,14 +117,49 @@ func NewRouter(reporting reporting.App) *gin.Engine {
 	internal := NewInternalController(reporting)
 	internalAPI := router.Group(URIInternal)
 	internalAPI.GET(URILiveliness, internal.Alive)
-	internalAPI.POST(URIInventorySearchInternal, internal.Search)
+	internalAPI.GET(URIHealth, internal.Health)
+	internalAPI.GET(URIStatus, internal.Status)
+	internalAPI.POST(URIInventorySearchInternal, searchBody, gzipMiddleware(), internal.Search)
+	internalAPI.POST(URIRawSearchInternal, searchBody, gzipMiddleware(), internal.RawSearch)
+	internalAPI.POST(URIAggregateInternal, searchBody, internal.Aggregate)
+	internalAPI.POST(URIMappingDryRunInternal, jsonBody, internal.MappingDryRun)
+	internalAPI.POST(URIMappingRefreshInternal, internal.MappingRefresh)
+	internalAPI.GET(URIConflictsInternal, internal.MappingConflicts)
+	internalAPI.GET(URIMappingDiffInternal, internal.MappingDiff)
 	internalAPI.POST(URIReindexInternal, internal.Reindex)
+	internalAPI.GET(URIStorageUsageInternal, internal.StorageUsage)
+	internalAPI.GET(URITenantsInternal, internal.TenantsStats)
+	internalAPI.GET(URIFeaturesInternal, internal.GetFeatures)
+	internalAPI.PUT(URIFeaturesInternal, jsonBody, internal.SetFeatures)
+	internalAPI.GET(URIQuotaAlertsInternal, internal.QuotaAlerts)
+	internalAPI.POST(URITasksInternal, jsonBody, internal.StartTask)
+	internalAPI.GET(URITaskInternal, internal.GetTask)
+	internalAPI.DELETE(URITaskInternal, internal.CancelTask)
 
 	mgmt := NewManagementController(reporting)
 	mgmtAPI := router.Group(URIManagement)
-	mgmtAPI.Use(identity.Middleware())
-	mgmtAPI.POST(URIInventorySearch, mgmt.Search)
+	mgmtAPI.Use(authMiddleware(reporting), rbacMiddleware())
+	mgmtAPI.POST(URIInventorySearch, searchBody, gzipMiddleware(), mgmt.Search)
 	mgmtAPI.GET(URIInventorySearchAttrs, mgmt.SearchAttrs)
+	mgmtAPI.POST(URIInventorySearchBatch, batchBody, gzipMiddleware(), mgmt.SearchBatch)
+	mgmtAPI.GET(URIReportAdoption, mgmt.ArtifactAdoption)
+	mgmtAPI.POST(URIInventoryAggregate, searchBody, mgmt.Aggregate)
+	mgmtAPI.POST(URIInventoryCompare, jsonBody, mgmt.CompareDevices)
+	mgmtAPI.GET(URIInventoryAnomalies, mgmt.GetAnomalies)
+	mgmtAPI.GET(URIInventoryChanges, gzipMiddleware(), mgmt.HarvestChanges)
+	mgmtAPI.POST(URIAPIKeys, jsonBody, mgmt.CreateAPIKey)
+	mgmtAPI.GET(URIAPIKeys, mgmt.GetAPIKeys)
+	mgmtAPI.DELETE(URIAPIKey, mgmt.DeleteAPIKey)
+	mgmtAPI.PUT(URIAttributeMetadata, jsonBody, mgmt.SetAttributeMetadata)
+	mgmtAPI.DELETE(URIAttributeMetadata, mgmt.DeleteAttributeMetadata)
+	mgmtAPI.PUT(URIDeviceTags, jsonBody, mgmt.SetDeviceTags)
+	mgmtAPI.DELETE(URIDeviceTag, mgmt.DeleteDeviceTag)
+
+	mgmtAPIV2 := router.Group(URIManagementV2)
+	mgmtAPIV2.Use(authMiddleware(reporting), rbacMiddleware())
+	mgmtAPIV2.POST(URIInventorySearch, searchBody, gzipMiddleware(), mgmt.SearchV2)
+	mgmtAPIV2.POST(URIInventorySearchTable, searchBody,
+		featureMiddleware(reporting, model.FeatureTableSearch), gzipMiddleware(), mgmt.SearchTable)
 
 	return router
 }
@@ -0,0 +1,111 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+const (
+	paramDeviceID = "device_id"
+	paramTagName  = "name"
+)
+
+// SetDeviceTags adds or replaces the device tags
+func (mc *ManagementController) SetDeviceTags(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	var tags model.DeviceTags
+	err := bindJSON(c, &tags)
+	if err == nil {
+		err = tags.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	err = mc.reporting.SetDeviceTags(ctx, id.Tenant, c.Param(paramDeviceID), tags)
+	renderTagsResult(c, err)
+}
+
+// DeleteDeviceTag removes a device tag
+func (mc *ManagementController) DeleteDeviceTag(c *gin.Context) {
+	if !readOnly(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	id := identity.FromContext(ctx)
+	if id == nil || id.Tenant == "" {
+		renderError(c,
+			http.StatusUnauthorized,
+			errors.New("tenant claim not present in JWT"),
+		)
+		return
+	}
+
+	err := mc.reporting.RemoveDeviceTags(ctx, id.Tenant, c.Param(paramDeviceID),
+		[]string{c.Param(paramTagName)})
+	renderTagsResult(c, err)
+}
+
+func renderTagsResult(c *gin.Context, err error) {
+	if err == reporting.ErrDeviceNotFound {
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	} else if errors.Cause(err) == reporting.ErrTooManyTagNames {
+		renderError(c,
+			http.StatusBadRequest,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
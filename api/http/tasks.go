@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/model"
+)
+
+const paramTaskID = "id"
+
+// StartTask starts a long-running admin task, tracked by GetTask
+func (ic *InternalController) StartTask(c *gin.Context) {
+	var req model.TaskRequest
+	err := bindJSON(c, &req)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		renderError(c,
+			http.StatusBadRequest,
+			errors.Wrap(err, "malformed request body"),
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	task, err := ic.reporting.StartTask(ctx, req)
+	if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
+
+// GetTask returns the status and the progress of an admin task
+func (ic *InternalController) GetTask(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	task, err := ic.reporting.GetTask(ctx, c.Param(paramTaskID))
+	if err == reporting.ErrTaskNotFound {
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+		return
+	} else if err != nil {
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// CancelTask requests the cancellation of an admin task
+func (ic *InternalController) CancelTask(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	task, err := ic.reporting.CancelTask(ctx, c.Param(paramTaskID))
+	switch err {
+	case nil:
+		c.JSON(http.StatusAccepted, task)
+	case reporting.ErrTaskNotFound:
+		renderError(c,
+			http.StatusNotFound,
+			err,
+		)
+	case reporting.ErrTaskFinished:
+		renderError(c,
+			http.StatusConflict,
+			err,
+		)
+	default:
+		renderError(c,
+			http.StatusInternalServerError,
+			err,
+		)
+	}
+}
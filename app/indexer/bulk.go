@@ -0,0 +1,85 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package indexer
+
+import (
+	"time"
+)
+
+// initialBulkSize is the number of devices of the first bulk request,
+// within the configured bounds
+const initialBulkSize = 200
+
+// maxBulkErrorRate is the share of failed devices above which
+// a bulk request counts as failed
+const maxBulkErrorRate = 0.01
+
+// bulkSizer adapts the number of devices per bulk request to the
+// measured latency and errors, within the bounds: the size grows by
+// a quarter while the requests are well under the target latency and
+// error free, shrinks in proportion when they are over the target,
+// and is halved on failures
+type bulkSizer struct {
+	min    int
+	max    int
+	target time.Duration
+
+	size int
+}
+
+func newBulkSizer(min, max int, target time.Duration) *bulkSizer {
+	size := initialBulkSize
+	if size < min {
+		size = min
+	} else if size > max {
+		size = max
+	}
+	return &bulkSizer{
+		min:    min,
+		max:    max,
+		target: target,
+		size:   size,
+	}
+}
+
+// Size returns the current number of devices per bulk request
+func (b *bulkSizer) Size() int {
+	return b.size
+}
+
+// Observe adjusts the size after a bulk request of 'total' devices,
+// 'failed' of which failed, all of them if the request failed;
+// it returns whether the size changed
+func (b *bulkSizer) Observe(latency time.Duration, failed, total int) bool {
+	size := b.size
+	switch {
+	case total > 0 && float64(failed)/float64(total) > maxBulkErrorRate:
+		size /= 2
+	case latency > b.target:
+		size = int(float64(size) * float64(b.target) / float64(latency))
+	case latency < b.target/2 && total >= b.size:
+		// only full requests tell whether a bigger one would fit
+		size += size/4 + 1
+	}
+
+	if size < b.min {
+		size = b.min
+	} else if size > b.max {
+		size = b.max
+	}
+	changed := size != b.size
+	b.size = size
+	return changed
+}
@@ -16,25 +16,55 @@ package indexer
 
 import (
 	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 
 	"github.com/mendersoftware/go-lib-micro/config"
+	"github.com/mendersoftware/go-lib-micro/log"
+	dconfig "github.com/mendersoftware/reporting/config"
 	"github.com/mendersoftware/reporting/model"
 	"github.com/mendersoftware/reporting/store"
 )
 
-const batchSize = 200
+// maxBulkRetries is the number of times a failed bulk request
+// is retried, with a smaller size, before giving up
+const maxBulkRetries = 3
 
 // InitAndRun initializes the indexer and runs it
 func InitAndRun(conf config.Reader, store store.Store, devices int64, tid string) error {
 	ctx := context.Background()
 
-	devicesToIndex := make([]*model.Device, 0, batchSize)
+	// on SIGINT/SIGTERM stop generating devices, but
+	// still index the pending batch before exiting
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, unix.SIGINT, unix.SIGTERM)
+	defer signal.Stop(quit)
+
+	sizer := newBulkSizer(
+		conf.GetInt(dconfig.SettingIndexerBulkMinSize),
+		conf.GetInt(dconfig.SettingIndexerBulkMaxSize),
+		conf.GetDuration(dconfig.SettingIndexerBulkTargetLatency),
+	)
+	devicesToIndex := make([]*model.Device, 0, sizer.Size())
 
+loop:
 	for i := int64(1); i <= devices; i++ {
+		select {
+		case <-quit:
+			log.FromContext(ctx).Infof("interrupted after %d devices, "+
+				"flushing the pending batch", i-1)
+			break loop
+		default:
+		}
+
 		device := model.RandomDevice(tid)
 		devicesToIndex = append(devicesToIndex, device)
-		if len(devicesToIndex) == batchSize {
-			err := store.BulkIndexDevices(ctx, devicesToIndex)
+		if len(devicesToIndex) >= sizer.Size() {
+			err := bulkIndex(ctx, store, sizer, devicesToIndex)
 			if err != nil {
 				return err
 			}
@@ -42,10 +72,54 @@ func InitAndRun(conf config.Reader, store store.Store, devices int64, tid string
 		}
 	}
 	if len(devicesToIndex) > 0 {
-		err := store.BulkIndexDevices(ctx, devicesToIndex)
+		err := bulkIndex(ctx, store, sizer, devicesToIndex)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// bulkIndex indexes the devices in bulk requests of the current size,
+// adjusting it after each request; the failed requests are retried,
+// the devices failing individually are only logged
+func bulkIndex(ctx context.Context, s store.Store, sizer *bulkSizer, devices []*model.Device) error {
+	l := log.FromContext(ctx)
+
+	retries := 0
+	for len(devices) > 0 {
+		n := sizer.Size()
+		if n > len(devices) {
+			n = len(devices)
+		}
+
+		start := time.Now()
+		err := s.BulkIndexDevices(ctx, devices[:n])
+		latency := time.Since(start)
+
+		failed, retry := 0, false
+		var bulkErr *store.BulkIndexError
+		if errors.As(err, &bulkErr) {
+			l.Warnf("%s", bulkErr)
+			failed = bulkErr.Failed
+		} else if err != nil {
+			retries++
+			if retries > maxBulkRetries {
+				return err
+			}
+			l.Warnf("bulk request of %d devices failed, retrying: %s", n, err)
+			failed, retry = n, true
+		}
+
+		if sizer.Observe(latency, failed, n) {
+			l.Debugf("bulk request of %d devices took %s, bulk size now %d",
+				n, latency, sizer.Size())
+		}
+		if retry {
+			continue
+		}
+		retries = 0
+		devices = devices[n:]
+	}
+	return nil
+}
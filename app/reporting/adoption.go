@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+func (app *app) GetArtifactAdoption(ctx context.Context, periodDays int) (*model.AdoptionReport, error) {
+	now := time.Now().UTC()
+	period := time.Duration(periodDays) * day
+
+	restriction, err := app.authzQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []model.AdoptionBucket
+	var after map[string]interface{}
+	for {
+		query := model.BuildAdoptionQuery(now, period, after)
+		if restriction != nil {
+			query["query"] = restriction
+		}
+		esRes, err := app.store.Search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		var aggs model.AdoptionAggregation
+		if err := decodeAggregations(esRes, &aggs); err != nil {
+			return nil, err
+		}
+
+		buckets = append(buckets, aggs.Adoption.Buckets...)
+		if len(aggs.Adoption.Buckets) == 0 || aggs.Adoption.AfterKey == nil {
+			break
+		}
+		after = aggs.Adoption.AfterKey
+	}
+
+	return model.NewAdoptionReport(periodDays, buckets), nil
+}
+
+// decodeAggregations unpacks the 'aggregations' of an ES response into 'v'
+func decodeAggregations(storeRes model.M, v interface{}) error {
+	aggs, ok := storeRes["aggregations"]
+	if !ok {
+		return errors.New("can't process store aggregations")
+	}
+
+	b, err := json.Marshal(aggs)
+	if err != nil {
+		return errors.Wrap(err, "can't process store aggregations")
+	}
+
+	return errors.Wrap(json.Unmarshal(b, v), "can't process store aggregations")
+}
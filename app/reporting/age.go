@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+)
+
+// RefreshDevicesAge recomputes the device age attributes, so that
+// the age filters and aggregations don't need any date math
+func (app *app) RefreshDevicesAge(ctx context.Context) error {
+	start := time.Now()
+	updated, err := app.store.UpdateDevicesAge(ctx, start.UTC())
+	if err != nil {
+		return errors.Wrap(err, "failed to refresh the devices age")
+	}
+	log.FromContext(ctx).Infof("refreshed the age of %d devices in %s",
+		updated, time.Since(start))
+	return nil
+}
+
+// RunDeviceAgeJob refreshes the device age attributes, and decays the
+// failed deployments counts, every 'interval' until the context is
+// canceled; the job runs on all the instances, but only the one holding
+// it refreshes the devices, see ClaimJobRun
+func RunDeviceAgeJob(ctx context.Context, app App, interval time.Duration) {
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := app.ClaimJobRun(ctx, jobDeviceAge,
+			time.Now().Truncate(interval), 2*interval)
+		if err != nil {
+			l.Error(err)
+		} else if claimed {
+			if err := app.RefreshDevicesAge(ctx); err != nil {
+				l.Error(err)
+			}
+			if err := app.RefreshDeploymentsFailed(ctx); err != nil {
+				l.Error(err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
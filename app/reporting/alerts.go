@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/devicemonitor"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WithMonitorAlerts enables indexing the summary of the device open
+// alerts, fetched from devicemonitor, as system attributes
+func WithMonitorAlerts(client devicemonitor.Client) AppOption {
+	return func(a *app) {
+		a.monitorClient = client
+	}
+}
+
+// addAlertsAttributes sets the open alerts count and the highest
+// alert severity as the device system attributes
+func (app *app) addAlertsAttributes(ctx context.Context, tid string, dev *model.InvDevice) error {
+	if app.monitorClient == nil {
+		return nil
+	}
+
+	summary, err := app.monitorClient.GetAlertsSummary(ctx, tid, string(dev.ID))
+	if err != nil {
+		return errors.Wrap(err, "failed to get the device alerts")
+	}
+
+	attrs := []model.InvDeviceAttribute{
+		{
+			Name:  model.AttrNameAlertsCount,
+			Scope: model.AttrScopeSystem,
+			Value: float64(summary.Count),
+		},
+		{
+			Name:  model.AttrNameAlertsSeverity,
+			Scope: model.AttrScopeSystem,
+			Value: summary.Severity,
+		},
+	}
+	dev.Attributes.Upsert(attrs...)
+
+	return nil
+}
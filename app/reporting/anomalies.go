@@ -0,0 +1,150 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+var (
+	ErrAnomalyReportNotFound = store.ErrAnomalyReportNotFound
+	ErrAnomaliesRestricted   = errors.New(
+		"anomalies are tenant-wide, not available to callers restricted to device groups")
+)
+
+// WithAnomalyDetection enables the analysis of the attribute
+// distributions, see DetectAnomalies
+func WithAnomalyDetection(cfg model.AnomalyConfig) AppOption {
+	return func(a *app) {
+		a.anomalies = &cfg
+	}
+}
+
+// DetectAnomalies analyzes the attribute distributions of all the
+// tenants, and stores the findings; per-tenant failures are logged
+func (app *app) DetectAnomalies(ctx context.Context) error {
+	if app.anomalies == nil {
+		return nil
+	}
+	l := log.FromContext(ctx)
+
+	tenants, err := app.store.GetTenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list tenants")
+	}
+
+	start := time.Now()
+	found := 0
+	for _, tid := range tenants {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := app.detectTenantAnomalies(ctx, tid)
+		if err != nil {
+			l.Warnf("anomalies: tid %s: %s", tid, err)
+			continue
+		}
+		found += n
+	}
+	l.Infof("anomalies: analyzed %d tenants in %s, %d findings",
+		len(tenants), time.Since(start), found)
+
+	return nil
+}
+
+func (app *app) detectTenantAnomalies(ctx context.Context, tid string) (int, error) {
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+	query := model.BuildDistributionQuery(app.anomalies.Attributes)
+	esRes, err := app.store.Search(tenantCtx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	current, truncated, total, err := model.ParseDistributions(app.anomalies.Attributes, esRes)
+	if err != nil {
+		return 0, err
+	}
+	if len(truncated) > 0 {
+		log.FromContext(ctx).Warnf("anomalies: tid %s: skipped the drops of %v, "+
+			"more values than tracked", tid, truncated)
+	}
+
+	var previous map[string]map[string]int
+	prevReport, err := app.store.GetAnomalyReport(ctx, tid)
+	if err == nil {
+		previous = prevReport.Distributions
+	} else if err != store.ErrAnomalyReportNotFound {
+		return 0, err
+	}
+
+	findings := model.DetectAnomalies(*app.anomalies, current, previous, truncated, total)
+	report := &model.AnomalyReport{
+		TenantID:      tid,
+		CreatedAt:     time.Now().UTC(),
+		TotalCount:    total,
+		Findings:      findings,
+		Truncated:     truncated,
+		Distributions: current,
+	}
+	if err := app.store.PutAnomalyReport(ctx, report); err != nil {
+		return 0, err
+	}
+
+	return len(report.Findings), nil
+}
+
+// GetAnomalies returns the latest findings of tenant 'tid'
+func (app *app) GetAnomalies(ctx context.Context, tid string) (*model.AnomalyReport, error) {
+	restriction, err := app.authzQuery(ctx)
+	if err != nil {
+		return nil, err
+	} else if restriction != nil {
+		return nil, ErrAnomaliesRestricted
+	}
+
+	report, err := app.store.GetAnomalyReport(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	report.Distributions = nil
+
+	return report, nil
+}
+
+// RunAnomalyJob analyzes the attribute distributions every 'interval'
+func RunAnomalyJob(ctx context.Context, app App, interval time.Duration) {
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := app.DetectAnomalies(ctx); err != nil {
+			l.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
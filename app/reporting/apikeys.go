@@ -0,0 +1,78 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyNotFound = store.ErrAPIKeyNotFound
+)
+
+// CreateAPIKey generates and stores a new tenant 'tid' API key, expiring
+// at 'expiresAt' if not nil; the plain text key is returned only once, here
+func (app *app) CreateAPIKey(ctx context.Context, tid, name string,
+	expiresAt *time.Time) (*model.APIKey, string, error) {
+	apiKey, key, err := model.NewAPIKey(tid, name, expiresAt)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to generate API key")
+	}
+
+	if err := app.store.CreateAPIKey(ctx, apiKey); err != nil {
+		return nil, "", err
+	}
+
+	apiKey.Hash = ""
+	return apiKey, key, nil
+}
+
+func (app *app) GetAPIKeys(ctx context.Context, tid string) ([]model.APIKey, error) {
+	return app.store.GetAPIKeys(ctx, tid)
+}
+
+func (app *app) DeleteAPIKey(ctx context.Context, tid, id string) error {
+	return app.store.DeleteAPIKey(ctx, tid, id)
+}
+
+// AuthenticateAPIKey looks up the plain text API key, returns
+// ErrInvalidAPIKey if malformed, unknown (e.g. deleted) or expired
+func (app *app) AuthenticateAPIKey(ctx context.Context, key string) (*model.APIKey, error) {
+	if err := model.CheckAPIKeyFormat(key); err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := app.store.GetAPIKeyByHash(ctx, model.HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if err := apiKey.Verify(key, time.Now()); err != nil {
+		log.FromContext(ctx).Debugf("rejected API key %s: %s", apiKey.ID, err)
+		return nil, ErrInvalidAPIKey
+	}
+
+	return apiKey, nil
+}
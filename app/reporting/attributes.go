@@ -0,0 +1,159 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+var (
+	ErrAttributeMetadataNotFound = store.ErrAttributeMetadataNotFound
+	ErrAttributeAliasConflict    = errors.New("conflicting attribute alias")
+)
+
+// WithAliasesCache caches the tenant attribute aliases
+// resolved in the searches for 'ttl'
+func WithAliasesCache(ttl time.Duration) AppOption {
+	return func(a *app) {
+		a.aliases = newTenantCache(ttl)
+	}
+}
+
+// SetAttributeMetadata stores the attribute metadata; its aliases must
+// neither be aliases of other attributes nor shadow existing attributes
+func (app *app) SetAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error {
+	if err := app.checkAliases(ctx, meta); err != nil {
+		return err
+	}
+	err := app.store.PutAttributeMetadata(ctx, meta)
+	app.aliases.invalidate(meta.TenantID)
+	return err
+}
+
+func (app *app) DeleteAttributeMetadata(ctx context.Context, tid, scope, name string) error {
+	err := app.store.DeleteAttributeMetadata(ctx, &model.AttributeMetadata{
+		TenantID: tid,
+		Scope:    scope,
+		Name:     name,
+	})
+	app.aliases.invalidate(tid)
+	return err
+}
+
+// checkAliases rejects the metadata of an attribute which is an alias
+// of another attribute, or with aliases which are other attributes
+// or their aliases
+func (app *app) checkAliases(ctx context.Context, meta *model.AttributeMetadata) error {
+	metadata, err := app.store.GetAttributesMetadata(ctx, meta.TenantID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the attribute aliases")
+	}
+	self := meta.Scope + "/" + meta.Name
+	attrs := map[string]bool{}
+	others := make([]model.AttributeMetadata, 0, len(metadata))
+	for _, m := range metadata {
+		if m.Scope+"/"+m.Name != self {
+			others = append(others, m)
+			attrs[m.Scope+"/"+m.Name] = true
+		}
+	}
+	aliases := model.NewAttributeAliases(others)
+	if attr, ok := aliases[self]; ok {
+		return errors.Wrapf(ErrAttributeAliasConflict,
+			"%s is an alias of %s/%s", self, attr.Scope, attr.Attribute)
+	}
+	if len(meta.Aliases) == 0 {
+		return nil
+	}
+
+	index, err := app.store.GetDevIndex(ctx, meta.TenantID)
+	if err != nil {
+		return err
+	}
+	props, err := indexProperties(index)
+	if err != nil {
+		return err
+	}
+	for k := range props {
+		if s, n, err := model.MaybeParseAttr(k); err == nil && n != "" {
+			attrs[s+"/"+model.Redot(n)] = true
+		}
+	}
+
+	for _, alias := range meta.AliasKeys() {
+		if attr, ok := aliases[alias]; ok {
+			return errors.Wrapf(ErrAttributeAliasConflict,
+				"%s is already an alias of %s/%s", alias, attr.Scope, attr.Attribute)
+		}
+		if attrs[alias] {
+			return errors.Wrapf(ErrAttributeAliasConflict,
+				"%s is an attribute", alias)
+		}
+	}
+	return nil
+}
+
+// attributeAliases returns the attribute aliases of the tenant in the
+// context, as of the cached aliases, if cached
+func (app *app) attributeAliases(ctx context.Context) (model.AttributeAliases, error) {
+	tid := tenantID(ctx)
+	if tid == "" {
+		return nil, nil
+	}
+	if cached, ok := app.aliases.get(tid); ok {
+		return cached.(model.AttributeAliases), nil
+	}
+	metadata, err := app.store.GetAttributesMetadata(ctx, tid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the attribute aliases")
+	}
+	aliases := model.NewAttributeAliases(metadata)
+	app.aliases.put(tid, aliases)
+	return aliases, nil
+}
+
+// addAttributeMetadata annotates the devices attributes with
+// the tenant's attribute metadata
+func (app *app) addAttributeMetadata(ctx context.Context, tid string, devs []model.InvDevice) error {
+	metadata, err := app.store.GetAttributesMetadata(ctx, tid)
+	if err != nil || len(metadata) == 0 {
+		return err
+	}
+
+	metaMap := model.NewAttributeMetadataMap(metadata)
+	for i := range devs {
+		for j, a := range devs[i].Attributes {
+			meta, ok := metaMap.Get(a.Scope, a.Name)
+			if !ok {
+				continue
+			}
+			attr := &devs[i].Attributes[j]
+			attr.DisplayName = meta.DisplayName
+			attr.Tag = meta.Tag
+			if meta.Description != "" {
+				description := meta.Description
+				attr.Description = &description
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// Authorizer is invoked before the device searches and aggregations,
+// and returns the mandatory filters restricting the devices visible
+// to the caller, e.g. derived from the caller's claims
+type Authorizer interface {
+	SearchFilters(ctx context.Context) ([]model.FilterPredicate, error)
+}
+
+// WithAuthorizer restricts the searches with the authorizer's filters
+func WithAuthorizer(authz Authorizer) AppOption {
+	return func(a *app) {
+		a.authz = authz
+	}
+}
+
+type allowedGroupsKey struct{}
+
+// WithAllowedGroups restricts the caller to the devices in 'groups'
+func WithAllowedGroups(ctx context.Context, groups []string) context.Context {
+	return context.WithValue(ctx, allowedGroupsKey{}, groups)
+}
+
+// GroupsAuthorizer restricts the searches to the device groups
+// allowed in the context, if any
+type GroupsAuthorizer struct{}
+
+func (GroupsAuthorizer) SearchFilters(ctx context.Context) ([]model.FilterPredicate, error) {
+	groups, _ := ctx.Value(allowedGroupsKey{}).([]string)
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	values := make([]interface{}, len(groups))
+	for i, g := range groups {
+		values[i] = g
+	}
+	return []model.FilterPredicate{{
+		Scope:     model.AttrScopeSystem,
+		Attribute: model.AttrNameGroup,
+		Type:      "$in",
+		Value:     values,
+	}}, nil
+}
+
+// authzQuery returns the bool query restricting the devices
+// visible to the caller, nil if not restricted
+func (app *app) authzQuery(ctx context.Context) (model.M, error) {
+	if app.authz == nil {
+		return nil, nil
+	}
+
+	filters, err := app.authz.SearchFilters(ctx)
+	if err != nil || len(filters) == 0 {
+		return nil, err
+	}
+	return model.FiltersQuery(filters, app.settings)
+}
+
+// authorize adds the caller's mandatory filters to the query
+func (app *app) authorize(ctx context.Context, query model.Query) (model.Query, error) {
+	restriction, err := app.authzQuery(ctx)
+	if err != nil || restriction == nil {
+		return query, err
+	}
+	return query.Must(restriction), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"sync"
+	"time"
+)
+
+type tenantCacheEntry struct {
+	value interface{}
+	ts    time.Time
+}
+
+// tenantCache caches a value per tenant ID, for 'ttl';
+// the expired entries are evicted on the next put
+type tenantCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	tenants map[string]tenantCacheEntry
+	now     func() time.Time
+}
+
+func newTenantCache(ttl time.Duration) *tenantCache {
+	return &tenantCache{
+		ttl:     ttl,
+		tenants: map[string]tenantCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+func (c *tenantCache) get(tid string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.tenants[tid]
+	if !ok || c.now().Sub(e.ts) >= c.ttl {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *tenantCache) put(tid string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for t, e := range c.tenants {
+		if now.Sub(e.ts) >= c.ttl {
+			delete(c.tenants, t)
+		}
+	}
+	c.tenants[tid] = tenantCacheEntry{value: value, ts: now}
+}
+
+func (c *tenantCache) invalidate(tid string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.tenants, tid)
+	c.mu.Unlock()
+}
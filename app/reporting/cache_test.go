@@ -0,0 +1,51 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantCache(t *testing.T) {
+	now := time.Now()
+	c := newTenantCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("foo", 1)
+	c.put("bar", 2)
+	v, ok := c.get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.invalidate("foo")
+	_, ok = c.get("foo")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.get("bar")
+	assert.False(t, ok)
+	// evicted on the next put
+	c.put("foo", 3)
+	assert.Len(t, c.tenants, 1)
+
+	// disabled
+	var disabled *tenantCache
+	disabled.put("foo", 1)
+	_, ok = disabled.get("foo")
+	assert.False(t, ok)
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// HarvestChanges returns the devices changed since the checkpoint,
+// and the checkpoint to resume from; the checkpoint is unchanged
+// when there are no new changes
+func (app *app) HarvestChanges(ctx context.Context, params *model.ChangesParams) (*model.ChangesResult, error) {
+	query, err := model.BuildChangesQuery(*params)
+	if err != nil {
+		return nil, err
+	}
+	query, err = app.authorize(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	esRes, err := app.store.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	devs, _, err := app.storeToInventoryDevs(esRes, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.applyRedactions(ctx, devs); err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := nextCursor(esRes, 1)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == "" {
+		checkpoint = params.Checkpoint
+	}
+
+	return &model.ChangesResult{
+		Devices:    devs,
+		Checkpoint: checkpoint,
+	}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var ErrDevicesNotFound = errors.New("devices not found")
+
+// CompareDevices returns the side-by-side attribute view of the devices;
+// returns ErrDevicesNotFound if any of them isn't visible to the caller
+func (app *app) CompareDevices(ctx context.Context, params *model.CompareParams) (*model.DeviceComparison, error) {
+	query, err := app.buildSearchQuery(ctx, &model.SearchParams{
+		DeviceIDs: params.DeviceIDs,
+		Page:      1,
+		PerPage:   len(params.DeviceIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	esRes, err := app.store.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	devs, total, err := app.storeToInventoryDevs(esRes, false)
+	if err != nil {
+		return nil, err
+	}
+	if total != len(params.DeviceIDs) {
+		return nil, ErrDevicesNotFound
+	}
+	if err := app.applyRedactions(ctx, devs); err != nil {
+		return nil, err
+	}
+
+	return model.CompareDevices(params.DeviceIDs, devs, params.All), nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/deviceconfig"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WithDeviceConfiguration enables indexing the reported and desired
+// device configuration, fetched from deviceconfig, in the
+// "configuration" scope
+func WithDeviceConfiguration(client deviceconfig.Client) AppOption {
+	return func(a *app) {
+		a.configClient = client
+	}
+}
+
+// addConfigurationAttributes replaces the device configuration
+// attributes with the current deviceconfig state
+func (app *app) addConfigurationAttributes(ctx context.Context, tid string,
+	dev *model.InvDevice) error {
+	if app.configClient == nil {
+		return nil
+	}
+
+	conf, err := app.configClient.GetConfiguration(ctx, tid, string(dev.ID))
+	if err != nil {
+		return errors.Wrap(err, "failed to get the device configuration")
+	}
+
+	attrs := dev.Attributes[:0]
+	for _, a := range dev.Attributes {
+		if a.Scope != model.AttrScopeConfiguration {
+			attrs = append(attrs, a)
+		}
+	}
+	dev.Attributes = append(attrs,
+		model.ConfigurationAttributes(conf.Reported, conf.Desired)...)
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// the maximum number of attributes quarantined from a single write,
+// so that a broken mapping can't make a device write loop forever
+const maxQuarantinedAttributes = 10
+
+// writeQuarantined writes the device with 'write'; when ES rejects
+// an attribute value because of a field type conflict, the attribute
+// is quarantined - dropped from the device, and the conflict recorded -
+// and the rest of the device written again
+func (app *app) writeQuarantined(
+	ctx context.Context,
+	dev *model.Device,
+	write func(*model.Device) error,
+) error {
+	l := log.FromContext(ctx)
+
+	for i := 0; ; i++ {
+		err := write(dev)
+		conflict, ok := errors.Cause(err).(*store.MappingConflictError)
+		if !ok || i == maxQuarantinedAttributes {
+			return err
+		}
+
+		attr := dev.Quarantine(conflict.Field)
+		if attr == nil {
+			return err
+		}
+		l.Warnf("quarantined attribute %s/%s of device %s: %s",
+			attr.Scope, attr.Name, dev.GetID(), conflict.Reason)
+
+		record := &model.MappingConflict{
+			TenantID:  dev.GetTenantID(),
+			DeviceID:  dev.GetID(),
+			Field:     conflict.Field,
+			Scope:     attr.Scope,
+			Attribute: attr.Name,
+			Reason:    conflict.Reason,
+			Timestamp: time.Now().UTC(),
+		}
+		if err := app.store.PutMappingConflict(ctx, record); err != nil {
+			l.Errorf("failed to record the mapping conflict of field %s: %v",
+				conflict.Field, err)
+		}
+	}
+}
+
+// GetMappingConflicts returns the attributes of tenant 'tid'
+// quarantined because of the mapping conflicts, the latest first
+func (app *app) GetMappingConflicts(ctx context.Context, tid string) ([]model.MappingConflict, error) {
+	return app.store.GetMappingConflicts(ctx, tid)
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type eventIDKey struct{}
+
+// WithEventID tags the reindex with the ID of the event triggering it,
+// so that the redelivered events are indexed only once
+func WithEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, eventIDKey{}, id)
+}
+
+// WithEventDeduplication skips the reindex of the events already
+// indexed within 'ttl', remembering up to 'size' recent events
+func WithEventDeduplication(size int, ttl time.Duration) AppOption {
+	return func(a *app) {
+		a.events = newEventCache(size, ttl)
+	}
+}
+
+// eventKey returns the cache key of the event in the context,
+// or "" if the deduplication doesn't apply
+func (app *app) eventKey(ctx context.Context, tid string) string {
+	if app.events == nil {
+		return ""
+	}
+	id, _ := ctx.Value(eventIDKey{}).(string)
+	if id == "" {
+		return ""
+	}
+	return tid + ":" + id
+}
+
+type eventEntry struct {
+	slot int
+	ts   time.Time
+}
+
+// eventCache remembers the recent events in a ring of fixed size,
+// evicting the oldest ones first
+type eventCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]eventEntry
+	ring []string
+	next int
+	now  func() time.Time
+}
+
+func newEventCache(size int, ttl time.Duration) *eventCache {
+	return &eventCache{
+		ttl:  ttl,
+		seen: make(map[string]eventEntry, size),
+		ring: make([]string, size),
+		now:  time.Now,
+	}
+}
+
+// seenOrAdd tells if the event was seen within the TTL, remembering
+// it otherwise; checked and added at once, so that of the concurrent
+// deliveries of an event only one is indexed
+func (c *eventCache) seenOrAdd(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.seen[key]; ok && c.now().Sub(e.ts) < c.ttl {
+		return true
+	}
+	c.add(key)
+	return false
+}
+
+// forget drops the event, e.g. failed to index, so that
+// its redelivery is indexed
+func (c *eventCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.seen, key)
+}
+
+// add remembers the event, the lock held
+func (c *eventCache) add(key string) {
+	// the evicted slot may hold an event since re-added to another slot
+	if old := c.ring[c.next]; old != "" && c.seen[old].slot == c.next {
+		delete(c.seen, old)
+	}
+	c.ring[c.next] = key
+	c.seen[key] = eventEntry{slot: c.next, ts: c.now()}
+	c.next = (c.next + 1) % len(c.ring)
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventCache(t *testing.T) {
+	now := time.Now()
+	c := newEventCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	assert.False(t, c.seenOrAdd("a"))
+	assert.True(t, c.seenOrAdd("a"))
+	assert.False(t, c.seenOrAdd("b"))
+
+	// the oldest event is evicted
+	assert.False(t, c.seenOrAdd("c"))
+	assert.False(t, c.seenOrAdd("a"))
+	assert.True(t, c.seenOrAdd("c"))
+	assert.Len(t, c.seen, 2)
+
+	// forgotten
+	c.forget("c")
+	assert.False(t, c.seenOrAdd("c"))
+
+	// expired
+	now = now.Add(time.Minute)
+	assert.False(t, c.seenOrAdd("a"))
+	assert.True(t, c.seenOrAdd("a"))
+}
+
+func TestEventCacheConcurrent(t *testing.T) {
+	c := newEventCache(10, time.Minute)
+
+	var added int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !c.seenOrAdd("a") {
+				atomic.AddInt32(&added, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), added)
+}
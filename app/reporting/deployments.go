@@ -0,0 +1,96 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/deployments"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// the window of the failed deployments counter
+const deploymentsFailedWindow = 30 * day
+
+// WithDeploymentsSummary enables indexing the summary of the device
+// deployments, fetched from deployments, as system attributes
+func WithDeploymentsSummary(client deployments.Client) AppOption {
+	return func(a *app) {
+		a.deployClient = client
+	}
+}
+
+// addDeploymentsAttributes sets the number of the deployments failed
+// within the last 30 days and the last deployment status as the
+// device system attributes
+func (app *app) addDeploymentsAttributes(ctx context.Context, tid string,
+	dev *model.InvDevice) error {
+	if app.deployClient == nil {
+		return nil
+	}
+
+	since := time.Now().Add(-deploymentsFailedWindow)
+	summary, err := app.deployClient.GetDeploymentsSummary(ctx, tid, string(dev.ID), since)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the device deployments")
+	}
+
+	attrs := []model.InvDeviceAttribute{
+		{
+			Name:  model.AttrNameDeploymentsFailed,
+			Scope: model.AttrScopeSystem,
+			Value: float64(summary.FailedCount),
+		},
+	}
+	if len(summary.FailedAt) > 0 {
+		failedAt := make([]interface{}, len(summary.FailedAt))
+		for i, ts := range summary.FailedAt {
+			failedAt[i] = model.FormatDeploymentFailedTs(ts)
+		}
+		attrs = append(attrs, model.InvDeviceAttribute{
+			Name:  model.AttrNameDeploymentsFailedTs,
+			Scope: model.AttrScopeSystem,
+			Value: failedAt,
+		})
+	}
+	if summary.LastStatus != "" {
+		attrs = append(attrs, model.InvDeviceAttribute{
+			Name:  model.AttrNameLastDeploymentStatus,
+			Scope: model.AttrScopeSystem,
+			Value: summary.LastStatus,
+		})
+	}
+	dev.Attributes.Upsert(attrs...)
+
+	return nil
+}
+
+// RefreshDeploymentsFailed decays the failed deployments counts of the
+// devices not reindexed since their oldest failure left the window
+func (app *app) RefreshDeploymentsFailed(ctx context.Context) error {
+	start := time.Now()
+	updated, err := app.store.UpdateDevicesDeploymentsFailed(ctx,
+		start.UTC().Add(-deploymentsFailedWindow))
+	if err != nil {
+		return errors.Wrap(err, "failed to refresh the failed deployments")
+	}
+	log.FromContext(ctx).Infof("refreshed the failed deployments of %d devices in %s",
+		updated, time.Since(start))
+	return nil
+}
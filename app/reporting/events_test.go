@@ -0,0 +1,75 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// blockingPublisher delivers the events once released
+type blockingPublisher struct {
+	release   chan struct{}
+	published chan *model.DeviceChangeEvent
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, event *model.DeviceChangeEvent) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.published <- event
+	return nil
+}
+
+func TestPublishChangesAsync(t *testing.T) {
+	p := &blockingPublisher{
+		release:   make(chan struct{}),
+		published: make(chan *model.DeviceChangeEvent, 1),
+	}
+	app := NewApp(nil, nil, WithEventsPublisher(p)).(*app)
+
+	prev := model.NewDevice("dev1").SetGroupName("old")
+	next := model.NewDevice("dev1").SetGroupName("new")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.publishChanges(ctx, prev, next, time.Now())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publishing held the reindex")
+	}
+
+	// the delivery outlives the reindex context
+	cancel()
+	close(p.release)
+	select {
+	case event := <-p.published:
+		assert.Equal(t, model.SubjectDeviceGroupChanged, event.Subject)
+		assert.Equal(t, "dev1", event.DeviceID)
+	case <-time.After(time.Second):
+		t.Fatal("event not published")
+	}
+}
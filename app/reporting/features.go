@@ -0,0 +1,70 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var ErrFeatureDisabled = errors.New("the feature is not enabled for the tenant")
+
+// WithFeaturesCache caches the tenant feature flags checked by
+// FeatureEnabled for 'ttl'
+func WithFeaturesCache(ttl time.Duration) AppOption {
+	return func(a *app) {
+		a.features = newTenantCache(ttl)
+	}
+}
+
+// GetTenantFeatures returns all the feature flags of the tenant,
+// the defaults included
+func (app *app) GetTenantFeatures(ctx context.Context, tid string) (model.FeatureFlags, error) {
+	features, err := app.store.GetTenantFeatures(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	return features.Resolve(), nil
+}
+
+// SetTenantFeatures replaces the feature flags set for the tenant;
+// the flags not set fall back to the defaults
+func (app *app) SetTenantFeatures(ctx context.Context, tid string, flags model.FeatureFlags) error {
+	err := app.store.PutTenantFeatures(ctx, &model.TenantFeatures{
+		TenantID:  tid,
+		Flags:     flags,
+		UpdatedAt: time.Now().UTC(),
+	})
+	app.features.invalidate(tid)
+	return err
+}
+
+// FeatureEnabled tells if the feature is enabled for the tenant,
+// as of the cached feature flags, if cached
+func (app *app) FeatureEnabled(ctx context.Context, tid, name string) (bool, error) {
+	if cached, ok := app.features.get(tid); ok {
+		return cached.(*model.TenantFeatures).Enabled(name), nil
+	}
+	features, err := app.store.GetTenantFeatures(ctx, tid)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get the tenant features")
+	}
+	app.features.put(tid, features)
+	return features.Enabled(name), nil
+}
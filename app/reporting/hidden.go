@@ -0,0 +1,86 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// ParseHiddenAttributes validates the "scope/name" glob patterns of
+// the attributes stripped from the API responses, e.g. "system/*_id"
+func ParseHiddenAttributes(patterns []string) ([]string, error) {
+	for _, p := range patterns {
+		if strings.Count(p, "/") != 1 {
+			return nil, errors.Errorf("malformed hidden attribute pattern %q: "+
+				"expected \"scope/name\"", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.Wrapf(err, "malformed hidden attribute pattern %q", p)
+		}
+	}
+	return patterns, nil
+}
+
+// WithHiddenAttributes strips the attributes matching the "scope/name"
+// patterns from the search results and the attributes listing, and
+// rejects the searches and aggregations by them
+func WithHiddenAttributes(patterns []string) AppOption {
+	return func(a *app) {
+		a.hiddenAttrs = patterns
+	}
+}
+
+// WithAttributeValuesLimit caps the number of values returned
+// per multi-valued attribute; 0 means no limit
+func WithAttributeValuesLimit(limit int) AppOption {
+	return func(a *app) {
+		a.valuesLimit = limit
+	}
+}
+
+// attributeValuesLimit returns the lower of the requested
+// and the configured attribute values limits
+func (app *app) attributeValuesLimit(params *model.SearchParams) int {
+	limit := params.AttributeValuesLimit
+	if limit == 0 || (app.valuesLimit > 0 && app.valuesLimit < limit) {
+		limit = app.valuesLimit
+	}
+	return limit
+}
+
+// checkHidden rejects the queries by the hidden attributes, as the
+// matches, the sort order or the aggregations would leak the values
+func (app *app) checkHidden(attrs []model.SelectAttribute) error {
+	for _, a := range attrs {
+		if app.isHidden(a.Scope, a.Attribute) {
+			return errors.Wrapf(ErrAttributeHidden, "%s/%s", a.Scope, a.Attribute)
+		}
+	}
+	return nil
+}
+
+func (app *app) isHidden(scope, name string) bool {
+	for _, p := range app.hiddenAttrs {
+		if ok, _ := path.Match(p, scope+"/"+name); ok {
+			return true
+		}
+	}
+	return false
+}
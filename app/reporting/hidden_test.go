@@ -0,0 +1,127 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// hiddenStore maps the attributes of 'attrs', with the 'metadata'
+type hiddenStore struct {
+	store.Store
+	attrs    []string
+	metadata []model.AttributeMetadata
+}
+
+func (s *hiddenStore) GetDevIndex(ctx context.Context, tid string) (map[string]interface{}, error) {
+	props := map[string]interface{}{"id": map[string]interface{}{}}
+	for _, a := range s.attrs {
+		props[a] = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"mappings": map[string]interface{}{"properties": props},
+	}, nil
+}
+
+func (s *hiddenStore) GetAttributesMetadata(ctx context.Context,
+	tid string) ([]model.AttributeMetadata, error) {
+	return s.metadata, nil
+}
+
+// the tenant features aren't set, the defaults apply
+func (s *hiddenStore) GetTenantFeatures(ctx context.Context,
+	tid string) (*model.TenantFeatures, error) {
+	return nil, nil
+}
+
+func TestHiddenAttributesQueries(t *testing.T) {
+	s := &hiddenStore{}
+	app := NewApp(s, nil, WithHiddenAttributes([]string{"system/*_id"}))
+	ctx := context.Background()
+	hidden := model.SelectAttribute{Scope: "system", Attribute: "tenant_id"}
+
+	testCases := map[string]*model.SearchParams{
+		"filter": {Filters: []model.FilterPredicate{{
+			Scope: hidden.Scope, Attribute: hidden.Attribute, Type: "$eq", Value: "foo",
+		}}},
+		"exclude filter": {ExcludeFilters: []model.FilterPredicate{{
+			Scope: hidden.Scope, Attribute: hidden.Attribute, Type: "$exists", Value: true,
+		}}},
+		"sort": {Sort: []model.SortCriteria{{
+			Scope: hidden.Scope, Attribute: hidden.Attribute, Order: "asc",
+		}}},
+		"collapse":    {Collapse: &hidden},
+		"group count": {GroupCount: &hidden},
+		"facet": {Facets: []model.AggregationTerm{{
+			Name: "ids", Scope: "inventory", Attribute: "device_type", Limit: 10,
+			Aggregations: []model.AggregationTerm{{
+				Name: "nested", Scope: hidden.Scope, Attribute: hidden.Attribute, Limit: 10,
+			}},
+		}}},
+	}
+	for name, params := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params.Page, params.PerPage = 1, 10
+			_, err := app.InventorySearchDevices(ctx, params)
+			assert.Equal(t, ErrAttributeHidden, errors.Cause(err))
+			assert.EqualError(t, err, "system/tenant_id: the attribute is hidden")
+		})
+	}
+
+	_, err := app.AggregateDevices(ctx, &model.AggregateParams{
+		Aggregations: []model.AggregationTerm{{
+			Name: "ids", Scope: hidden.Scope, Attribute: hidden.Attribute, Limit: 10,
+		}},
+	})
+	assert.Equal(t, ErrAttributeHidden, errors.Cause(err))
+
+	// hidden through an alias
+	s.metadata = []model.AttributeMetadata{{
+		TenantID: "foo", Scope: hidden.Scope, Name: hidden.Attribute, Aliases: []string{"tid"},
+	}}
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: "foo"})
+	_, err = app.AggregateDevices(tenantCtx, &model.AggregateParams{
+		Filters: []model.FilterPredicate{{
+			Scope: hidden.Scope, Attribute: "tid", Type: "$eq", Value: "foo",
+		}},
+		Aggregations: []model.AggregationTerm{{
+			Name: "types", Scope: "inventory", Attribute: "device_type", Limit: 10,
+		}},
+	})
+	assert.Equal(t, ErrAttributeHidden, errors.Cause(err))
+}
+
+func TestHiddenAttributesListing(t *testing.T) {
+	s := &hiddenStore{attrs: []string{
+		model.ToAttr("system", "tenant_id", model.TypeStr),
+		model.ToAttr("system", "group_id", model.TypeStr),
+		model.ToAttr("inventory", "device_type", model.TypeStr),
+	}}
+	app := NewApp(s, nil, WithHiddenAttributes([]string{"system/*_id"}))
+
+	attrs, err := app.GetSearchableInvAttrs(context.Background(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.InvFilterAttr{
+		{Name: "device_type", Scope: "inventory", Count: 1},
+	}, attrs)
+}
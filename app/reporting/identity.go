@@ -0,0 +1,63 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/deviceauth"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WithIdentityAttributes enables indexing the selected fields of the
+// device identity data, fetched from deviceauth, as identity attributes
+func WithIdentityAttributes(client deviceauth.Client, names []string) AppOption {
+	return func(a *app) {
+		a.devauthClient = client
+		a.identityAttrs = names
+	}
+}
+
+// addIdentityAttributes merges the selected identity data fields
+// into the device identity attributes, overriding the inventory ones
+func (app *app) addIdentityAttributes(ctx context.Context, tid string, dev *model.InvDevice) error {
+	if app.devauthClient == nil || len(app.identityAttrs) == 0 {
+		return nil
+	}
+
+	data, err := app.devauthClient.GetIdentityData(ctx, tid, string(dev.ID))
+	if err == deviceauth.ErrDeviceNotFound {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get the device identity data")
+	}
+
+	for _, name := range app.identityAttrs {
+		val, ok := data[name]
+		if !ok {
+			continue
+		}
+
+		dev.Attributes.Upsert(model.InvDeviceAttribute{
+			Name:  name,
+			Scope: model.AttrScopeIdentity,
+			Value: val,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the background jobs run by a single instance per period
+const (
+	jobDeviceAge   = "device_age"
+	jobQuotas      = "quotas"
+	jobMaintenance = "maintenance"
+)
+
+// ClaimJobRun claims the run of the background 'job' for the period
+// starting at 'slot', run by each instance; only the first instance to
+// claim the period runs the job for it, and the instance keeps the job
+// as long as it claims a run within 'ttl' of the previous one
+func (app *app) ClaimJobRun(ctx context.Context, job string, slot time.Time,
+	ttl time.Duration) (bool, error) {
+	holder, err := os.Hostname()
+	if err != nil {
+		holder = "unknown"
+	}
+
+	claimed, err := app.store.ClaimJobRun(ctx, job, slot.UTC().Format(time.RFC3339),
+		holder, ttl)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to claim the %s job run", job)
+	}
+	return claimed, nil
+}
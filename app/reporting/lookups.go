@@ -0,0 +1,46 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WithDeviceIDsLookup stores the device IDs of the searches with more
+// than 'threshold' IDs (e.g. the static groups of thousands of devices)
+// in a lookup document, referenced by the query instead of inlining
+// them; 0 always inlines the IDs
+func WithDeviceIDsLookup(threshold int) AppOption {
+	return func(a *app) {
+		a.idsLookup = threshold
+	}
+}
+
+// useDeviceIDsLookup tells if the search IDs go to a lookup document
+func (app *app) useDeviceIDsLookup(ids []string) bool {
+	return app.idsLookup > 0 && len(ids) > app.idsLookup
+}
+
+// deviceIDsLookupFilter stores the device IDs, returning
+// the filter matching them through a terms lookup
+func (app *app) deviceIDsLookupFilter(ctx context.Context, ids []string) (model.QueryPart, error) {
+	lookup, err := app.store.PutDeviceIDsLookup(ctx, tenantID(ctx), ids)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewDevIDsLookupFilter(*lookup), nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// MaintenanceWindow is the daily off-peak window, in UTC,
+// the index maintenance runs in
+type MaintenanceWindow struct {
+	// minutes since midnight
+	start, end int
+}
+
+// ParseMaintenanceWindow parses a window in the form "HH:MM-HH:MM",
+// e.g. "02:00-04:00"; the window may span midnight, e.g. "23:00-01:00"
+func ParseMaintenanceWindow(def string) (*MaintenanceWindow, error) {
+	var h1, m1, h2, m2 int
+	n, err := fmt.Sscanf(def, "%d:%d-%d:%d", &h1, &m1, &h2, &m2)
+	if err != nil || n != 4 ||
+		h1 < 0 || h1 > 23 || m1 < 0 || m1 > 59 ||
+		h2 < 0 || h2 > 23 || m2 < 0 || m2 > 59 {
+		return nil, errors.Errorf("malformed maintenance window %q", def)
+	}
+
+	w := &MaintenanceWindow{
+		start: h1*60 + m1,
+		end:   h2*60 + m2,
+	}
+	if w.start == w.end {
+		return nil, errors.Errorf("malformed maintenance window %q: empty window", def)
+	}
+	return w, nil
+}
+
+// Contains tells if 't' falls within the window
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	minutes := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minutes >= w.start && minutes < w.end
+	}
+	return minutes >= w.start || minutes < w.end
+}
+
+// Start returns the start of the window 't' falls within, or
+// of the last window before 't'
+func (w *MaintenanceWindow) Start(t time.Time) time.Time {
+	t = t.UTC()
+	minutes := t.Hour()*60 + t.Minute()
+	start := t.Truncate(day).Add(time.Duration(w.start) * time.Minute)
+	if minutes < w.start {
+		start = start.Add(-day)
+	}
+	return start
+}
+
+// OptimizeIndices force merges the devices indices of all the tenants,
+// and purges the unused device IDs lookups; per-tenant failures are
+// logged and skipped, and the run is reported, see GetMaintenanceReport.
+// The points in time opened by the tasks are closed by the tasks as
+// soon as they end or fail, and the ones of the other clients aren't
+// ours to close, so the open search contexts are only reported
+func (app *app) OptimizeIndices(ctx context.Context) error {
+	l := log.FromContext(ctx)
+
+	tenants, err := app.store.GetTenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list tenants")
+	}
+
+	start := time.Now()
+	report := &model.MaintenanceReport{
+		StartedAt: start.UTC(),
+	}
+	report.Holder, _ = os.Hostname()
+	for _, tid := range tenants {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := app.store.ForceMergeDevices(ctx, tid); err != nil {
+			l.Warnf("maintenance: %s", err)
+			report.FailedIndices++
+		} else {
+			report.MergedIndices++
+		}
+	}
+	l.Infof("maintenance: force merged %d devices indices in %s, %d failed",
+		report.MergedIndices, time.Since(start), report.FailedIndices)
+
+	// the lookups of the searches by many device IDs are shared
+	// by the repeated searches, kept for a day
+	purged, err := app.store.PurgeDeviceIDsLookups(ctx, time.Now().Add(-day))
+	if err != nil {
+		l.Warnf("maintenance: %s", err)
+		report.LookupsFailure = true
+	} else {
+		l.Infof("maintenance: purged %d device IDs lookups", purged)
+		report.PurgedLookups = purged
+	}
+
+	// the search contexts hold on to the segments merged away
+	if contexts, err := app.store.GetOpenSearchContexts(ctx); err != nil {
+		l.Warnf("maintenance: %s", err)
+	} else {
+		l.Infof("maintenance: %d search contexts open", contexts)
+		report.OpenContexts = &contexts
+	}
+
+	report.Duration = time.Since(start).Milliseconds()
+	if err := app.store.PutMaintenanceReport(ctx, report); err != nil {
+		l.Warnf("maintenance: %s", err)
+	}
+
+	return nil
+}
+
+// GetMaintenanceReport returns the report of the last index
+// maintenance, run by any instance, or nil if it never ran
+func (app *app) GetMaintenanceReport(ctx context.Context) (*model.MaintenanceReport, error) {
+	return app.store.GetMaintenanceReport(ctx)
+}
+
+// RunMaintenanceJob optimizes the indices once a day, within the
+// off-peak window; the window is checked every 'interval', and only
+// the instance holding the job runs it, see ClaimJobRun
+func RunMaintenanceJob(ctx context.Context, app App, window *MaintenanceWindow,
+	interval time.Duration) {
+	l := log.FromContext(ctx)
+
+	var lastClaim time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now().UTC()
+		if window.Contains(now) && now.Sub(lastClaim) > day-interval {
+			claimed, err := app.ClaimJobRun(ctx, jobMaintenance, window.Start(now), 2*day)
+			if err != nil {
+				l.Error(err)
+			} else {
+				lastClaim = now
+			}
+			if claimed {
+				if err := app.OptimizeIndices(ctx); err != nil {
+					l.Error(err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
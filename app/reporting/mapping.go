@@ -0,0 +1,91 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// DryRunMapping reports how the attributes would be mapped in
+// tenant 'tid' devices index, without indexing anything
+func (app *app) DryRunMapping(ctx context.Context, tid string, attrs []model.MappingAttribute) ([]model.AttributeMapping, error) {
+	index, err := app.store.GetDevIndex(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := indexProperties(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.DryRunMapping(props, totalFieldsLimit(index), attrs, app.settings), nil
+}
+
+// ReconcileMapping re-derives tenant 'tid' attribute mapping state from
+// the devices index mapping, e.g. after a datastore restore, and reports
+// the attribute metadata of the attributes missing from the index;
+// with 'prune' the orphaned metadata is deleted
+func (app *app) ReconcileMapping(ctx context.Context, tid string, prune bool) (*model.MappingReconciliation, error) {
+	index, err := app.store.GetDevIndex(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := indexProperties(index)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := app.store.GetAttributesMetadata(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := model.ReconcileMapping(props, metadata)
+	if !prune {
+		return ret, nil
+	}
+
+	for i := range ret.OrphanedMetadata {
+		err := app.store.DeleteAttributeMetadata(ctx, &ret.OrphanedMetadata[i])
+		if err != nil && err != store.ErrAttributeMetadataNotFound {
+			return nil, err
+		}
+	}
+	ret.Pruned = true
+
+	return ret, nil
+}
+
+// totalFieldsLimit returns the index 'mapping.total_fields.limit' setting,
+// or the ES default if not set
+func totalFieldsLimit(index map[string]interface{}) int {
+	setting := index
+	for _, key := range []string{"settings", "index", "mapping", "total_fields"} {
+		setting, _ = setting[key].(map[string]interface{})
+	}
+	// index settings are returned as strings
+	limit, err := strconv.Atoi(fmt.Sprint(setting["limit"]))
+	if err != nil || limit <= 0 {
+		return model.DefaultTotalFieldsLimit
+	}
+	return limit
+}
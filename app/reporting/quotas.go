@@ -0,0 +1,154 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/events"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// quotaAlerts tracks the tenants approaching their quotas
+type quotaAlerts struct {
+	config    model.QuotaConfig
+	publisher events.AlertPublisher
+
+	mu     sync.Mutex
+	alerts map[string]model.QuotaAlert
+}
+
+// WithQuotaAlerts enables the soft quota alerts, see CheckQuotas;
+// the publisher, optional, is notified of the new alerts
+func WithQuotaAlerts(cfg model.QuotaConfig, publisher events.AlertPublisher) AppOption {
+	return func(a *app) {
+		a.quotas = &quotaAlerts{
+			config:    cfg,
+			publisher: publisher,
+			alerts:    map[string]model.QuotaAlert{},
+		}
+	}
+}
+
+// CheckQuotas checks the usage of all the tenants against the quotas;
+// with 'notify', every alert is logged, with the usage share, and the
+// publisher is notified once per alert, until the usage drops under the
+// threshold, otherwise the alerts are only kept for GetQuotaAlerts
+func (app *app) CheckQuotas(ctx context.Context, notify bool) error {
+	if app.quotas == nil {
+		return nil
+	}
+	l := log.FromContext(ctx)
+
+	stats, err := app.GetTenantsStats(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the tenants stats")
+	}
+
+	now := time.Now().UTC()
+	alerts := map[string]model.QuotaAlert{}
+	for _, s := range stats {
+		for _, alert := range app.quotas.config.Check(s, now) {
+			alerts[alert.TenantID+"/"+alert.Quota] = alert
+		}
+	}
+
+	app.quotas.mu.Lock()
+	prev := app.quotas.alerts
+	app.quotas.alerts = alerts
+	app.quotas.mu.Unlock()
+
+	if !notify {
+		return nil
+	}
+	for key, alert := range alerts {
+		l.F(log.Ctx{
+			"tenant_id":   alert.TenantID,
+			"quota":       alert.Quota,
+			"usage":       alert.Usage,
+			"limit":       alert.Limit,
+			"usage_share": alert.Share,
+		}).Warnf("quota: tenant %s uses %d of %d %s",
+			alert.TenantID, alert.Usage, alert.Limit, alert.Quota)
+
+		if _, ok := prev[key]; ok || app.quotas.publisher == nil {
+			continue
+		}
+		alert := alert
+		if err := app.quotas.publisher.PublishQuotaAlert(ctx, &alert); err != nil {
+			l.Warnf("quota: failed to publish the alert of tid %s: %s",
+				alert.TenantID, err)
+			// retried on the next check
+			app.quotas.mu.Lock()
+			delete(app.quotas.alerts, key)
+			app.quotas.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// GetQuotaAlerts returns the alerts raised by the last quota check
+func (app *app) GetQuotaAlerts() []model.QuotaAlert {
+	ret := []model.QuotaAlert{}
+	if app.quotas == nil {
+		return ret
+	}
+
+	app.quotas.mu.Lock()
+	for _, alert := range app.quotas.alerts {
+		ret = append(ret, alert)
+	}
+	app.quotas.mu.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].TenantID != ret[j].TenantID {
+			return ret[i].TenantID < ret[j].TenantID
+		}
+		return ret[i].Quota < ret[j].Quota
+	})
+	return ret
+}
+
+// RunQuotaJob checks the quotas every 'interval', until the context
+// is canceled; all the instances check the quotas, to list the alerts,
+// but only the one holding the job logs and publishes them, see ClaimJobRun
+func RunQuotaJob(ctx context.Context, app App, interval time.Duration) {
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := app.ClaimJobRun(ctx, jobQuotas,
+			time.Now().Truncate(interval), 2*interval)
+		if err != nil {
+			l.Error(err)
+		}
+		if err := app.CheckQuotas(ctx, claimed); err != nil {
+			l.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
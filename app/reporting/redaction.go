@@ -0,0 +1,119 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+type redactedAttrsKey struct{}
+
+type redactedValuesKey struct{}
+
+// WithRedactedAttributes grants the caller access to the
+// redacted attributes in the search results
+func WithRedactedAttributes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redactedAttrsKey{}, true)
+}
+
+// WithRedactedValues grants the caller the real values of the
+// redacted attributes in the search results; only the redacted
+// values are indexed, the real ones are looked up in the inventory
+func WithRedactedValues(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redactedValuesKey{}, true)
+}
+
+func canViewRedacted(ctx context.Context) bool {
+	allowed, _ := ctx.Value(redactedAttrsKey{}).(bool)
+	return allowed
+}
+
+func canViewRedactedValues(ctx context.Context) bool {
+	allowed, _ := ctx.Value(redactedValuesKey{}).(bool)
+	return allowed
+}
+
+// applyRedactions reveals the real values of the redacted attributes
+// to the callers granted them, keeps the redacted values for the ones
+// granted the redacted attributes, and drops them for the others
+func (app *app) applyRedactions(ctx context.Context, devs []model.InvDevice) error {
+	if !app.settings.Redactions.Any() {
+		return nil
+	}
+	if canViewRedactedValues(ctx) {
+		return app.revealRedacted(ctx, devs)
+	}
+	if !canViewRedacted(ctx) {
+		app.dropRedacted(devs)
+	}
+	return nil
+}
+
+// revealRedacted replaces the values of the redacted attributes
+// with the real ones, from the inventory, in a single request
+func (app *app) revealRedacted(ctx context.Context, devs []model.InvDevice) error {
+	if len(devs) == 0 {
+		return nil
+	}
+	ids := make([]string, len(devs))
+	for i := range devs {
+		ids[i] = string(devs[i].ID)
+	}
+	invDevs, err := app.invClient.GetDevices(ctx, tenantID(ctx), ids)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the redacted attributes values")
+	}
+	values := make(map[model.DeviceID]model.DeviceAttributes, len(invDevs))
+	for _, d := range invDevs {
+		values[d.ID] = d.Attributes
+	}
+
+	for i := range devs {
+		attrs := devs[i].Attributes[:0]
+		for _, a := range devs[i].Attributes {
+			if !app.settings.Redactions.IsRedacted(a.Scope, a.Name) {
+				attrs = append(attrs, a)
+				continue
+			}
+			// the attributes removed since the indexing are dropped
+			for _, r := range values[devs[i].ID] {
+				if r.Scope == a.Scope && r.Name == a.Name {
+					a.Value = r.Value
+					attrs = append(attrs, a)
+					break
+				}
+			}
+		}
+		devs[i].Attributes = attrs
+	}
+	return nil
+}
+
+// dropRedacted removes the redacted attributes from the devices
+func (app *app) dropRedacted(devs []model.InvDevice) {
+	for i := range devs {
+		attrs := devs[i].Attributes[:0]
+		for _, a := range devs[i].Attributes {
+			if !app.settings.Redactions.IsRedacted(a.Scope, a.Name) {
+				attrs = append(attrs, a)
+			}
+		}
+		devs[i].Attributes = attrs
+	}
+}
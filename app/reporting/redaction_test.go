@@ -0,0 +1,88 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/client/inventory"
+	"github.com/mendersoftware/reporting/model"
+)
+
+// invDevicesClient returns the inventory devices of 'devs'
+type invDevicesClient struct {
+	inventory.Client
+	devs map[string]model.InvDevice
+}
+
+func (c *invDevicesClient) GetDevices(ctx context.Context, tid string,
+	deviceIDs []string) ([]model.InvDevice, error) {
+	ret := []model.InvDevice{}
+	for _, id := range deviceIDs {
+		if dev, ok := c.devs[id]; ok {
+			ret = append(ret, dev)
+		}
+	}
+	return ret, nil
+}
+
+func TestApplyRedactions(t *testing.T) {
+	redactions, err := model.ParseRedactions([]string{"custom/email:hash"},
+		"0123456789abcdef0123456789abcdef")
+	assert.NoError(t, err)
+	client := &invDevicesClient{devs: map[string]model.InvDevice{
+		"dev-1": {ID: "dev-1", Attributes: model.DeviceAttributes{
+			{Scope: "custom", Name: "email", Value: "user@example.com"},
+		}},
+	}}
+	app := NewApp(nil, client,
+		WithSettings(model.Settings{Redactions: redactions})).(*app)
+
+	devices := func() []model.InvDevice {
+		return []model.InvDevice{
+			{ID: "dev-1", Attributes: model.DeviceAttributes{
+				{Scope: "custom", Name: "email", Value: []interface{}{"6af73c4e"}},
+				{Scope: "inventory", Name: "device_type", Value: []interface{}{"qemu"}},
+			}},
+			// the attribute removed since the indexing
+			{ID: "dev-2", Attributes: model.DeviceAttributes{
+				{Scope: "custom", Name: "email", Value: []interface{}{"e3b0c442"}},
+			}},
+		}
+	}
+	ctx := context.Background()
+
+	devs := devices()
+	assert.NoError(t, app.applyRedactions(ctx, devs))
+	assert.Equal(t, model.DeviceAttributes{
+		{Scope: "inventory", Name: "device_type", Value: []interface{}{"qemu"}},
+	}, devs[0].Attributes)
+	assert.Empty(t, devs[1].Attributes)
+
+	devs = devices()
+	assert.NoError(t, app.applyRedactions(WithRedactedAttributes(ctx), devs))
+	assert.Equal(t, devices(), devs)
+
+	devs = devices()
+	assert.NoError(t, app.applyRedactions(WithRedactedValues(ctx), devs))
+	assert.Equal(t, model.DeviceAttributes{
+		{Scope: "custom", Name: "email", Value: "user@example.com"},
+		{Scope: "inventory", Name: "device_type", Value: []interface{}{"qemu"}},
+	}, devs[0].Attributes)
+	assert.Empty(t, devs[1].Attributes)
+}
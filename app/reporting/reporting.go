@@ -15,12 +15,20 @@ package reporting
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/mendersoftware/go-lib-micro/identity"
 	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
 
+	"github.com/mendersoftware/reporting/client/deployments"
+	"github.com/mendersoftware/reporting/client/deviceauth"
+	"github.com/mendersoftware/reporting/client/deviceconfig"
+	"github.com/mendersoftware/reporting/client/devicemonitor"
+	"github.com/mendersoftware/reporting/client/events"
 	"github.com/mendersoftware/reporting/client/inventory"
 	"github.com/mendersoftware/reporting/model"
 	"github.com/mendersoftware/reporting/store"
@@ -29,62 +37,581 @@ import (
 const (
 	SvcInventory  = "inventory"
 	SvcDeviceauth = "deviceauth"
+	// SvcDevicemonitor triggers the reindex on the device alerts change
+	SvcDevicemonitor = "devicemonitor"
+	// SvcDeviceconfig triggers the reindex on the device configuration change
+	SvcDeviceconfig = "deviceconfig"
+	// SvcDeployments triggers the reindex on the device deployment status change
+	SvcDeployments = "deployments"
+)
+
+const (
+	// eventsPublishTimeout bounds the delivery of the change events
+	// of a device, done in the background of the reindex
+	eventsPublishTimeout = 10 * time.Second
+	// maxPendingPublishes bounds the devices the change events are
+	// delivered for at once, the events of the others are dropped
+	maxPendingPublishes = 64
 )
 
 var (
-	knownServices = []string{SvcInventory, SvcDeviceauth}
+	knownServices = []string{SvcInventory, SvcDeviceauth, SvcDevicemonitor, SvcDeviceconfig,
+		SvcDeployments}
 
 	ErrUnknownService = errors.New("unknown service name")
+
+	ErrAggregationNotNumeric = errors.New("the aggregation requires a numeric attribute")
+	ErrAttributeHidden       = errors.New("the attribute is hidden")
+
+	ErrSearchQueueFull = store.ErrSearchQueueFull
 )
 
 type App interface {
-	InventorySearchDevices(ctx context.Context, searchParams *model.SearchParams) (interface{}, int, error)
+	HealthCheck(ctx context.Context) ([]string, error)
+	GetCapabilities() *model.Capabilities
+	InventorySearchDevices(ctx context.Context, searchParams *model.SearchParams) (*model.SearchResult, error)
+	InventorySearchDevicesBatch(ctx context.Context, params model.BatchSearchParams) ([]model.BatchSearchResult, error)
 	GetSearchableInvAttrs(ctx context.Context, tid string) ([]model.InvFilterAttr, error)
 	Reindex(ctx context.Context, tenantID, devID string, service string) error
+	PurgeStaleDevices(ctx context.Context, retention DeviceRetention) error
+	GetArtifactAdoption(ctx context.Context, periodDays int) (*model.AdoptionReport, error)
+	RawSearch(ctx context.Context, tid string, query model.RawQuery) (model.M, error)
+	AggregateDevices(ctx context.Context, params *model.AggregateParams) ([]model.DeviceAggregation, error)
+	CreateAPIKey(ctx context.Context, tid, name string,
+		expiresAt *time.Time) (*model.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, tid string) ([]model.APIKey, error)
+	DeleteAPIKey(ctx context.Context, tid, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*model.APIKey, error)
+	WarmUp(ctx context.Context) error
+	GetStorageUsage(ctx context.Context, tid string) ([]model.TenantUsage, error)
+	GetTenantsStats(ctx context.Context) ([]model.TenantStats, error)
+	DiffTenantsMappings(ctx context.Context, tidA, tidB string) (*model.MappingDiff, error)
+	OptimizeIndices(ctx context.Context) error
+	RefreshDevicesAge(ctx context.Context) error
+	RefreshDeploymentsFailed(ctx context.Context) error
+	ClaimJobRun(ctx context.Context, job string, slot time.Time, ttl time.Duration) (bool, error)
+	GetMaintenanceReport(ctx context.Context) (*model.MaintenanceReport, error)
+	CompareDevices(ctx context.Context, params *model.CompareParams) (*model.DeviceComparison, error)
+	DetectAnomalies(ctx context.Context) error
+	GetAnomalies(ctx context.Context, tid string) (*model.AnomalyReport, error)
+	HarvestChanges(ctx context.Context, params *model.ChangesParams) (*model.ChangesResult, error)
+	DryRunMapping(ctx context.Context, tid string, attrs []model.MappingAttribute) ([]model.AttributeMapping, error)
+	GetMappingConflicts(ctx context.Context, tid string) ([]model.MappingConflict, error)
+	SetDeviceTags(ctx context.Context, tid, devID string, tags model.DeviceTags) error
+	RemoveDeviceTags(ctx context.Context, tid, devID string, names []string) error
+	ReconcileMapping(ctx context.Context, tid string, prune bool) (*model.MappingReconciliation, error)
+	SetAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error
+	DeleteAttributeMetadata(ctx context.Context, tid, scope, name string) error
+	GetTenantFeatures(ctx context.Context, tid string) (model.FeatureFlags, error)
+	SetTenantFeatures(ctx context.Context, tid string, flags model.FeatureFlags) error
+	FeatureEnabled(ctx context.Context, tid, name string) (bool, error)
+	CheckQuotas(ctx context.Context, notify bool) error
+	GetQuotaAlerts() []model.QuotaAlert
+	StartTask(ctx context.Context, req model.TaskRequest) (*model.Task, error)
+	GetTask(ctx context.Context, id string) (*model.Task, error)
+	CancelTask(ctx context.Context, id string) (*model.Task, error)
+	StopTasks(ctx context.Context) error
 }
 
+type AppOption func(*app)
+
 type app struct {
-	store     store.Store
-	invClient inventory.Client
+	store         store.Store
+	invClient     inventory.Client
+	devauthClient deviceauth.Client
+	identityAttrs []string
+	monitorClient devicemonitor.Client
+	configClient  deviceconfig.Client
+	deployClient  deployments.Client
+	publisher     events.Publisher
+	publishing    chan struct{}
+	authz         Authorizer
+	hiddenAttrs   []string
+	valuesLimit   int
+	anomalies     *model.AnomalyConfig
+	defaultSort   *model.DefaultSort
+	events        *eventCache
+	features      *tenantCache
+	aliases       *tenantCache
+	propagateTags bool
+	maxTagNames   int
+	idsLookup     int
+	quotas        *quotaAlerts
+	tasks         *taskRunner
+	settings      model.Settings
 }
 
-func NewApp(store store.Store, client inventory.Client) App {
-	return &app{
+func NewApp(store store.Store, client inventory.Client, opts ...AppOption) App {
+	app := &app{
 		store:     store,
 		invClient: client,
+		tasks:     newTaskRunner(),
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+	return app
+}
+
+// WithSettings sets the attribute settings and the cluster capabilities
+// the devices are indexed and the queries built with; they must match
+// the ones the index template was migrated with
+func WithSettings(settings model.Settings) AppOption {
+	return func(a *app) {
+		a.settings = settings
+	}
+}
+
+// WithEventsPublisher enables publishing the device change events
+func WithEventsPublisher(publisher events.Publisher) AppOption {
+	return func(a *app) {
+		a.publisher = publisher
+		a.publishing = make(chan struct{}, maxPendingPublishes)
+	}
+}
+
+// WithDefaultSort sorts the searches without an explicit sort
+// by the configured criteria, instead of the relevance score
+func WithDefaultSort(sort *model.DefaultSort) AppOption {
+	return func(a *app) {
+		a.defaultSort = sort
+	}
+}
+
+// HealthCheck verifies the service dependencies: the store,
+// and the inventory service used for the devices enrichment;
+// a degraded, but available, store is reported as a warning
+func (app *app) HealthCheck(ctx context.Context) ([]string, error) {
+	health, err := app.store.ClusterHealth(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "store")
+	}
+	var warnings []string
+	switch health.Status {
+	case model.ClusterStatusGreen:
+	case model.ClusterStatusYellow:
+		warnings = append(warnings, fmt.Sprintf(
+			"store: cluster status %s, %d unassigned shards, %d pending tasks",
+			health.Status, health.UnassignedShards, health.PendingTasks))
+	default:
+		return nil, errors.Errorf("store: cluster status %s, %d unassigned shards",
+			health.Status, health.UnassignedShards)
+	}
+	if health.QueuedSearches > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"store: %d searches queued, recent queue time p50 %s, p95 %s, max %s, "+
+				"%d searches rejected",
+			health.QueuedSearches, health.SearchQueueTime.P50, health.SearchQueueTime.P95,
+			health.SearchQueueTime.Max, health.RejectedSearches))
+	}
+
+	if err := app.invClient.CheckHealth(ctx); err != nil {
+		return nil, errors.Wrap(err, "inventory")
 	}
+	return warnings, nil
 }
 
-func (app *app) InventorySearchDevices(ctx context.Context, searchParams *model.SearchParams) (interface{}, int, error) {
-	query, err := model.BuildQuery(*searchParams)
+// GetCapabilities returns the features supported by the store cluster
+func (app *app) GetCapabilities() *model.Capabilities {
+	return app.store.Capabilities()
+}
+
+// InventorySearchDevices returns the page of devices matching the search
+// params, the total count, and the cursor of the next page, if any
+func (app *app) InventorySearchDevices(ctx context.Context, searchParams *model.SearchParams) (*model.SearchResult, error) {
+	query, err := app.buildSearchQuery(ctx, searchParams)
+	if err != nil {
+		return nil, err
+	}
+
+	if searchParams.CountFirst && len(searchParams.Facets) == 0 {
+		count, err := app.store.Count(ctx, query.CountQuery(), 1)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			res := &model.SearchResult{
+				Devices: []model.InvDevice{},
+			}
+			if searchParams.GroupCount != nil {
+				res.GroupCount = new(int)
+			}
+			return res, nil
+		}
+	}
+
+	esRes, err := app.store.Search(ctx, query)
+
 	if err != nil {
-		return nil, 0, err
+		if searchParams.Collapse != nil && collapseMultiValued(err.Error()) {
+			return nil, model.ErrCollapseMultiValued
+		}
+		if len(searchParams.Facets) > 0 && tooManyBuckets(err.Error()) {
+			return nil, model.ErrTooManyBuckets
+		}
+		return nil, err
 	}
 
-	if len(searchParams.DeviceIDs) > 0 {
+	res, total, err := app.storeToInventoryDevs(esRes, searchParams.WithScore)
+	if err != nil {
+		return nil, err
+	}
+	if total, err = collapsedTotal(esRes, searchParams.Collapse, total); err != nil {
+		return nil, err
+	}
+	if err := app.applyRedactions(ctx, res); err != nil {
+		return nil, err
+	}
+	model.TruncateAttributeValues(res, app.attributeValuesLimit(searchParams))
+	if searchParams.WithAttributeMetadata {
+		if err := app.addAttributeMetadata(ctx, tenantID(ctx), res); err != nil {
+			return nil, err
+		}
+	}
+
+	// collapsed results and samples can't be paged with search_after
+	var cursor string
+	if searchParams.Collapse == nil && searchParams.Sample == 0 {
+		cursor, err = nextCursor(esRes, searchParams.PerPage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	facets, err := parseFacets(esRes, searchParams.Facets)
+	if err != nil {
+		return nil, err
+	}
+	groupCount, err := parseGroupCount(esRes, searchParams.GroupCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.SearchResult{
+		Devices:    res,
+		TotalCount: total,
+		NextCursor: cursor,
+		Facets:     facets,
+		GroupCount: groupCount,
+	}, nil
+}
+
+// InventorySearchDevicesBatch executes the searches in a single store
+// request; the results are positional, a failed search doesn't fail
+// the whole batch
+func (app *app) InventorySearchDevicesBatch(ctx context.Context, params model.BatchSearchParams) ([]model.BatchSearchResult, error) {
+	queries := make([]interface{}, len(params))
+	for i := range params {
+		query, err := app.buildSearchQuery(ctx, &params[i])
+		if err != nil {
+			return nil, err
+		}
+		queries[i] = query
+	}
+
+	esRes, err := app.store.MultiSearch(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]model.BatchSearchResult, len(esRes))
+	for i, r := range esRes {
+		if esErr, ok := r["error"]; ok {
+			ret[i].Error = fmt.Sprintf("search failed: %v", esErr)
+			if params[i].Collapse != nil && collapseMultiValued(ret[i].Error) {
+				ret[i].Error = model.ErrCollapseMultiValued.Error()
+			}
+			if len(params[i].Facets) > 0 && tooManyBuckets(ret[i].Error) {
+				ret[i].Error = model.ErrTooManyBuckets.Error()
+			}
+			continue
+		}
+
+		devs, total, err := app.storeToInventoryDevs(r, params[i].WithScore)
+		if err != nil {
+			return nil, err
+		}
+		if total, err = collapsedTotal(r, params[i].Collapse, total); err != nil {
+			return nil, err
+		}
+		if err := app.applyRedactions(ctx, devs); err != nil {
+			return nil, err
+		}
+		model.TruncateAttributeValues(devs, app.attributeValuesLimit(&params[i]))
+		if params[i].WithAttributeMetadata {
+			if err := app.addAttributeMetadata(ctx, tenantID(ctx), devs); err != nil {
+				return nil, err
+			}
+		}
+		facets, err := parseFacets(r, params[i].Facets)
+		if err != nil {
+			return nil, err
+		}
+		groupCount, err := parseGroupCount(r, params[i].GroupCount)
+		if err != nil {
+			return nil, err
+		}
+		ret[i].Devices = devs
+		ret[i].TotalCount = total
+		ret[i].Facets = facets
+		ret[i].GroupCount = groupCount
+	}
+
+	return ret, nil
+}
+
+func (app *app) buildSearchQuery(ctx context.Context, searchParams *model.SearchParams) (model.Query, error) {
+	if len(searchParams.ScriptFilters) > 0 {
+		auditScriptFilters(ctx, searchParams.ScriptFilters)
+	}
+
+	if len(searchParams.Facets) > 0 {
+		enabled, err := app.FeatureEnabled(ctx, tenantID(ctx), model.FeatureFacets)
+		if err != nil {
+			return nil, err
+		} else if !enabled {
+			return nil, errors.Wrap(ErrFeatureDisabled, model.FeatureFacets)
+		}
+	}
+
+	aliases, err := app.attributeAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if app.defaultSort != nil {
+		searchParams.DefaultSort = app.defaultSort.For(tenantID(ctx))
+	}
+	searchParams.ResolveAliases(aliases)
+	if err := app.checkHidden(searchParams.QueriedAttributes()); err != nil {
+		return nil, err
+	}
+	searchParams.ApplyTimezone()
+	if searchParams.GroupCount != nil {
+		searchParams.GroupCountType, err = app.attributeType(ctx, *searchParams.GroupCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deviceIDs := searchParams.DeviceIDs
+	if app.useDeviceIDsLookup(deviceIDs) {
+		// the IDs are matched through the lookup instead
+		searchParams.DeviceIDs = nil
+		defer func() { searchParams.DeviceIDs = deviceIDs }()
+	}
+
+	query, err := model.BuildQuery(*searchParams, app.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.useDeviceIDsLookup(deviceIDs) {
+		lookup, err := app.deviceIDsLookupFilter(ctx, deviceIDs)
+		if err != nil {
+			return nil, err
+		}
+		query = lookup.AddTo(query)
+	} else if len(deviceIDs) > 0 {
 		query = query.Must(model.M{
 			"terms": model.M{
-				"id": searchParams.DeviceIDs,
+				"id": deviceIDs,
 			},
 		})
 	}
 
+	return app.authorize(ctx, query)
+}
+
+// auditScriptFilters logs every script filter execution
+func auditScriptFilters(ctx context.Context, filters []model.ScriptFilter) {
+	l := log.FromContext(ctx)
+
+	tid := tenantID(ctx)
+	for _, f := range filters {
+		l.Infof("audit: executing script filter, tid %s, source %q, params %v",
+			tid, f.Source, f.Params)
+	}
+}
+
+// tenantID returns the tenant of the identity in the context, if any
+func tenantID(ctx context.Context) string {
+	if id := identity.FromContext(ctx); id != nil {
+		return id.Tenant
+	}
+	return ""
+}
+
+// parseFacets extracts the facet counts from the search results, if requested
+func parseFacets(storeRes map[string]interface{}, terms []model.AggregationTerm) ([]model.DeviceAggregation, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	aggs, ok := storeRes["aggregations"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("can't process store facets")
+	}
+	return model.ParseFacets(terms, aggs)
+}
+
+// parseGroupCount extracts the group count from the search results, if requested
+func parseGroupCount(storeRes map[string]interface{}, attr *model.SelectAttribute) (*int, error) {
+	if attr == nil {
+		return nil, nil
+	}
+	aggs, ok := storeRes["aggregations"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("can't process store group count")
+	}
+	count, err := model.ParseGroupCount(aggs)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// collapsedTotal returns the number of the collapsed results, i.e. of
+// the distinct values, for a collapsed search, the total hits otherwise
+func collapsedTotal(storeRes map[string]interface{}, collapse *model.SelectAttribute,
+	total int) (int, error) {
+	if collapse == nil {
+		return total, nil
+	}
+	aggs, ok := storeRes["aggregations"].(map[string]interface{})
+	if !ok {
+		return 0, errors.New("can't process store collapse count")
+	}
+	return model.ParseCollapseCount(aggs)
+}
+
+// collapseMultiValued tells if the store failed the search collapsed by
+// a multi-valued attribute, which is only detected on the execution
+func collapseMultiValued(reason string) bool {
+	return strings.Contains(reason, "must be single valued")
+}
+
+// tooManyBuckets tells if the store failed the aggregation exceeding
+// its buckets limit, e.g. a fine date histogram over years of dates
+func tooManyBuckets(reason string) bool {
+	return strings.Contains(reason, "too_many_buckets_exception")
+}
+
+// nextCursor encodes the sort values of the last hit, if the page is full
+func nextCursor(storeRes map[string]interface{}, perPage int) (string, error) {
+	hitsM, _ := storeRes["hits"].(map[string]interface{})
+	hitsS, _ := hitsM["hits"].([]interface{})
+	if len(hitsS) == 0 || len(hitsS) < perPage {
+		return "", nil
+	}
+
+	last, _ := hitsS[len(hitsS)-1].(map[string]interface{})
+	sortValues, ok := last["sort"].([]interface{})
+	if !ok {
+		return "", nil
+	}
+
+	return model.EncodeCursor(sortValues)
+}
+
+func (app *app) AggregateDevices(ctx context.Context, params *model.AggregateParams) ([]model.DeviceAggregation, error) {
+	aliases, err := app.attributeAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params.ResolveAliases(aliases)
+	if err := app.checkHidden(params.QueriedAttributes()); err != nil {
+		return nil, err
+	}
+	params.ApplyTimezone()
+
+	if err := app.checkMetricAttributes(ctx, params.Aggregations); err != nil {
+		return nil, err
+	}
+
+	query, err := model.BuildAggregateQuery(*params, app.settings)
+	if err != nil {
+		return nil, err
+	}
+	query, err = app.authorize(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
 	esRes, err := app.store.Search(ctx, query)
+	if err != nil {
+		if tooManyBuckets(err.Error()) {
+			return nil, model.ErrTooManyBuckets
+		}
+		return nil, err
+	}
 
+	aggs, ok := esRes["aggregations"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("can't process store aggregations")
+	}
+
+	return model.ParseAggregations(params.Aggregations, aggs)
+}
+
+// checkMetricAttributes verifies that the attributes of the
+// metric aggregations are indexed as numbers
+func (app *app) checkMetricAttributes(ctx context.Context, terms []model.AggregationTerm) error {
+	attrs := model.MetricAttributes(terms)
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	index, err := app.store.GetDevIndex(ctx, tenantID(ctx))
 	if err != nil {
-		return nil, 0, err
+		return err
+	}
+	props, err := indexProperties(index)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attrs {
+		if _, ok := props[model.ToAttr(a.Scope, a.Attribute, model.TypeNum)]; !ok {
+			return errors.Wrapf(ErrAggregationNotNumeric, "%s/%s", a.Scope, a.Attribute)
+		}
 	}
+	return nil
+}
 
-	res, total, err := app.storeToInventoryDevs(esRes)
+// attributeType resolves the indexed type of the attribute from the
+// tenant mapping: strings, unless indexed as numbers only
+func (app *app) attributeType(ctx context.Context, attr model.SelectAttribute) (model.Type, error) {
+	index, err := app.store.GetDevIndex(ctx, tenantID(ctx))
 	if err != nil {
-		return nil, 0, err
+		return model.TypeAny, err
+	}
+	props, err := indexProperties(index)
+	if err != nil {
+		return model.TypeAny, err
 	}
 
-	return res, total, err
+	if _, ok := props[model.ToAttr(attr.Scope, attr.Attribute, model.TypeStr)]; ok {
+		return model.TypeStr, nil
+	}
+	if _, ok := props[model.ToAttr(attr.Scope, attr.Attribute, model.TypeNum)]; ok {
+		return model.TypeNum, nil
+	}
+	return model.TypeStr, nil
 }
 
-// storeToInventoryDevs translates ES results directly to iventory devices
-func (a *app) storeToInventoryDevs(storeRes map[string]interface{}) ([]model.InvDevice, int, error) {
+// RawSearch executes a validated raw ES query against tenant 'tid' devices
+func (app *app) RawSearch(ctx context.Context, tid string, query model.RawQuery) (model.M, error) {
+	l := log.FromContext(ctx)
+	l.Infof("executing raw search query for tid %s", tid)
+
+	if err := query.ValidateFeatures(app.settings); err != nil {
+		return nil, err
+	}
+	return app.store.Search(ctx, query.ForTenant(tid))
+}
+
+// storeToInventoryDevs translates ES results directly to iventory devices,
+// optionally including the relevance score of each device
+func (a *app) storeToInventoryDevs(storeRes map[string]interface{}, withScore bool) ([]model.InvDevice, int, error) {
 	devs := []model.InvDevice{}
 
 	hitsM, ok := storeRes["hits"].(map[string]interface{})
@@ -113,6 +640,17 @@ func (a *app) storeToInventoryDevs(storeRes map[string]interface{}) ([]model.Inv
 			return nil, 0, err
 		}
 
+		hit, _ := v.(map[string]interface{})
+		if withScore {
+			if score, ok := hit["_score"].(float64); ok {
+				res.Score = &score
+			}
+		}
+		if count, ok := model.CollapsedCount(hit); ok {
+			res.CollapsedCount = &count
+		}
+		res.MatchedFilters = model.MatchedFilters(hit)
+
 		devs = append(devs, *res)
 	}
 
@@ -151,6 +689,7 @@ func (a *app) storeToInventoryDev(storeRes interface{}) (*model.InvDevice, error
 	ret := &model.InvDevice{
 		ID: model.DeviceID(id),
 	}
+	setTypedFields(ret, sourceM)
 
 	attrs := []model.InvDeviceAttribute{}
 
@@ -161,7 +700,7 @@ func (a *app) storeToInventoryDev(storeRes interface{}) (*model.InvDevice, error
 			return nil, err
 		}
 
-		if n != "" {
+		if n != "" && !a.isHidden(s, model.Redot(n)) {
 			a := model.InvDeviceAttribute{
 				Name:  model.Redot(n),
 				Scope: s,
@@ -177,7 +716,68 @@ func (a *app) storeToInventoryDev(storeRes interface{}) (*model.InvDevice, error
 	return ret, nil
 }
 
+// setTypedFields promotes the device fields and system attributes
+// consumers commonly look for to the typed device fields
+func setTypedFields(dev *model.InvDevice, sourceM map[string]interface{}) {
+	if group, ok := firstValue(sourceM["groupName"]).(string); ok {
+		dev.Group = model.GroupName(group)
+	}
+	if status, ok := firstValue(sourceM["status"]).(string); ok {
+		dev.Status = status
+	}
+	if ts, ok := parseTime(sourceM["createdAt"]); ok {
+		dev.CreatedTs = &ts
+	}
+	if ts, ok := parseTime(sourceM["updatedAt"]); ok {
+		dev.UpdatedTs = ts
+	}
+	checkInAttr := model.ToAttr(model.AttrScopeSystem, model.AttrNameCheckedIn, model.TypeStr)
+	if ts, ok := parseTime(sourceM[checkInAttr]); ok {
+		dev.CheckInTime = &ts
+	}
+	staleAttr := model.ToAttr(model.AttrScopeSystem, model.AttrNameStaleSince, model.TypeStr)
+	if _, ok := parseTime(sourceM[staleAttr]); ok {
+		dev.Stale = true
+	}
+}
+
+// firstValue unwraps the single value arrays returned for 'fields'
+func firstValue(v interface{}) interface{} {
+	if arr, ok := v.([]interface{}); ok {
+		if len(arr) == 0 {
+			return nil
+		}
+		return arr[0]
+	}
+	return v
+}
+
+func parseTime(v interface{}) (time.Time, bool) {
+	s, ok := firstValue(v).(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	return ts, err == nil
+}
+
 func (app *app) Reindex(ctx context.Context, tenantID, devID string, service string) error {
+	key := app.eventKey(ctx, tenantID)
+	if key != "" && app.events.seenOrAdd(key) {
+		log.FromContext(ctx).Debugf("skipping the redelivered event %s", key)
+		return nil
+	}
+
+	if err := app.reindex(ctx, tenantID, devID, service); err != nil {
+		if key != "" {
+			app.events.forget(key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (app *app) reindex(ctx context.Context, tenantID, devID string, service string) error {
 	l := log.FromContext(ctx)
 	l.Debugf("triggered reindexing for device %v:%v", tenantID, devID)
 
@@ -195,10 +795,29 @@ func (app *app) Reindex(ctx context.Context, tenantID, devID string, service str
 	l.Debug("getting inventory device")
 	devs, err := app.invClient.GetDevices(ctx, tenantID, []string{devID})
 	if err != nil {
+		app.markStale(ctx, tenantID, devID)
 		return err
 	}
 	l.Debugf("got inventory device %v\n", devs)
 
+	if len(devs) > 0 {
+		if err := app.addIdentityAttributes(ctx, tenantID, &devs[0]); err != nil {
+			return err
+		}
+		// the attributes of the other services are best effort: the
+		// device is still indexed without them, keeping the previously
+		// indexed values, as the update document doesn't carry them
+		if err := app.addAlertsAttributes(ctx, tenantID, &devs[0]); err != nil {
+			l.Warnf("indexing the device without the alerts attributes: %s", err)
+		}
+		if err := app.addConfigurationAttributes(ctx, tenantID, &devs[0]); err != nil {
+			l.Warnf("indexing the device without the configuration attributes: %s", err)
+		}
+		if err := app.addDeploymentsAttributes(ctx, tenantID, &devs[0]); err != nil {
+			l.Warnf("indexing the device without the deployments attributes: %s", err)
+		}
+	}
+
 	l.Debugf("getting store device")
 	esdev, err := app.store.GetDevice(ctx, tenantID, devID)
 
@@ -207,14 +826,24 @@ func (app *app) Reindex(ctx context.Context, tenantID, devID string, service str
 	}
 
 	now := time.Now().UTC()
+	// the device age counts from its creation in the inventory,
+	// falling back to the time it was first indexed
+	created, createdKnown := devs[0].InventoryCreatedTs()
 
 	if esdev == nil {
 		l.Debug("device not found in store, but it's ok, creating")
-		newdev, _ := model.NewDeviceFromInv(tenantID, &devs[0])
-		newdev.SetCreatedAt(now)
+		newdev, _ := model.NewDeviceFromInv(tenantID, &devs[0], app.settings)
+		if createdKnown {
+			newdev.SetCreatedAt(created.UTC())
+		} else {
+			newdev.SetCreatedAt(now)
+		}
 		newdev.SetUpdatedAt(now)
+		newdev.SetAge(now)
 
-		err := app.store.IndexDevice(ctx, newdev)
+		err := app.writeQuarantined(ctx, newdev, func(dev *model.Device) error {
+			return app.store.IndexDevice(ctx, dev)
+		})
 		if err != nil {
 			return err
 		}
@@ -228,51 +857,207 @@ func (app *app) Reindex(ctx context.Context, tenantID, devID string, service str
 	// the logic is extremely complex
 	// instead prepare a 'new' device (based on the inventory device) as an update document
 	// worst case - noop from ES
-	update, err := model.NewDeviceFromInv(tenantID, &devs[0])
-	update.SetUpdatedAt(now)
-
+	update, err := model.NewDeviceFromInv(tenantID, &devs[0], app.settings)
 	if err != nil {
 		return err
 	}
+	update.SetUpdatedAt(now)
+	if createdKnown {
+		update.SetCreatedAt(created.UTC())
+		update.SetAge(now)
+	}
 
 	l.Debugf("updating device %v", update)
-	err = app.store.UpdateDevice(ctx, tenantID, devID, update)
+	err = app.writeQuarantined(ctx, update, func(dev *model.Device) error {
+		return app.store.UpdateDevice(ctx, tenantID, devID, dev)
+	})
 	if err != nil {
 		return err
 	}
 
+	if isStale(esdev) {
+		if err := app.store.SetDeviceStale(ctx, tenantID, devID, nil); err != nil {
+			return err
+		}
+	}
+
+	app.publishChanges(ctx, esdev, update, now)
+
 	return nil
 }
 
-func (app *app) GetSearchableInvAttrs(ctx context.Context, tid string) ([]model.InvFilterAttr, error) {
+// publishChanges emits the group/status change events in the
+// background, not to hold the reindex on a slow subscriber; failures
+// are logged only, as the device is already indexed
+func (app *app) publishChanges(ctx context.Context, prev, next *model.Device, ts time.Time) {
+	if app.publisher == nil {
+		return
+	}
+	changes := model.DeviceChangeEvents(prev, next, ts)
+	if len(changes) == 0 {
+		return
+	}
+
 	l := log.FromContext(ctx)
+	select {
+	case app.publishing <- struct{}{}:
+	default:
+		l.Errorf("dropped %d events for device %s: too many pending deliveries",
+			len(changes), changes[0].DeviceID)
+		return
+	}
 
-	index, err := app.store.GetDevIndex(ctx, tid)
+	// the deliveries outlive the reindex request context
+	pubCtx, cancel := context.WithTimeout(
+		log.WithContext(context.Background(), l), eventsPublishTimeout)
+	go func() {
+		defer func() { <-app.publishing }()
+		defer cancel()
+		for _, event := range changes {
+			if err := app.publisher.Publish(pubCtx, event); err != nil {
+				l.Errorf("failed to publish event %s for device %s: %v",
+					event.Subject, event.DeviceID, err)
+			}
+		}
+	}()
+}
+
+// GetStorageUsage reports the storage used by tenant 'tid',
+// or by all the tenants if 'tid' is empty
+func (app *app) GetStorageUsage(ctx context.Context, tid string) ([]model.TenantUsage, error) {
+	usage, err := app.store.GetStorageUsage(ctx, tid)
 	if err != nil {
 		return nil, err
 	}
 
-	// inventory attributes are under 'mappings.properties'
-	mappings, ok := index["mappings"]
-	if !ok {
-		return nil, errors.New("can't parse index mappings")
+	if len(usage) == 0 {
+		return usage, nil
 	}
 
-	mappingsM, ok := mappings.(map[string]interface{})
-	if !ok {
-		return nil, errors.New("can't parse index mappings")
+	// the indices definitions are fetched at once, not per tenant
+	var indices map[string]map[string]interface{}
+	if tid != "" {
+		index, err := app.store.GetDevIndex(ctx, tid)
+		if err != nil {
+			return nil, err
+		}
+		indices = map[string]map[string]interface{}{tid: index}
+	} else if indices, err = app.store.GetDevIndices(ctx); err != nil {
+		return nil, err
 	}
 
-	props, ok := mappingsM["properties"]
-	if !ok {
-		return nil, errors.New("can't parse index properties")
+	for i := range usage {
+		index, ok := indices[usage[i].TenantID]
+		if !ok {
+			continue
+		}
+		props, err := indexProperties(index)
+		if err != nil {
+			return nil, err
+		}
+		usage[i].AttributeCount, err = countSearchableAttrs(props)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	propsM, ok := props.(map[string]interface{})
-	if !ok {
-		return nil, errors.New("can't parse index properties")
+	return usage, nil
+}
+
+// countSearchableAttrs counts the inventory attributes among the index
+// fields, incl. the hidden ones, which take up the mapping fields too
+func countSearchableAttrs(props map[string]interface{}) (int, error) {
+	count := 0
+	for k := range props {
+		_, n, err := model.MaybeParseAttr(k)
+		if err != nil {
+			return 0, err
+		}
+		if n != "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetTenantsStats reports the device count, the last device update,
+// and the mapping fields usage of all the tenants
+func (app *app) GetTenantsStats(ctx context.Context) ([]model.TenantStats, error) {
+	usage, err := app.store.GetStorageUsage(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	lastUpdated, err := app.store.GetLastUpdated(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := app.store.GetDevIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]model.TenantStats, len(usage))
+	for i, u := range usage {
+		ret[i] = model.TenantStats{
+			TenantID:           u.TenantID,
+			DeviceCount:        u.DeviceCount,
+			MappingFieldsLimit: model.DefaultTotalFieldsLimit,
+		}
+		if index, ok := indices[u.TenantID]; ok {
+			props, err := indexProperties(index)
+			if err != nil {
+				return nil, err
+			}
+			ret[i].MappingFields = model.CountMappingFields(props)
+			ret[i].MappingFieldsLimit = totalFieldsLimit(index)
+		}
+		if ts, ok := lastUpdated[u.TenantID]; ok {
+			ret[i].LastUpdated = &ts
+		}
+	}
+
+	return ret, nil
+}
+
+// DiffTenantsMappings compares the searchable attributes of two tenants,
+// e.g. to debug a filter matching the devices of a tenant only
+func (app *app) DiffTenantsMappings(ctx context.Context, tidA, tidB string) (*model.MappingDiff, error) {
+	props := make([]map[string]interface{}, 2)
+	for i, tid := range []string{tidA, tidB} {
+		index, err := app.store.GetDevIndex(ctx, tid)
+		if err != nil {
+			return nil, err
+		}
+		props[i], err = indexProperties(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return model.DiffMappings(tidA, props[0], tidB, props[1]), nil
+}
+
+func (app *app) GetSearchableInvAttrs(ctx context.Context, tid string) ([]model.InvFilterAttr, error) {
+	l := log.FromContext(ctx)
+
+	index, err := app.store.GetDevIndex(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	propsM, err := indexProperties(index)
+	if err != nil {
+		return nil, err
 	}
 
+	metadata, err := app.store.GetAttributesMetadata(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	metaMap := model.NewAttributeMetadataMap(metadata)
+
 	ret := []model.InvFilterAttr{}
 
 	for k := range propsM {
@@ -282,8 +1067,14 @@ func (app *app) GetSearchableInvAttrs(ctx context.Context, tid string) ([]model.
 			return nil, err
 		}
 
-		if n != "" {
-			ret = append(ret, model.InvFilterAttr{Name: n, Scope: s, Count: 1})
+		if n != "" && !app.isHidden(s, model.Redot(n)) {
+			attr := model.InvFilterAttr{Name: n, Scope: s, Count: 1}
+			if meta, ok := metaMap.Get(s, model.Redot(n)); ok {
+				attr.DisplayName = meta.DisplayName
+				attr.Tag = meta.Tag
+				attr.Description = meta.Description
+			}
+			ret = append(ret, attr)
 		}
 	}
 
@@ -303,3 +1094,29 @@ func (app *app) GetSearchableInvAttrs(ctx context.Context, tid string) ([]model.
 
 	return ret, nil
 }
+
+// indexProperties extracts the fields of an index definition
+func indexProperties(index map[string]interface{}) (map[string]interface{}, error) {
+	// inventory attributes are under 'mappings.properties'
+	mappings, ok := index["mappings"]
+	if !ok {
+		return nil, errors.New("can't parse index mappings")
+	}
+
+	mappingsM, ok := mappings.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("can't parse index mappings")
+	}
+
+	props, ok := mappingsM["properties"]
+	if !ok {
+		return nil, errors.New("can't parse index properties")
+	}
+
+	propsM, ok := props.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("can't parse index properties")
+	}
+
+	return propsM, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package reporting
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+)
+
+const day = 24 * time.Hour
+
+// DeviceRetention maps tenant IDs to the number of days
+// devices are kept after their last update
+type DeviceRetention map[string]int
+
+// ParseDeviceRetention parses retention definitions in the form
+// "tenant_id:days", e.g. "5f8f7e6d5c4b3a2910000000:7"
+func ParseDeviceRetention(defs []string) (DeviceRetention, error) {
+	ret := DeviceRetention{}
+	for _, def := range defs {
+		parts := strings.SplitN(def, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("malformed retention definition %q", def)
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days < 1 {
+			return nil, errors.Errorf("malformed retention definition %q: "+
+				"number of days must be a positive integer", def)
+		}
+		ret[parts[0]] = days
+	}
+	return ret, nil
+}
+
+// PurgeStaleDevices deletes the devices not updated within the retention
+// of their tenant; a tenant failing doesn't stop the purge of the others,
+// the failed tenants are reported in the error
+func (app *app) PurgeStaleDevices(ctx context.Context, retention DeviceRetention) error {
+	l := log.FromContext(ctx)
+
+	now := time.Now().UTC()
+	var failed []string
+	for tid, days := range retention {
+		before := now.Add(-time.Duration(days) * day)
+		deleted, err := app.store.DeleteDevicesUpdatedBefore(ctx, tid, before)
+		if err != nil {
+			l.Errorf("failed to purge stale devices, tid %s: %s", tid, err)
+			failed = append(failed, tid)
+			continue
+		}
+		if deleted > 0 {
+			l.Infof("purged %d devices not updated since %s, tid %s",
+				deleted, before.Format(time.RFC3339), tid)
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return errors.Errorf("failed to purge stale devices, tids %s",
+			strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// RunRetentionJob purges the stale devices every 'interval'
+// until the context is canceled
+func RunRetentionJob(ctx context.Context, app App, retention DeviceRetention,
+	interval time.Duration) {
+	l := log.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := app.PurgeStaleDevices(ctx, retention); err != nil {
+			l.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
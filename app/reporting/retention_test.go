@@ -0,0 +1,57 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/store"
+)
+
+// purgeStore records the purged tenants, failing
+// the purges of the tenants in 'failing'
+type purgeStore struct {
+	store.Store
+	purged  []string
+	failing map[string]bool
+}
+
+func (s *purgeStore) DeleteDevicesUpdatedBefore(ctx context.Context, tid string,
+	before time.Time) (int, error) {
+	if s.failing[tid] {
+		return 0, errors.New("connection refused")
+	}
+	s.purged = append(s.purged, tid)
+	return 1, nil
+}
+
+func TestPurgeStaleDevices(t *testing.T) {
+	s := &purgeStore{failing: map[string]bool{"bar": true, "baz": true}}
+	app := NewApp(s, nil)
+
+	err := app.PurgeStaleDevices(context.Background(), DeviceRetention{
+		"foo": 7, "bar": 7, "baz": 7, "qux": 30,
+	})
+	assert.EqualError(t, err, "failed to purge stale devices, tids bar, baz")
+	assert.ElementsMatch(t, []string{"foo", "qux"}, s.purged)
+
+	s.failing = nil
+	assert.NoError(t, app.PurgeStaleDevices(context.Background(), DeviceRetention{"foo": 7}))
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// searchStore returns the given result, or error, for every search
+type searchStore struct {
+	store.Store
+	res model.M
+	err error
+}
+
+func (s *searchStore) Search(ctx context.Context, query interface{}) (model.M, error) {
+	return s.res, s.err
+}
+
+func TestInventorySearchDevicesCollapse(t *testing.T) {
+	params := func() *model.SearchParams {
+		return &model.SearchParams{
+			Page:     1,
+			PerPage:  10,
+			Collapse: &model.SelectAttribute{Scope: "inventory", Attribute: "site_id"},
+		}
+	}
+
+	s := &searchStore{res: model.M{
+		"hits": map[string]interface{}{
+			"total": map[string]interface{}{"value": float64(12)},
+			"hits":  []interface{}{},
+		},
+		"aggregations": map[string]interface{}{
+			model.CollapseCountAggregation: map[string]interface{}{"value": float64(3)},
+		},
+	}}
+	app := NewApp(s, nil)
+
+	res, err := app.InventorySearchDevices(context.Background(), params())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res.TotalCount)
+
+	s.res, s.err = nil, errors.New("search failed: illegal_argument_exception: "+
+		"failed to collapse, the collapse field must be single valued")
+	_, err = app.InventorySearchDevices(context.Background(), params())
+	assert.Equal(t, model.ErrCollapseMultiValued, err)
+
+	s.err = errors.New("connection refused")
+	_, err = app.InventorySearchDevices(context.Background(), params())
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestAggregateDevicesTooManyBuckets(t *testing.T) {
+	params := &model.AggregateParams{
+		Aggregations: []model.AggregationTerm{{
+			Name: "created", Type: model.AggregationDateHistogram,
+			Scope: "system", Attribute: "created_ts", Interval: "1m",
+		}},
+	}
+	s := &searchStore{err: errors.New("search failed: too_many_buckets_exception: " +
+		"Trying to create too many buckets. Must be less than or equal to: [65535]")}
+	app := NewApp(s, nil)
+
+	_, err := app.AggregateDevices(context.Background(), params)
+	assert.Equal(t, model.ErrTooManyBuckets, err)
+}
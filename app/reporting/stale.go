@@ -0,0 +1,48 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// markStale flags the indexed device data as stale when it can't be
+// refreshed from the inventory: the searches keep serving it, with
+// the stale marker, until the reindex succeeds
+func (app *app) markStale(ctx context.Context, tid, devID string) {
+	esdev, err := app.store.GetDevice(ctx, tid, devID)
+	if err != nil || esdev == nil || isStale(esdev) {
+		return
+	}
+	now := time.Now().UTC()
+	if err := app.store.SetDeviceStale(ctx, tid, devID, &now); err != nil {
+		log.FromContext(ctx).Warnf("failed to mark device %s stale: %s", devID, err)
+	}
+}
+
+// isStale tells if the indexed device carries the stale marker
+func isStale(dev *model.Device) bool {
+	for _, a := range dev.SystemAttributes {
+		if a.Name == model.AttrNameStaleSince && a.IsStr() && len(a.String) > 0 {
+			return true
+		}
+	}
+	return false
+}
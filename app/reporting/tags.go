@@ -0,0 +1,127 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+var (
+	ErrDeviceNotFound  = store.ErrDeviceNotFound
+	ErrTooManyTagNames = model.ErrTooManyTagNames
+)
+
+// WithTagsPropagation propagates the device tags set or removed
+// through reporting to inventory, as the inventory device tags
+func WithTagsPropagation(enabled bool) AppOption {
+	return func(a *app) {
+		a.propagateTags = enabled
+	}
+}
+
+// WithMaxTagNames limits the number of the distinct tag names of a
+// tenant, each mapped to a devices index field; 0 for no limit
+func WithMaxTagNames(max int) AppOption {
+	return func(a *app) {
+		a.maxTagNames = max
+	}
+}
+
+// SetDeviceTags adds or replaces the tags of the device, if visible
+// to the caller; returns ErrDeviceNotFound otherwise, and
+// ErrTooManyTagNames if the tenant tag names would exceed the limit
+func (app *app) SetDeviceTags(ctx context.Context, tid, devID string, tags model.DeviceTags) error {
+	if err := app.checkDeviceVisible(ctx, devID); err != nil {
+		return err
+	}
+	if app.maxTagNames > 0 {
+		index, err := app.store.GetDevIndex(ctx, tid)
+		if err != nil {
+			return err
+		}
+		props, err := indexProperties(index)
+		if err != nil {
+			return err
+		}
+		if err := model.CheckTagNames(props, tags, app.maxTagNames); err != nil {
+			return err
+		}
+	}
+	if err := app.propagateDeviceTags(ctx, tid, devID, tags, nil); err != nil {
+		return err
+	}
+	return app.store.SetDeviceTags(ctx, tid, devID, tags)
+}
+
+// RemoveDeviceTags removes the named tags of the device, if visible
+// to the caller; returns ErrDeviceNotFound otherwise
+func (app *app) RemoveDeviceTags(ctx context.Context, tid, devID string, names []string) error {
+	if err := app.checkDeviceVisible(ctx, devID); err != nil {
+		return err
+	}
+	if err := app.propagateDeviceTags(ctx, tid, devID, nil, names); err != nil {
+		return err
+	}
+	return app.store.RemoveDeviceTags(ctx, tid, devID, names)
+}
+
+// checkDeviceVisible verifies the device is indexed,
+// and not restricted from the caller
+func (app *app) checkDeviceVisible(ctx context.Context, devID string) error {
+	query, err := app.buildSearchQuery(ctx, &model.SearchParams{
+		DeviceIDs: []string{devID},
+		Page:      1,
+		PerPage:   1,
+	})
+	if err != nil {
+		return err
+	}
+
+	count, err := app.store.Count(ctx, query.CountQuery(), 1)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// propagateDeviceTags replaces the inventory device tags with the
+// indexed ones, with the 'tags' set and the 'removed' ones removed, if
+// enabled; the inventory is written before the index, so that the tags
+// are unchanged on failure, and the index, if it fails afterwards,
+// catches up on the reindex of the device
+func (app *app) propagateDeviceTags(ctx context.Context, tid, devID string,
+	tags model.DeviceTags, removed []string) error {
+	if !app.propagateTags {
+		return nil
+	}
+
+	dev, err := app.store.GetDevice(ctx, tid, devID)
+	if err != nil {
+		return err
+	} else if dev == nil {
+		return ErrDeviceNotFound
+	}
+
+	err = app.invClient.SetDeviceTags(ctx, tid, devID, dev.Tags().Merge(tags, removed))
+	return errors.Wrap(err, "failed to propagate the device tags to inventory")
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+const (
+	// devices reindexed between the task progress updates
+	taskPageSize = 500
+
+	// the point in time is kept alive between the pages, and
+	// closed right away once the task ends or fails
+	taskPITKeepAlive    = "5m"
+	taskPITCloseTimeout = 10 * time.Second
+)
+
+var (
+	ErrTaskNotFound = store.ErrTaskNotFound
+	ErrTaskFinished = errors.New("task already finished")
+
+	errTaskCanceled = errors.New("task canceled")
+)
+
+// taskRunner tracks the admin tasks running in this instance
+type taskRunner struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func newTaskRunner() *taskRunner {
+	return &taskRunner{
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+// StartTask stores a new admin task and runs it in the background;
+// the task status and progress are tracked in the store, see GetTask
+func (app *app) StartTask(ctx context.Context, req model.TaskRequest) (*model.Task, error) {
+	task := model.NewTask(req)
+	if err := app.store.PutTask(ctx, task); err != nil {
+		return nil, err
+	}
+
+	// the task outlives the request
+	taskCtx, cancel := context.WithCancel(context.Background())
+	taskCtx = log.WithContext(taskCtx, log.FromContext(ctx).F(log.Ctx{
+		"task_id":   task.ID,
+		"task_type": task.Type,
+		"tenant_id": task.TenantID,
+	}))
+
+	app.tasks.mu.Lock()
+	app.tasks.cancels[task.ID] = cancel
+	app.tasks.mu.Unlock()
+	app.tasks.wg.Add(1)
+
+	ret := *task
+	go app.runTask(taskCtx, task)
+
+	return &ret, nil
+}
+
+func (app *app) runTask(ctx context.Context, task *model.Task) {
+	defer app.tasks.wg.Done()
+	defer func() {
+		app.tasks.mu.Lock()
+		cancel := app.tasks.cancels[task.ID]
+		delete(app.tasks.cancels, task.ID)
+		app.tasks.mu.Unlock()
+		cancel()
+	}()
+	l := log.FromContext(ctx)
+
+	task.Status = model.TaskStatusRunning
+	err := app.updateTask(ctx, task)
+	if err == nil {
+		err = app.execTask(ctx, task)
+	}
+
+	switch {
+	case err == nil:
+		task.Complete(model.TaskStatusSucceeded, nil)
+	case err == errTaskCanceled || ctx.Err() != nil:
+		task.Complete(model.TaskStatusCanceled, nil)
+	default:
+		task.Complete(model.TaskStatusFailed, err)
+	}
+	l.Infof("task %s: %s, %d/%d done", task.ID, task.Status,
+		task.Progress.Done, task.Progress.Total)
+
+	// the task context may be canceled already
+	if _, err := app.store.UpdateTask(context.Background(), task); err != nil {
+		l.Errorf("task %s: failed to store the final status: %s", task.ID, err)
+	}
+}
+
+// updateTask stores the task status and progress, and stops
+// the task if its cancellation was requested in the meantime
+func (app *app) updateTask(ctx context.Context, task *model.Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	task.UpdatedAt = time.Now().UTC()
+	stored, err := app.store.UpdateTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	if stored.CancelRequested {
+		task.CancelRequested = true
+		return errTaskCanceled
+	}
+	return nil
+}
+
+func (app *app) execTask(ctx context.Context, task *model.Task) error {
+	switch task.Type {
+	case model.TaskReindex:
+		return app.reindexTenant(ctx, task)
+	case model.TaskPurgeDevices:
+		before := time.Now().UTC().Add(-time.Duration(task.RetentionDays) * day)
+		deleted, err := app.store.DeleteDevicesUpdatedBefore(ctx, task.TenantID, before)
+		if err != nil {
+			return err
+		}
+		task.Progress = model.TaskProgress{Done: deleted, Total: deleted}
+	case model.TaskOptimize:
+		if err := app.store.ForceMergeDevices(ctx, task.TenantID); err != nil {
+			return err
+		}
+		task.Progress = model.TaskProgress{Done: 1, Total: 1}
+	default:
+		return errors.Errorf("unknown task type: %s", task.Type)
+	}
+	return nil
+}
+
+// reindexTenant reindexes the tenant's devices page by page, updating
+// the task progress after each; the devices failing to reindex are
+// logged and skipped, and fail the task when it completes. Only the
+// devices already indexed are walked: the devices missing from the
+// index are indexed by their next change event. Where supported, the
+// pages are searched on a point in time of the index, so that the
+// devices updated meanwhile aren't skipped or reindexed twice
+func (app *app) reindexTenant(ctx context.Context, task *model.Task) error {
+	l := log.FromContext(ctx)
+	tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: task.TenantID})
+
+	service := task.Service
+	if service == "" {
+		service = SvcInventory
+	}
+
+	pit := ""
+	if c := app.store.Capabilities(); c != nil && c.PointInTime {
+		var err error
+		pit, err = app.store.OpenPointInTime(ctx, task.TenantID, taskPITKeepAlive)
+		if err != nil {
+			return err
+		}
+		// the point in time ID may change from page to page
+		defer func() { app.closePointInTime(ctx, pit) }()
+	}
+
+	failed := 0
+	cursor := ""
+	for {
+		query, err := model.BuildQuery(model.SearchParams{
+			Page:    1,
+			PerPage: taskPageSize,
+			Cursor:  cursor,
+			Attributes: []model.SelectAttribute{
+				{Scope: model.AttrScopeIdentity, Attribute: "status"},
+			},
+		}, app.settings)
+		if err != nil {
+			return err
+		}
+		var esRes model.M
+		if pit != "" {
+			esRes, err = app.store.SearchPointInTime(ctx, query.With(model.M{
+				"pit": model.M{"id": pit, "keep_alive": taskPITKeepAlive},
+			}))
+			if id, ok := esRes["pit_id"].(string); ok {
+				pit = id
+			}
+		} else {
+			esRes, err = app.store.Search(tenantCtx, query.With(model.M{
+				"track_total_hits": true,
+			}))
+		}
+		if err != nil {
+			return err
+		}
+		devs, total, err := app.storeToInventoryDevs(esRes, false)
+		if err != nil {
+			return err
+		}
+
+		for _, dev := range devs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := app.reindex(tenantCtx, task.TenantID, string(dev.ID), service)
+			if err == ErrUnknownService {
+				return err
+			} else if err != nil {
+				l.Warnf("task %s: failed to reindex device %s: %s", task.ID, dev.ID, err)
+				failed++
+			}
+		}
+
+		task.Progress.Done += len(devs)
+		task.Progress.Total = total
+		if err := app.updateTask(ctx, task); err != nil {
+			return err
+		}
+
+		cursor, err = nextCursor(esRes, taskPageSize)
+		if err != nil {
+			return err
+		} else if cursor == "" {
+			break
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d devices failed to reindex", failed)
+	}
+	return nil
+}
+
+// closePointInTime releases the point in time once the iteration ends
+// or fails, also when failing on 'ctx' done, e.g. on the shutdown
+func (app *app) closePointInTime(ctx context.Context, id string) {
+	closeCtx, cancel := context.WithTimeout(context.Background(), taskPITCloseTimeout)
+	defer cancel()
+	if err := app.store.ClosePointInTime(closeCtx, id); err != nil {
+		log.FromContext(ctx).Warnf("failed to close the point in time: %s", err)
+	}
+}
+
+// GetTask returns the task status and progress
+func (app *app) GetTask(ctx context.Context, id string) (*model.Task, error) {
+	return app.store.GetTask(ctx, id)
+}
+
+// CancelTask requests the cancellation of a task: the task stops at
+// its next progress update, or immediately if run by this instance
+func (app *app) CancelTask(ctx context.Context, id string) (*model.Task, error) {
+	task, err := app.store.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Finished() {
+		return nil, ErrTaskFinished
+	}
+
+	task.CancelRequested = true
+	task.UpdatedAt = time.Now().UTC()
+	if err := app.store.RequestTaskCancel(ctx, id, task.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	app.tasks.mu.Lock()
+	if cancel, ok := app.tasks.cancels[id]; ok {
+		cancel()
+	}
+	app.tasks.mu.Unlock()
+
+	return task, nil
+}
+
+// StopTasks cancels the tasks running in this instance, and waits
+// for them to store their final status, or for the context to be done
+func (app *app) StopTasks(ctx context.Context) error {
+	app.tasks.mu.Lock()
+	for _, cancel := range app.tasks.cancels {
+		cancel()
+	}
+	app.tasks.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		app.tasks.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// pitStore serves an empty page on a point in time,
+// recording the points in time opened and closed
+type pitStore struct {
+	store.Store
+	searchErr error
+	opened    []string
+	searched  []string
+	closed    []string
+}
+
+func (s *pitStore) Capabilities() *model.Capabilities {
+	return &model.Capabilities{PointInTime: true}
+}
+
+func (s *pitStore) OpenPointInTime(ctx context.Context, tid, keepAlive string) (string, error) {
+	s.opened = append(s.opened, "pit-1")
+	return "pit-1", nil
+}
+
+func (s *pitStore) SearchPointInTime(ctx context.Context, query interface{}) (model.M, error) {
+	b, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	var q struct {
+		PIT struct {
+			ID string `json:"id"`
+		} `json:"pit"`
+	}
+	if err := json.Unmarshal(b, &q); err != nil {
+		return nil, err
+	}
+	s.searched = append(s.searched, q.PIT.ID)
+	if s.searchErr != nil {
+		return nil, s.searchErr
+	}
+	return model.M{
+		"pit_id": "pit-2",
+		"hits": map[string]interface{}{
+			"total": map[string]interface{}{"value": float64(0)},
+			"hits":  []interface{}{},
+		},
+	}, nil
+}
+
+func (s *pitStore) ClosePointInTime(ctx context.Context, id string) error {
+	s.closed = append(s.closed, id)
+	return nil
+}
+
+func (s *pitStore) UpdateTask(ctx context.Context, task *model.Task) (*model.Task, error) {
+	return task, nil
+}
+
+func TestReindexTenantPointInTime(t *testing.T) {
+	s := &pitStore{}
+	app := NewApp(s, nil).(*app)
+
+	// the latest point in time ID is closed once the iteration ends
+	err := app.reindexTenant(context.Background(), &model.Task{TaskRequest: model.TaskRequest{TenantID: "foo"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pit-1"}, s.opened)
+	assert.Equal(t, []string{"pit-1"}, s.searched)
+	assert.Equal(t, []string{"pit-2"}, s.closed)
+
+	// or fails
+	s.opened, s.closed = nil, nil
+	s.searchErr = errors.New("connection refused")
+	err = app.reindexTenant(context.Background(), &model.Task{TaskRequest: model.TaskRequest{TenantID: "foo"}})
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, []string{"pit-1"}, s.closed)
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+	"github.com/mendersoftware/reporting/store"
+)
+
+// usageStore serves the devices indices definitions
+// of the tenants, counting the lookups
+type usageStore struct {
+	store.Store
+	indices map[string]map[string]interface{}
+	lookups int
+}
+
+func (s *usageStore) GetStorageUsage(ctx context.Context, tid string) ([]model.TenantUsage, error) {
+	ret := []model.TenantUsage{}
+	for _, t := range []string{"bar", "foo"} {
+		if tid == "" || tid == t {
+			ret = append(ret, model.TenantUsage{TenantID: t})
+		}
+	}
+	return ret, nil
+}
+
+func (s *usageStore) GetDevIndex(ctx context.Context, tid string) (map[string]interface{}, error) {
+	s.lookups++
+	index, ok := s.indices[tid]
+	if !ok {
+		return nil, errors.New("index not found")
+	}
+	return index, nil
+}
+
+func (s *usageStore) GetDevIndices(ctx context.Context) (map[string]map[string]interface{}, error) {
+	s.lookups++
+	return s.indices, nil
+}
+
+func TestGetStorageUsage(t *testing.T) {
+	index := func(fields ...string) map[string]interface{} {
+		props := map[string]interface{}{"id": map[string]interface{}{}}
+		for _, f := range fields {
+			props[f] = map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"mappings": map[string]interface{}{"properties": props},
+		}
+	}
+	s := &usageStore{indices: map[string]map[string]interface{}{
+		"foo": index("inventory_mac_str", "identity_sn_str", "inventory_ram_num"),
+		"bar": index("inventory_mac_str"),
+	}}
+	app := NewApp(s, nil)
+
+	usage, err := app.GetStorageUsage(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantUsage{
+		{TenantID: "bar", AttributeCount: 1},
+		{TenantID: "foo", AttributeCount: 3},
+	}, usage)
+	assert.Equal(t, 1, s.lookups)
+
+	s.lookups = 0
+	usage, err = app.GetStorageUsage(context.Background(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantUsage{{TenantID: "foo", AttributeCount: 3}}, usage)
+	assert.Equal(t, 1, s.lookups)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package reporting
+
+import (
+	"context"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/identity"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WarmUp loads the mapping and issues a cheap query for every tenant,
+// so that the first user requests after a deployment don't pay for
+// the cold caches; per-tenant failures are logged and skipped
+func (app *app) WarmUp(ctx context.Context) error {
+	l := log.FromContext(ctx)
+
+	tenants, err := app.store.GetTenants(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list tenants")
+	}
+
+	query, err := model.BuildQuery(model.SearchParams{
+		Page:    1,
+		PerPage: 0,
+	}, app.settings)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	for _, tid := range tenants {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := app.GetSearchableInvAttrs(ctx, tid); err != nil {
+			l.Warnf("warm-up: failed to get the attributes, tid %s: %s", tid, err)
+			continue
+		}
+
+		tenantCtx := identity.WithContext(ctx, &identity.Identity{Tenant: tid})
+		if _, err := app.store.Search(tenantCtx, query); err != nil {
+			l.Warnf("warm-up: failed to query the devices, tid %s: %s", tid, err)
+		}
+	}
+	l.Infof("warm-up of %d tenants done in %s", len(tenants), time.Since(start))
+
+	return nil
+}
@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -29,11 +30,21 @@ import (
 
 	api "github.com/mendersoftware/reporting/api/http"
 	"github.com/mendersoftware/reporting/app/reporting"
+	"github.com/mendersoftware/reporting/client/deployments"
+	"github.com/mendersoftware/reporting/client/deviceauth"
+	"github.com/mendersoftware/reporting/client/deviceconfig"
+	"github.com/mendersoftware/reporting/client/devicemonitor"
+	"github.com/mendersoftware/reporting/client/events"
 	"github.com/mendersoftware/reporting/client/inventory"
+	"github.com/mendersoftware/reporting/client/transport"
 	dconfig "github.com/mendersoftware/reporting/config"
+	"github.com/mendersoftware/reporting/model"
 	"github.com/mendersoftware/reporting/store"
 )
 
+// how often the maintenance window is checked
+const maintenanceCheckInterval = 5 * time.Minute
+
 func init() {
 	if mode := os.Getenv(gin.EnvGinMode); mode != "" {
 		gin.SetMode(mode)
@@ -42,8 +53,11 @@ func init() {
 	}
 }
 
-// InitAndRun initializes the server and runs it
-func InitAndRun(conf config.Reader, store store.Store) error {
+// InitAndRun initializes the server and runs it, with the attribute
+// settings of the store, and the faults injected into the requests
+// of the outbound clients, see transport.Faults
+func InitAndRun(conf config.Reader, store store.Store, settings model.Settings,
+	faults transport.Faults) error {
 	ctx := context.Background()
 
 	log.Setup(conf.GetBool(dconfig.SettingDebugLog))
@@ -54,11 +68,194 @@ func InitAndRun(conf config.Reader, store store.Store) error {
 	invClient := inventory.NewClient(
 		conf.GetString(dconfig.SettingInventoryAddr),
 		false,
+	).WithFaults(faults).WithResponseLimits(
+		int64(conf.GetInt(dconfig.SettingInventoryMaxResponseSize)),
+		int64(conf.GetInt(dconfig.SettingInventoryMaxDeviceSize)),
 	)
+	if threshold := conf.GetInt(dconfig.SettingInventoryBreakerThreshold); threshold > 0 {
+		invClient = invClient.WithCircuitBreaker(threshold,
+			conf.GetDuration(dconfig.SettingInventoryBreakerCooldown))
+	}
+
+	retention, err := reporting.ParseDeviceRetention(
+		conf.GetStringSlice(dconfig.SettingDeviceRetention))
+	if err != nil {
+		return err
+	}
+
+	var maintenance *reporting.MaintenanceWindow
+	if def := conf.GetString(dconfig.SettingMaintenanceWindow); def != "" {
+		maintenance, err = reporting.ParseMaintenanceWindow(def)
+		if err != nil {
+			return err
+		}
+	}
+
+	hidden, err := reporting.ParseHiddenAttributes(
+		conf.GetStringSlice(dconfig.SettingHiddenAttributes))
+	if err != nil {
+		return err
+	}
+
+	defaultSort, err := model.ParseDefaultSort(
+		conf.GetStringSlice(dconfig.SettingDefaultSort),
+		conf.GetStringSlice(dconfig.SettingDefaultSortTenants))
+	if err != nil {
+		return err
+	}
+
+	opts := []reporting.AppOption{
+		reporting.WithSettings(settings),
+		reporting.WithAuthorizer(reporting.GroupsAuthorizer{}),
+	}
+	if len(defaultSort.Global) > 0 || len(defaultSort.Tenants) > 0 {
+		opts = append(opts, reporting.WithDefaultSort(defaultSort))
+	}
+	if size := conf.GetInt(dconfig.SettingReindexDedupSize); size > 0 {
+		opts = append(opts, reporting.WithEventDeduplication(size,
+			conf.GetDuration(dconfig.SettingReindexDedupTTL)))
+	}
+	if ttl := conf.GetDuration(dconfig.SettingFeaturesCacheTTL); ttl > 0 {
+		opts = append(opts, reporting.WithFeaturesCache(ttl))
+	}
+	if ttl := conf.GetDuration(dconfig.SettingAliasesCacheTTL); ttl > 0 {
+		opts = append(opts, reporting.WithAliasesCache(ttl))
+	}
+	if len(hidden) > 0 {
+		opts = append(opts, reporting.WithHiddenAttributes(hidden))
+	}
+	if threshold := conf.GetInt(dconfig.SettingDeviceIDsLookupThreshold); threshold > 0 {
+		opts = append(opts, reporting.WithDeviceIDsLookup(threshold))
+	}
+	if limit := conf.GetInt(dconfig.SettingMaxAttributeValues); limit > 0 {
+		opts = append(opts, reporting.WithAttributeValuesLimit(limit))
+	}
+	if url := conf.GetString(dconfig.SettingEventsWebhookURL); url != "" {
+		publisher := events.NewWebhookPublisher(url).WithFaults(faults)
+		opts = append(opts, reporting.WithEventsPublisher(publisher))
+	}
 
-	reporting := reporting.NewApp(store, invClient)
+	if attrs := conf.GetStringSlice(dconfig.SettingIdentityAttributes); len(attrs) > 0 {
+		devauthClient := deviceauth.NewClient(
+			conf.GetString(dconfig.SettingDeviceauthAddr),
+			false,
+		).WithFaults(faults)
+		opts = append(opts, reporting.WithIdentityAttributes(devauthClient, attrs))
+	}
+
+	anomalyInterval := conf.GetDuration(dconfig.SettingAnomalyInterval)
+	if anomalyInterval > 0 {
+		attrs, err := model.ParseAnomalyAttributes(
+			conf.GetStringSlice(dconfig.SettingAnomalyAttributes))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, reporting.WithAnomalyDetection(model.AnomalyConfig{
+			Attributes: attrs,
+			RareShare:  conf.GetFloat64(dconfig.SettingAnomalyRareShare),
+			DropShare:  conf.GetFloat64(dconfig.SettingAnomalyDropShare),
+		}))
+	}
+
+	quotaInterval := conf.GetDuration(dconfig.SettingQuotaInterval)
+	if quotaInterval > 0 {
+		var publisher events.AlertPublisher
+		if url := conf.GetString(dconfig.SettingQuotaWebhookURL); url != "" {
+			publisher = events.NewAlertWebhookPublisher(url).WithFaults(faults)
+		}
+		opts = append(opts, reporting.WithQuotaAlerts(model.QuotaConfig{
+			MaxDevices: int64(conf.GetInt(dconfig.SettingQuotaMaxDevices)),
+			AlertShare: conf.GetFloat64(dconfig.SettingQuotaAlertShare),
+		}, publisher))
+	}
 
-	var router = api.NewRouter(reporting)
+	if conf.GetBool(dconfig.SettingMonitorAlerts) {
+		monitorClient := devicemonitor.NewClient(
+			conf.GetString(dconfig.SettingDevicemonitorAddr),
+			false,
+		).WithFaults(faults)
+		opts = append(opts, reporting.WithMonitorAlerts(monitorClient))
+	}
+
+	if conf.GetBool(dconfig.SettingIndexDeployments) {
+		deploymentsClient := deployments.NewClient(
+			conf.GetString(dconfig.SettingDeploymentsAddr),
+			false,
+		).WithFaults(faults)
+		opts = append(opts, reporting.WithDeploymentsSummary(deploymentsClient))
+	}
+
+	if conf.GetBool(dconfig.SettingIndexConfiguration) {
+		configClient := deviceconfig.NewClient(
+			conf.GetString(dconfig.SettingDeviceconfigAddr),
+			false,
+		).WithFaults(faults)
+		opts = append(opts, reporting.WithDeviceConfiguration(configClient))
+	}
+
+	if conf.GetBool(dconfig.SettingPropagateTags) {
+		opts = append(opts, reporting.WithTagsPropagation(true))
+	}
+	opts = append(opts, reporting.WithMaxTagNames(conf.GetInt(dconfig.SettingMaxTagNames)))
+
+	app := reporting.NewApp(store, invClient, opts...)
+
+	if conf.GetBool(dconfig.SettingWarmUp) {
+		warmUpCtx, cancel := context.WithTimeout(ctx,
+			conf.GetDuration(dconfig.SettingWarmUpTimeout))
+		if err := app.WarmUp(warmUpCtx); err != nil {
+			l.Warnf("warm-up failed: %s", err)
+		}
+		cancel()
+	}
+
+	var jobs sync.WaitGroup
+	jobsCtx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+	if len(retention) > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reporting.RunRetentionJob(jobsCtx, app, retention,
+				conf.GetDuration(dconfig.SettingDeviceRetentionInterval))
+		}()
+	}
+	if maintenance != nil {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reporting.RunMaintenanceJob(jobsCtx, app, maintenance, maintenanceCheckInterval)
+		}()
+	}
+	if ageInterval := conf.GetDuration(dconfig.SettingDeviceAgeInterval); ageInterval > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reporting.RunDeviceAgeJob(jobsCtx, app, ageInterval)
+		}()
+	}
+	if anomalyInterval > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reporting.RunAnomalyJob(jobsCtx, app, anomalyInterval)
+		}()
+	}
+	if quotaInterval > 0 {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			reporting.RunQuotaJob(jobsCtx, app, quotaInterval)
+		}()
+	}
+
+	var router = api.NewRouter(app,
+		api.WithBodyLimits(
+			int64(conf.GetInt(dconfig.SettingSearchMaxBodySize)),
+			int64(conf.GetInt(dconfig.SettingBatchSearchMaxBodySize)),
+		),
+		api.WithStrictJSON(conf.GetBool(dconfig.SettingStrictJSON)),
+	)
 	srv := &http.Server{
 		Addr:    listen,
 		Handler: router,
@@ -76,11 +273,39 @@ func InitAndRun(conf config.Reader, store store.Store) error {
 
 	l.Info("Shutdown Server ...")
 
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	// stop accepting new connections and drain the inflight
+	// requests, within the shutdown deadline
+	ctxWithTimeout, cancel := context.WithTimeout(ctx,
+		conf.GetDuration(dconfig.SettingShutdownTimeout))
 	defer cancel()
+	srv.SetKeepAlivesEnabled(false)
 	if err := srv.Shutdown(ctxWithTimeout); err != nil {
-		l.Fatal("Server Shutdown: ", err)
+		l.Errorf("Server Shutdown: %s", err)
+	}
+
+	cancelJobs()
+	if !waitJobs(ctxWithTimeout, &jobs) {
+		l.Warn("background jobs still running after the shutdown timeout")
+	}
+	if err := app.StopTasks(ctxWithTimeout); err != nil {
+		l.Warn("admin tasks still running after the shutdown timeout")
 	}
 
 	return nil
 }
+
+// waitJobs waits for the background jobs to complete, and tells
+// if they did before the context was done
+func waitJobs(ctx context.Context, jobs *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deployments
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/reporting/client/rest"
+	"github.com/mendersoftware/reporting/client/transport"
+)
+
+const (
+	urlDeviceDeployments = "/api/internal/v1/deployments/tenants/:tid/deployments/devices/:id"
+
+	// the most recent deployments looked at
+	deploymentsPerPage = 100
+
+	statusFailure = "failure"
+)
+
+// DeploymentsSummary summarizes the recent deployments of a device
+type DeploymentsSummary struct {
+	// FailedCount is the number of deployments failed since the given time
+	FailedCount int
+	// FailedAt are the creation times of the failed deployments
+	FailedAt []time.Time
+	// LastStatus is the status of the latest deployment, if any
+	LastStatus string
+}
+
+//go:generate ../../utils/mockgen.sh
+type Client interface {
+	//GetDeploymentsSummary returns the summary of the device deployments
+	GetDeploymentsSummary(ctx context.Context, tid, deviceID string,
+		since time.Time) (*DeploymentsSummary, error)
+}
+
+type client struct {
+	client  *http.Client
+	urlBase string
+}
+
+func NewClient(urlBase string, skipVerify bool) *client {
+	return &client{
+		client: &http.Client{
+			Transport: transport.New(skipVerify),
+		},
+		urlBase: urlBase,
+	}
+}
+
+// WithFaults injects the faults into the requests, see transport.Faults
+func (c *client) WithFaults(faults transport.Faults) *client {
+	c.client.Transport = transport.Wrap(c.client.Transport, faults)
+	return c
+}
+
+func (c *client) GetDeploymentsSummary(ctx context.Context, tid, deviceID string,
+	since time.Time) (*DeploymentsSummary, error) {
+	url := rest.JoinURL(c.urlBase, urlDeviceDeployments)
+	url = strings.Replace(url, ":tid", tid, 1)
+	url = strings.Replace(url, ":id", deviceID, 1)
+
+	// the device deployments, the latest first
+	var deployments []struct {
+		Device struct {
+			Status  string    `json:"status"`
+			Created time.Time `json:"created"`
+		} `json:"device"`
+	}
+	query := neturl.Values{"per_page": {strconv.Itoa(deploymentsPerPage)}}
+	err := rest.GetJSON(ctx, c.client, url, query, &deployments)
+	// a device unknown to deployments has no deployments
+	if err == rest.ErrNotFound {
+		return &DeploymentsSummary{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	summary := &DeploymentsSummary{}
+	for i, d := range deployments {
+		if i == 0 {
+			summary.LastStatus = d.Device.Status
+		}
+		if d.Device.Status == statusFailure && !d.Device.Created.Before(since) {
+			summary.FailedCount++
+			summary.FailedAt = append(summary.FailedAt, d.Device.Created)
+		}
+	}
+
+	return summary, nil
+}
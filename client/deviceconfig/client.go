@@ -0,0 +1,78 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package deviceconfig
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/mendersoftware/reporting/client/rest"
+	"github.com/mendersoftware/reporting/client/transport"
+)
+
+const (
+	urlDeviceConfiguration = "/api/internal/v1/deviceconfig/tenants/:tid/configurations/device/:id"
+)
+
+// Configuration is the device configuration: the one
+// reported by the device, and the desired one
+type Configuration struct {
+	Reported map[string]string `json:"reported"`
+	Desired  map[string]string `json:"configured"`
+}
+
+//go:generate ../../utils/mockgen.sh
+type Client interface {
+	//GetConfiguration returns the reported and desired device configuration
+	GetConfiguration(ctx context.Context, tid, deviceID string) (*Configuration, error)
+}
+
+type client struct {
+	client  *http.Client
+	urlBase string
+}
+
+func NewClient(urlBase string, skipVerify bool) *client {
+	return &client{
+		client: &http.Client{
+			Transport: transport.New(skipVerify),
+		},
+		urlBase: urlBase,
+	}
+}
+
+// WithFaults injects the faults into the requests, see transport.Faults
+func (c *client) WithFaults(faults transport.Faults) *client {
+	c.client.Transport = transport.Wrap(c.client.Transport, faults)
+	return c
+}
+
+func (c *client) GetConfiguration(ctx context.Context, tid, deviceID string) (*Configuration, error) {
+	url := rest.JoinURL(c.urlBase, urlDeviceConfiguration)
+	url = strings.Replace(url, ":tid", tid, 1)
+	url = strings.Replace(url, ":id", deviceID, 1)
+
+	conf := &Configuration{}
+	err := rest.GetJSON(ctx, c.client, url, nil, conf)
+	// a device unknown to deviceconfig has no configuration
+	if err == rest.ErrNotFound {
+		return &Configuration{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package devicemonitor
+
+import (
+	"context"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/mendersoftware/reporting/client/rest"
+	"github.com/mendersoftware/reporting/client/transport"
+)
+
+const (
+	urlDeviceAlerts = "/api/internal/v1/devicemonitor/tenants/:tid/devices/:id/alerts"
+)
+
+// alert severity levels, from the lowest
+const (
+	SeverityOK       = "OK"
+	SeverityWarning  = "WARNING"
+	SeverityCritical = "CRITICAL"
+)
+
+var severities = map[string]int{
+	SeverityOK:       0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// AlertsSummary summarizes the open alerts of a device
+type AlertsSummary struct {
+	Count    int
+	Severity string
+}
+
+//go:generate ../../utils/mockgen.sh
+type Client interface {
+	//GetAlertsSummary returns the summary of the device open alerts
+	GetAlertsSummary(ctx context.Context, tid, deviceID string) (*AlertsSummary, error)
+}
+
+type client struct {
+	client  *http.Client
+	urlBase string
+}
+
+func NewClient(urlBase string, skipVerify bool) *client {
+	return &client{
+		client: &http.Client{
+			Transport: transport.New(skipVerify),
+		},
+		urlBase: urlBase,
+	}
+}
+
+// WithFaults injects the faults into the requests, see transport.Faults
+func (c *client) WithFaults(faults transport.Faults) *client {
+	c.client.Transport = transport.Wrap(c.client.Transport, faults)
+	return c
+}
+
+func (c *client) GetAlertsSummary(ctx context.Context, tid, deviceID string) (*AlertsSummary, error) {
+	url := rest.JoinURL(c.urlBase, urlDeviceAlerts)
+	url = strings.Replace(url, ":tid", tid, 1)
+	url = strings.Replace(url, ":id", deviceID, 1)
+
+	var alerts []struct {
+		Level string `json:"level"`
+	}
+	err := rest.GetJSON(ctx, c.client, url, neturl.Values{"resolved": {"false"}}, &alerts)
+	// a device unknown to devicemonitor has no alerts
+	if err == rest.ErrNotFound {
+		return &AlertsSummary{Severity: SeverityOK}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	summary := &AlertsSummary{Severity: SeverityOK}
+	for _, a := range alerts {
+		if a.Level == SeverityOK {
+			continue
+		}
+		summary.Count++
+		if severities[a.Level] > severities[summary.Severity] {
+			summary.Severity = a.Level
+		}
+	}
+
+	return summary, nil
+}
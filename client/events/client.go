@@ -0,0 +1,117 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/transport"
+	"github.com/mendersoftware/reporting/model"
+)
+
+const (
+	hdrSubject     = "X-Men-Subject"
+	defaultTimeout = 10 * time.Second
+)
+
+// Publisher delivers device change events to the subscribers; the
+// events are delivered by webhook, as the service has no message
+// broker connection, with the subject a broker would publish them on
+type Publisher interface {
+	Publish(ctx context.Context, event *model.DeviceChangeEvent) error
+}
+
+// AlertPublisher delivers the quota alerts to the operators
+type AlertPublisher interface {
+	PublishQuotaAlert(ctx context.Context, alert *model.QuotaAlert) error
+}
+
+type webhookPublisher struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookPublisher returns a publisher POSTing the events to 'url',
+// the event subject is passed in the X-Men-Subject header
+func NewWebhookPublisher(url string) *webhookPublisher {
+	return &webhookPublisher{
+		client: &http.Client{
+			Transport: transport.New(false),
+		},
+		url: url,
+	}
+}
+
+// NewAlertWebhookPublisher returns a publisher POSTing the quota
+// alerts to 'url', with the "quota.<name>" subject in the
+// X-Men-Subject header
+func NewAlertWebhookPublisher(url string) *webhookPublisher {
+	return &webhookPublisher{
+		client: &http.Client{
+			Transport: transport.New(false),
+		},
+		url: url,
+	}
+}
+
+// WithFaults injects the faults into the requests, see transport.Faults
+func (p *webhookPublisher) WithFaults(faults transport.Faults) *webhookPublisher {
+	p.client.Transport = transport.Wrap(p.client.Transport, faults)
+	return p
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, event *model.DeviceChangeEvent) error {
+	return p.post(ctx, event.Subject, event)
+}
+
+func (p *webhookPublisher) PublishQuotaAlert(ctx context.Context, alert *model.QuotaAlert) error {
+	return p.post(ctx, "quota."+alert.Quota, alert)
+}
+
+func (p *webhookPublisher) post(ctx context.Context, subject string, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize event")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(hdrSubject, subject)
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rsp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		return errors.Errorf(
+			"%s %s request failed with status %v", req.Method, req.URL, rsp.Status)
+	}
+
+	return nil
+}
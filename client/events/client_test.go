@@ -0,0 +1,82 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	var (
+		subject string
+		event   model.DeviceChangeEvent
+		status  = http.StatusAccepted
+	)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			subject = r.Header.Get(hdrSubject)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+			w.WriteHeader(status)
+		}))
+	defer srv.Close()
+
+	p := NewWebhookPublisher(srv.URL)
+	sent := model.DeviceChangeEvent{
+		Subject:   "device.group.changed",
+		TenantID:  "foo",
+		DeviceID:  "dev-1",
+		Old:       "staging",
+		New:       "production",
+		Timestamp: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, p.Publish(context.Background(), &sent))
+	assert.Equal(t, "device.group.changed", subject)
+	assert.Equal(t, sent, event)
+
+	status = http.StatusBadGateway
+	err := p.Publish(context.Background(), &sent)
+	assert.EqualError(t, err, "POST "+srv.URL+" request failed with status 502 Bad Gateway")
+}
+
+func TestAlertWebhookPublisher(t *testing.T) {
+	var subject string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			subject = r.Header.Get(hdrSubject)
+		}))
+	defer srv.Close()
+
+	p := NewAlertWebhookPublisher(srv.URL)
+	assert.NoError(t, p.PublishQuotaAlert(context.Background(), &model.QuotaAlert{
+		TenantID: "foo",
+		Quota:    "devices",
+	}))
+	assert.Equal(t, "quota.devices", subject)
+
+	// the webhook is unreachable
+	srv.Close()
+	assert.Error(t, p.PublishQuotaAlert(context.Background(), &model.QuotaAlert{}))
+}
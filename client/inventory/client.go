@@ -16,58 +16,112 @@ package inventory
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mendersoftware/go-lib-micro/log"
 	"github.com/pkg/errors"
 
+	"github.com/mendersoftware/reporting/client/transport"
 	"github.com/mendersoftware/reporting/model"
 )
 
 const (
 	urlSearch      = "/api/internal/v2/inventory/tenants/:tid/filters/search"
+	urlHealth      = "/api/internal/v1/inventory/health"
+	urlDeviceTags  = "/api/internal/v1/inventory/tenants/:tid/device/:id/attribute/scope/tags"
 	defaultTimeout = 10 * time.Second
+
+	// the failed responses are only logged
+	maxErrorBody = 4096
+
+	hdrTotalCount = "X-Total-Count"
 )
 
 //go:generate ../../utils/mockgen.sh
 type Client interface {
 	//GetDevices uses the search endpoint to get devices just by ids (not filters)
 	GetDevices(ctx context.Context, tid string, deviceIDs []string) ([]model.InvDevice, error)
+	//ListDevices pages through all the tenant devices, returns the total count too
+	ListDevices(ctx context.Context, tid string, page, perPage int) ([]model.InvDevice, int, error)
+	//CheckHealth checks the inventory service health
+	CheckHealth(ctx context.Context) error
+	//SetDeviceTags replaces the device tags, the attributes of the tags scope
+	SetDeviceTags(ctx context.Context, tid, devID string, tags model.DeviceTags) error
 }
 
 type client struct {
-	client  *http.Client
-	urlBase string
+	client      *http.Client
+	urlBase     string
+	maxResponse int64
+	maxDevice   int64
 }
 
 func NewClient(urlBase string, skipVerify bool) *client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
-	}
-
 	return &client{
 		client: &http.Client{
-			Transport: tr,
+			Transport: transport.New(skipVerify),
 		},
 		urlBase: urlBase,
 	}
 }
 
-func (c *client) GetDevices(ctx context.Context, tid string, deviceIDs []string) ([]model.InvDevice, error) {
-	l := log.FromContext(ctx)
+// WithFaults injects the faults into the requests, see transport.Faults
+func (c *client) WithFaults(faults transport.Faults) *client {
+	c.client.Transport = transport.Wrap(c.client.Transport, faults)
+	return c
+}
+
+// WithResponseLimits rejects the search responses larger than 'maxResponse'
+// bytes, and truncates the attributes of the devices larger than 'maxDevice'
+// bytes; a limit of 0 disables it
+func (c *client) WithResponseLimits(maxResponse, maxDevice int64) *client {
+	c.maxResponse = maxResponse
+	c.maxDevice = maxDevice
+	return c
+}
 
+// WithCircuitBreaker fails the requests immediately, with
+// transport.ErrCircuitOpen, for 'cooldown' after 'threshold'
+// consecutive failures, rather than piling up on a service down
+func (c *client) WithCircuitBreaker(threshold int, cooldown time.Duration) *client {
+	c.client.Transport = transport.NewBreaker(threshold, cooldown).
+		Wrap(c.client.Transport)
+	return c
+}
+
+func (c *client) GetDevices(ctx context.Context, tid string, deviceIDs []string) ([]model.InvDevice, error) {
 	getReq := &GetDevsReq{
 		DeviceIDs: deviceIDs,
 	}
 
-	body, err := json.Marshal(getReq)
+	invDevs, _, err := c.search(ctx, tid, getReq, false)
+	return invDevs, err
+}
+
+func (c *client) ListDevices(ctx context.Context, tid string, page, perPage int) ([]model.InvDevice, int, error) {
+	listReq := &ListDevsReq{
+		Page:    page,
+		PerPage: perPage,
+	}
+
+	return c.search(ctx, tid, listReq, true)
+}
+
+// search returns the devices matching the request, and their
+// total count, from the X-Total-Count header, if 'withTotal'
+func (c *client) search(ctx context.Context, tid string, searchReq interface{},
+	withTotal bool) ([]model.InvDevice, int, error) {
+	l := log.FromContext(ctx)
+
+	body, err := json.Marshal(searchReq)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to serialize get devices request")
+		return nil, 0, errors.Wrapf(err, "failed to serialize get devices request")
 	}
 
 	rd := bytes.NewReader(body)
@@ -77,7 +131,7 @@ func (c *client) GetDevices(ctx context.Context, tid string, deviceIDs []string)
 
 	req, err := http.NewRequest(http.MethodPost, url, rd)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to create request")
+		return nil, 0, errors.Wrapf(err, "failed to create request")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -87,30 +141,123 @@ func (c *client) GetDevices(ctx context.Context, tid string, deviceIDs []string)
 
 	rsp, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
+		return nil, 0, errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
 	}
 	defer rsp.Body.Close()
 
-	body, err = ioutil.ReadAll(rsp.Body)
-	if err != nil {
-		body = []byte("<failed to read>")
-	}
-
 	if rsp.StatusCode != http.StatusOK {
+		body, err = ioutil.ReadAll(io.LimitReader(rsp.Body, maxErrorBody))
+		if err != nil {
+			body = []byte("<failed to read>")
+		}
 		l.Errorf("request %s %s failed with status %v, response: %s",
 			req.Method, req.URL, rsp.Status, body)
 
-		return nil, errors.Errorf(
+		return nil, 0, errors.Errorf(
 			"%s %s request failed with status %v", req.Method, req.URL, rsp.Status)
 	}
 
-	var invDevs []model.InvDevice
-	err = json.Unmarshal(body, &invDevs)
+	var src io.Reader = rsp.Body
+	if c.maxResponse > 0 {
+		if rsp.ContentLength > c.maxResponse {
+			return nil, 0, ErrResponseTooLarge
+		}
+		src = &limitedReader{r: rsp.Body, n: c.maxResponse}
+	}
+
+	invDevs, truncated, err := decodeDevices(src, c.maxDevice)
+	if err == ErrResponseTooLarge {
+		return nil, 0, err
+	} else if err != nil {
+		return nil, 0, errors.New("failed to parse inventory device(s)")
+	}
+	if len(truncated) > 0 {
+		l.Warnf("truncated the attributes of the device(s) larger than %d bytes: %v",
+			c.maxDevice, truncated)
+	}
+
+	if !withTotal {
+		return invDevs, len(invDevs), nil
+	}
+	// the page size can't stand for the total, the paging would stop early
+	total, err := strconv.Atoi(rsp.Header.Get(hdrTotalCount))
+	if err != nil || total < 0 {
+		return nil, 0, errors.Wrapf(ErrTotalCountInvalid, "value %q",
+			rsp.Header.Get(hdrTotalCount))
+	}
+
+	return invDevs, total, nil
+}
+
+func (c *client) CheckHealth(ctx context.Context) error {
+	url := joinURL(c.urlBase, urlHealth)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, errors.New("failed to parse inventory device(s)")
+		return errors.Wrapf(err, "failed to create request")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rsp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf(
+			"%s %s request failed with status %v", req.Method, req.URL, rsp.Status)
+	}
+
+	return nil
+}
+
+func (c *client) SetDeviceTags(
+	ctx context.Context,
+	tid, devID string,
+	tags model.DeviceTags,
+) error {
+	attrs := make([]model.InvDeviceAttribute, len(tags))
+	for i, tag := range tags {
+		attrs[i] = model.InvDeviceAttribute{
+			Name:  tag.Name,
+			Value: tag.Value,
+			Scope: model.AttrScopeTags,
+		}
+	}
+	body, err := json.Marshal(attrs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to serialize device tags")
+	}
+
+	url := joinURL(c.urlBase, urlDeviceTags)
+	url = strings.Replace(url, ":tid", tid, 1)
+	url = strings.Replace(url, ":id", devID, 1)
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rsp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf(
+			"%s %s request failed with status %v", req.Method, req.URL, rsp.Status)
 	}
 
-	return invDevs, nil
+	return nil
 }
 
 func joinURL(base, url string) string {
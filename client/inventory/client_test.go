@@ -0,0 +1,69 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inventory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+func TestListDevices(t *testing.T) {
+	total := "3"
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/internal/v2/inventory/tenants/foo/filters/search",
+				r.URL.Path)
+			assert.Equal(t, "HTTP/2.0", r.Proto)
+			if total != "" {
+				w.Header().Set(hdrTotalCount, total)
+			}
+			_, _ = w.Write([]byte(`[
+				{"id": "dev-1", "attributes": [
+					{"scope": "inventory", "name": "device_type", "value": "qemu"}
+				]},
+				{"id": "dev-2"}
+			]`))
+		}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := NewClient(srv.URL, true)
+	ctx := context.Background()
+
+	devs, count, err := c.ListDevices(ctx, "foo", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, devs, 2)
+	assert.Equal(t, model.DeviceID("dev-1"), devs[0].ID)
+
+	// the page size doesn't stand for the missing total
+	for _, total = range []string{"", "many", "-1"} {
+		_, _, err = c.ListDevices(ctx, "foo", 1, 2)
+		assert.Equal(t, ErrTotalCountInvalid, errors.Cause(err))
+	}
+
+	// the total isn't needed to get the devices by ID
+	devs, err = c.GetDevices(ctx, "foo", []string{"dev-1", "dev-2"})
+	assert.NoError(t, err)
+	assert.Len(t, devs, 2)
+}
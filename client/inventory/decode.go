@@ -0,0 +1,124 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrResponseTooLarge  = errors.New("inventory response too large")
+	ErrTotalCountInvalid = errors.New("inventory response without a valid X-Total-Count")
+)
+
+// limitedReader fails the reads past 'n' bytes,
+// unlike io.LimitReader which ends with a silent EOF
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// decodeDevices decodes the devices array one device at a time; the
+// devices larger than 'maxDevice' bytes are truncated, keeping the
+// attributes fitting in the limit, a limit of 0 keeps all of them
+func decodeDevices(r io.Reader, maxDevice int64) ([]model.InvDevice, []model.DeviceID, error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, err
+	} else if tok == nil {
+		// null
+		return nil, nil, nil
+	} else if tok != json.Delim('[') {
+		return nil, nil, errors.Errorf("expected an array, got %v", tok)
+	}
+
+	var (
+		devs      = []model.InvDevice{}
+		truncated []model.DeviceID
+	)
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+
+		var dev model.InvDevice
+		if maxDevice > 0 && int64(len(raw)) > maxDevice {
+			var err error
+			dev, err = truncateDevice(raw, maxDevice)
+			if err != nil {
+				return nil, nil, err
+			}
+			truncated = append(truncated, dev.ID)
+		} else if err := json.Unmarshal(raw, &dev); err != nil {
+			return nil, nil, err
+		}
+		devs = append(devs, dev)
+	}
+
+	// the closing bracket
+	if _, err := dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	return devs, truncated, nil
+}
+
+// truncateDevice decodes the device keeping the attributes, in order,
+// which fit in 'maxDevice' bytes in total, skipping the others
+func truncateDevice(raw json.RawMessage, maxDevice int64) (model.InvDevice, error) {
+	var dev struct {
+		model.InvDevice
+		Attributes []json.RawMessage `json:"attributes"`
+	}
+	if err := json.Unmarshal(raw, &dev); err != nil {
+		return model.InvDevice{}, err
+	}
+
+	kept := make([][]byte, 0, len(dev.Attributes))
+	size := int64(0)
+	for _, attr := range dev.Attributes {
+		if size+int64(len(attr)) > maxDevice {
+			continue
+		}
+		size += int64(len(attr))
+		kept = append(kept, attr)
+	}
+
+	attrs := append([]byte{'['}, bytes.Join(kept, []byte{','})...)
+	attrs = append(attrs, ']')
+	if err := json.Unmarshal(attrs, &dev.InvDevice.Attributes); err != nil {
+		return model.InvDevice{}, err
+	}
+	return dev.InvDevice, nil
+}
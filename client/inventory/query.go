@@ -18,3 +18,10 @@ package inventory
 type GetDevsReq struct {
 	DeviceIDs []string `json:"device_ids"`
 }
+
+//ListDevsReq is an inventory search query without filters,
+// paging through all the tenant devices
+type ListDevsReq struct {
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+}
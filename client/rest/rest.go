@@ -0,0 +1,91 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package rest holds the HTTP/JSON helpers of the clients of the
+// internal APIs of the other services
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var (
+	// ErrNotFound is returned for the resources unknown to the service
+	ErrNotFound = errors.New("resource not found")
+)
+
+// JoinURL joins the service base URL and the API path
+func JoinURL(base, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if !strings.HasSuffix(base, "/") {
+		base = base + "/"
+	}
+	return base + path
+}
+
+// GetJSON gets the JSON resource at 'rawURL', with the 'query'
+// parameters, if any, into 'v'; returns ErrNotFound on 404, and
+// logs the response of the other failed requests
+func GetJSON(ctx context.Context, client *http.Client, rawURL string,
+	query url.Values, v interface{}) error {
+	l := log.FromContext(ctx)
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rsp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "failed to submit %s %s", req.Method, req.URL)
+	}
+	defer rsp.Body.Close()
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		body = []byte("<failed to read>")
+	}
+
+	if rsp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	} else if rsp.StatusCode != http.StatusOK {
+		l.Errorf("request %s %s failed with status %v, response: %s",
+			req.Method, req.URL, rsp.Status, body)
+
+		return errors.Errorf(
+			"%s %s request failed with status %v", req.Method, req.URL, rsp.Status)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrapf(err, "failed to parse the %s %s response", req.Method, req.URL)
+	}
+	return nil
+}
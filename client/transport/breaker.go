@@ -0,0 +1,126 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Breaker stops the requests to a failing service: after 'threshold'
+// consecutive failures (transport errors or 5xx responses) the circuit
+// opens, and the requests fail immediately with ErrCircuitOpen for
+// 'cooldown'; then a single trial request closes the circuit on
+// success, or opens it again
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Wrap returns the round tripper guarded by the breaker
+func (b *Breaker) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &breakerTransport{
+		RoundTripper: rt,
+		breaker:      b,
+	}
+}
+
+// Open tells if the circuit is open
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
+
+// allow tells if the request may go, and if it's the trial request
+func (b *Breaker) allow() (ok, trial bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.trial = true
+	return true, true
+}
+
+// done records the outcome of a request; only the trial request
+// ends the trial, the requests sent before the circuit opened don't
+func (b *Breaker) done(trial, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if trial {
+		b.trial = false
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a request without an outcome
+func (b *Breaker) release(trial bool) {
+	if !trial {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+type breakerTransport struct {
+	http.RoundTripper
+	breaker *Breaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ok, trial := t.breaker.allow()
+	if !ok {
+		return nil, errors.Wrapf(ErrCircuitOpen, "%s %s", req.Method, req.URL)
+	}
+
+	rsp, err := t.RoundTripper.RoundTrip(req)
+	// the canceled requests tell nothing about the service
+	if err != nil && req.Context().Err() != nil {
+		t.breaker.release(trial)
+		return rsp, err
+	}
+	t.breaker.done(trial, err != nil || rsp.StatusCode >= http.StatusInternalServerError)
+
+	return rsp, err
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	rt := NewBreaker(2, time.Hour).Wrap(roundTripperFunc(
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status}, nil
+		}))
+	req, _ := http.NewRequest(http.MethodGet, "http://inventory/foo", nil)
+
+	for i := 0; i < 2; i++ {
+		rsp, err := rt.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, rsp.StatusCode)
+	}
+
+	// open until the cooldown ends
+	status = http.StatusOK
+	_, err := rt.RoundTrip(req)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+}
+
+func TestBreakerTrial(t *testing.T) {
+	b := NewBreaker(1, 0)
+
+	okA, trialA := b.allow()
+	assert.True(t, okA)
+	assert.False(t, trialA)
+
+	// B fails while A is in flight, opening the circuit
+	okB, trialB := b.allow()
+	assert.True(t, okB)
+	b.done(trialB, true)
+	assert.True(t, b.Open())
+
+	// a single trial request after the cooldown
+	okC, trialC := b.allow()
+	assert.True(t, okC)
+	assert.True(t, trialC)
+
+	// A, sent before the circuit opened, doesn't end the trial
+	b.done(trialA, true)
+	ok, _ := b.allow()
+	assert.False(t, ok)
+	b.release(false)
+	ok, _ = b.allow()
+	assert.False(t, ok)
+
+	// the trial success closes the circuit
+	b.done(trialC, false)
+	assert.False(t, b.Open())
+	ok, trial := b.allow()
+	assert.True(t, ok)
+	assert.False(t, trial)
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Faults configures the fault injection into the outbound requests,
+// to exercise the resilience paths in the integration tests and
+// in staging; never enable it in production
+type Faults struct {
+	// Latency is added before each request
+	Latency time.Duration
+	// ErrorRate is the share of the requests failing
+	// with a transport error, without being sent
+	ErrorRate float64
+	// PartialRate is the share of the responses with
+	// the body truncated to its first half
+	PartialRate float64
+}
+
+func (f Faults) enabled() bool {
+	return f.Latency > 0 || f.ErrorRate > 0 || f.PartialRate > 0
+}
+
+var ErrFaultInjected = errors.New("fault injected")
+
+// Wrap returns the round tripper injecting the faults into the
+// requests of 'rt', or 'rt' itself if none are configured
+func Wrap(rt http.RoundTripper, faults Faults) http.RoundTripper {
+	if !faults.enabled() {
+		return rt
+	}
+	return &faultyTransport{
+		RoundTripper: rt,
+		faults:       faults,
+	}
+}
+
+type faultyTransport struct {
+	http.RoundTripper
+	faults Faults
+}
+
+func (t *faultyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.faults.Latency > 0 {
+		timer := time.NewTimer(t.faults.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	if rand.Float64() < t.faults.ErrorRate {
+		return nil, errors.Wrapf(ErrFaultInjected, "%s %s", req.Method, req.URL)
+	}
+
+	rsp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil || rand.Float64() >= t.faults.PartialRate {
+		return rsp, err
+	}
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = body[:len(body)/2]
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	rsp.ContentLength = int64(len(body))
+	rsp.Header.Del("Content-Length")
+
+	return rsp, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package transport
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			_, _ = gz.Write([]byte(`{"proto": "` + r.Proto + `"}`))
+			gz.Close()
+		}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	rt := New(true)
+	assert.NotNil(t, rt.(*http.Transport).Proxy)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	rsp, err := rt.RoundTrip(req)
+	assert.NoError(t, err)
+	defer rsp.Body.Close()
+
+	// HTTP/2 is negotiated, the response is decompressed
+	assert.Equal(t, 2, rsp.ProtoMajor)
+	assert.True(t, rsp.Uncompressed)
+	body, err := ioutil.ReadAll(rsp.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"proto": "HTTP/2.0"}`, string(body))
+
+	// the server certificate is verified, unless skipped
+	_, err = New(false).RoundTrip(req)
+	assert.Error(t, err)
+}
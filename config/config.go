@@ -24,14 +24,401 @@ const (
 	// SettingListenDefault is the default value for the listen address
 	SettingListenDefault = ":8080"
 
+	// SettingSearchMaxBodySize is the config key for the maximum request
+	// body size, in bytes, of the search endpoints
+	SettingSearchMaxBodySize = "search_max_body_size"
+	// SettingSearchMaxBodySizeDefault is the default maximum search body size (1 MiB)
+	SettingSearchMaxBodySizeDefault = 1 << 20
+
+	// SettingBatchSearchMaxBodySize is the config key for the maximum
+	// request body size, in bytes, of the batch search endpoint
+	SettingBatchSearchMaxBodySize = "batch_search_max_body_size"
+	// SettingBatchSearchMaxBodySizeDefault is the default maximum batch search body size (4 MiB)
+	SettingBatchSearchMaxBodySizeDefault = 4 << 20
+
+	// SettingStrictJSON is the config key for rejecting the search
+	// request bodies with unknown fields
+	SettingStrictJSON = "strict_json"
+	// SettingStrictJSONDefault is the default strict JSON parsing (disabled)
+	SettingStrictJSONDefault = false
+
+	// SettingDeploymentSize is the config key for the deployment size
+	// profile (small, medium or large) setting the tuned defaults
+	SettingDeploymentSize = "deployment_size"
+	// SettingDeploymentSizeDefault is the default deployment size (none)
+	SettingDeploymentSizeDefault = ""
+
 	// SettingElasticsearchAddresses is the config key for the elasticsearch addresses
 	SettingElasticsearchAddresses = "elasticsearch_addresses"
 	// SettingElasticsearchAddressesDefault is the default value for the elasticsearch addresses
 	SettingElasticsearchAddressesDefault = "http://localhost:9200"
 
+	// SettingElasticsearchReplicaAddresses is the config key for the addresses
+	// of the replica cluster the searches fail over to
+	SettingElasticsearchReplicaAddresses = "elasticsearch_replica_addresses"
+	// SettingElasticsearchReplicaAddressesDefault is the default value for
+	// the replica cluster addresses (no failover)
+	SettingElasticsearchReplicaAddressesDefault = ""
+
+	// SettingElasticsearchShards is the config key for the number of primary
+	// shards of newly created devices indices
+	SettingElasticsearchShards = "elasticsearch_shards"
+	// SettingElasticsearchShardsDefault is the default number of primary shards
+	SettingElasticsearchShardsDefault = 1
+
+	// SettingElasticsearchReplicas is the config key for the number of
+	// replicas of the devices indices
+	SettingElasticsearchReplicas = "elasticsearch_replicas"
+	// SettingElasticsearchReplicasDefault is the default number of replicas
+	SettingElasticsearchReplicasDefault = 1
+
+	// SettingElasticsearchSearchPreference is the config key for the
+	// ES search preference, e.g. "_local"
+	SettingElasticsearchSearchPreference = "elasticsearch_search_preference"
+	// SettingElasticsearchSearchPreferenceDefault is the default search preference (none)
+	SettingElasticsearchSearchPreferenceDefault = ""
+
+	// SettingElasticsearchPreferredNodes is the config key for the ES node
+	// filter of the nodes searched first, e.g. "zone:eu-west-1a"
+	SettingElasticsearchPreferredNodes = "elasticsearch_preferred_nodes"
+	// SettingElasticsearchPreferredNodesDefault is the default preferred nodes filter (none)
+	SettingElasticsearchPreferredNodesDefault = ""
+
+	// SettingElasticsearchAdaptiveReplicaSelection is the config key for
+	// the cluster adaptive replica selection, applied with the settings
+	SettingElasticsearchAdaptiveReplicaSelection = "elasticsearch_adaptive_replica_selection"
+	// SettingElasticsearchAdaptiveReplicaSelectionDefault leaves the
+	// cluster setting as it is
+	SettingElasticsearchAdaptiveReplicaSelectionDefault = ""
+
+	// SettingElasticsearchSearchConcurrency is the config key for the
+	// maximum number of concurrent searches, 0 for no limit
+	SettingElasticsearchSearchConcurrency = "elasticsearch_search_concurrency"
+	// SettingElasticsearchSearchConcurrencyDefault is the default search concurrency (no limit)
+	SettingElasticsearchSearchConcurrencyDefault = 0
+
+	// SettingElasticsearchSearchQueue is the config key for the maximum
+	// number of searches waiting for a free concurrency slot
+	SettingElasticsearchSearchQueue = "elasticsearch_search_queue"
+	// SettingElasticsearchSearchQueueDefault is the default search queue size
+	SettingElasticsearchSearchQueueDefault = 100
+
+	// SettingElasticsearchSearchQueueTimeout is the config key for the
+	// maximum wait of a queued search, before it is rejected
+	SettingElasticsearchSearchQueueTimeout = "elasticsearch_search_queue_timeout"
+	// SettingElasticsearchSearchQueueTimeoutDefault is the default search queue timeout
+	SettingElasticsearchSearchQueueTimeoutDefault = "2s"
+
+	// SettingElasticsearchRoutingByTenant is the config key for routing
+	// the devices documents by tenant ID
+	SettingElasticsearchRoutingByTenant = "elasticsearch_routing_by_tenant"
+	// SettingElasticsearchRoutingByTenantDefault is the default value for routing by tenant
+	SettingElasticsearchRoutingByTenantDefault = false
+
+	// SettingElasticsearchIndexPrefix is the config key for the prefix of the
+	// index and index template names, e.g. the environment
+	SettingElasticsearchIndexPrefix = "elasticsearch_index_prefix"
+	// SettingElasticsearchIndexPrefixDefault is the default index name prefix (none)
+	SettingElasticsearchIndexPrefixDefault = ""
+
+	// SettingElasticsearchIndexSuffix is the config key for the suffix of the
+	// index and index template names, e.g. a version
+	SettingElasticsearchIndexSuffix = "elasticsearch_index_suffix"
+	// SettingElasticsearchIndexSuffixDefault is the default index name suffix (none)
+	SettingElasticsearchIndexSuffixDefault = ""
+
+	// SettingAttributeAnalyzers is the config key for the list of string attributes
+	// analyzed at index time, in the form "scope/name:analyzer"
+	SettingAttributeAnalyzers = "attribute_analyzers"
+	// SettingAttributeAnalyzersDefault is the default value for the analyzed attributes
+	SettingAttributeAnalyzersDefault = ""
+
+	// SettingAttributeNormalizers is the config key for the list of string
+	// attributes normalized at index and query time, in the form "scope/name:normalizer"
+	SettingAttributeNormalizers = "attribute_normalizers"
+	// SettingAttributeNormalizersDefault is the default value for the normalized attributes
+	SettingAttributeNormalizersDefault = ""
+
+	// SettingRedactedAttributes is the config key for the list of string attributes
+	// redacted at index time, in the form "scope/name:method"
+	SettingRedactedAttributes = "redacted_attributes"
+	// SettingRedactedAttributesDefault is the default value for the redacted attributes
+	SettingRedactedAttributesDefault = ""
+	// SettingRedactionKey is the config key for the secret key
+	// of the hash redaction
+	SettingRedactionKey = "redaction_key"
+	// SettingRedactionKeyDefault is the default value for the redaction key
+	SettingRedactionKeyDefault = ""
+
+	// SettingNestedAttributes is the config key for the list of object
+	// attributes indexed as nested objects, in the form "scope/name"
+	SettingNestedAttributes = "nested_attributes"
+	// SettingNestedAttributesDefault is the default value for the nested attributes
+	SettingNestedAttributesDefault = ""
+
+	// SettingHiddenAttributes is the config key for the list of "scope/name"
+	// glob patterns of the attributes stripped from the API responses
+	SettingHiddenAttributes = "hidden_attributes"
+	// SettingHiddenAttributesDefault is the default value for the hidden attributes
+	SettingHiddenAttributesDefault = ""
+
+	// SettingMaxAttributeValues is the config key for the maximum number
+	// of values returned per multi-valued attribute, 0 for no limit
+	SettingMaxAttributeValues = "max_attribute_values"
+	// SettingMaxAttributeValuesDefault is the default attribute values limit
+	SettingMaxAttributeValuesDefault = 0
+
+	// SettingDeviceIDsLookupThreshold is the config key for the number of
+	// search device IDs above which they are stored in a terms lookup
+	// document instead of inlined in the query, 0 to always inline them
+	SettingDeviceIDsLookupThreshold = "device_ids_lookup_threshold"
+	// SettingDeviceIDsLookupThresholdDefault is the default device IDs lookup threshold
+	SettingDeviceIDsLookupThresholdDefault = 1000
+
+	// SettingDeviceRetention is the config key for the list of per-tenant device
+	// retention periods, in the form "tenant_id:days"
+	SettingDeviceRetention = "device_retention"
+	// SettingDeviceRetentionDefault is the default value for the device retention
+	SettingDeviceRetentionDefault = ""
+
+	// SettingDeviceRetentionInterval is the config key for the interval
+	// between the runs of the stale devices purge job
+	SettingDeviceRetentionInterval = "device_retention_interval"
+	// SettingDeviceRetentionIntervalDefault is the default value for the purge job interval
+	SettingDeviceRetentionIntervalDefault = "1h"
+
+	// SettingDeviceAgeInterval is the config key for the interval between
+	// the refreshes of the device age attributes, 0 to disable them
+	SettingDeviceAgeInterval = "device_age_interval"
+	// SettingDeviceAgeIntervalDefault is the default age refresh interval
+	SettingDeviceAgeIntervalDefault = "24h"
+
+	// SettingDefaultSort is the config key for the default sort of the
+	// searches without an explicit one, as "scope/attribute[:asc|desc]"
+	SettingDefaultSort = "default_sort"
+	// SettingDefaultSortDefault is the default value for the default sort (by score)
+	SettingDefaultSortDefault = ""
+
+	// SettingDefaultSortTenants is the config key for the per-tenant default
+	// sort, as "tenant_id:scope/attribute[:asc|desc]"
+	SettingDefaultSortTenants = "default_sort_tenants"
+	// SettingDefaultSortTenantsDefault is the default value for the per-tenant default sort
+	SettingDefaultSortTenantsDefault = ""
+
+	// SettingAnomalyInterval is the config key for the interval of the
+	// attribute distributions analysis, 0 to disable it
+	SettingAnomalyInterval = "anomaly_detection_interval"
+	// SettingAnomalyIntervalDefault is the default analysis interval (disabled)
+	SettingAnomalyIntervalDefault = "0s"
+
+	// SettingAnomalyAttributes is the config key for the list of
+	// "scope/name" attributes analyzed for anomalies
+	SettingAnomalyAttributes = "anomaly_attributes"
+	// SettingAnomalyAttributesDefault is the default list of analyzed attributes
+	SettingAnomalyAttributesDefault = "inventory/artifact_name inventory/device_type"
+
+	// SettingAnomalyRareShare is the config key for the share of the
+	// devices under which an attribute value is flagged as rare
+	SettingAnomalyRareShare = "anomaly_rare_share"
+	// SettingAnomalyRareShareDefault is the default rare value share (0.1%)
+	SettingAnomalyRareShareDefault = 0.001
+
+	// SettingAnomalyDropShare is the config key for the share of the
+	// devices lost by a value, since the last analysis, flagged as a drop
+	SettingAnomalyDropShare = "anomaly_drop_share"
+	// SettingAnomalyDropShareDefault is the default count drop share (50%)
+	SettingAnomalyDropShareDefault = 0.5
+
+	// SettingQuotaInterval is the config key for the interval of the
+	// tenants quota checks, 0 to disable them
+	SettingQuotaInterval = "quota_check_interval"
+	// SettingQuotaIntervalDefault is the default quota check interval (disabled)
+	SettingQuotaIntervalDefault = "0s"
+
+	// SettingQuotaMaxDevices is the config key for the devices quota
+	// of the tenants, 0 for none
+	SettingQuotaMaxDevices = "quota_max_devices"
+	// SettingQuotaMaxDevicesDefault is the default devices quota (none)
+	SettingQuotaMaxDevicesDefault = 0
+
+	// SettingQuotaAlertShare is the config key for the share of a quota
+	// over which an alert is raised
+	SettingQuotaAlertShare = "quota_alert_share"
+	// SettingQuotaAlertShareDefault is the default quota alert share (80%)
+	SettingQuotaAlertShareDefault = 0.8
+
+	// SettingQuotaWebhookURL is the config key for the URL the quota
+	// alerts are POSTed to
+	SettingQuotaWebhookURL = "quota_webhook_url"
+	// SettingQuotaWebhookURLDefault is the default value for the quota alerts URL (disabled)
+	SettingQuotaWebhookURLDefault = ""
+
+	// SettingMaintenanceWindow is the config key for the daily off-peak
+	// window, in UTC, the devices indices are optimized in, e.g. "02:00-04:00"
+	SettingMaintenanceWindow = "maintenance_window"
+	// SettingMaintenanceWindowDefault is the default maintenance window (disabled)
+	SettingMaintenanceWindowDefault = ""
+
+	// SettingEventsWebhookURL is the config key for the URL the device change
+	// events are POSTed to
+	SettingEventsWebhookURL = "events_webhook_url"
+	// SettingEventsWebhookURLDefault is the default value for the events URL (disabled)
+	SettingEventsWebhookURLDefault = ""
+
+	// SettingWarmUp is the config key for warming up the devices indices
+	// of all the tenants on startup, before serving requests
+	SettingWarmUp = "warmup"
+	// SettingWarmUpDefault is the default value for the startup warm-up
+	SettingWarmUpDefault = false
+
+	// SettingWarmUpTimeout is the config key for the maximum duration of
+	// the startup warm-up
+	SettingWarmUpTimeout = "warmup_timeout"
+	// SettingWarmUpTimeoutDefault is the default value for the warm-up timeout
+	SettingWarmUpTimeoutDefault = "1m"
+
+	// SettingShutdownTimeout is the config key for the maximum duration
+	// to wait for the inflight requests to complete on shutdown
+	SettingShutdownTimeout = "shutdown_timeout"
+	// SettingShutdownTimeoutDefault is the default value for the shutdown timeout
+	SettingShutdownTimeoutDefault = "30s"
+
+	// SettingFaultInjectionLatency is the config key for the latency
+	// injected into the Elasticsearch and the services requests
+	SettingFaultInjectionLatency = "fault_injection_latency"
+	// SettingFaultInjectionLatencyDefault is the default injected latency (none)
+	SettingFaultInjectionLatencyDefault = "0s"
+
+	// SettingFaultInjectionErrorRate is the config key for the share
+	// of the requests failing with an injected transport error
+	SettingFaultInjectionErrorRate = "fault_injection_error_rate"
+	// SettingFaultInjectionErrorRateDefault is the default injected error rate (none)
+	SettingFaultInjectionErrorRateDefault = 0.0
+
+	// SettingFaultInjectionPartialRate is the config key for the share
+	// of the responses with an injected truncated body
+	SettingFaultInjectionPartialRate = "fault_injection_partial_rate"
+	// SettingFaultInjectionPartialRateDefault is the default injected partial rate (none)
+	SettingFaultInjectionPartialRateDefault = 0.0
+
 	SettingInventoryAddr        = "inventory_addr"
 	SettingInventoryAddrDefault = "http://mender-inventory:8080/"
 
+	// SettingInventoryMaxResponseSize is the config key for the maximum
+	// size, in bytes, of the inventory search responses, rejected if larger
+	SettingInventoryMaxResponseSize = "inventory_max_response_size"
+	// SettingInventoryMaxResponseSizeDefault is the default maximum response size (32 MiB)
+	SettingInventoryMaxResponseSizeDefault = 32 << 20
+
+	// SettingInventoryMaxDeviceSize is the config key for the maximum size,
+	// in bytes, of an inventory device, with the attributes truncated if larger
+	SettingInventoryMaxDeviceSize = "inventory_max_device_size"
+	// SettingInventoryMaxDeviceSizeDefault is the default maximum device size (1 MiB)
+	SettingInventoryMaxDeviceSizeDefault = 1 << 20
+
+	// SettingInventoryBreakerThreshold is the config key for the number of
+	// consecutive inventory request failures opening the circuit breaker,
+	// 0 to disable it
+	SettingInventoryBreakerThreshold = "inventory_breaker_threshold"
+	// SettingInventoryBreakerThresholdDefault is the default breaker threshold
+	SettingInventoryBreakerThresholdDefault = 5
+
+	// SettingInventoryBreakerCooldown is the config key for the time the
+	// inventory requests fail immediately once the circuit breaker opens
+	SettingInventoryBreakerCooldown = "inventory_breaker_cooldown"
+	// SettingInventoryBreakerCooldownDefault is the default breaker cooldown
+	SettingInventoryBreakerCooldownDefault = "30s"
+
+	SettingDeviceauthAddr        = "deviceauth_addr"
+	SettingDeviceauthAddrDefault = "http://mender-device-auth:8080/"
+
+	// SettingIdentityAttributes is the config key for the list of identity data
+	// fields, fetched from deviceauth, indexed as identity attributes
+	SettingIdentityAttributes = "identity_attributes"
+	// SettingIdentityAttributesDefault is the default value for the identity attributes
+	SettingIdentityAttributesDefault = ""
+
+	SettingDevicemonitorAddr        = "devicemonitor_addr"
+	SettingDevicemonitorAddrDefault = "http://mender-devicemonitor:8080/"
+
+	// SettingMonitorAlerts is the config key for indexing the summary
+	// of the device open alerts, fetched from devicemonitor
+	SettingMonitorAlerts = "monitor_alerts"
+	// SettingMonitorAlertsDefault is the default value for the alerts indexing
+	SettingMonitorAlertsDefault = false
+
+	SettingDeploymentsAddr        = "deployments_addr"
+	SettingDeploymentsAddrDefault = "http://mender-deployments:8080/"
+
+	// SettingIndexDeployments is the config key for indexing the summary
+	// of the device deployments, fetched from deployments
+	SettingIndexDeployments = "index_deployments"
+	// SettingIndexDeploymentsDefault is the default value for the deployments indexing
+	SettingIndexDeploymentsDefault = false
+
+	SettingDeviceconfigAddr        = "deviceconfig_addr"
+	SettingDeviceconfigAddrDefault = "http://mender-deviceconfig:8080/"
+
+	// SettingIndexConfiguration is the config key for indexing the reported
+	// and desired device configuration, fetched from deviceconfig
+	SettingIndexConfiguration = "index_configuration"
+	// SettingIndexConfigurationDefault is the default value for the configuration indexing
+	SettingIndexConfigurationDefault = false
+
+	// SettingPropagateTags is the config key for propagating the device
+	// tags set through reporting to inventory
+	SettingPropagateTags = "propagate_tags"
+	// SettingPropagateTagsDefault is the default value for the tags propagation
+	SettingPropagateTagsDefault = false
+
+	// SettingMaxTagNames is the config key for the number of distinct tag
+	// names of a tenant, each mapped to an index field, 0 for no limit
+	SettingMaxTagNames = "max_tag_names"
+	// SettingMaxTagNamesDefault is the default number of distinct tag names
+	SettingMaxTagNamesDefault = 100
+
+	// SettingReindexDedupSize is the config key for the number of recent
+	// reindex event IDs remembered to skip the redelivered events, 0 to disable
+	SettingReindexDedupSize = "reindex_dedup_size"
+	// SettingReindexDedupSizeDefault is the default number of remembered events
+	SettingReindexDedupSizeDefault = 10000
+
+	// SettingReindexDedupTTL is the config key for how long
+	// the reindex event IDs are remembered
+	SettingReindexDedupTTL = "reindex_dedup_ttl"
+	// SettingReindexDedupTTLDefault is the default event ID retention
+	SettingReindexDedupTTLDefault = "10m"
+
+	// SettingFeaturesCacheTTL is the config key for how long the tenant
+	// feature flags are cached, 0 to disable the cache
+	SettingFeaturesCacheTTL = "features_cache_ttl"
+	// SettingFeaturesCacheTTLDefault is the default feature flags cache TTL
+	SettingFeaturesCacheTTLDefault = "30s"
+
+	// SettingAliasesCacheTTL is the config key for how long the tenant
+	// attribute aliases are cached, 0 to disable the cache
+	SettingAliasesCacheTTL = "aliases_cache_ttl"
+	// SettingAliasesCacheTTLDefault is the default attribute aliases cache TTL
+	SettingAliasesCacheTTLDefault = "30s"
+
+	// SettingIndexerBulkMinSize is the config key for the minimum
+	// number of devices per bulk request of the indexer
+	SettingIndexerBulkMinSize = "indexer_bulk_min_size"
+	// SettingIndexerBulkMinSizeDefault is the default minimum bulk size
+	SettingIndexerBulkMinSizeDefault = 50
+
+	// SettingIndexerBulkMaxSize is the config key for the maximum
+	// number of devices per bulk request of the indexer
+	SettingIndexerBulkMaxSize = "indexer_bulk_max_size"
+	// SettingIndexerBulkMaxSizeDefault is the default maximum bulk size
+	SettingIndexerBulkMaxSizeDefault = 2000
+
+	// SettingIndexerBulkTargetLatency is the config key for the bulk request
+	// latency the indexer sizes the bulk requests for
+	SettingIndexerBulkTargetLatency = "indexer_bulk_target_latency"
+	// SettingIndexerBulkTargetLatencyDefault is the default target latency
+	SettingIndexerBulkTargetLatencyDefault = "1s"
+
 	// SettingDebugLog is the config key for the truning on the debug log
 	SettingDebugLog = "debug_log"
 	// SettingDebugLogDefault is the default value for the debug log enabling
@@ -42,8 +429,77 @@ var (
 	// Defaults are the default configuration settings
 	Defaults = []config.Default{
 		{Key: SettingListen, Value: SettingListenDefault},
+		{Key: SettingSearchMaxBodySize, Value: SettingSearchMaxBodySizeDefault},
+		{Key: SettingBatchSearchMaxBodySize, Value: SettingBatchSearchMaxBodySizeDefault},
+		{Key: SettingStrictJSON, Value: SettingStrictJSONDefault},
+		{Key: SettingDeploymentSize, Value: SettingDeploymentSizeDefault},
 		{Key: SettingElasticsearchAddresses, Value: SettingElasticsearchAddressesDefault},
+		{Key: SettingElasticsearchReplicaAddresses, Value: SettingElasticsearchReplicaAddressesDefault},
+		{Key: SettingElasticsearchShards, Value: SettingElasticsearchShardsDefault},
+		{Key: SettingElasticsearchReplicas, Value: SettingElasticsearchReplicasDefault},
+		{Key: SettingElasticsearchRoutingByTenant, Value: SettingElasticsearchRoutingByTenantDefault},
+		{Key: SettingElasticsearchSearchPreference, Value: SettingElasticsearchSearchPreferenceDefault},
+		{Key: SettingElasticsearchPreferredNodes, Value: SettingElasticsearchPreferredNodesDefault},
+		{Key: SettingElasticsearchAdaptiveReplicaSelection,
+			Value: SettingElasticsearchAdaptiveReplicaSelectionDefault},
+		{Key: SettingElasticsearchSearchConcurrency,
+			Value: SettingElasticsearchSearchConcurrencyDefault},
+		{Key: SettingElasticsearchSearchQueue, Value: SettingElasticsearchSearchQueueDefault},
+		{Key: SettingElasticsearchSearchQueueTimeout,
+			Value: SettingElasticsearchSearchQueueTimeoutDefault},
+		{Key: SettingElasticsearchIndexPrefix, Value: SettingElasticsearchIndexPrefixDefault},
+		{Key: SettingElasticsearchIndexSuffix, Value: SettingElasticsearchIndexSuffixDefault},
 		{Key: SettingDebugLog, Value: SettingDebugLogDefault},
 		{Key: SettingInventoryAddr, Value: SettingInventoryAddrDefault},
+		{Key: SettingInventoryMaxResponseSize, Value: SettingInventoryMaxResponseSizeDefault},
+		{Key: SettingInventoryMaxDeviceSize, Value: SettingInventoryMaxDeviceSizeDefault},
+		{Key: SettingInventoryBreakerThreshold, Value: SettingInventoryBreakerThresholdDefault},
+		{Key: SettingInventoryBreakerCooldown, Value: SettingInventoryBreakerCooldownDefault},
+		{Key: SettingDeviceauthAddr, Value: SettingDeviceauthAddrDefault},
+		{Key: SettingIdentityAttributes, Value: SettingIdentityAttributesDefault},
+		{Key: SettingDevicemonitorAddr, Value: SettingDevicemonitorAddrDefault},
+		{Key: SettingMonitorAlerts, Value: SettingMonitorAlertsDefault},
+		{Key: SettingDeploymentsAddr, Value: SettingDeploymentsAddrDefault},
+		{Key: SettingIndexDeployments, Value: SettingIndexDeploymentsDefault},
+		{Key: SettingDeviceconfigAddr, Value: SettingDeviceconfigAddrDefault},
+		{Key: SettingIndexConfiguration, Value: SettingIndexConfigurationDefault},
+		{Key: SettingPropagateTags, Value: SettingPropagateTagsDefault},
+		{Key: SettingMaxTagNames, Value: SettingMaxTagNamesDefault},
+		{Key: SettingReindexDedupSize, Value: SettingReindexDedupSizeDefault},
+		{Key: SettingReindexDedupTTL, Value: SettingReindexDedupTTLDefault},
+		{Key: SettingFeaturesCacheTTL, Value: SettingFeaturesCacheTTLDefault},
+		{Key: SettingAliasesCacheTTL, Value: SettingAliasesCacheTTLDefault},
+		{Key: SettingIndexerBulkMinSize, Value: SettingIndexerBulkMinSizeDefault},
+		{Key: SettingIndexerBulkMaxSize, Value: SettingIndexerBulkMaxSizeDefault},
+		{Key: SettingIndexerBulkTargetLatency, Value: SettingIndexerBulkTargetLatencyDefault},
+		{Key: SettingAttributeAnalyzers, Value: SettingAttributeAnalyzersDefault},
+		{Key: SettingAttributeNormalizers, Value: SettingAttributeNormalizersDefault},
+		{Key: SettingRedactedAttributes, Value: SettingRedactedAttributesDefault},
+		{Key: SettingRedactionKey, Value: SettingRedactionKeyDefault},
+		{Key: SettingNestedAttributes, Value: SettingNestedAttributesDefault},
+		{Key: SettingHiddenAttributes, Value: SettingHiddenAttributesDefault},
+		{Key: SettingMaxAttributeValues, Value: SettingMaxAttributeValuesDefault},
+		{Key: SettingDeviceIDsLookupThreshold, Value: SettingDeviceIDsLookupThresholdDefault},
+		{Key: SettingDeviceRetention, Value: SettingDeviceRetentionDefault},
+		{Key: SettingDeviceRetentionInterval, Value: SettingDeviceRetentionIntervalDefault},
+		{Key: SettingDeviceAgeInterval, Value: SettingDeviceAgeIntervalDefault},
+		{Key: SettingDefaultSort, Value: SettingDefaultSortDefault},
+		{Key: SettingDefaultSortTenants, Value: SettingDefaultSortTenantsDefault},
+		{Key: SettingAnomalyInterval, Value: SettingAnomalyIntervalDefault},
+		{Key: SettingAnomalyAttributes, Value: SettingAnomalyAttributesDefault},
+		{Key: SettingAnomalyRareShare, Value: SettingAnomalyRareShareDefault},
+		{Key: SettingAnomalyDropShare, Value: SettingAnomalyDropShareDefault},
+		{Key: SettingQuotaInterval, Value: SettingQuotaIntervalDefault},
+		{Key: SettingQuotaMaxDevices, Value: SettingQuotaMaxDevicesDefault},
+		{Key: SettingQuotaAlertShare, Value: SettingQuotaAlertShareDefault},
+		{Key: SettingQuotaWebhookURL, Value: SettingQuotaWebhookURLDefault},
+		{Key: SettingMaintenanceWindow, Value: SettingMaintenanceWindowDefault},
+		{Key: SettingEventsWebhookURL, Value: SettingEventsWebhookURLDefault},
+		{Key: SettingWarmUp, Value: SettingWarmUpDefault},
+		{Key: SettingWarmUpTimeout, Value: SettingWarmUpTimeoutDefault},
+		{Key: SettingShutdownTimeout, Value: SettingShutdownTimeoutDefault},
+		{Key: SettingFaultInjectionLatency, Value: SettingFaultInjectionLatencyDefault},
+		{Key: SettingFaultInjectionErrorRate, Value: SettingFaultInjectionErrorRateDefault},
+		{Key: SettingFaultInjectionPartialRate, Value: SettingFaultInjectionPartialRateDefault},
 	}
 )
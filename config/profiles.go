@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package config
+
+import (
+	"github.com/mendersoftware/go-lib-micro/config"
+	"github.com/pkg/errors"
+)
+
+// deployment sizes
+const (
+	DeploymentSizeSmall  = "small"
+	DeploymentSizeMedium = "medium"
+	DeploymentSizeLarge  = "large"
+)
+
+// Profiles are the defaults tuned for each deployment size,
+// overriding the general defaults, but not the configured values;
+// the routing by tenant isn't part of them, as switching it on an
+// existing cluster strands the devices indexed with the other
+// routing, it must be set explicitly, on new clusters only
+var Profiles = map[string][]config.Default{
+	DeploymentSizeSmall: {
+		{Key: SettingElasticsearchShards, Value: 1},
+		{Key: SettingElasticsearchReplicas, Value: 0},
+		{Key: SettingElasticsearchSearchConcurrency, Value: 4},
+		{Key: SettingElasticsearchSearchQueue, Value: 50},
+		{Key: SettingIndexerBulkMinSize, Value: 20},
+		{Key: SettingIndexerBulkMaxSize, Value: 500},
+		{Key: SettingReindexDedupSize, Value: 1000},
+		{Key: SettingReindexDedupTTL, Value: "5m"},
+		{Key: SettingWarmUp, Value: false},
+		{Key: SettingShutdownTimeout, Value: "10s"},
+	},
+	DeploymentSizeMedium: {
+		{Key: SettingElasticsearchShards, Value: 2},
+		{Key: SettingElasticsearchReplicas, Value: 1},
+		{Key: SettingElasticsearchSearchConcurrency, Value: 16},
+		{Key: SettingElasticsearchSearchQueue, Value: 100},
+		{Key: SettingIndexerBulkMinSize, Value: 50},
+		{Key: SettingIndexerBulkMaxSize, Value: 2000},
+		{Key: SettingReindexDedupSize, Value: 10000},
+		{Key: SettingReindexDedupTTL, Value: "10m"},
+		{Key: SettingWarmUp, Value: true},
+		{Key: SettingWarmUpTimeout, Value: "1m"},
+		{Key: SettingShutdownTimeout, Value: "30s"},
+	},
+	DeploymentSizeLarge: {
+		{Key: SettingElasticsearchShards, Value: 5},
+		{Key: SettingElasticsearchReplicas, Value: 2},
+		{Key: SettingElasticsearchSearchConcurrency, Value: 64},
+		{Key: SettingElasticsearchSearchQueue, Value: 500},
+		{Key: SettingIndexerBulkMinSize, Value: 100},
+		{Key: SettingIndexerBulkMaxSize, Value: 5000},
+		{Key: SettingReindexDedupSize, Value: 100000},
+		{Key: SettingReindexDedupTTL, Value: "15m"},
+		{Key: SettingWarmUp, Value: true},
+		{Key: SettingWarmUpTimeout, Value: "5m"},
+		{Key: SettingShutdownTimeout, Value: "60s"},
+	},
+}
+
+// ApplyProfile sets the defaults of the configured deployment size, if any
+func ApplyProfile(c config.Handler) error {
+	size := c.GetString(SettingDeploymentSize)
+	if size == "" {
+		return nil
+	}
+
+	profile, ok := Profiles[size]
+	if !ok {
+		return errors.Errorf("%s: unknown deployment size %q", SettingDeploymentSize, size)
+	}
+	config.SetDefaults(c, profile)
+
+	return nil
+}
@@ -0,0 +1,319 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package config
+
+import (
+	"net/url"
+	"regexp"
+
+	"github.com/mendersoftware/go-lib-micro/config"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ES index names are lowercase, and can't start with '-', '_' or '+';
+	// the suffix can't contain '-' either, the tenant ID separator,
+	// so that the devices index patterns of the deployments don't overlap
+	indexPrefixRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	indexSuffixRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9._]*$`)
+
+	// Validators are the configuration validators, run on startup
+	Validators = []config.Validator{
+		validateListen,
+		validateElasticsearch,
+		validateInventory,
+		validateDeviceauth,
+		validateDevicemonitor,
+		validateDeployments,
+		validateDeviceconfig,
+		validateTags,
+		validateReindexDedup,
+		validateFeatures,
+		validateAliases,
+		validateIndexerBulk,
+		validateDeviceRetention,
+		validateDeviceAge,
+		validateMaxAttributeValues,
+		validateAnomalies,
+		validateEvents,
+		validateQuotas,
+		validateWarmUp,
+		validateShutdown,
+		validateFaultInjection,
+	}
+)
+
+func validateListen(c config.Reader) error {
+	if c.GetString(SettingListen) == "" {
+		return errors.Errorf("%s: must not be empty", SettingListen)
+	}
+	for _, key := range []string{SettingSearchMaxBodySize, SettingBatchSearchMaxBodySize} {
+		if c.GetInt(key) < 0 {
+			return errors.Errorf("%s: must not be negative", key)
+		}
+	}
+	return nil
+}
+
+func validateElasticsearch(c config.Reader) error {
+	addresses := c.GetStringSlice(SettingElasticsearchAddresses)
+	if len(addresses) == 0 {
+		return errors.Errorf("%s: at least one address is required",
+			SettingElasticsearchAddresses)
+	}
+	for _, addr := range addresses {
+		if err := validateURL(addr); err != nil {
+			return errors.Wrap(err, SettingElasticsearchAddresses)
+		}
+	}
+
+	for _, addr := range c.GetStringSlice(SettingElasticsearchReplicaAddresses) {
+		if err := validateURL(addr); err != nil {
+			return errors.Wrap(err, SettingElasticsearchReplicaAddresses)
+		}
+	}
+
+	if prefix := c.GetString(SettingElasticsearchIndexPrefix); prefix != "" &&
+		!indexPrefixRegexp.MatchString(prefix) {
+		return errors.Errorf("%s: invalid index name prefix %q",
+			SettingElasticsearchIndexPrefix, prefix)
+	}
+	if suffix := c.GetString(SettingElasticsearchIndexSuffix); suffix != "" &&
+		!indexSuffixRegexp.MatchString(suffix) {
+		return errors.Errorf("%s: invalid index name suffix %q",
+			SettingElasticsearchIndexSuffix, suffix)
+	}
+
+	if c.GetInt(SettingElasticsearchShards) < 1 {
+		return errors.Errorf("%s: must be at least 1", SettingElasticsearchShards)
+	}
+	if c.GetInt(SettingElasticsearchReplicas) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingElasticsearchReplicas)
+	}
+
+	switch v := c.GetString(SettingElasticsearchAdaptiveReplicaSelection); v {
+	case "", "true", "false":
+	default:
+		return errors.Errorf("%s: must be true or false, got %q",
+			SettingElasticsearchAdaptiveReplicaSelection, v)
+	}
+
+	if c.GetInt(SettingElasticsearchSearchConcurrency) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingElasticsearchSearchConcurrency)
+	}
+	if c.GetInt(SettingElasticsearchSearchQueue) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingElasticsearchSearchQueue)
+	}
+	if c.GetInt(SettingElasticsearchSearchConcurrency) > 0 &&
+		c.GetDuration(SettingElasticsearchSearchQueueTimeout) <= 0 {
+		return errors.Errorf("%s: must be a positive duration",
+			SettingElasticsearchSearchQueueTimeout)
+	}
+
+	return nil
+}
+
+func validateInventory(c config.Reader) error {
+	for _, key := range []string{SettingInventoryMaxResponseSize, SettingInventoryMaxDeviceSize,
+		SettingInventoryBreakerThreshold} {
+		if c.GetInt(key) < 0 {
+			return errors.Errorf("%s: must not be negative", key)
+		}
+	}
+	if c.GetInt(SettingInventoryBreakerThreshold) > 0 &&
+		c.GetDuration(SettingInventoryBreakerCooldown) <= 0 {
+		return errors.Errorf("%s: must be a positive duration", SettingInventoryBreakerCooldown)
+	}
+	return errors.Wrap(validateURL(c.GetString(SettingInventoryAddr)),
+		SettingInventoryAddr)
+}
+
+func validateDeviceauth(c config.Reader) error {
+	if len(c.GetStringSlice(SettingIdentityAttributes)) > 0 {
+		return errors.Wrap(validateURL(c.GetString(SettingDeviceauthAddr)),
+			SettingDeviceauthAddr)
+	}
+	return nil
+}
+
+func validateDevicemonitor(c config.Reader) error {
+	if c.GetBool(SettingMonitorAlerts) {
+		return errors.Wrap(validateURL(c.GetString(SettingDevicemonitorAddr)),
+			SettingDevicemonitorAddr)
+	}
+	return nil
+}
+
+func validateDeployments(c config.Reader) error {
+	if c.GetBool(SettingIndexDeployments) {
+		return errors.Wrap(validateURL(c.GetString(SettingDeploymentsAddr)),
+			SettingDeploymentsAddr)
+	}
+	return nil
+}
+
+func validateDeviceconfig(c config.Reader) error {
+	if c.GetBool(SettingIndexConfiguration) {
+		return errors.Wrap(validateURL(c.GetString(SettingDeviceconfigAddr)),
+			SettingDeviceconfigAddr)
+	}
+	return nil
+}
+
+func validateTags(c config.Reader) error {
+	if c.GetInt(SettingMaxTagNames) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingMaxTagNames)
+	}
+	return nil
+}
+
+func validateReindexDedup(c config.Reader) error {
+	if c.GetInt(SettingReindexDedupSize) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingReindexDedupSize)
+	}
+	if c.GetInt(SettingReindexDedupSize) > 0 && c.GetDuration(SettingReindexDedupTTL) <= 0 {
+		return errors.Errorf("%s: must be a positive duration", SettingReindexDedupTTL)
+	}
+	return nil
+}
+
+func validateFeatures(c config.Reader) error {
+	if c.GetDuration(SettingFeaturesCacheTTL) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingFeaturesCacheTTL)
+	}
+	return nil
+}
+
+func validateAliases(c config.Reader) error {
+	if c.GetDuration(SettingAliasesCacheTTL) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingAliasesCacheTTL)
+	}
+	return nil
+}
+
+func validateIndexerBulk(c config.Reader) error {
+	if c.GetInt(SettingIndexerBulkMinSize) < 1 {
+		return errors.Errorf("%s: must be at least 1", SettingIndexerBulkMinSize)
+	}
+	if c.GetInt(SettingIndexerBulkMaxSize) < c.GetInt(SettingIndexerBulkMinSize) {
+		return errors.Errorf("%s: must be at least %s",
+			SettingIndexerBulkMaxSize, SettingIndexerBulkMinSize)
+	}
+	if c.GetDuration(SettingIndexerBulkTargetLatency) <= 0 {
+		return errors.Errorf("%s: must be a positive duration", SettingIndexerBulkTargetLatency)
+	}
+	return nil
+}
+
+func validateDeviceRetention(c config.Reader) error {
+	if len(c.GetStringSlice(SettingDeviceRetention)) > 0 &&
+		c.GetDuration(SettingDeviceRetentionInterval) <= 0 {
+		return errors.Errorf("%s: must be a positive duration",
+			SettingDeviceRetentionInterval)
+	}
+	return nil
+}
+
+func validateDeviceAge(c config.Reader) error {
+	if c.GetDuration(SettingDeviceAgeInterval) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingDeviceAgeInterval)
+	}
+	return nil
+}
+
+func validateMaxAttributeValues(c config.Reader) error {
+	if c.GetInt(SettingMaxAttributeValues) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingMaxAttributeValues)
+	}
+	if c.GetInt(SettingDeviceIDsLookupThreshold) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingDeviceIDsLookupThreshold)
+	}
+	return nil
+}
+
+func validateAnomalies(c config.Reader) error {
+	if c.GetDuration(SettingAnomalyInterval) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingAnomalyInterval)
+	}
+	for _, key := range []string{SettingAnomalyRareShare, SettingAnomalyDropShare} {
+		if share := c.GetFloat64(key); share <= 0 || share > 1 {
+			return errors.Errorf("%s: must be a share between 0 and 1", key)
+		}
+	}
+	return nil
+}
+
+func validateEvents(c config.Reader) error {
+	if addr := c.GetString(SettingEventsWebhookURL); addr != "" {
+		return errors.Wrap(validateURL(addr), SettingEventsWebhookURL)
+	}
+	return nil
+}
+
+func validateQuotas(c config.Reader) error {
+	if c.GetDuration(SettingQuotaInterval) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingQuotaInterval)
+	}
+	if c.GetInt(SettingQuotaMaxDevices) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingQuotaMaxDevices)
+	}
+	if share := c.GetFloat64(SettingQuotaAlertShare); share <= 0 || share > 1 {
+		return errors.Errorf("%s: must be a share between 0 and 1", SettingQuotaAlertShare)
+	}
+	if addr := c.GetString(SettingQuotaWebhookURL); addr != "" {
+		return errors.Wrap(validateURL(addr), SettingQuotaWebhookURL)
+	}
+	return nil
+}
+
+func validateWarmUp(c config.Reader) error {
+	if c.GetBool(SettingWarmUp) && c.GetDuration(SettingWarmUpTimeout) <= 0 {
+		return errors.Errorf("%s: must be a positive duration", SettingWarmUpTimeout)
+	}
+	return nil
+}
+
+func validateShutdown(c config.Reader) error {
+	if c.GetDuration(SettingShutdownTimeout) <= 0 {
+		return errors.Errorf("%s: must be a positive duration", SettingShutdownTimeout)
+	}
+	return nil
+}
+
+func validateFaultInjection(c config.Reader) error {
+	if c.GetDuration(SettingFaultInjectionLatency) < 0 {
+		return errors.Errorf("%s: must not be negative", SettingFaultInjectionLatency)
+	}
+	for _, key := range []string{SettingFaultInjectionErrorRate, SettingFaultInjectionPartialRate} {
+		if rate := c.GetFloat64(key); rate < 0 || rate > 1 {
+			return errors.Errorf("%s: must be a share between 0 and 1", key)
+		}
+	}
+	return nil
+}
+
+func validateURL(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return errors.Wrapf(err, "invalid URL %q", addr)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("invalid URL %q: scheme must be http or https", addr)
+	}
+	if u.Host == "" {
+		return errors.Errorf("invalid URL %q: missing host", addr)
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -26,7 +28,9 @@ import (
 
 	"github.com/mendersoftware/reporting/app/indexer"
 	"github.com/mendersoftware/reporting/app/server"
+	"github.com/mendersoftware/reporting/client/transport"
 	dconfig "github.com/mendersoftware/reporting/config"
+	"github.com/mendersoftware/reporting/model"
 	"github.com/mendersoftware/reporting/store"
 )
 
@@ -84,6 +88,71 @@ func doMain(args []string) {
 				Usage:  "Run the migrations",
 				Action: cmdMigrate,
 			},
+			{
+				Name: "check",
+				Usage: "Verify the configuration, the Elasticsearch connectivity " +
+					"and version, the index template compatibility, and the datastore " +
+					"index templates",
+				Action: cmdCheck,
+			},
+			{
+				Name:  "store",
+				Usage: "Manage the data store",
+				Subcommands: []cli.Command{
+					{
+						Name:  "settings",
+						Usage: "Manage the indices settings",
+						Subcommands: []cli.Command{
+							{
+								Name: "apply",
+								Usage: "Apply the configured shards/replicas/routing " +
+									"settings to the index template, and the replicas " +
+									"to the existing indices",
+								Action: cmdStoreSettingsApply,
+							},
+						},
+					},
+					{
+						Name: "schema",
+						Usage: "Export and import the index templates and mappings, " +
+							"for the clusters the service can't migrate itself",
+						Subcommands: []cli.Command{
+							{
+								Name: "export",
+								Usage: "Export the configured index templates and mappings " +
+									"for the target cluster version, without connecting",
+								Action: cmdStoreSchemaExport,
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:  "distribution",
+										Usage: "Target cluster distribution: elasticsearch or opensearch",
+										Value: model.DistributionElasticsearch,
+									},
+									&cli.StringFlag{
+										Name:  "version",
+										Usage: "Target cluster `VERSION`, e.g. 7.17.0",
+									},
+									&cli.StringFlag{
+										Name:  "output",
+										Usage: "Schema bundle `FILE`, standard output by default",
+									},
+								},
+							},
+							{
+								Name:   "import",
+								Usage:  "Apply an exported schema bundle to the cluster",
+								Action: cmdStoreSchemaImport,
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:  "input",
+										Usage: "Schema bundle `FILE`, standard input by default",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
 		},
 	}
 	app.Usage = "Reporting"
@@ -103,6 +172,19 @@ func doMain(args []string) {
 		config.Config.AutomaticEnv()
 		config.Config.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 
+		if err := dconfig.ApplyProfile(config.Config); err != nil {
+			return cli.NewExitError(
+				fmt.Sprintf("invalid configuration: %s", err),
+				1)
+		}
+
+		err = config.ValidateConfig(config.Config, dconfig.Validators...)
+		if err != nil {
+			return cli.NewExitError(
+				fmt.Sprintf("invalid configuration: %s", err),
+				1)
+		}
+
 		return nil
 	}
 
@@ -117,14 +199,26 @@ func cmdServer(args *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	ctx := context.Background()
 	if args.Bool("automigrate") {
-		ctx := context.Background()
 		err := store.Migrate(ctx)
 		if err != nil {
 			return err
 		}
 	}
-	return server.InitAndRun(config.Config, store)
+	if err := store.Check(ctx); err != nil {
+		// an outdated template only affects newly created indices
+		if !isTemplateError(err) {
+			return cli.NewExitError(fmt.Sprintf("startup check failed: %s", err), 1)
+		}
+		log.Printf("WARNING: %s", err)
+	}
+	settings, err := getSettings()
+	if err != nil {
+		return err
+	}
+	settings.Capabilities = store.Capabilities()
+	return server.InitAndRun(config.Config, store, settings, getFaults())
 }
 
 func cmdIndexer(args *cli.Context) error {
@@ -153,13 +247,192 @@ func cmdMigrate(args *cli.Context) error {
 	return store.Migrate(ctx)
 }
 
+func isTemplateError(err error) bool {
+	return errors.Is(err, store.ErrTemplateMissing) ||
+		errors.Is(err, store.ErrTemplateOutdated)
+}
+
+func cmdCheck(args *cli.Context) error {
+	store, err := getStore(args)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("check failed: %s", err), 1)
+	}
+	ctx := context.Background()
+	if err := store.Check(ctx); err != nil {
+		return cli.NewExitError(fmt.Sprintf("check failed: %s", err), 1)
+	}
+	fmt.Println("OK")
+	return nil
+}
+
+func cmdStoreSettingsApply(args *cli.Context) error {
+	store, err := getStore(args)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	return store.ApplySettings(ctx)
+}
+
+func cmdStoreSchemaExport(args *cli.Context) error {
+	capabilities, err := model.NewCapabilities(args.String("distribution"),
+		args.String("version"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid target: %s", err), 1)
+	}
+
+	opts, err := storeOptions()
+	if err != nil {
+		return err
+	}
+	schema, err := store.ExportSchema(capabilities, opts...)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if path := args.String("output"); path != "" && path != "-" {
+		out, err = os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
+
+func cmdStoreSchemaImport(args *cli.Context) error {
+	in := os.Stdin
+	if path := args.String("input"); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var schema store.Schema
+	if err := json.NewDecoder(in).Decode(&schema); err != nil {
+		return cli.NewExitError(fmt.Sprintf("malformed schema bundle: %s", err), 1)
+	}
+
+	s, err := getStore(args)
+	if err != nil {
+		return err
+	}
+	capabilities := s.Capabilities()
+	if target := capabilities.Distribution + " " + capabilities.Version; schema.Target != "" &&
+		schema.Target != target {
+		log.Printf("WARNING: schema bundle exported for %s, importing to %s",
+			schema.Target, target)
+	}
+
+	ctx := context.Background()
+	return s.ImportSchema(ctx, &schema)
+}
+
 func getStore(args *cli.Context) (store.Store, error) {
-	addresses := config.Config.GetStringSlice(dconfig.SettingElasticsearchAddresses)
-	store, err := store.NewStore(
-		store.WithServerAddresses(addresses),
-	)
+	opts, err := storeOptions()
 	if err != nil {
 		return nil, err
 	}
+
+	faults := getFaults()
+	if faults != (transport.Faults{}) {
+		log.Printf("WARNING: fault injection enabled: latency %s, error rate %v, "+
+			"partial rate %v", faults.Latency, faults.ErrorRate, faults.PartialRate)
+	}
+	opts = append(opts, store.WithFaults(faults))
+
+	store, err := store.NewStore(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Init(context.Background()); err != nil {
+		return nil, err
+	}
 	return store, nil
 }
+
+// getFaults returns the faults injected into the outbound
+// requests, from the configuration
+func getFaults() transport.Faults {
+	return transport.Faults{
+		Latency:     config.Config.GetDuration(dconfig.SettingFaultInjectionLatency),
+		ErrorRate:   config.Config.GetFloat64(dconfig.SettingFaultInjectionErrorRate),
+		PartialRate: config.Config.GetFloat64(dconfig.SettingFaultInjectionPartialRate),
+	}
+}
+
+// getSettings returns the attribute settings from the configuration,
+// without the cluster capabilities, detected by the store
+func getSettings() (model.Settings, error) {
+	analyzers, err := model.ParseAnalyzers(
+		config.Config.GetStringSlice(dconfig.SettingAttributeAnalyzers))
+	if err != nil {
+		return model.Settings{}, err
+	}
+
+	normalizers, err := model.ParseNormalizers(
+		config.Config.GetStringSlice(dconfig.SettingAttributeNormalizers))
+	if err != nil {
+		return model.Settings{}, err
+	}
+
+	redactions, err := model.ParseRedactions(
+		config.Config.GetStringSlice(dconfig.SettingRedactedAttributes),
+		config.Config.GetString(dconfig.SettingRedactionKey))
+	if err != nil {
+		return model.Settings{}, err
+	}
+
+	nested, err := model.ParseNestedAttributes(
+		config.Config.GetStringSlice(dconfig.SettingNestedAttributes))
+	if err != nil {
+		return model.Settings{}, err
+	}
+
+	return model.Settings{
+		Analyzers:   analyzers,
+		Normalizers: normalizers,
+		Redactions:  redactions,
+		Nested:      nested,
+	}, nil
+}
+
+// storeOptions returns the store options, from the configuration
+func storeOptions() ([]store.StoreOption, error) {
+	addresses := config.Config.GetStringSlice(dconfig.SettingElasticsearchAddresses)
+	settings, err := getSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []store.StoreOption{
+		store.WithServerAddresses(addresses),
+		store.WithReplicaAddresses(
+			config.Config.GetStringSlice(dconfig.SettingElasticsearchReplicaAddresses)),
+		store.WithShards(config.Config.GetInt(dconfig.SettingElasticsearchShards)),
+		store.WithReplicas(config.Config.GetInt(dconfig.SettingElasticsearchReplicas)),
+		store.WithRoutingByTenant(
+			config.Config.GetBool(dconfig.SettingElasticsearchRoutingByTenant)),
+		store.WithAttributeAnalyzers(settings.Analyzers),
+		store.WithIndexPrefix(config.Config.GetString(dconfig.SettingElasticsearchIndexPrefix)),
+		store.WithIndexSuffix(config.Config.GetString(dconfig.SettingElasticsearchIndexSuffix)),
+		store.WithSearchPreference(
+			config.Config.GetString(dconfig.SettingElasticsearchSearchPreference)),
+		store.WithPreferredNodes(
+			config.Config.GetString(dconfig.SettingElasticsearchPreferredNodes)),
+		store.WithSearchConcurrency(
+			config.Config.GetInt(dconfig.SettingElasticsearchSearchConcurrency),
+			config.Config.GetInt(dconfig.SettingElasticsearchSearchQueue),
+			config.Config.GetDuration(dconfig.SettingElasticsearchSearchQueueTimeout)),
+	}
+	if config.Config.GetString(dconfig.SettingElasticsearchAdaptiveReplicaSelection) != "" {
+		opts = append(opts, store.WithAdaptiveReplicaSelection(
+			config.Config.GetBool(dconfig.SettingElasticsearchAdaptiveReplicaSelection)))
+	}
+	return opts, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+const (
+	attrArtifactName = "artifact_name"
+	attrDeviceType   = "device_type"
+
+	// number of composite buckets fetched per request
+	adoptionPageSize = 1000
+
+	aggAdoption       = "adoption"
+	aggCurrentPeriod  = "current"
+	aggPreviousPeriod = "previous"
+)
+
+// AdoptionReport is the breakdown of artifacts installed per device type
+type AdoptionReport struct {
+	PeriodDays  int                  `json:"period_days"`
+	DeviceTypes []DeviceTypeAdoption `json:"device_types"`
+}
+
+type DeviceTypeAdoption struct {
+	DeviceType string             `json:"device_type"`
+	Count      int                `json:"count"`
+	Artifacts  []ArtifactAdoption `json:"artifacts"`
+}
+
+// ArtifactAdoption describes a single artifact within a device type:
+// the percentage of devices running it, and the trend - the difference
+// (in percentage points) between the share of the artifact among the
+// devices updated in the current period and in the previous one
+type ArtifactAdoption struct {
+	ArtifactName string  `json:"artifact_name"`
+	Count        int     `json:"count"`
+	Percentage   float64 `json:"percentage"`
+	Trend        float64 `json:"trend"`
+}
+
+// AdoptionBucket is a single composite aggregation bucket
+type AdoptionBucket struct {
+	Key struct {
+		DeviceType   string `json:"device_type"`
+		ArtifactName string `json:"artifact_name"`
+	} `json:"key"`
+	DocCount int `json:"doc_count"`
+	Current  struct {
+		DocCount int `json:"doc_count"`
+	} `json:"current"`
+	Previous struct {
+		DocCount int `json:"doc_count"`
+	} `json:"previous"`
+}
+
+// AdoptionAggregation is the result of the adoption composite aggregation
+type AdoptionAggregation struct {
+	Adoption struct {
+		AfterKey map[string]interface{} `json:"after_key"`
+		Buckets  []AdoptionBucket       `json:"buckets"`
+	} `json:"adoption"`
+}
+
+// BuildAdoptionQuery prepares the composite aggregation over
+// device types and artifact names, with per-period sub counts;
+// 'after' is the after_key of the previous page, if any
+func BuildAdoptionQuery(now time.Time, period time.Duration, after map[string]interface{}) M {
+	composite := M{
+		"size": adoptionPageSize,
+		"sources": S{
+			M{attrDeviceType: M{"terms": M{
+				"field": ToAttr(scopeInventory, attrDeviceType, TypeStr),
+			}}},
+			M{attrArtifactName: M{"terms": M{
+				"field": ToAttr(scopeInventory, attrArtifactName, TypeStr),
+			}}},
+		},
+	}
+	if after != nil {
+		composite["after"] = after
+	}
+
+	return M{
+		"size": 0,
+		"aggs": M{
+			aggAdoption: M{
+				"composite": composite,
+				"aggs": M{
+					aggCurrentPeriod: M{"filter": M{"range": M{
+						"updatedAt": M{"gte": now.Add(-period)},
+					}}},
+					aggPreviousPeriod: M{"filter": M{"range": M{
+						"updatedAt": M{
+							"gte": now.Add(-2 * period),
+							"lt":  now.Add(-period),
+						},
+					}}},
+				},
+			},
+		},
+	}
+}
+
+// NewAdoptionReport computes the percentages and trends from the
+// complete list of adoption buckets
+func NewAdoptionReport(periodDays int, buckets []AdoptionBucket) *AdoptionReport {
+	type totals struct {
+		all, current, previous int
+	}
+
+	report := &AdoptionReport{
+		PeriodDays:  periodDays,
+		DeviceTypes: []DeviceTypeAdoption{},
+	}
+
+	// buckets are sorted by device type, then artifact name
+	var typeTotals totals
+	var typeBuckets []AdoptionBucket
+	flush := func() {
+		if len(typeBuckets) == 0 {
+			return
+		}
+		dt := DeviceTypeAdoption{
+			DeviceType: typeBuckets[0].Key.DeviceType,
+			Count:      typeTotals.all,
+			Artifacts:  make([]ArtifactAdoption, 0, len(typeBuckets)),
+		}
+		for _, b := range typeBuckets {
+			dt.Artifacts = append(dt.Artifacts, ArtifactAdoption{
+				ArtifactName: b.Key.ArtifactName,
+				Count:        b.DocCount,
+				Percentage:   percentage(b.DocCount, typeTotals.all),
+				Trend: percentage(b.Current.DocCount, typeTotals.current) -
+					percentage(b.Previous.DocCount, typeTotals.previous),
+			})
+		}
+		report.DeviceTypes = append(report.DeviceTypes, dt)
+		typeTotals = totals{}
+		typeBuckets = typeBuckets[:0]
+	}
+
+	for _, b := range buckets {
+		if len(typeBuckets) > 0 && typeBuckets[0].Key.DeviceType != b.Key.DeviceType {
+			flush()
+		}
+		typeBuckets = append(typeBuckets, b)
+		typeTotals.all += b.DocCount
+		typeTotals.current += b.Current.DocCount
+		typeTotals.previous += b.Previous.DocCount
+	}
+	flush()
+
+	return report
+}
+
+func percentage(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// device age buckets, by the upper bound (exclusive) in days;
+// devices older than the last bound fall in AgeBucketOldest
+var ageBuckets = []struct {
+	Name    string
+	MaxDays int
+}{
+	{Name: "0-7d", MaxDays: 7},
+	{Name: "7-30d", MaxDays: 30},
+	{Name: "30-90d", MaxDays: 90},
+	{Name: "90-365d", MaxDays: 365},
+}
+
+const AgeBucketOldest = "365d+"
+
+// the update script recomputes the age from the '_source', as the
+// doc values aren't available to update_by_query scripts
+const deviceAgeScript = "" +
+	"if (ctx._source.createdAt == null) { ctx.op = 'noop'; return; } " +
+	"long days = (params.now - ZonedDateTime.parse(ctx._source.createdAt)" +
+	".toInstant().toEpochMilli()) / 86400000L; " +
+	"if (days < 0) { days = 0; } " +
+	"String bucket = params.oldest; " +
+	"for (int i = 0; i < params.bounds.size(); i++) { " +
+	"if (days < params.bounds.get(i)) { bucket = params.names.get(i); break; } } " +
+	"ctx._source[params.days_field] = [days]; " +
+	"ctx._source[params.bucket_field] = [bucket];"
+
+// DeviceAge returns the device age in full days, and its age bucket
+func DeviceAge(created, now time.Time) (int, string) {
+	days := int(now.Sub(created) / (24 * time.Hour))
+	if days < 0 {
+		days = 0
+	}
+	for _, b := range ageBuckets {
+		if days < b.MaxDays {
+			return days, b.Name
+		}
+	}
+	return days, AgeBucketOldest
+}
+
+// InventoryCreatedTs returns the time the device was created in the
+// inventory, from the created_ts field or system attribute
+func (d *InvDevice) InventoryCreatedTs() (time.Time, bool) {
+	if d.CreatedTs != nil && !d.CreatedTs.IsZero() {
+		return *d.CreatedTs, true
+	}
+	for _, attr := range d.Attributes {
+		if attr.Scope != AttrScopeSystem || attr.Name != AttrNameCreated {
+			continue
+		}
+		if val, ok := attr.Value.(string); ok {
+			if ts, err := time.Parse(time.RFC3339Nano, val); err == nil {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// SetAge sets the device age system attributes, based on
+// the device creation time
+func (a *Device) SetAge(now time.Time) *Device {
+	if a.CreatedAt == nil {
+		return a
+	}
+	days, bucket := DeviceAge(*a.CreatedAt, now)
+
+	a.SystemAttributes.Upsert(
+		NewInventoryAttribute(scopeSystem).
+			SetName(AttrNameAgeDays).
+			SetNumeric(float64(days)),
+		NewInventoryAttribute(scopeSystem).
+			SetName(AttrNameAgeBucket).
+			SetString(bucket),
+	)
+	return a
+}
+
+// BuildDeviceAgeUpdate prepares the update_by_query body
+// refreshing the age attributes of all the devices
+func BuildDeviceAgeUpdate(now time.Time) M {
+	bounds := make([]int, len(ageBuckets))
+	names := make([]string, len(ageBuckets))
+	for i, b := range ageBuckets {
+		bounds[i] = b.MaxDays
+		names[i] = b.Name
+	}
+
+	return M{
+		"query": M{
+			"exists": M{"field": "createdAt"},
+		},
+		"script": M{
+			"lang":   "painless",
+			"source": deviceAgeScript,
+			"params": M{
+				"now":          now.UnixNano() / int64(time.Millisecond),
+				"bounds":       bounds,
+				"names":        names,
+				"oldest":       AgeBucketOldest,
+				"days_field":   ToAttr(scopeSystem, AttrNameAgeDays, TypeNum),
+				"bucket_field": ToAttr(scopeSystem, AttrNameAgeBucket, TypeStr),
+			},
+		},
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceAge(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		created time.Time
+		days    int
+		bucket  string
+	}{
+		"new": {
+			created: now.Add(-time.Hour),
+			days:    0,
+			bucket:  "0-7d",
+		},
+		"in the future": {
+			created: now.Add(time.Hour),
+			days:    0,
+			bucket:  "0-7d",
+		},
+		"bucket bound": {
+			created: now.Add(-30 * 24 * time.Hour),
+			days:    30,
+			bucket:  "30-90d",
+		},
+		"oldest": {
+			created: now.Add(-730 * 24 * time.Hour),
+			days:    730,
+			bucket:  AgeBucketOldest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			days, bucket := DeviceAge(tc.created, now)
+			assert.Equal(t, tc.days, days)
+			assert.Equal(t, tc.bucket, bucket)
+		})
+	}
+}
+
+func TestDeviceSetAge(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	dev := NewDevice("foo").SetCreatedAt(now.AddDate(0, 0, -10))
+	dev.SetAge(now)
+	dev.SetAge(now.AddDate(0, 0, 1))
+
+	b, err := json.Marshal(dev)
+	assert.NoError(t, err)
+
+	var res map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, []interface{}{float64(11)}, res["system_device_age_days_num"])
+	assert.Equal(t, []interface{}{"7-30d"}, res["system_device_age_bucket_str"])
+	assert.Len(t, dev.SystemAttributes, 2)
+}
+
+func TestInventoryCreatedTs(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	ts, ok := (&InvDevice{CreatedTs: &created}).InventoryCreatedTs()
+	assert.True(t, ok)
+	assert.Equal(t, created, ts)
+
+	ts, ok = (&InvDevice{
+		Attributes: DeviceAttributes{{
+			Scope: AttrScopeSystem,
+			Name:  AttrNameCreated,
+			Value: created.Format(time.RFC3339),
+		}},
+	}).InventoryCreatedTs()
+	assert.True(t, ok)
+	assert.True(t, created.Equal(ts))
+
+	_, ok = (&InvDevice{}).InventoryCreatedTs()
+	assert.False(t, ok)
+
+	b, err := json.Marshal(InvDevice{ID: "dev1"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "created_ts")
+}
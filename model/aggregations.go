@@ -0,0 +1,425 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAggregationLimit = 10
+	maxAggregationLimit     = 100
+	maxAggregationDepth     = 3
+)
+
+// aggregation types
+const (
+	AggregationTerms         = "terms"
+	AggregationDateHistogram = "date_histogram"
+	AggregationPercentiles   = "percentiles"
+	AggregationCardinality   = "cardinality"
+)
+
+const maxPercents = 10
+
+// minHistogramInterval is the finest date histogram interval: the empty
+// buckets are returned too, so that a finer one over the devices dates
+// span makes for too many buckets
+const minHistogramInterval = time.Minute
+
+var (
+	// ErrTooManyBuckets is returned for the date histograms too fine
+	// for the span of the devices dates, only detected on the execution
+	ErrTooManyBuckets = errors.New("the date histogram makes for too many buckets, " +
+		"use a longer interval")
+
+	validAggregationTypes = []interface{}{
+		AggregationTerms,
+		AggregationDateHistogram,
+		AggregationPercentiles,
+		AggregationCardinality,
+	}
+
+	// the metric aggregation types compute a single result
+	// over the numeric attribute values, rather than buckets
+	metricAggregationTypes = map[string]bool{
+		AggregationPercentiles: true,
+		AggregationCardinality: true,
+	}
+
+	defaultPercents = []float64{50, 95, 99}
+
+	// system date attributes and the fields they are indexed as
+	dateAttrs = map[string]string{
+		AttrNameCreated:   "createdAt",
+		AttrNameUpdated:   "updatedAt",
+		AttrNameCheckedIn: ToAttr(scopeSystem, AttrNameCheckedIn, TypeStr),
+	}
+
+	calendarIntervals = map[string]bool{
+		"minute": true, "1m": true,
+		"hour": true, "1h": true,
+		"day": true, "1d": true,
+		"week": true, "1w": true,
+		"month": true, "1M": true,
+		"quarter": true, "1q": true,
+		"year": true, "1y": true,
+	}
+
+	fixedIntervalRegexp = regexp.MustCompile(`^[1-9][0-9]*(ms|s|m|h|d)$`)
+	// UTC offset ("+01:00") or an IANA time zone ID ("Europe/Oslo")
+	timezoneRegexp = regexp.MustCompile(`^([+-][0-9]{2}:[0-9]{2}|[A-Za-z]+(/[A-Za-z0-9_+-]+)*)$`)
+)
+
+// AggregateParams describes a device aggregation request:
+// the devices matching the filters are grouped by the
+// aggregation terms, possibly nested
+type AggregateParams struct {
+	Filters      []FilterPredicate `json:"filters"`
+	Aggregations []AggregationTerm `json:"aggregations"`
+	// Timezone applies to the date filters and the date
+	// histograms without an explicit one, UTC by default
+	Timezone string `json:"timezone"`
+}
+
+// AggregationTerm returns the top 'Limit' values of an attribute,
+// ordered by device count, with optional sub-aggregations per value;
+// the "date_histogram" type instead groups devices by a system date
+// attribute (created_ts, updated_ts, check_in_time), bucketed by
+// 'Interval' in the 'Timezone' (UTC by default); the "percentiles"
+// (at 'Percents', the median, p95 and p99 by default) and "cardinality"
+// (approximate distinct count) types compute a metric over the values
+// of a numeric attribute, and can't be nested further
+type AggregationTerm struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type,omitempty"`
+	Scope        string            `json:"scope"`
+	Attribute    string            `json:"attribute"`
+	Limit        int               `json:"limit"`
+	Interval     string            `json:"interval,omitempty"`
+	Timezone     string            `json:"timezone,omitempty"`
+	Percents     []float64         `json:"percents,omitempty"`
+	Aggregations []AggregationTerm `json:"aggregations,omitempty"`
+}
+
+// DeviceAggregation is the result of an aggregation term: the items
+// of the bucket aggregations, or the value(s) of the metric ones
+type DeviceAggregation struct {
+	Name        string                  `json:"name"`
+	Items       []DeviceAggregationItem `json:"items"`
+	OtherCount  int                     `json:"other_count"`
+	Value       *float64                `json:"value,omitempty"`
+	Percentiles map[string]float64      `json:"percentiles,omitempty"`
+}
+
+type DeviceAggregationItem struct {
+	Key          interface{}         `json:"key"`
+	Count        int                 `json:"count"`
+	Aggregations []DeviceAggregation `json:"aggregations,omitempty"`
+}
+
+func (p AggregateParams) Validate() error {
+	for _, f := range p.Filters {
+		err := f.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(p.Aggregations) == 0 {
+		return errors.New("at least one aggregation must be provided")
+	}
+
+	if err := validateTimezone(p.Timezone); err != nil {
+		return err
+	}
+
+	return validateAggregationTerms(p.Aggregations, 1)
+}
+
+// QueriedAttributes returns the attributes the devices
+// are filtered or aggregated by
+func (p AggregateParams) QueriedAttributes() []SelectAttribute {
+	return termsAttributes(filterAttributes(nil, p.Filters), p.Aggregations)
+}
+
+func validateAggregationTerms(terms []AggregationTerm, depth int) error {
+	if depth > maxAggregationDepth {
+		return errors.Errorf("aggregations can't be nested deeper than %d levels",
+			maxAggregationDepth)
+	}
+
+	names := map[string]bool{}
+	for _, t := range terms {
+		err := validation.ValidateStruct(&t,
+			validation.Field(&t.Name, validation.Required),
+			validation.Field(&t.Scope, validation.Required),
+			validation.Field(&t.Attribute, validation.Required),
+			validation.Field(&t.Limit, validation.Min(0), validation.Max(maxAggregationLimit)),
+			validation.Field(&t.Type, validation.In(validAggregationTypes...)))
+		if err != nil {
+			return err
+		}
+		if t.Type == AggregationDateHistogram {
+			if err := t.validateDateHistogram(); err != nil {
+				return err
+			}
+		}
+		if metricAggregationTypes[t.Type] {
+			if err := t.validateMetric(); err != nil {
+				return err
+			}
+		}
+		if names[t.Name] {
+			return errors.Errorf("duplicate aggregation name: %s", t.Name)
+		}
+		names[t.Name] = true
+
+		if err := validateAggregationTerms(t.Aggregations, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t AggregationTerm) validateDateHistogram() error {
+	if _, ok := dateAttrs[t.Attribute]; !ok || t.Scope != scopeSystem {
+		return errors.Errorf("date histogram not supported for attribute %s/%s",
+			t.Scope, t.Attribute)
+	}
+	if !calendarIntervals[t.Interval] {
+		if !fixedIntervalRegexp.MatchString(t.Interval) {
+			return errors.Errorf("invalid date histogram interval: %q", t.Interval)
+		}
+		if fixedIntervalDuration(t.Interval) < minHistogramInterval {
+			return errors.Errorf("date histogram interval %q shorter than %s",
+				t.Interval, minHistogramInterval)
+		}
+	}
+	if t.Timezone != "" && !timezoneRegexp.MatchString(t.Timezone) {
+		return errors.Errorf("invalid time zone: %q", t.Timezone)
+	}
+	return nil
+}
+
+// fixedIntervalDuration converts a valid fixed interval, the
+// days being the only unit unknown to time.ParseDuration
+func fixedIntervalDuration(interval string) time.Duration {
+	if days := strings.TrimSuffix(interval, "d"); days != interval {
+		n, _ := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour
+	}
+	d, _ := time.ParseDuration(interval)
+	return d
+}
+
+func (t AggregationTerm) validateMetric() error {
+	if len(t.Aggregations) > 0 {
+		return errors.Errorf("%s aggregation %s can't have sub-aggregations",
+			t.Type, t.Name)
+	}
+	if t.Type != AggregationPercentiles {
+		return nil
+	}
+	if len(t.Percents) > maxPercents {
+		return errors.Errorf("at most %d percents can be requested", maxPercents)
+	}
+	for _, p := range t.Percents {
+		if p < 0 || p > 100 {
+			return errors.Errorf("invalid percent: %v", p)
+		}
+	}
+	return nil
+}
+
+// MetricAttributes lists the attributes of the metric aggregations,
+// including the nested ones, which must be indexed as numbers
+func MetricAttributes(terms []AggregationTerm) []SelectAttribute {
+	attrs := []SelectAttribute{}
+	for _, t := range terms {
+		if metricAggregationTypes[t.Type] {
+			attrs = append(attrs, SelectAttribute{Scope: t.Scope, Attribute: t.Attribute})
+		}
+		attrs = append(attrs, MetricAttributes(t.Aggregations)...)
+	}
+	return attrs
+}
+
+// BuildAggregateQuery prepares a query returning only the aggregations
+// of the devices matching the filters
+func BuildAggregateQuery(params AggregateParams, s Settings) (Query, error) {
+	query, err := BuildQuery(SearchParams{
+		Filters: params.Filters,
+		Page:    1,
+		PerPage: 0,
+	}, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.With(M{
+		"aggs": BuildAggregations(params.Aggregations),
+	}), nil
+}
+
+// BuildAggregations translates the aggregation terms to ES aggregations
+func BuildAggregations(terms []AggregationTerm) M {
+	aggs := M{}
+	for _, t := range terms {
+		limit := t.Limit
+		if limit == 0 {
+			limit = defaultAggregationLimit
+		}
+
+		var agg M
+		switch t.Type {
+		case AggregationDateHistogram:
+			agg = M{AggregationDateHistogram: t.dateHistogram()}
+		case AggregationPercentiles:
+			percents := t.Percents
+			if len(percents) == 0 {
+				percents = defaultPercents
+			}
+			agg = M{
+				AggregationPercentiles: M{
+					"field":    ToAttr(t.Scope, t.Attribute, TypeNum),
+					"percents": percents,
+				},
+			}
+		case AggregationCardinality:
+			agg = M{
+				AggregationCardinality: M{
+					"field": ToAttr(t.Scope, t.Attribute, TypeNum),
+				},
+			}
+		default:
+			agg = M{
+				"terms": M{
+					"field": ToAttr(t.Scope, t.Attribute, TypeStr),
+					"size":  limit,
+				},
+			}
+		}
+		if len(t.Aggregations) > 0 {
+			agg["aggs"] = BuildAggregations(t.Aggregations)
+		}
+		aggs[t.Name] = agg
+	}
+	return aggs
+}
+
+func (t AggregationTerm) dateHistogram() M {
+	histogram := M{
+		"field":         dateAttrs[t.Attribute],
+		"min_doc_count": 0,
+	}
+	if calendarIntervals[t.Interval] {
+		histogram["calendar_interval"] = t.Interval
+	} else {
+		histogram["fixed_interval"] = t.Interval
+	}
+	if t.Timezone != "" {
+		histogram["time_zone"] = t.Timezone
+	}
+	return histogram
+}
+
+// ParseAggregations translates the ES aggregations results
+// back to device aggregations, following the requested terms
+func ParseAggregations(terms []AggregationTerm, aggs map[string]interface{}) ([]DeviceAggregation, error) {
+	ret := make([]DeviceAggregation, 0, len(terms))
+	for _, t := range terms {
+		aggM, ok := aggs[t.Name].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("can't process aggregation %s", t.Name)
+		}
+
+		if metricAggregationTypes[t.Type] {
+			ret = append(ret, parseMetric(t, aggM))
+			continue
+		}
+
+		buckets, ok := aggM["buckets"].([]interface{})
+		if !ok {
+			return nil, errors.Errorf("can't process aggregation %s buckets", t.Name)
+		}
+
+		other, _ := aggM["sum_other_doc_count"].(float64)
+		agg := DeviceAggregation{
+			Name:       t.Name,
+			Items:      make([]DeviceAggregationItem, 0, len(buckets)),
+			OtherCount: int(other),
+		}
+
+		for _, b := range buckets {
+			bucketM, ok := b.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("can't process aggregation %s bucket", t.Name)
+			}
+			count, _ := bucketM["doc_count"].(float64)
+			item := DeviceAggregationItem{
+				Key:   bucketM["key"],
+				Count: int(count),
+			}
+			// date histogram keys are epoch millis, prefer the formatted date
+			if key, ok := bucketM["key_as_string"]; ok {
+				item.Key = key
+			}
+			if len(t.Aggregations) > 0 {
+				sub, err := ParseAggregations(t.Aggregations, bucketM)
+				if err != nil {
+					return nil, err
+				}
+				item.Aggregations = sub
+			}
+			agg.Items = append(agg.Items, item)
+		}
+
+		ret = append(ret, agg)
+	}
+
+	return ret, nil
+}
+
+// parseMetric translates a metric aggregation result; the values
+// are missing (null) when no device has the attribute
+func parseMetric(t AggregationTerm, aggM map[string]interface{}) DeviceAggregation {
+	agg := DeviceAggregation{
+		Name:  t.Name,
+		Items: []DeviceAggregationItem{},
+	}
+	switch t.Type {
+	case AggregationCardinality:
+		if value, ok := aggM["value"].(float64); ok {
+			agg.Value = &value
+		}
+	case AggregationPercentiles:
+		values, _ := aggM["values"].(map[string]interface{})
+		agg.Percentiles = make(map[string]float64, len(values))
+		for percent, v := range values {
+			if value, ok := v.(float64); ok {
+				agg.Percentiles[percent] = value
+			}
+		}
+	}
+	return agg
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricAggregations(t *testing.T) {
+	params := AggregateParams{
+		Aggregations: []AggregationTerm{
+			{
+				Name:      "types",
+				Scope:     "inventory",
+				Attribute: "device_type",
+				Aggregations: []AggregationTerm{{
+					Name:      "age",
+					Type:      AggregationPercentiles,
+					Scope:     "system",
+					Attribute: "device_age_days",
+					Percents:  []float64{95},
+				}},
+			},
+			{
+				Name:      "sites",
+				Type:      AggregationCardinality,
+				Scope:     "inventory",
+				Attribute: "site_id",
+			},
+		},
+	}
+	assert.NoError(t, params.Validate())
+	assert.Equal(t, []SelectAttribute{
+		{Scope: "system", Attribute: "device_age_days"},
+		{Scope: "inventory", Attribute: "site_id"},
+	}, MetricAttributes(params.Aggregations))
+
+	b, err := json.Marshal(BuildAggregations(params.Aggregations))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"types": {
+			"terms": {"field": "inventory_device_type_str", "size": 10},
+			"aggs": {"age": {"percentiles": {
+				"field": "system_device_age_days_num", "percents": [95]
+			}}}
+		},
+		"sites": {"cardinality": {"field": "inventory_site_id_num"}}
+	}`, string(b))
+
+	var aggs map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"types": {"sum_other_doc_count": 0, "buckets": [
+			{"key": "rpi4", "doc_count": 2, "age": {"values": {"95.0": 12.5}}}
+		]},
+		"sites": {"value": 7}
+	}`), &aggs))
+	res, err := ParseAggregations(params.Aggregations, aggs)
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, map[string]float64{"95.0": 12.5},
+		res[0].Items[0].Aggregations[0].Percentiles)
+	assert.Equal(t, float64(7), *res[1].Value)
+
+	// metrics can't be nested further
+	params.Aggregations[1].Aggregations = []AggregationTerm{{
+		Name: "types", Scope: "inventory", Attribute: "device_type",
+	}}
+	assert.Error(t, params.Validate())
+
+	params.Aggregations[1].Aggregations = nil
+	params.Aggregations[0].Aggregations[0].Percents = []float64{101}
+	assert.Error(t, params.Validate())
+}
+
+func TestDateHistogramInterval(t *testing.T) {
+	testCases := map[string]bool{
+		"day":    true,
+		"month":  true,
+		"1m":     true,
+		"90s":    true,
+		"12h":    true,
+		"7d":     true,
+		"59s":    false,
+		"1000ms": false,
+		"0m":     false,
+		"2w":     false,
+	}
+	for interval, valid := range testCases {
+		params := AggregateParams{
+			Aggregations: []AggregationTerm{{
+				Name: "created", Type: AggregationDateHistogram,
+				Scope: "system", Attribute: "created_ts", Interval: interval,
+			}},
+		}
+		err := params.Validate()
+		if valid {
+			assert.NoError(t, err, interval)
+		} else {
+			assert.Error(t, err, interval)
+		}
+	}
+}
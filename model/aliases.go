@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maxAttributeAliases = 10
+
+// AttributeAliases resolves the tenant's attribute aliases, keyed by
+// the "scope/name" of the alias, to the aliased attributes
+type AttributeAliases map[string]SelectAttribute
+
+// NewAttributeAliases collects the aliases of the attribute metadata
+func NewAttributeAliases(metadata []AttributeMetadata) AttributeAliases {
+	ret := AttributeAliases{}
+	for _, m := range metadata {
+		for _, alias := range m.AliasKeys() {
+			ret[alias] = SelectAttribute{Scope: m.Scope, Attribute: m.Name}
+		}
+	}
+	return ret
+}
+
+// AliasKeys returns the "scope/name" of the aliases; an alias
+// without a scope is in the scope of the aliased attribute
+func (m AttributeMetadata) AliasKeys() []string {
+	ret := make([]string, len(m.Aliases))
+	for i, alias := range m.Aliases {
+		if !strings.Contains(alias, "/") {
+			alias = m.Scope + "/" + alias
+		}
+		ret[i] = alias
+	}
+	return ret
+}
+
+func (a AttributeAliases) resolve(scope, name string) (string, string) {
+	if attr, ok := a[scope+"/"+name]; ok {
+		return attr.Scope, attr.Attribute
+	}
+	return scope, name
+}
+
+func (a AttributeAliases) resolveFilters(filters []FilterPredicate) {
+	for i := range filters {
+		filters[i].Scope, filters[i].Attribute =
+			a.resolve(filters[i].Scope, filters[i].Attribute)
+	}
+}
+
+func (a AttributeAliases) resolveTerms(terms []AggregationTerm) {
+	for i := range terms {
+		terms[i].Scope, terms[i].Attribute = a.resolve(terms[i].Scope, terms[i].Attribute)
+		a.resolveTerms(terms[i].Aggregations)
+	}
+}
+
+// ResolveAliases rewrites the aliased attributes of the search
+// params to the attributes they stand for
+func (sp *SearchParams) ResolveAliases(aliases AttributeAliases) {
+	if len(aliases) == 0 {
+		return
+	}
+	aliases.resolveFilters(sp.Filters)
+	aliases.resolveFilters(sp.ExcludeFilters)
+	aliases.resolveFilters(sp.AnyFilters)
+	if sp.Query != nil {
+		for _, f := range sp.Query.Filters() {
+			f.Scope, f.Attribute = aliases.resolve(f.Scope, f.Attribute)
+		}
+	}
+	for i := range sp.Sort {
+		sp.Sort[i].Scope, sp.Sort[i].Attribute =
+			aliases.resolve(sp.Sort[i].Scope, sp.Sort[i].Attribute)
+	}
+	for i := range sp.DefaultSort {
+		sp.DefaultSort[i].Scope, sp.DefaultSort[i].Attribute =
+			aliases.resolve(sp.DefaultSort[i].Scope, sp.DefaultSort[i].Attribute)
+	}
+	for i := range sp.Attributes {
+		sp.Attributes[i].Scope, sp.Attributes[i].Attribute =
+			aliases.resolve(sp.Attributes[i].Scope, sp.Attributes[i].Attribute)
+	}
+	if sp.Collapse != nil {
+		sp.Collapse.Scope, sp.Collapse.Attribute =
+			aliases.resolve(sp.Collapse.Scope, sp.Collapse.Attribute)
+	}
+	if sp.GroupCount != nil {
+		sp.GroupCount.Scope, sp.GroupCount.Attribute =
+			aliases.resolve(sp.GroupCount.Scope, sp.GroupCount.Attribute)
+	}
+	aliases.resolveTerms(sp.Facets)
+}
+
+// ResolveAliases rewrites the aliased attributes of the
+// aggregation params to the attributes they stand for
+func (p *AggregateParams) ResolveAliases(aliases AttributeAliases) {
+	if len(aliases) == 0 {
+		return
+	}
+	aliases.resolveFilters(p.Filters)
+	aliases.resolveTerms(p.Aggregations)
+}
+
+func validateAliases(scope, name string, aliases []string) error {
+	if len(aliases) > maxAttributeAliases {
+		return errors.Errorf("at most %d aliases are allowed", maxAttributeAliases)
+	}
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		aliasScope, aliasName := scope, alias
+		if parts := strings.SplitN(alias, "/", 2); len(parts) == 2 {
+			aliasScope, aliasName = parts[0], parts[1]
+		}
+		valid := false
+		for _, s := range validMappingScopes {
+			if s == aliasScope {
+				valid = true
+			}
+		}
+		if !valid || aliasName == "" || strings.Contains(aliasName, "/") {
+			return errors.Errorf("malformed alias %q: expected \"name\" or \"scope/name\"",
+				alias)
+		}
+		if aliasScope == scope && aliasName == name {
+			return errors.Errorf("alias %q refers to the attribute itself", alias)
+		}
+		if seen[aliasScope+"/"+aliasName] {
+			return errors.Errorf("duplicate alias %q", alias)
+		}
+		seen[aliasScope+"/"+aliasName] = true
+	}
+	return nil
+}
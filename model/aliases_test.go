@@ -0,0 +1,62 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveAliases(t *testing.T) {
+	aliases := NewAttributeAliases([]AttributeMetadata{
+		{Scope: "inventory", Name: "hostname", Aliases: []string{"host", "custom/hostname"}},
+	})
+
+	params := SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "host", Type: "$eq", Value: "foo"},
+			{Scope: "custom", Attribute: "hostname", Type: "$eq", Value: "foo"},
+			{Scope: "identity", Attribute: "host", Type: "$eq", Value: "foo"},
+		},
+		Sort:     []SortCriteria{{Scope: "inventory", Attribute: "host", Order: "asc"}},
+		Collapse: &SelectAttribute{Scope: "inventory", Attribute: "host"},
+	}
+	defaultSort := &DefaultSort{
+		Global: []SortCriteria{{Scope: "inventory", Attribute: "host", Order: "desc"}},
+	}
+	params.DefaultSort = defaultSort.For("tenant")
+	params.ResolveAliases(aliases)
+
+	assert.Equal(t, []FilterPredicate{
+		{Scope: "inventory", Attribute: "hostname", Type: "$eq", Value: "foo"},
+		{Scope: "inventory", Attribute: "hostname", Type: "$eq", Value: "foo"},
+		{Scope: "identity", Attribute: "host", Type: "$eq", Value: "foo"},
+	}, params.Filters)
+	assert.Equal(t, "hostname", params.Sort[0].Attribute)
+	assert.Equal(t, "hostname", params.DefaultSort[0].Attribute)
+	// the configured default sort is left as is
+	assert.Equal(t, "host", defaultSort.Global[0].Attribute)
+	assert.Equal(t, "hostname", params.Collapse.Attribute)
+
+	meta := AttributeMetadata{TenantID: "t", Scope: "inventory", Name: "hostname"}
+	meta.Aliases = []string{"inventory/hostname"}
+	assert.Error(t, meta.Validate())
+	meta.Aliases = []string{"foo/host"}
+	assert.Error(t, meta.Validate())
+	meta.Aliases = []string{"host", "custom/host"}
+	assert.NoError(t, meta.Validate())
+	meta.Aliases = []string{"host", "inventory/host"}
+	assert.Error(t, meta.Validate())
+}
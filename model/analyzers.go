@@ -0,0 +1,124 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// analyzers installed via the index template
+const (
+	AnalyzerKeywordLowercase = "keyword_lowercase"
+	AnalyzerPathHierarchy    = "path_hierarchy"
+	// AnalyzerIP isn't an actual analyzer: it adds an 'ip' typed
+	// subfield, for the $cidr filter
+	AnalyzerIP = "ip"
+)
+
+// subfields holding the analyzed copies of string attributes
+const (
+	subfieldLowercase = "lowercase"
+	subfieldPath      = "path"
+	subfieldIP        = "ip"
+)
+
+var (
+	analyzerSubfields = map[string]string{
+		AnalyzerKeywordLowercase: subfieldLowercase,
+		AnalyzerPathHierarchy:    subfieldPath,
+		AnalyzerIP:               subfieldIP,
+	}
+
+	ErrUnknownAnalyzer = errors.New("unknown analyzer")
+)
+
+// Analyzers maps flat string attribute names (see ToAttr)
+// to the names of the analyzers applied to them
+type Analyzers map[string]string
+
+// ParseAnalyzers parses analyzer definitions in the form
+// "scope/name:analyzer", e.g. "identity/serial_no:keyword_lowercase"
+func ParseAnalyzers(defs []string) (Analyzers, error) {
+	ret := Analyzers{}
+	for _, def := range defs {
+		attr := strings.SplitN(def, ":", 2)
+		if len(attr) != 2 {
+			return nil, errors.Errorf("malformed analyzer definition %q", def)
+		}
+		scopeName := strings.SplitN(attr[0], "/", 2)
+		if len(scopeName) != 2 || scopeName[0] == "" || scopeName[1] == "" {
+			return nil, errors.Errorf("malformed analyzer definition %q", def)
+		}
+		if _, ok := analyzerSubfields[attr[1]]; !ok {
+			return nil, errors.Wrap(ErrUnknownAnalyzer, attr[1])
+		}
+		ret[ToAttr(scopeName[0], scopeName[1], TypeStr)] = attr[1]
+	}
+	return ret, nil
+}
+
+// Mapping returns the ES field mapping for an analyzed string attribute:
+// the plain keyword plus the analyzed subfield
+func (a Analyzers) Mapping(attr string) M {
+	mapping := M{"type": "keyword"}
+	switch a[attr] {
+	case AnalyzerKeywordLowercase:
+		mapping["fields"] = M{
+			subfieldLowercase: M{
+				"type":       "keyword",
+				"normalizer": AnalyzerKeywordLowercase,
+			},
+		}
+	case AnalyzerPathHierarchy:
+		mapping["fields"] = M{
+			subfieldPath: M{
+				"type":            "text",
+				"analyzer":        AnalyzerPathHierarchy,
+				"search_analyzer": "keyword",
+			},
+		}
+	case AnalyzerIP:
+		mapping["fields"] = M{
+			subfieldIP: M{
+				"type":             "ip",
+				"ignore_malformed": true,
+			},
+		}
+	}
+	return mapping
+}
+
+// ipAttr returns the ip typed subfield of an attribute, if any
+func (a Analyzers) ipAttr(attr string) (string, bool) {
+	if a[attr] != AnalyzerIP {
+		return "", false
+	}
+	return attr + "." + subfieldIP, true
+}
+
+// analyzedAttr returns the (sub)field to match against
+// for a given flat attribute name
+func (a Analyzers) analyzedAttr(attr string) string {
+	// exact matches stay on the keyword, the ip subfield is for $cidr
+	if a[attr] == AnalyzerIP {
+		return attr
+	}
+	if sub, ok := analyzerSubfields[a[attr]]; ok {
+		return attr + "." + sub
+	}
+	return attr
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAnalyzers(t *testing.T) {
+	testCases := map[string]struct {
+		defs []string
+		res  Analyzers
+		err  bool
+	}{
+		"ok": {
+			defs: []string{
+				"identity/serial_no:keyword_lowercase",
+				"inventory/rootfs.path:path_hierarchy",
+			},
+			res: Analyzers{
+				"identity_serial_no_str":    AnalyzerKeywordLowercase,
+				"inventory_rootfs．path_str": AnalyzerPathHierarchy,
+			},
+		},
+		"ok, empty": {
+			res: Analyzers{},
+		},
+		"error, no analyzer": {
+			defs: []string{"identity/serial_no"},
+			err:  true,
+		},
+		"error, no scope": {
+			defs: []string{"serial_no:keyword_lowercase"},
+			err:  true,
+		},
+		"error, unknown analyzer": {
+			defs: []string{"identity/serial_no:snowball"},
+			err:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseAnalyzers(tc.defs)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.res, res)
+			}
+		})
+	}
+}
+
+func TestBuildQueryAnalyzed(t *testing.T) {
+	s := Settings{
+		Analyzers: Analyzers{"identity_serial_no_str": AnalyzerKeywordLowercase},
+	}
+
+	q, err := BuildQuery(SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "identity", Attribute: "serial_no", Type: "$eq", Value: "ABC123"},
+			{Scope: "identity", Attribute: "mac", Type: "$eq", Value: "00:11"},
+		},
+	}, s)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"match":{"identity_serial_no_str.lowercase":"ABC123"}}`)
+	assert.Contains(t, string(b), `{"match":{"identity_mac_str":"00:11"}}`)
+}
+
+func TestBuildQueryCIDR(t *testing.T) {
+	s := Settings{
+		Analyzers: Analyzers{"inventory_ipv4_wlan0_str": AnalyzerIP},
+	}
+
+	q, err := BuildQuery(SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "ipv4_wlan0", Type: "$cidr", Value: "10.2.0.0/16"},
+			{Scope: "inventory", Attribute: "ipv4_wlan0", Type: "$eq", Value: "10.2.0.1"},
+		},
+	}, s)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"term":{"inventory_ipv4_wlan0_str.ip":"10.2.0.0/16"}}`)
+	assert.Contains(t, string(b), `{"match":{"inventory_ipv4_wlan0_str":"10.2.0.1"}}`)
+
+	_, err = BuildQuery(SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "ipv4_eth0", Type: "$cidr", Value: "10.2.0.0/16"},
+		},
+	}, s)
+	assert.Equal(t, ErrNotIPAttribute, err)
+
+	_, err = BuildQuery(SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "ipv4_wlan0", Type: "$cidr", Value: "10.2.0.0/33"},
+		},
+	}, s)
+	assert.Equal(t, ErrCIDRRequired, err)
+}
@@ -0,0 +1,216 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"fmt"
+	gosort "sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// anomaly kinds
+const (
+	// AnomalyRareValue is an attribute value present on
+	// a tiny share of the tenant's devices
+	AnomalyRareValue = "rare_value"
+	// AnomalyCountDrop is an attribute value whose device count
+	// dropped sharply since the previous analysis
+	AnomalyCountDrop = "count_drop"
+)
+
+const (
+	// the number of top values tracked per attribute
+	distributionSize = 1000
+	// below this number of devices the shares are too noisy
+	minAnomalyDevices = 100
+	// the values with fewer devices aren't checked for drops
+	minDropCount = 10
+)
+
+// AnomalyConfig selects the analyzed attributes and the thresholds
+type AnomalyConfig struct {
+	Attributes []SelectAttribute
+	// RareShare flags the values present on less than
+	// this share of the devices, e.g. 0.001 for 0.1%
+	RareShare float64
+	// DropShare flags the values whose device count
+	// dropped by at least this share, e.g. 0.5 for 50%
+	DropShare float64
+}
+
+// AnomalyReport holds the latest findings of a tenant; the
+// distributions are kept to detect drops on the next analysis,
+// the truncated attributes have more values than tracked
+type AnomalyReport struct {
+	TenantID      string                    `json:"tenant_id"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	TotalCount    int                       `json:"total_count"`
+	Findings      []Anomaly                 `json:"findings"`
+	Truncated     []string                  `json:"truncated,omitempty"`
+	Distributions map[string]map[string]int `json:"distributions,omitempty"`
+}
+
+// Anomaly is a statistically unusual attribute value
+type Anomaly struct {
+	Kind          string      `json:"kind"`
+	Scope         string      `json:"scope"`
+	Name          string      `json:"name"`
+	Value         interface{} `json:"value"`
+	Count         int         `json:"count"`
+	PreviousCount int         `json:"previous_count,omitempty"`
+	Share         float64     `json:"share"`
+}
+
+// ParseAnomalyAttributes parses the "scope/name" analyzed attributes
+func ParseAnomalyAttributes(defs []string) ([]SelectAttribute, error) {
+	attrs := make([]SelectAttribute, 0, len(defs))
+	for _, def := range defs {
+		parts := strings.SplitN(def, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("malformed anomaly attribute %q: "+
+				"expected \"scope/name\"", def)
+		}
+		attrs = append(attrs, SelectAttribute{Scope: parts[0], Attribute: parts[1]})
+	}
+	return attrs, nil
+}
+
+// BuildDistributionQuery prepares a query returning only the device
+// counts of the top values of each attribute
+func BuildDistributionQuery(attrs []SelectAttribute) Query {
+	aggs := M{}
+	for _, a := range attrs {
+		aggs[a.Scope+"/"+a.Attribute] = M{
+			"terms": M{
+				"field": ToAttr(a.Scope, a.Attribute, TypeStr),
+				"size":  distributionSize,
+			},
+		}
+	}
+	return NewQuery().
+		WithPage(1, 0).
+		With(M{
+			"aggs":             aggs,
+			"track_total_hits": true,
+		})
+}
+
+// ParseDistributions translates the distribution query results
+// to the device counts per value, keyed by "scope/name", and the
+// attributes whose values didn't all fit in the tracked top values
+func ParseDistributions(attrs []SelectAttribute,
+	res M) (map[string]map[string]int, []string, int, error) {
+	hits, _ := res["hits"].(map[string]interface{})
+	totalM, _ := hits["total"].(map[string]interface{})
+	total, ok := totalM["value"].(float64)
+	if !ok {
+		return nil, nil, 0, errors.New("can't process total hits value")
+	}
+
+	aggs, ok := res["aggregations"].(map[string]interface{})
+	if !ok {
+		return nil, nil, 0, errors.New("can't process store aggregations")
+	}
+
+	ret := make(map[string]map[string]int, len(attrs))
+	var truncated []string
+	for _, a := range attrs {
+		key := a.Scope + "/" + a.Attribute
+		aggM, _ := aggs[key].(map[string]interface{})
+		buckets, ok := aggM["buckets"].([]interface{})
+		if !ok {
+			return nil, nil, 0, errors.Errorf("can't process aggregation %s buckets", key)
+		}
+		if other, _ := aggM["sum_other_doc_count"].(float64); other > 0 {
+			truncated = append(truncated, key)
+		}
+		counts := make(map[string]int, len(buckets))
+		for _, b := range buckets {
+			bucketM, _ := b.(map[string]interface{})
+			count, _ := bucketM["doc_count"].(float64)
+			counts[fmt.Sprint(bucketM["key"])] = int(count)
+		}
+		ret[key] = counts
+	}
+
+	return ret, truncated, int(total), nil
+}
+
+// DetectAnomalies flags the rare values of the current distributions,
+// and the values whose counts dropped since the previous ones; the drops
+// of the truncated attributes aren't checked, a value missing from their
+// current top values may have only moved to the untracked tail
+func DetectAnomalies(cfg AnomalyConfig, current, previous map[string]map[string]int,
+	truncated []string, total int) []Anomaly {
+	findings := []Anomaly{}
+	if total < minAnomalyDevices {
+		return findings
+	}
+
+	skipDrops := make(map[string]bool, len(truncated))
+	for _, key := range truncated {
+		skipDrops[key] = true
+	}
+
+	for _, a := range cfg.Attributes {
+		key := a.Scope + "/" + a.Attribute
+		counts := current[key]
+		for value, count := range counts {
+			share := float64(count) / float64(total)
+			if share < cfg.RareShare {
+				findings = append(findings, Anomaly{
+					Kind:  AnomalyRareValue,
+					Scope: a.Scope,
+					Name:  a.Attribute,
+					Value: value,
+					Count: count,
+					Share: share,
+				})
+			}
+		}
+		if skipDrops[key] {
+			continue
+		}
+		for value, prev := range previous[key] {
+			count := counts[value]
+			if prev >= minDropCount &&
+				float64(prev-count) >= cfg.DropShare*float64(prev) {
+				findings = append(findings, Anomaly{
+					Kind:          AnomalyCountDrop,
+					Scope:         a.Scope,
+					Name:          a.Attribute,
+					Value:         value,
+					Count:         count,
+					PreviousCount: prev,
+					Share:         float64(count) / float64(total),
+				})
+			}
+		}
+	}
+
+	gosort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Scope+"/"+a.Name != b.Scope+"/"+b.Name {
+			return a.Scope+"/"+a.Name < b.Scope+"/"+b.Name
+		}
+		return fmt.Sprint(a.Value) < fmt.Sprint(b.Value)
+	})
+	return findings
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	cfg := AnomalyConfig{
+		Attributes: []SelectAttribute{{Scope: "inventory", Attribute: "artifact_name"}},
+		RareShare:  0.01,
+		DropShare:  0.5,
+	}
+	previous := map[string]map[string]int{
+		"inventory/artifact_name": {"v1": 600, "v2": 400},
+	}
+	current := map[string]map[string]int{
+		"inventory/artifact_name": {"v1": 795, "v2": 200, "v3": 5},
+	}
+
+	findings := DetectAnomalies(cfg, current, previous, nil, 1000)
+	assert.Equal(t, []Anomaly{
+		{
+			Kind: AnomalyCountDrop, Scope: "inventory", Name: "artifact_name",
+			Value: "v2", Count: 200, PreviousCount: 400, Share: 0.2,
+		},
+		{
+			Kind: AnomalyRareValue, Scope: "inventory", Name: "artifact_name",
+			Value: "v3", Count: 5, Share: 0.005,
+		},
+	}, findings)
+
+	// too few devices to tell
+	assert.Empty(t, DetectAnomalies(cfg, current, previous, nil, 50))
+
+	// v2 may have moved to the untracked tail
+	assert.Equal(t, []Anomaly{
+		{
+			Kind: AnomalyRareValue, Scope: "inventory", Name: "artifact_name",
+			Value: "v3", Count: 5, Share: 0.005,
+		},
+	}, DetectAnomalies(cfg, current, previous, []string{"inventory/artifact_name"}, 1000))
+
+	_, err := ParseAnomalyAttributes([]string{"inventory"})
+	assert.Error(t, err)
+}
+
+func TestParseDistributions(t *testing.T) {
+	attrs := []SelectAttribute{
+		{Scope: "inventory", Attribute: "artifact_name"},
+		{Scope: "inventory", Attribute: "device_type"},
+	}
+	res := M{
+		"hits": map[string]interface{}{
+			"total": map[string]interface{}{"value": float64(1000)},
+		},
+		"aggregations": map[string]interface{}{
+			"inventory/artifact_name": map[string]interface{}{
+				"sum_other_doc_count": float64(10),
+				"buckets": []interface{}{
+					map[string]interface{}{"key": "v1", "doc_count": float64(990)},
+				},
+			},
+			"inventory/device_type": map[string]interface{}{
+				"sum_other_doc_count": float64(0),
+				"buckets": []interface{}{
+					map[string]interface{}{"key": "rpi4", "doc_count": float64(1000)},
+				},
+			},
+		},
+	}
+
+	dists, truncated, total, err := ParseDistributions(attrs, res)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, total)
+	assert.Equal(t, []string{"inventory/artifact_name"}, truncated)
+	assert.Equal(t, map[string]map[string]int{
+		"inventory/artifact_name": {"v1": 990},
+		"inventory/device_type":   {"rpi4": 1000},
+	}, dists)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// APIKeyPrefix tells API keys apart from JWTs in the Authorization header
+	APIKeyPrefix = "rpk_"
+
+	apiKeyLength = 32
+)
+
+var (
+	ErrAPIKeyMalformed = errors.New("malformed API key")
+	ErrAPIKeyMismatch  = errors.New("API key doesn't match the hash")
+	ErrAPIKeyNoTenant  = errors.New("API key isn't bound to a tenant")
+	ErrAPIKeyExpired   = errors.New("API key expired")
+)
+
+// APIKey grants read-only reporting access within a single tenant;
+// only the hash of the key is stored
+type APIKey struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is the time the key expires at, if any
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (r APIKeyRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 256)),
+		validation.Field(&r.ExpiresAt, validation.By(inFuture)))
+}
+
+func inFuture(value interface{}) error {
+	if t, ok := value.(*time.Time); ok && t != nil && !t.After(time.Now()) {
+		return errors.New("must be in the future")
+	}
+	return nil
+}
+
+// NewAPIKey generates a new API key for tenant 'tid', expiring at
+// 'expiresAt' if not nil; returns the key metadata and the plain text key
+func NewAPIKey(tid, name string, expiresAt *time.Time) (*APIKey, string, error) {
+	buf := make([]byte, apiKeyLength)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	return &APIKey{
+		ID:        uuid.New().String(),
+		TenantID:  tid,
+		Name:      name,
+		Hash:      HashAPIKey(key),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}, key, nil
+}
+
+// HashAPIKey returns the hex encoded SHA256 of the key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey tells if a bearer token is an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// CheckAPIKeyFormat verifies that the API key is the prefix followed
+// by the encoded random bytes, as generated by NewAPIKey
+func CheckAPIKeyFormat(key string) error {
+	if !IsAPIKey(key) {
+		return ErrAPIKeyMalformed
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(key, APIKeyPrefix))
+	if err != nil || len(buf) != apiKeyLength {
+		return ErrAPIKeyMalformed
+	}
+	return nil
+}
+
+// Verify checks that the stored API key matches the plain text 'key',
+// is bound to a tenant, and isn't expired at 'now'
+func (k *APIKey) Verify(key string, now time.Time) error {
+	if err := CheckAPIKeyFormat(key); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(HashAPIKey(key))) != 1 {
+		return ErrAPIKeyMismatch
+	}
+	if k.TenantID == "" {
+		return ErrAPIKeyNoTenant
+	}
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyVerify(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	apiKey, key, err := NewAPIKey("tenant", "ci", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, CheckAPIKeyFormat(key))
+	assert.NoError(t, apiKey.Verify(key, now))
+
+	for _, malformed := range []string{
+		"",
+		"foo",
+		APIKeyPrefix,
+		APIKeyPrefix + "!!!",
+		key[:len(key)-1],
+		key + "A",
+		strings.TrimPrefix(key, APIKeyPrefix),
+	} {
+		assert.ErrorIs(t, CheckAPIKeyFormat(malformed), ErrAPIKeyMalformed, malformed)
+		assert.ErrorIs(t, apiKey.Verify(malformed, now), ErrAPIKeyMalformed, malformed)
+	}
+
+	_, other, err := NewAPIKey("tenant", "other", nil)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, apiKey.Verify(other, now), ErrAPIKeyMismatch)
+
+	noTenant := *apiKey
+	noTenant.TenantID = ""
+	assert.ErrorIs(t, noTenant.Verify(key, now), ErrAPIKeyNoTenant)
+
+	expiring, key, err := NewAPIKey("tenant", "ci", &future)
+	assert.NoError(t, err)
+	assert.NoError(t, expiring.Verify(key, now))
+	assert.ErrorIs(t, expiring.Verify(key, future), ErrAPIKeyExpired)
+	assert.ErrorIs(t, expiring.Verify(key, future.Add(time.Second)), ErrAPIKeyExpired)
+
+	assert.NoError(t, APIKeyRequest{Name: "ci"}.Validate())
+	assert.NoError(t, APIKeyRequest{Name: "ci", ExpiresAt: &future}.Validate())
+	assert.Error(t, APIKeyRequest{Name: "ci", ExpiresAt: &past}.Validate())
+	assert.Error(t, APIKeyRequest{}.Validate())
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const maxAttributeMetadataLength = 1024
+
+// AttributeMetadata describes a tenant's attribute for the UIs:
+// the display name, the tag (category) to group the filters
+// by, and a description; the aliases ("name" or "scope/name")
+// are resolved to the attribute in the searches
+type AttributeMetadata struct {
+	TenantID    string   `json:"tenant_id"`
+	Scope       string   `json:"scope"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+type AttributeMetadataRequest struct {
+	DisplayName string   `json:"display_name"`
+	Tag         string   `json:"tag"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases"`
+}
+
+func (m AttributeMetadata) Validate() error {
+	err := validation.ValidateStruct(&m,
+		validation.Field(&m.TenantID, validation.Required),
+		validation.Field(&m.Scope, validation.Required, validation.In(validMappingScopes...)),
+		validation.Field(&m.Name, validation.Required),
+		validation.Field(&m.DisplayName, validation.Length(0, 256)),
+		validation.Field(&m.Tag, validation.Length(0, 256)),
+		validation.Field(&m.Description, validation.Length(0, maxAttributeMetadataLength)))
+	if err != nil {
+		return err
+	}
+	return validateAliases(m.Scope, m.Name, m.Aliases)
+}
+
+// ID returns the metadata document ID, unique per tenant attribute
+func (m AttributeMetadata) ID() string {
+	sum := sha256.Sum256([]byte(m.TenantID + "/" + m.Scope + "/" + m.Name))
+	return hex.EncodeToString(sum[:])
+}
+
+// AttributeMetadataMap indexes the metadata by "scope/name"
+type AttributeMetadataMap map[string]AttributeMetadata
+
+func NewAttributeMetadataMap(metadata []AttributeMetadata) AttributeMetadataMap {
+	ret := make(AttributeMetadataMap, len(metadata))
+	for _, m := range metadata {
+		ret[m.Scope+"/"+m.Name] = m
+	}
+	return ret
+}
+
+func (m AttributeMetadataMap) Get(scope, name string) (AttributeMetadata, bool) {
+	meta, ok := m[scope+"/"+name]
+	return meta, ok
+}
@@ -22,6 +22,8 @@ const (
 	TypeStr
 	TypeNum
 	TypeBool
+	// TypeObj is the type of the attributes indexed as nested objects
+	TypeObj
 )
 
 // scope prefixes
@@ -30,18 +32,24 @@ const (
 	scopeIdentity  = "identity"
 	scopeCustom    = "custom"
 	scopeSystem    = "system"
+	// the device configuration, from deviceconfig
+	scopeConfiguration = "configuration"
+	// the user tags, set through reporting or inventory
+	scopeTags = "tags"
 )
 
 // type enum/suffixes
 const (
 	typeStr = "str"
 	typeNum = "num"
+	typeObj = "obj"
 )
 
 var (
 	attrSuffixes = map[Type]string{
 		TypeStr: typeStr,
 		TypeNum: typeNum,
+		TypeObj: typeObj,
 	}
 )
 
@@ -0,0 +1,52 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+// MaxBatchSearches is the maximum number of searches in a batch
+const MaxBatchSearches = 10
+
+// BatchSearchParams are independent searches executed in a single request
+type BatchSearchParams []SearchParams
+
+// BatchSearchResult is the result of a single search of a batch,
+// either the matching devices or the error
+type BatchSearchResult struct {
+	Devices    []InvDevice         `json:"devices"`
+	TotalCount int                 `json:"total_count"`
+	Facets     []DeviceAggregation `json:"facets,omitempty"`
+	GroupCount *int                `json:"group_count,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+func (p BatchSearchParams) Validate() error {
+	if len(p) == 0 {
+		return errors.New("at least one search must be provided")
+	}
+	if len(p) > MaxBatchSearches {
+		return errors.Errorf("at most %d searches are allowed", MaxBatchSearches)
+	}
+
+	for i, sp := range p {
+		if err := sp.Validate(); err != nil {
+			return errors.Wrapf(err, "search %d", i)
+		}
+	}
+
+	return nil
+}
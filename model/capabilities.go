@@ -0,0 +1,108 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// the search engine distributions
+const (
+	DistributionElasticsearch = "elasticsearch"
+	DistributionOpenSearch    = "opensearch"
+)
+
+var (
+	ErrFeatureUnsupported = errors.New("feature not supported by the Elasticsearch cluster")
+
+	// the capabilities assumed without a detected cluster
+	allCapabilities = &Capabilities{
+		Distribution:    DistributionElasticsearch,
+		VersionFields:   true,
+		CaseInsensitive: true,
+		RuntimeFields:   true,
+		PointInTime:     true,
+	}
+)
+
+// Capabilities are the features supported by the cluster, derived
+// from its distribution and version, so that the features missing
+// from older clusters are rejected upfront rather than at query time:
+// the 'version' field type of the *_version* attributes, the case
+// insensitive $regex of the lowercase analyzed attributes, the raw
+// query runtime fields
+type Capabilities struct {
+	Distribution    string `json:"distribution"`
+	Version         string `json:"version"`
+	VersionFields   bool   `json:"version_fields"`
+	CaseInsensitive bool   `json:"case_insensitive"`
+	RuntimeFields   bool   `json:"runtime_fields"`
+	PointInTime     bool   `json:"point_in_time"`
+}
+
+// NewCapabilities derives the capabilities of Elasticsearch 7.8+,
+// the first version with the composable index templates, or of
+// OpenSearch 1.x and 2.x, forked from Elasticsearch 7.10; the other
+// versions are not supported
+func NewCapabilities(distribution, version string) (*Capabilities, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return nil, errors.Errorf("can't parse %s version %q", distribution, version)
+	}
+	major, errMajor := strconv.Atoi(parts[0])
+	minor, errMinor := strconv.Atoi(parts[1])
+	if errMajor != nil || errMinor != nil {
+		return nil, errors.Errorf("can't parse %s version %q", distribution, version)
+	}
+
+	c := &Capabilities{
+		Distribution: distribution,
+		Version:      version,
+	}
+	switch distribution {
+	case "", DistributionElasticsearch:
+		if major != 7 || minor < 8 {
+			return nil, errors.Errorf("unsupported Elasticsearch version %s, required 7.8+",
+				version)
+		}
+		c.Distribution = DistributionElasticsearch
+		c.VersionFields = minor >= 10
+		c.CaseInsensitive = minor >= 10
+		c.RuntimeFields = minor >= 11
+		c.PointInTime = minor >= 10
+	case DistributionOpenSearch:
+		if major != 1 && major != 2 {
+			return nil, errors.Errorf("unsupported OpenSearch version %s, required 1.x or 2.x",
+				version)
+		}
+		// the 'version' field type, the runtime fields and
+		// the point in time API are Elasticsearch only
+		c.CaseInsensitive = true
+	default:
+		return nil, errors.Errorf("unsupported distribution %q", distribution)
+	}
+
+	return c, nil
+}
+
+func requireFeature(supported bool, feature string) error {
+	if !supported {
+		return errors.Wrap(ErrFeatureUnsupported, feature)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCapabilities(t *testing.T) {
+	testCases := map[string]struct {
+		distribution string
+		version      string
+		capabilities *Capabilities
+		err          bool
+	}{
+		"ok, elasticsearch": {
+			version: "7.17.3",
+			capabilities: &Capabilities{
+				Distribution:    DistributionElasticsearch,
+				Version:         "7.17.3",
+				VersionFields:   true,
+				CaseInsensitive: true,
+				RuntimeFields:   true,
+				PointInTime:     true,
+			},
+		},
+		"ok, elasticsearch without runtime fields": {
+			version: "7.10.2",
+			capabilities: &Capabilities{
+				Distribution:    DistributionElasticsearch,
+				Version:         "7.10.2",
+				VersionFields:   true,
+				CaseInsensitive: true,
+				PointInTime:     true,
+			},
+		},
+		"ok, old elasticsearch": {
+			version: "7.9.0",
+			capabilities: &Capabilities{
+				Distribution: DistributionElasticsearch,
+				Version:      "7.9.0",
+			},
+		},
+		"ok, opensearch": {
+			distribution: DistributionOpenSearch,
+			version:      "2.11.0",
+			capabilities: &Capabilities{
+				Distribution:    DistributionOpenSearch,
+				Version:         "2.11.0",
+				CaseInsensitive: true,
+			},
+		},
+		"error, elasticsearch 7.7": {
+			version: "7.7.1",
+			err:     true,
+		},
+		"error, elasticsearch 8": {
+			version: "8.1.0",
+			err:     true,
+		},
+		"error, version": {
+			version: "seven",
+			err:     true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewCapabilities(tc.distribution, tc.version)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.capabilities, c)
+		})
+	}
+}
+
+func TestRawQueryRuntimeFieldsUnsupported(t *testing.T) {
+	s := Settings{Capabilities: &Capabilities{Version: "7.10.2"}}
+
+	q := RawQuery{
+		"runtime_mappings": map[string]interface{}{},
+	}
+	assert.NoError(t, q.Validate())
+	assert.Equal(t, ErrFeatureUnsupported, errors.Cause(q.ValidateFeatures(s)))
+	assert.NoError(t, q.ValidateFeatures(Settings{}))
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	DefaultChangesLimit = 500
+	MaxChangesLimit     = 5000
+
+	// the devices updated within the index refresh interval may not
+	// be searchable yet, they are left for the next harvest so that
+	// no change is skipped past the checkpoint; the writes taking
+	// longer to be searchable may still be skipped, and the deleted
+	// devices aren't reported, see the HarvestChanges API
+	changesSettleTime = "now-5s"
+)
+
+// ChangesParams requests the devices changed since the checkpoint,
+// in the order of the changes; an empty checkpoint starts from the
+// oldest change
+type ChangesParams struct {
+	Checkpoint string
+	Limit      int
+}
+
+// ChangesResult holds the changed devices, and the checkpoint
+// to resume the harvest from
+type ChangesResult struct {
+	Devices    []InvDevice
+	Checkpoint string
+}
+
+func (p ChangesParams) Validate() error {
+	return validation.ValidateStruct(&p,
+		validation.Field(&p.Limit, validation.Min(1), validation.Max(MaxChangesLimit)),
+		validation.Field(&p.Checkpoint, validation.By(func(interface{}) error {
+			if p.Checkpoint == "" {
+				return nil
+			}
+			_, err := DecodeCursor(p.Checkpoint)
+			return err
+		})))
+}
+
+// BuildChangesQuery prepares a query returning the devices updated
+// after the checkpoint, ordered by the update time and ID
+func BuildChangesQuery(params ChangesParams) (Query, error) {
+	query := NewQuery().
+		Must(M{
+			"range": M{
+				"updatedAt": M{"lt": changesSettleTime},
+			},
+		}).
+		WithSort(M{"updatedAt": M{"order": "asc"}})
+	query = NewIDSort().AddTo(query)
+
+	if params.Checkpoint != "" {
+		searchAfter, err := DecodeCursor(params.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		query = query.With(M{"search_after": searchAfter})
+	}
+
+	return query.WithPage(1, params.Limit), nil
+}
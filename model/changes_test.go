@@ -0,0 +1,45 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildChangesQuery(t *testing.T) {
+	checkpoint, err := EncodeCursor([]interface{}{1620000000000, "dev-1"})
+	assert.NoError(t, err)
+
+	params := ChangesParams{Checkpoint: checkpoint, Limit: 100}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildChangesQuery(params)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"query": {"bool": {"must": [{"range": {"updatedAt": {"lt": "now-5s"}}}]}},
+		"sort": [{"updatedAt": {"order": "asc"}}, {"id": {"order": "asc"}}],
+		"search_after": [1620000000000, "dev-1"],
+		"from": 0,
+		"size": 100
+	}`, string(b))
+
+	assert.Error(t, ChangesParams{Checkpoint: "!", Limit: 100}.Validate())
+	assert.Error(t, ChangesParams{Limit: MaxChangesLimit + 1}.Validate())
+}
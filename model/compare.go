@@ -0,0 +1,124 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"reflect"
+	gosort "sort"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	minCompareDevices = 2
+	maxCompareDevices = 10
+)
+
+// CompareParams selects the devices to compare, by default
+// only the attributes differing between them are returned
+type CompareParams struct {
+	DeviceIDs []string `json:"device_ids"`
+	All       bool     `json:"all"`
+}
+
+// DeviceComparison is the side-by-side attribute view of the
+// devices; the attribute values follow the DeviceIDs order
+type DeviceComparison struct {
+	DeviceIDs  []string              `json:"device_ids"`
+	Attributes []AttributeComparison `json:"attributes"`
+}
+
+// AttributeComparison holds the values of an attribute per device,
+// nil where the device doesn't have the attribute
+type AttributeComparison struct {
+	Scope   string        `json:"scope"`
+	Name    string        `json:"name"`
+	Values  []interface{} `json:"values"`
+	Differs bool          `json:"differs"`
+}
+
+func (p CompareParams) Validate() error {
+	err := validation.ValidateStruct(&p,
+		validation.Field(&p.DeviceIDs, validation.Required,
+			validation.Length(minCompareDevices, maxCompareDevices),
+			validation.Each(validation.Required)))
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, id := range p.DeviceIDs {
+		if seen[id] {
+			return errors.Errorf("duplicate device ID: %s", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// CompareDevices builds the side-by-side view of the devices, in the
+// 'ids' order; the attributes are sorted by scope and name
+func CompareDevices(ids []string, devs []InvDevice, all bool) *DeviceComparison {
+	pos := make(map[string]int, len(ids))
+	for i, id := range ids {
+		pos[id] = i
+	}
+
+	byAttr := map[string]*AttributeComparison{}
+	for _, dev := range devs {
+		i, ok := pos[string(dev.ID)]
+		if !ok {
+			continue
+		}
+		for _, attr := range dev.Attributes {
+			key := attr.Scope + "/" + attr.Name
+			cmp, ok := byAttr[key]
+			if !ok {
+				cmp = &AttributeComparison{
+					Scope:  attr.Scope,
+					Name:   attr.Name,
+					Values: make([]interface{}, len(ids)),
+				}
+				byAttr[key] = cmp
+			}
+			cmp.Values[i] = attr.Value
+		}
+	}
+
+	ret := &DeviceComparison{
+		DeviceIDs:  ids,
+		Attributes: []AttributeComparison{},
+	}
+	for _, cmp := range byAttr {
+		for _, v := range cmp.Values[1:] {
+			if !reflect.DeepEqual(v, cmp.Values[0]) {
+				cmp.Differs = true
+				break
+			}
+		}
+		if all || cmp.Differs {
+			ret.Attributes = append(ret.Attributes, *cmp)
+		}
+	}
+	gosort.Slice(ret.Attributes, func(i, j int) bool {
+		a, b := ret.Attributes[i], ret.Attributes[j]
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		}
+		return a.Name < b.Name
+	})
+
+	return ret
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareDevices(t *testing.T) {
+	devs := []InvDevice{
+		{
+			ID: "2",
+			Attributes: DeviceAttributes{
+				{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+				{Scope: "inventory", Name: "artifact_name", Value: "v2"},
+			},
+		},
+		{
+			ID: "1",
+			Attributes: DeviceAttributes{
+				{Scope: "inventory", Name: "device_type", Value: "rpi4"},
+				{Scope: "inventory", Name: "artifact_name", Value: "v1"},
+				{Scope: "identity", Name: "mac", Value: "00:11"},
+			},
+		},
+	}
+	ids := []string{"1", "2"}
+
+	res := CompareDevices(ids, devs, false)
+	assert.Equal(t, &DeviceComparison{
+		DeviceIDs: ids,
+		Attributes: []AttributeComparison{
+			{Scope: "identity", Name: "mac", Values: []interface{}{"00:11", nil}, Differs: true},
+			{Scope: "inventory", Name: "artifact_name", Values: []interface{}{"v1", "v2"}, Differs: true},
+		},
+	}, res)
+
+	res = CompareDevices(ids, devs, true)
+	assert.Len(t, res.Attributes, 3)
+	assert.Equal(t, AttributeComparison{
+		Scope: "inventory", Name: "device_type", Values: []interface{}{"rpi4", "rpi4"},
+	}, res.Attributes[2])
+
+	assert.Error(t, CompareParams{DeviceIDs: []string{"1"}}.Validate())
+	assert.Error(t, CompareParams{DeviceIDs: []string{"1", "1"}}.Validate())
+	assert.NoError(t, CompareParams{DeviceIDs: ids}.Validate())
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	gosort "sort"
+)
+
+// the configuration attributes are named after the configuration
+// key, prefixed with its state, e.g. "reported.timezone"
+const (
+	ConfigurationReported = "reported"
+	ConfigurationDesired  = "desired"
+)
+
+// ConfigurationAttributes translates the reported and desired device
+// configuration to the "configuration" scope attributes
+func ConfigurationAttributes(reported, desired map[string]string) []InvDeviceAttribute {
+	attrs := make([]InvDeviceAttribute, 0, len(reported)+len(desired))
+	for _, state := range []struct {
+		prefix string
+		config map[string]string
+	}{
+		{prefix: ConfigurationReported, config: reported},
+		{prefix: ConfigurationDesired, config: desired},
+	} {
+		keys := make([]string, 0, len(state.config))
+		for k := range state.config {
+			keys = append(keys, k)
+		}
+		gosort.Strings(keys)
+
+		for _, k := range keys {
+			attrs = append(attrs, InvDeviceAttribute{
+				Name:  state.prefix + "." + k,
+				Scope: scopeConfiguration,
+				Value: state.config[k],
+			})
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationAttributes(t *testing.T) {
+	attrs := ConfigurationAttributes(
+		map[string]string{"timezone": "UTC", "hostname": "rpi"},
+		map[string]string{"timezone": "CET"},
+	)
+
+	dev, err := NewDeviceFromInv("tenant", &InvDevice{
+		ID:         "foo",
+		Attributes: attrs,
+	}, Settings{})
+	assert.NoError(t, err)
+	assert.Len(t, dev.ConfigurationAttributes, 3)
+
+	b, err := json.Marshal(dev)
+	assert.NoError(t, err)
+
+	var res map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, []interface{}{"rpi"}, res[ToAttr(scopeConfiguration, "reported.hostname", TypeStr)])
+	assert.Equal(t, []interface{}{"UTC"}, res[ToAttr(scopeConfiguration, "reported.timezone", TypeStr)])
+	assert.Equal(t, []interface{}{"CET"}, res[ToAttr(scopeConfiguration, "desired.timezone", TypeStr)])
+
+	scope, name, err := MaybeParseAttr(
+		ToAttr(scopeConfiguration, "desired.timezone", TypeStr))
+	assert.NoError(t, err)
+	assert.Equal(t, AttrScopeConfiguration, scope)
+	assert.Equal(t, "desired.timezone", Redot(name))
+}
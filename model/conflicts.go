@@ -0,0 +1,58 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// MappingConflict records an attribute quarantined on indexing: its
+// value was rejected by the type of the devices index mapping field,
+// so the device was indexed without it
+type MappingConflict struct {
+	TenantID  string    `json:"tenant_id"`
+	DeviceID  string    `json:"device_id"`
+	Field     string    `json:"field"`
+	Scope     string    `json:"scope"`
+	Attribute string    `json:"attribute"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Quarantine removes the attribute indexed as 'field', or as one of
+// its subfields, from the device; returns the removed attribute,
+// or nil if the device has no such attribute
+func (d *Device) Quarantine(field string) *InventoryAttribute {
+	field = strings.SplitN(field, ".", 2)[0]
+
+	for _, attrs := range []*DeviceInventory{
+		&d.CustomAttributes,
+		&d.IdentityAttributes,
+		&d.InventoryAttributes,
+		&d.SystemAttributes,
+		&d.ConfigurationAttributes,
+		&d.TagsAttributes,
+	} {
+		for i, a := range *attrs {
+			if name, _ := a.Map(); name == field {
+				*attrs = append((*attrs)[:i], (*attrs)[i+1:]...)
+				return a
+			}
+		}
+	}
+
+	return nil
+}
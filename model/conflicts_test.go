@@ -0,0 +1,49 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceQuarantine(t *testing.T) {
+	dev := NewDevice("foo")
+	dev.InventoryAttributes = DeviceInventory{
+		NewInventoryAttribute(scopeInventory).SetName("mem_total").SetNumeric(1024),
+		NewInventoryAttribute(scopeInventory).SetName("rootfs.version").SetString("v1"),
+	}
+	dev.IdentityAttributes = DeviceInventory{
+		NewInventoryAttribute(scopeIdentity).SetName("mac").SetString("00:11:22:33:44:55"),
+	}
+
+	attr := dev.Quarantine(ToAttr(scopeInventory, "rootfs.version", TypeStr))
+	if assert.NotNil(t, attr) {
+		assert.Equal(t, "rootfs.version", attr.Name)
+	}
+	assert.Len(t, dev.InventoryAttributes, 1)
+
+	// subfields resolve to their attribute
+	attr = dev.Quarantine("identity_mac_str.ip")
+	if assert.NotNil(t, attr) {
+		assert.Equal(t, "mac", attr.Name)
+	}
+	assert.Len(t, dev.IdentityAttributes, 0)
+
+	// the type suffix must match too
+	assert.Nil(t, dev.Quarantine("inventory_mem_total_str"))
+	assert.Len(t, dev.InventoryAttributes, 1)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// the deployment failure times are indexed as strings, in a fixed
+// format, so that they sort, and compare in range queries, as times
+const deploymentFailedTsFormat = "2006-01-02T15:04:05Z"
+
+// the update script drops the failures out of the window from the
+// '_source', as the doc values aren't available to update_by_query
+// scripts, and recounts the remaining ones
+const deploymentsFailedScript = "" +
+	"List failed = ctx._source[params.ts_field]; " +
+	"if (failed == null) { ctx.op = 'noop'; return; } " +
+	"List kept = new ArrayList(); " +
+	"for (def ts : failed) { if (ts.compareTo(params.since) >= 0) { kept.add(ts); } } " +
+	"if (kept.size() == failed.size()) { ctx.op = 'noop'; return; } " +
+	"ctx._source[params.ts_field] = kept; " +
+	"ctx._source[params.count_field] = [kept.size()];"
+
+// FormatDeploymentFailedTs formats the time of a deployment failure
+// as indexed in the system/deployments_failed_ts attribute
+func FormatDeploymentFailedTs(ts time.Time) string {
+	return ts.UTC().Format(deploymentFailedTsFormat)
+}
+
+// BuildDeploymentsFailedUpdate prepares the update_by_query body
+// dropping the deployment failures before 'since' from the failed
+// deployments counts of the devices failed since
+func BuildDeploymentsFailedUpdate(since time.Time) M {
+	tsField := ToAttr(scopeSystem, AttrNameDeploymentsFailedTs, TypeStr)
+	sinceTs := FormatDeploymentFailedTs(since)
+
+	return M{
+		"query": M{
+			"range": M{
+				tsField: M{"lt": sinceTs},
+			},
+		},
+		"script": M{
+			"lang":   "painless",
+			"source": deploymentsFailedScript,
+			"params": M{
+				"since":       sinceTs,
+				"ts_field":    tsField,
+				"count_field": ToAttr(scopeSystem, AttrNameDeploymentsFailed, TypeNum),
+			},
+		},
+	}
+}
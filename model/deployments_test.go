@@ -0,0 +1,37 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDeploymentsFailedUpdate(t *testing.T) {
+	since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+
+	update := BuildDeploymentsFailedUpdate(since)
+	assert.Equal(t, M{
+		"range": M{
+			"system_deployments_failed_ts_str": M{"lt": "2021-06-01T10:00:00Z"},
+		},
+	}, update["query"])
+
+	params := update["script"].(M)["params"].(M)
+	assert.Equal(t, "2021-06-01T10:00:00Z", params["since"])
+	assert.Equal(t, "system_deployments_failed_30d_num", params["count_field"])
+}
@@ -48,6 +48,9 @@ type Device struct {
 	SystemAttributes    DeviceInventory `json:"systemAttributes,omitempty"`
 	CreatedAt           *time.Time      `json:"createdAt,omitempty"`
 	UpdatedAt           *time.Time      `json:"updatedAt,omitempty"`
+
+	ConfigurationAttributes DeviceInventory `json:"configurationAttributes,omitempty"`
+	TagsAttributes          DeviceInventory `json:"tagsAttributes,omitempty"`
 }
 
 func NewDevice(id string) *Device {
@@ -56,7 +59,7 @@ func NewDevice(id string) *Device {
 	}
 }
 
-func NewDeviceFromInv(tenant string, invdev *InvDevice) (*Device, error) {
+func NewDeviceFromInv(tenant string, invdev *InvDevice, s Settings) (*Device, error) {
 	dev := NewDevice(string(invdev.ID))
 	dev.SetTenantID(tenant)
 
@@ -67,6 +70,9 @@ func NewDeviceFromInv(tenant string, invdev *InvDevice) (*Device, error) {
 
 		attr.SetName(invattr.Name).
 			SetVal(invattr.Value)
+		s.Nested.nest(attr, invattr.Value)
+		s.Normalizers.normalize(attr)
+		s.Redactions.redact(attr)
 
 		if err := dev.AppendAttr(attr); err != nil {
 			return nil, err
@@ -132,6 +138,12 @@ func (a *Device) AppendAttr(attr *InventoryAttribute) error {
 	case scopeCustom:
 		a.CustomAttributes = append(a.CustomAttributes, attr)
 		return nil
+	case scopeConfiguration:
+		a.ConfigurationAttributes = append(a.ConfigurationAttributes, attr)
+		return nil
+	case scopeTags:
+		a.TagsAttributes = append(a.TagsAttributes, attr)
+		return nil
 	default:
 		return errors.New("unknown attribute scope " + attr.Scope)
 	}
@@ -224,11 +236,31 @@ func (a *Device) SetUpdatedAt(val time.Time) *Device {
 
 type DeviceInventory []*InventoryAttribute
 
+// Upsert replaces the attributes of the same name,
+// and appends the others
+func (inv *DeviceInventory) Upsert(attrs ...*InventoryAttribute) {
+	for _, attr := range attrs {
+		found := false
+		for i, a := range *inv {
+			if a.Name == attr.Name {
+				(*inv)[i] = attr
+				found = true
+				break
+			}
+		}
+		if !found {
+			*inv = append(*inv, attr)
+		}
+	}
+}
+
 type InventoryAttribute struct {
 	Scope   string
 	Name    string
 	String  []string
 	Numeric []float64
+	// Objects are the values of the nested attributes
+	Objects []map[string]interface{}
 }
 
 func (a *InventoryAttribute) IsStr() bool {
@@ -239,6 +271,10 @@ func (a *InventoryAttribute) IsNum() bool {
 	return a.Numeric != nil
 }
 
+func (a *InventoryAttribute) IsObj() bool {
+	return a.Objects != nil
+}
+
 func NewInventoryAttribute(s string) *InventoryAttribute {
 	return &InventoryAttribute{
 		Scope: s,
@@ -296,6 +332,13 @@ func (a *InventoryAttribute) SetNumerics(val []float64) *InventoryAttribute {
 	return a
 }
 
+func (a *InventoryAttribute) SetObjects(val []map[string]interface{}) *InventoryAttribute {
+	a.Objects = val
+	a.String = nil
+	a.Numeric = nil
+	return a
+}
+
 // SetVal inspects the 'val' type and sets the correct subtype field
 // useful for translating from inventory attributes (interface{})
 func (a *InventoryAttribute) SetVal(val interface{}) *InventoryAttribute {
@@ -304,6 +347,8 @@ func (a *InventoryAttribute) SetVal(val interface{}) *InventoryAttribute {
 		a.SetNumeric(val)
 	case string:
 		a.SetString(val)
+	case map[string]interface{}:
+		a.SetObjects([]map[string]interface{}{val})
 	case []interface{}:
 		switch val[0].(type) {
 		case float64:
@@ -318,6 +363,14 @@ func (a *InventoryAttribute) SetVal(val interface{}) *InventoryAttribute {
 				strs[i] = v.(string)
 			}
 			a.SetStrings(strs)
+		case map[string]interface{}:
+			objs := make([]map[string]interface{}, 0, len(val))
+			for _, v := range val {
+				if obj, ok := v.(map[string]interface{}); ok {
+					objs = append(objs, obj)
+				}
+			}
+			a.SetObjects(objs)
 		}
 	}
 
@@ -484,6 +537,16 @@ func (d *Device) MarshalJSON() ([]byte, error) {
 		m[name] = val
 	}
 
+	for _, a := range d.ConfigurationAttributes {
+		name, val := a.Map()
+		m[name] = val
+	}
+
+	for _, a := range d.TagsAttributes {
+		name, val := a.Map()
+		m[name] = val
+	}
+
 	return json.Marshal(m)
 }
 
@@ -501,6 +564,11 @@ func (a *InventoryAttribute) Map() (string, interface{}) {
 		val = a.Numeric
 	}
 
+	if a.IsObj() {
+		typ = TypeObj
+		val = a.Objects
+	}
+
 	name := ToAttr(a.Scope, a.Name, typ)
 
 	return name, val
@@ -512,7 +580,8 @@ func MaybeParseAttr(field string) (string, string, error) {
 	scope := ""
 	name := ""
 
-	for _, s := range []string{scopeInventory, scopeIdentity, scopeCustom, scopeSystem} {
+	for _, s := range []string{scopeInventory, scopeIdentity, scopeCustom, scopeSystem,
+		scopeConfiguration, scopeTags} {
 		if strings.HasPrefix(field, s+"_") {
 			scope = s
 			break
@@ -520,7 +589,7 @@ func MaybeParseAttr(field string) (string, string, error) {
 	}
 
 	if scope != "" {
-		for _, s := range []string{typeStr, typeNum} {
+		for _, s := range []string{typeStr, typeNum, typeObj} {
 			if strings.HasSuffix(field, "_"+s) {
 				// strip the prefix/suffix
 				start := strings.Index(field, "_")
@@ -0,0 +1,312 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	dslDefaultSize = 20
+	DSLMaxSize     = 500
+
+	// ES index.max_result_window default
+	maxResultWindow = 10000
+)
+
+var (
+	// top-level search body keys accepted in a raw query
+	dslAllowedKeys = map[string]bool{
+		"query":            true,
+		"size":             true,
+		"from":             true,
+		"sort":             true,
+		"_source":          true,
+		"fields":           true,
+		"aggs":             true,
+		"aggregations":     true,
+		"track_total_hits": true,
+		"runtime_mappings": true,
+	}
+
+	// compound query clauses, and the keys holding their sub-clauses
+	dslCompoundClauses = map[string][]string{
+		"bool":           {"must", "must_not", "should", "filter"},
+		"constant_score": {"filter"},
+		"dis_max":        {"queries"},
+	}
+
+	// leaf query clauses, their parameters are not inspected further
+	dslLeafClauses = map[string]bool{
+		"match_all":    true,
+		"match_none":   true,
+		"match":        true,
+		"match_phrase": true,
+		"term":         true,
+		"terms":        true,
+		"range":        true,
+		"exists":       true,
+		"prefix":       true,
+		"wildcard":     true,
+		"regexp":       true,
+		"fuzzy":        true,
+		"ids":          true,
+	}
+
+	dslAggregations = map[string]bool{
+		"terms":          true,
+		"composite":      true,
+		"filter":         true,
+		"filters":        true,
+		"missing":        true,
+		"range":          true,
+		"date_range":     true,
+		"histogram":      true,
+		"date_histogram": true,
+		"min":            true,
+		"max":            true,
+		"avg":            true,
+		"sum":            true,
+		"stats":          true,
+		"value_count":    true,
+		"cardinality":    true,
+		"percentiles":    true,
+	}
+
+	ErrDSLSizeExceeded = errors.Errorf("size must not exceed %d", DSLMaxSize)
+)
+
+// RawQuery is a raw ES search body, restricted to an allow-listed
+// subset of the query DSL, and executed read-only within a single tenant
+type RawQuery map[string]interface{}
+
+func (q RawQuery) Validate() error {
+	for k, v := range q {
+		if !dslAllowedKeys[k] {
+			return errors.Errorf("search body key not allowed: %s", k)
+		}
+		switch k {
+		case "query":
+			if err := validateDSLClause(v); err != nil {
+				return errors.Wrap(err, "invalid query")
+			}
+		case "aggs", "aggregations":
+			if err := validateDSLAggs(v); err != nil {
+				return errors.Wrap(err, "invalid aggregations")
+			}
+		case "runtime_mappings":
+			if err := validateRuntimeMappings(v); err != nil {
+				return errors.Wrap(err, "invalid runtime mappings")
+			}
+		case "sort":
+			if hasScript(v) {
+				return errors.New("script sorting is not allowed")
+			}
+		case "size", "from":
+			n, ok := v.(float64)
+			if !ok || n < 0 {
+				return errors.Errorf("%s must be a non-negative number", k)
+			}
+			if k == "size" && n > DSLMaxSize {
+				return ErrDSLSizeExceeded
+			}
+		}
+	}
+
+	if from, ok := q["from"].(float64); ok && from+q.size() > maxResultWindow {
+		return errors.Errorf("from + size must not exceed %d", maxResultWindow)
+	}
+
+	return nil
+}
+
+// ValidateFeatures rejects the raw queries using the features
+// the cluster doesn't support, see Capabilities
+func (q RawQuery) ValidateFeatures(s Settings) error {
+	if _, ok := q["runtime_mappings"]; ok {
+		return requireFeature(s.capabilities().RuntimeFields, "runtime fields")
+	}
+	return nil
+}
+
+func (q RawQuery) size() float64 {
+	if size, ok := q["size"].(float64); ok {
+		return size
+	}
+	return dslDefaultSize
+}
+
+// ForTenant wraps the query so that only tenant 'tid' devices match,
+// regardless of the query contents
+func (q RawQuery) ForTenant(tid string) M {
+	ret := M{}
+	for k, v := range q {
+		ret[k] = v
+	}
+
+	tenantBool := M{
+		"filter": S{
+			M{"term": M{"tenantID": tid}},
+		},
+	}
+	if query, ok := q["query"]; ok {
+		tenantBool["must"] = S{query}
+	}
+	ret["query"] = M{"bool": tenantBool}
+	ret["size"] = q.size()
+
+	return ret
+}
+
+func validateDSLClause(clause interface{}) error {
+	clauseM, ok := clause.(map[string]interface{})
+	if !ok || len(clauseM) != 1 {
+		return errors.New("query clause must be an object with a single key")
+	}
+
+	for typ, params := range clauseM {
+		if typ == "terms" {
+			return validateDSLTerms(params)
+		}
+		if dslLeafClauses[typ] {
+			return nil
+		}
+
+		subKeys, ok := dslCompoundClauses[typ]
+		if !ok {
+			return errors.Errorf("query clause not allowed: %s", typ)
+		}
+
+		paramsM, ok := params.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("malformed %s clause", typ)
+		}
+		for _, k := range subKeys {
+			switch sub := paramsM[k].(type) {
+			case nil:
+			case []interface{}:
+				for _, c := range sub {
+					if err := validateDSLClause(c); err != nil {
+						return err
+					}
+				}
+			default:
+				if err := validateDSLClause(sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateDSLTerms rejects the terms lookups, whose object parameters
+// fetch the terms from any document, of any index: only the lists
+// of values, and the scalar parameters (e.g. boost), are accepted
+func validateDSLTerms(params interface{}) error {
+	paramsM, ok := params.(map[string]interface{})
+	if !ok {
+		return errors.New("malformed terms clause")
+	}
+	for field, v := range paramsM {
+		if _, ok := v.(map[string]interface{}); ok {
+			return errors.Errorf("terms lookup not allowed: %s", field)
+		}
+	}
+	return nil
+}
+
+func validateDSLAggs(aggs interface{}) error {
+	aggsM, ok := aggs.(map[string]interface{})
+	if !ok {
+		return errors.New("aggregations must be an object")
+	}
+
+	for name, agg := range aggsM {
+		aggM, ok := agg.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("malformed aggregation %s", name)
+		}
+		for typ, params := range aggM {
+			switch {
+			case typ == "aggs" || typ == "aggregations":
+				if err := validateDSLAggs(params); err != nil {
+					return err
+				}
+			case typ == "meta":
+			case !dslAggregations[typ]:
+				return errors.Errorf("aggregation not allowed: %s", typ)
+			case hasScript(params):
+				return errors.Errorf("scripts are not allowed in aggregation %s", name)
+			case typ == "filter":
+				if err := validateDSLClause(params); err != nil {
+					return err
+				}
+			case typ == "filters":
+				if err := validateDSLFilters(params); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateDSLFilters(params interface{}) error {
+	paramsM, ok := params.(map[string]interface{})
+	if !ok {
+		return errors.New("malformed filters aggregation")
+	}
+
+	switch filters := paramsM["filters"].(type) {
+	case map[string]interface{}:
+		for _, c := range filters {
+			if err := validateDSLClause(c); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, c := range filters {
+			if err := validateDSLClause(c); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("malformed filters aggregation")
+	}
+
+	return nil
+}
+
+// hasScript looks for a 'script' (or '_script') key at any depth
+func hasScript(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			if k == "script" || k == "_script" || hasScript(sub) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, sub := range v {
+			if hasScript(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawQueryValidate(t *testing.T) {
+	testCases := map[string]struct {
+		body string
+		err  bool
+	}{
+		"ok": {
+			body: `{
+				"query": {"bool": {
+					"must": [{"term": {"status": "accepted"}}],
+					"should": {"range": {"updatedAt": {"gte": "now-1d"}}}
+				}},
+				"aggs": {"types": {
+					"terms": {"field": "inventory_device_type_str"},
+					"aggs": {"groups": {"cardinality": {"field": "groupName"}}}
+				}},
+				"size": 10
+			}`,
+		},
+		"error, script query": {
+			body: `{"query": {"bool": {"filter": {"script": {"script": "1"}}}}}`,
+			err:  true,
+		},
+		"error, script in aggregation": {
+			body: `{"aggs": {"a": {"terms": {"script": {"source": "1"}}}}}`,
+			err:  true,
+		},
+		"error, script sort": {
+			body: `{"sort": [{"_script": {"type": "number"}}]}`,
+			err:  true,
+		},
+		"error, key not allowed": {
+			body: `{"script_fields": {}}`,
+			err:  true,
+		},
+		"ok, terms": {
+			body: `{"query": {"terms": {"groupName": ["foo", "bar"], "boost": 1.0}}}`,
+		},
+		"error, terms lookup": {
+			body: `{"query": {"bool": {"filter": {"terms": {"id": {
+				"index": "devices-other-tenant",
+				"id": "1",
+				"path": "ids"
+			}}}}}}`,
+			err: true,
+		},
+		"error, size exceeded": {
+			body: `{"size": 10000}`,
+			err:  true,
+		},
+		"ok, runtime field": {
+			body: `{
+				"runtime_mappings": {"type_lower": {
+					"type": "keyword",
+					"script": {"source": "emit(doc['inventory_device_type_str'].value.toLowerCase())"}
+				}},
+				"query": {"term": {"type_lower": "qemux86-64"}}
+			}`,
+		},
+		"error, runtime field loop": {
+			body: `{"runtime_mappings": {"f": {
+				"type": "long",
+				"script": "while (true) { emit(1) }"
+			}}}`,
+			err: true,
+		},
+		"error, runtime field class access": {
+			body: `{"runtime_mappings": {"f": {
+				"type": "keyword",
+				"script": "emit(System.getenv('HOME'))"
+			}}}`,
+			err: true,
+		},
+		"error, runtime field type": {
+			body: `{"runtime_mappings": {"f": {"type": "geo_point", "script": "emit(1)"}}}`,
+			err:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var q RawQuery
+			assert.NoError(t, json.Unmarshal([]byte(tc.body), &q))
+
+			err := q.Validate()
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRawQueryForTenant(t *testing.T) {
+	q := RawQuery{
+		"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+	}
+
+	b, err := json.Marshal(q.ForTenant("foo"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"query": {"bool": {
+			"filter": [{"term": {"tenantID": "foo"}}],
+			"must": [{"match_all": {}}]
+		}},
+		"size": 20
+	}`, string(b))
+}
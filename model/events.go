@@ -0,0 +1,63 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// event subjects
+const (
+	SubjectDeviceGroupChanged  = "reporting.devices.group.changed"
+	SubjectDeviceStatusChanged = "reporting.devices.status.changed"
+)
+
+// DeviceChangeEvent is emitted when indexing detects
+// a change of a device's group or status
+type DeviceChangeEvent struct {
+	Subject   string    `json:"subject"`
+	TenantID  string    `json:"tenant_id"`
+	DeviceID  string    `json:"device_id"`
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeviceChangeEvents compares the previous and next versions of a device
+// and returns the group/status change events, if any
+func DeviceChangeEvents(prev, next *Device, ts time.Time) []*DeviceChangeEvent {
+	events := []*DeviceChangeEvent{}
+	if prev.GetGroupName() != next.GetGroupName() {
+		events = append(events, &DeviceChangeEvent{
+			Subject:   SubjectDeviceGroupChanged,
+			TenantID:  next.GetTenantID(),
+			DeviceID:  next.GetID(),
+			Old:       prev.GetGroupName(),
+			New:       next.GetGroupName(),
+			Timestamp: ts,
+		})
+	}
+	if prev.GetStatus() != next.GetStatus() {
+		events = append(events, &DeviceChangeEvent{
+			Subject:   SubjectDeviceStatusChanged,
+			TenantID:  next.GetTenantID(),
+			DeviceID:  next.GetID(),
+			Old:       prev.GetStatus(),
+			New:       next.GetStatus(),
+			Timestamp: ts,
+		})
+	}
+	return events
+}
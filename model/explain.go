@@ -0,0 +1,93 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strconv"
+)
+
+// the name prefixes of the any and exclude filters, which are
+// numbered apart from the filters, e.g. "any:0"
+const (
+	namedAnyPrefix     = "any:"
+	namedExcludePrefix = "exclude:"
+)
+
+// namedFilter wraps the clauses of a filter in a bool query named
+// after the filter position, so that ES reports, per hit, the
+// filters it matched; the clauses of the "*" scope filters are
+// named after the position and the scope, e.g. "0:inventory"
+type namedFilter struct {
+	part QueryPart
+	name string
+}
+
+func NewNamedFilter(part QueryPart, i int) *namedFilter {
+	return newNamedFilter(part, strconv.Itoa(i))
+}
+
+func newNamedFilter(part QueryPart, name string) *namedFilter {
+	return &namedFilter{
+		part: part,
+		name: name,
+	}
+}
+
+func (f *namedFilter) AddTo(q Query) Query {
+	return q.Must(f.boolQuery())
+}
+
+// boolQuery returns the named bool query of the filter clauses
+func (f *namedFilter) boolQuery() M {
+	if anyScope, ok := f.part.(*filterAnyScope); ok {
+		anyScope.setNames(f.name)
+	}
+
+	sub := &query{}
+	f.part.AddTo(sub)
+	named := sub.boolQuery()
+	named["bool"].(M)["_name"] = f.name
+
+	return named
+}
+
+// namedFiltersQuery returns the bool query matching the filter, named
+// 'name' if not empty, see namedFilter
+func namedFiltersQuery(f FilterPredicate, name string, s Settings) (M, error) {
+	if name == "" {
+		return FiltersQuery([]FilterPredicate{f}, s)
+	}
+	part, err := getFilterPart(f, s)
+	if err != nil {
+		return nil, err
+	}
+	return newNamedFilter(part, name).boolQuery(), nil
+}
+
+// MatchedFilters extracts the names of the filters a search hit matched
+func MatchedFilters(hit map[string]interface{}) []string {
+	matched, _ := hit["matched_queries"].([]interface{})
+	if len(matched) == 0 {
+		return nil
+	}
+
+	ret := make([]string, 0, len(matched))
+	for _, m := range matched {
+		if name, ok := m.(string); ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
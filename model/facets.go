@@ -0,0 +1,123 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+// MaxFacets is the maximum number of facets of a search
+const MaxFacets = 10
+
+// facets counts the values of the facet attributes alongside the
+// search hits; the filters on the facet attributes are applied as a
+// post filter, so that each facet is counted with all the other
+// filters applied, but not its own
+type facets struct {
+	postFilter *query
+	aggs       M
+}
+
+// isFacetFilter tells if the filter is on one of the facet attributes
+func isFacetFilter(terms []AggregationTerm, fp FilterPredicate) bool {
+	for _, t := range terms {
+		if t.Scope == fp.Scope && t.Attribute == fp.Attribute {
+			return true
+		}
+	}
+	return false
+}
+
+// NewFacets counts the facet 'terms'; with 'explain', the post filter
+// clauses are named after the filter positions, see namedFilter
+func NewFacets(
+	terms []AggregationTerm,
+	filters []FilterPredicate,
+	explain bool,
+	s Settings,
+) (*facets, error) {
+	f := &facets{
+		postFilter: NewQuery().(*query),
+		aggs:       M{},
+	}
+
+	var facetFilters []FilterPredicate
+	for i, fp := range filters {
+		if !isFacetFilter(terms, fp) {
+			continue
+		}
+		part, err := getFilterPart(fp, s)
+		if err != nil {
+			return nil, err
+		}
+		if explain {
+			part = NewNamedFilter(part, i)
+		}
+		part.AddTo(f.postFilter)
+		facetFilters = append(facetFilters, fp)
+	}
+
+	for _, t := range terms {
+		others := NewQuery().(*query)
+		for _, fp := range facetFilters {
+			if t.Scope == fp.Scope && t.Attribute == fp.Attribute {
+				continue
+			}
+			part, err := getFilterPart(fp, s)
+			if err != nil {
+				return nil, err
+			}
+			part.AddTo(others)
+		}
+		f.aggs[t.Name] = M{
+			"filter": others.boolQuery(),
+			"aggs":   BuildAggregations([]AggregationTerm{t}),
+		}
+	}
+
+	return f, nil
+}
+
+func (f *facets) AddTo(q Query) Query {
+	if len(f.postFilter.must) > 0 || len(f.postFilter.mustNot) > 0 {
+		q = q.With(M{"post_filter": f.postFilter.boolQuery()})
+	}
+	return q.With(M{"aggs": f.aggs})
+}
+
+func validateFacets(terms []AggregationTerm) error {
+	if len(terms) > MaxFacets {
+		return errors.Errorf("at most %d facets are allowed", MaxFacets)
+	}
+	return validateAggregationTerms(terms, 1)
+}
+
+// ParseFacets translates the ES facet aggregations results
+// back to device aggregations
+func ParseFacets(terms []AggregationTerm, aggs map[string]interface{}) ([]DeviceAggregation, error) {
+	ret := make([]DeviceAggregation, 0, len(terms))
+	for _, t := range terms {
+		facet, ok := aggs[t.Name].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("can't process facet %s", t.Name)
+		}
+		agg, err := ParseAggregations([]AggregationTerm{t}, facet)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, agg...)
+	}
+	return ret, nil
+}
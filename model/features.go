@@ -0,0 +1,78 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the tenant feature flags
+const (
+	// FeatureFacets enables the facet counts of the searches
+	FeatureFacets = "facets"
+	// FeatureTableSearch enables the table mode search
+	FeatureTableSearch = "table_search"
+)
+
+// the feature flags, and whether they are enabled for
+// the tenants without an explicit setting
+var featureDefaults = map[string]bool{
+	FeatureFacets:      true,
+	FeatureTableSearch: true,
+}
+
+// FeatureFlags are the feature flags, by name
+type FeatureFlags map[string]bool
+
+func (f FeatureFlags) Validate() error {
+	for name := range f {
+		if _, ok := featureDefaults[name]; !ok {
+			return errors.Errorf("unknown feature flag: %s", name)
+		}
+	}
+	return nil
+}
+
+// TenantFeatures are the feature flags set for a tenant,
+// overriding the defaults, so that the features can be
+// rolled out gradually
+type TenantFeatures struct {
+	TenantID  string       `json:"tenant_id"`
+	Flags     FeatureFlags `json:"flags"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Enabled tells if the feature is enabled for the tenant,
+// or by default if the tenant features aren't set (nil)
+func (f *TenantFeatures) Enabled(name string) bool {
+	if f != nil {
+		if enabled, ok := f.Flags[name]; ok {
+			return enabled
+		}
+	}
+	return featureDefaults[name]
+}
+
+// Resolve returns all the feature flags of the tenant,
+// the defaults included
+func (f *TenantFeatures) Resolve() FeatureFlags {
+	flags := make(FeatureFlags, len(featureDefaults))
+	for name := range featureDefaults {
+		flags[name] = f.Enabled(name)
+	}
+	return flags
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantFeatures(t *testing.T) {
+	var none *TenantFeatures
+	assert.True(t, none.Enabled(FeatureFacets))
+	assert.False(t, none.Enabled("history"))
+
+	features := &TenantFeatures{
+		TenantID: "foo",
+		Flags:    FeatureFlags{FeatureFacets: false},
+	}
+	assert.False(t, features.Enabled(FeatureFacets))
+	assert.Equal(t, FeatureFlags{
+		FeatureFacets:      false,
+		FeatureTableSearch: true,
+	}, features.Resolve())
+
+	assert.NoError(t, FeatureFlags{FeatureTableSearch: false}.Validate())
+	assert.Error(t, FeatureFlags{"history": true}.Validate())
+}
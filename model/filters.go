@@ -31,11 +31,25 @@ var validSelectors = []interface{}{
 	"$ne",
 	"$nin",
 	"$exists",
+	"$empty",
 	"$regex",
+	"$cidr",
+	"$size",
+	"$fuzzy",
+	"$on_day",
+	"$in_week",
+	"$in_month",
+	"$elem_match",
 }
 
 var validSortOrders = []interface{}{"asc", "desc"}
 
+// SortScore is the sort attribute ordering devices by relevance score
+const SortScore = "_score"
+
+// ScopeAny is the filter scope matching the attribute in any scope
+const ScopeAny = "*"
+
 type SearchParams struct {
 	Page       int               `json:"page"`
 	PerPage    int               `json:"per_page"`
@@ -43,6 +57,66 @@ type SearchParams struct {
 	Sort       []SortCriteria    `json:"sort"`
 	Attributes []SelectAttribute `json:"attributes"`
 	DeviceIDs  []string          `json:"device_ids"`
+	WithScore  bool              `json:"with_score"`
+	Cursor     string            `json:"cursor"`
+	Collapse   *SelectAttribute  `json:"collapse"`
+	Facets     []AggregationTerm `json:"facets"`
+	// CountFirst checks for any matching devices with a cheap count
+	// first, skipping the search if there are none; not applied
+	// with facets, which are counted regardless of the matches
+	CountFirst bool `json:"count_first"`
+	// ExplainFilters annotates each device with the positions of
+	// the filters it matched, see InvDevice.MatchedFilters; the
+	// any filters are reported as "any:<position>", and the exclude
+	// filters, never matched by the found devices, are named alike
+	// as "exclude:<position>"
+	ExplainFilters bool `json:"explain_filters"`
+	// WithAttributeMetadata annotates the device attributes
+	// with the tenant's attribute metadata
+	WithAttributeMetadata bool `json:"with_attribute_metadata"`
+	// AttributeValuesLimit is the maximum number of values returned
+	// per multi-valued attribute, capped by the configured limit
+	AttributeValuesLimit int `json:"attribute_values_limit"`
+	// ExcludeFilters drop the devices matching any of them
+	ExcludeFilters []FilterPredicate `json:"exclude_filters"`
+	// AnyFilters require the devices to match at least
+	// MinimumShouldMatch of them (1 by default)
+	AnyFilters         []FilterPredicate `json:"any_filters"`
+	MinimumShouldMatch int               `json:"minimum_should_match"`
+
+	ScriptFilters []ScriptFilter `json:"script_filters"`
+
+	// Query is the structured query, combined with the other filters
+	Query *QueryNode `json:"query"`
+
+	// Sample returns a random sample of this many matching devices,
+	// rather than a page; the same SampleSeed returns the same sample
+	Sample     int    `json:"sample"`
+	SampleSeed *int64 `json:"sample_seed"`
+
+	// Timezone applies to the date filters and the date histogram
+	// facets without an explicit one, UTC by default
+	Timezone string `json:"timezone"`
+
+	// GroupCount counts the distinct values of the attribute
+	// among all the matching devices, not only the page
+	GroupCount *SelectAttribute `json:"group_count"`
+	// GroupCountType is the indexed type of the group count
+	// attribute, from the tenant mapping; strings if not set
+	GroupCountType Type `json:"-"`
+
+	// DefaultSort is the configured sort applied without an explicit one
+	DefaultSort []SortCriteria `json:"-"`
+}
+
+// SearchResult is the page of devices matching a search,
+// with the facet and group counts if requested
+type SearchResult struct {
+	Devices    []InvDevice
+	TotalCount int
+	NextCursor string
+	Facets     []DeviceAggregation
+	GroupCount *int
 }
 
 type Filter struct {
@@ -58,10 +132,14 @@ type FilterPredicate struct {
 	Value     interface{} `json:"value" bson:"value"`
 }
 
+// SortCriteria orders the devices by an attribute; with 'Numeric' the
+// string values holding numbers (e.g. "1024") are sorted as numbers,
+// rather than lexicographically, and the other strings last
 type SortCriteria struct {
 	Scope     string `json:"scope"`
 	Attribute string `json:"attribute"`
 	Order     string `json:"order"`
+	Numeric   bool   `json:"numeric,omitempty"`
 }
 
 type SelectAttribute struct {
@@ -77,11 +155,30 @@ func (sp SearchParams) Validate() error {
 		}
 	}
 
+	for _, f := range sp.ExcludeFilters {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "exclude_filters")
+		}
+	}
+
+	for _, f := range sp.AnyFilters {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "any_filters")
+		}
+	}
+	if sp.MinimumShouldMatch < 0 || sp.MinimumShouldMatch > len(sp.AnyFilters) {
+		return errors.Errorf("minimum_should_match must be between 0 and %d",
+			len(sp.AnyFilters))
+	}
+
 	for _, s := range sp.Sort {
 		err := validation.ValidateStruct(&s,
-			validation.Field(&s.Scope, validation.Required),
+			validation.Field(&s.Scope, validation.When(s.Attribute != SortScore,
+				validation.Required)),
 			validation.Field(&s.Attribute, validation.Required),
-			validation.Field(&s.Order, validation.Required, validation.In(validSortOrders...)))
+			validation.Field(&s.Order, validation.Required, validation.In(validSortOrders...)),
+			validation.Field(&s.Numeric, validation.When(s.Attribute == SortScore,
+				validation.Empty)))
 		if err != nil {
 			return err
 		}
@@ -95,9 +192,98 @@ func (sp SearchParams) Validate() error {
 			return err
 		}
 	}
+
+	if sp.AttributeValuesLimit < 0 {
+		return errors.New("attribute_values_limit must not be negative")
+	}
+
+	if sp.Cursor != "" {
+		if _, err := DecodeCursor(sp.Cursor); err != nil {
+			return err
+		}
+	}
+
+	if err := validateTimezone(sp.Timezone); err != nil {
+		return err
+	}
+
+	if err := sp.validateSample(); err != nil {
+		return errors.Wrap(err, "sample")
+	}
+
+	if sp.Query != nil {
+		if err := sp.Query.Validate(); err != nil {
+			return errors.Wrap(err, "query")
+		}
+	}
+
+	if err := validateScriptFilters(sp.ScriptFilters); err != nil {
+		return err
+	}
+
+	if err := validateFacets(sp.Facets); err != nil {
+		return errors.Wrap(err, "facets")
+	}
+
+	if err := sp.validateGroupCount(); err != nil {
+		return errors.Wrap(err, "group_count")
+	}
+
+	if sp.Collapse != nil {
+		err := validation.ValidateStruct(sp.Collapse,
+			validation.Field(&sp.Collapse.Scope, validation.Required),
+			validation.Field(&sp.Collapse.Attribute, validation.Required))
+		if err != nil {
+			return errors.Wrap(err, "collapse")
+		}
+		if sp.Cursor != "" {
+			return errors.New("collapse can't be combined with a cursor")
+		}
+		if err := sp.validateReservedFacet(CollapseCountAggregation); err != nil {
+			return errors.Wrap(err, "collapse")
+		}
+	}
 	return nil
 }
 
+// QueriedAttributes returns the attributes the search filters, sorts,
+// collapses, counts or aggregates the devices by
+func (sp SearchParams) QueriedAttributes() []SelectAttribute {
+	ret := filterAttributes(nil, sp.Filters)
+	ret = filterAttributes(ret, sp.ExcludeFilters)
+	ret = filterAttributes(ret, sp.AnyFilters)
+	if sp.Query != nil {
+		for _, f := range sp.Query.Filters() {
+			ret = append(ret, SelectAttribute{Scope: f.Scope, Attribute: f.Attribute})
+		}
+	}
+	for _, s := range sp.Sort {
+		ret = append(ret, SelectAttribute{Scope: s.Scope, Attribute: s.Attribute})
+	}
+	if sp.Collapse != nil {
+		ret = append(ret, *sp.Collapse)
+	}
+	if sp.GroupCount != nil {
+		ret = append(ret, *sp.GroupCount)
+	}
+	return termsAttributes(ret, sp.Facets)
+}
+
+func filterAttributes(attrs []SelectAttribute, filters []FilterPredicate) []SelectAttribute {
+	for _, f := range filters {
+		attrs = append(attrs, SelectAttribute{Scope: f.Scope, Attribute: f.Attribute})
+	}
+	return attrs
+}
+
+func termsAttributes(attrs []SelectAttribute, terms []AggregationTerm) []SelectAttribute {
+	for _, t := range terms {
+		attrs = append(attrs, SelectAttribute{Scope: t.Scope, Attribute: t.Attribute})
+		attrs = termsAttributes(attrs, t.Aggregations)
+	}
+	return attrs
+}
+
 func (f Filter) Validate() error {
 	err := validation.ValidateStruct(&f,
 		validation.Field(&f.Name, validation.Required))
@@ -0,0 +1,100 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	gosort "sort"
+	"strings"
+)
+
+// queryShape is the normalized form of the search params: the
+// filter values are stripped, and the filters sorted, so that
+// searches differing only by the values share a fingerprint
+type queryShape struct {
+	Filters       []string `json:"filters,omitempty"`
+	Query         string   `json:"query,omitempty"`
+	Sort          []string `json:"sort,omitempty"`
+	Facets        []string `json:"facets,omitempty"`
+	Collapse      string   `json:"collapse,omitempty"`
+	GroupCount    string   `json:"group_count,omitempty"`
+	ScriptFilters int      `json:"script_filters,omitempty"`
+	DeviceIDs     bool     `json:"device_ids,omitempty"`
+}
+
+// Fingerprint returns a stable hash of the search params shape,
+// suitable for grouping the searches in logs and metrics
+func (sp SearchParams) Fingerprint() string {
+	shape := queryShape{
+		ScriptFilters: len(sp.ScriptFilters),
+		DeviceIDs:     len(sp.DeviceIDs) > 0,
+	}
+	for _, f := range sp.Filters {
+		shape.Filters = append(shape.Filters, f.Scope+"/"+f.Attribute+" "+f.Type)
+	}
+	for _, f := range sp.ExcludeFilters {
+		shape.Filters = append(shape.Filters, "not "+f.Scope+"/"+f.Attribute+" "+f.Type)
+	}
+	for _, f := range sp.AnyFilters {
+		shape.Filters = append(shape.Filters, "any "+f.Scope+"/"+f.Attribute+" "+f.Type)
+	}
+	gosort.Strings(shape.Filters)
+	if sp.Query != nil {
+		shape.Query = sp.Query.shape()
+	}
+	// the sort criteria order matters
+	for _, s := range sp.Sort {
+		shape.Sort = append(shape.Sort, s.Scope+"/"+s.Attribute+" "+s.Order)
+	}
+	for _, f := range sp.Facets {
+		shape.Facets = append(shape.Facets, f.Scope+"/"+f.Attribute+" "+f.Type)
+	}
+	gosort.Strings(shape.Facets)
+	if sp.Collapse != nil {
+		shape.Collapse = sp.Collapse.Scope + "/" + sp.Collapse.Attribute
+	}
+	if sp.GroupCount != nil {
+		shape.GroupCount = sp.GroupCount.Scope + "/" + sp.GroupCount.Attribute
+	}
+
+	b, _ := json.Marshal(shape)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// shape returns the normalized form of the query tree, like the
+// filters: without the values, and with the and/or children sorted,
+// e.g. "and(inventory/device_type $eq,not(system/group $in))"
+func (n QueryNode) shape() string {
+	switch {
+	case n.Filter != nil:
+		return n.Filter.Scope + "/" + n.Filter.Attribute + " " + n.Filter.Type
+	case n.Not != nil:
+		return "not(" + n.Not.shape() + ")"
+	}
+
+	op, children := "and", n.And
+	if n.Or != nil {
+		op, children = "or", n.Or
+	}
+	shapes := make([]string, 0, len(children))
+	for _, c := range children {
+		shapes = append(shapes, c.shape())
+	}
+	gosort.Strings(shapes)
+	return op + "(" + strings.Join(shapes, ",") + ")"
+}
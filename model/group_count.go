@@ -0,0 +1,144 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GroupCountAggregation is the name of the group count aggregation,
+	// reserved among the facet names
+	GroupCountAggregation = "group_count"
+	// CollapseCountAggregation is the name of the aggregation counting
+	// the collapsed results, reserved among the facet names
+	CollapseCountAggregation = "collapse_count"
+)
+
+// groupCount counts the distinct values of an attribute among all the
+// devices matching a search, not only the page; the count is approximate
+// above a few thousands of values
+type groupCount struct {
+	name   string
+	attr   SelectAttribute
+	typ    Type
+	facets *facets
+}
+
+// NewGroupCount counts the distinct values of the attribute, indexed
+// as 'typ' (strings for TypeAny); with facets, the facet filters,
+// applied as a post filter, are applied to the count too, which
+// shares the aggregations with the facets
+func NewGroupCount(attr SelectAttribute, typ Type, f *facets) *groupCount {
+	if typ == TypeAny {
+		typ = TypeStr
+	}
+	return &groupCount{
+		name:   GroupCountAggregation,
+		attr:   attr,
+		typ:    typ,
+		facets: f,
+	}
+}
+
+// NewCollapseCount counts the results of a search collapsed by the
+// attribute, i.e. its distinct values, as NewGroupCount does
+func NewCollapseCount(attr SelectAttribute, f *facets) *groupCount {
+	return &groupCount{
+		name:   CollapseCountAggregation,
+		attr:   attr,
+		typ:    TypeStr,
+		facets: f,
+	}
+}
+
+func (g *groupCount) AddTo(q Query) Query {
+	agg := M{
+		AggregationCardinality: M{
+			"field": ToAttr(g.attr.Scope, g.attr.Attribute, g.typ),
+		},
+	}
+
+	// the aggregations added so far, e.g. the facets or the other count
+	aggs := M{}
+	if qq, ok := q.(*query); ok {
+		if added, ok := qq.extra["aggs"].(M); ok {
+			for name, a := range added {
+				aggs[name] = a
+			}
+		}
+	}
+	if g.facets != nil {
+		agg = M{
+			"filter": g.facets.postFilter.boolQuery(),
+			"aggs":   M{g.name: agg},
+		}
+	}
+	aggs[g.name] = agg
+
+	return q.With(M{"aggs": aggs})
+}
+
+func (sp SearchParams) validateGroupCount() error {
+	if sp.GroupCount == nil {
+		return nil
+	}
+	err := validation.ValidateStruct(sp.GroupCount,
+		validation.Field(&sp.GroupCount.Scope, validation.Required),
+		validation.Field(&sp.GroupCount.Attribute, validation.Required))
+	if err != nil {
+		return err
+	}
+	return sp.validateReservedFacet(GroupCountAggregation)
+}
+
+func (sp SearchParams) validateReservedFacet(name string) error {
+	for _, t := range sp.Facets {
+		if t.Name == name {
+			return errors.Errorf("facet name %s is reserved", name)
+		}
+	}
+	return nil
+}
+
+// ParseGroupCount extracts the group count from the search aggregations
+func ParseGroupCount(aggs map[string]interface{}) (int, error) {
+	return parseCount(aggs, GroupCountAggregation)
+}
+
+// ParseCollapseCount extracts the count of the collapsed
+// results from the search aggregations
+func ParseCollapseCount(aggs map[string]interface{}) (int, error) {
+	return parseCount(aggs, CollapseCountAggregation)
+}
+
+func parseCount(aggs map[string]interface{}, name string) (int, error) {
+	agg, ok := aggs[name].(map[string]interface{})
+	if !ok {
+		return 0, errors.Errorf("can't process the %s", strings.Replace(name, "_", " ", 1))
+	}
+	// nested in the facet filter aggregation, with facets
+	if filtered, ok := agg[name].(map[string]interface{}); ok {
+		agg = filtered
+	}
+	value, ok := agg["value"].(float64)
+	if !ok {
+		return 0, errors.Errorf("can't process the %s value", strings.Replace(name, "_", " ", 1))
+	}
+	return int(value), nil
+}
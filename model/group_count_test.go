@@ -0,0 +1,91 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildQueryGroupCount(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+		},
+		GroupCount: &SelectAttribute{Scope: "system", Attribute: "group"},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Aggs map[string]M `json:"aggs"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, M{
+		"cardinality": map[string]interface{}{"field": "system_group_str"},
+	}, res.Aggs[GroupCountAggregation])
+
+	// the numeric attributes are counted by their numeric field
+	params.GroupCountType = TypeNum
+	q, err = BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"cardinality":{"field":"system_group_num"}`)
+	params.GroupCountType = TypeAny
+
+	// with facets, the facet filters apply to the group count too
+	params.Facets = []AggregationTerm{
+		{Name: "types", Scope: "inventory", Attribute: "device_type"},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err = BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+
+	res.Aggs = nil
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Contains(t, res.Aggs, "types")
+	assert.Contains(t, res.Aggs[GroupCountAggregation], "filter")
+	assert.Contains(t, string(b), "post_filter")
+
+	count, err := ParseGroupCount(map[string]interface{}{
+		GroupCountAggregation: map[string]interface{}{
+			"doc_count": float64(10),
+			GroupCountAggregation: map[string]interface{}{
+				"value": float64(3),
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	params.Facets[0].Name = GroupCountAggregation
+	assert.Error(t, params.Validate())
+
+	params.Facets = nil
+	params.GroupCount.Attribute = ""
+	assert.Error(t, params.Validate())
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// ES cluster health statuses
+const (
+	ClusterStatusGreen  = "green"
+	ClusterStatusYellow = "yellow"
+	ClusterStatusRed    = "red"
+)
+
+// ClusterHealth is the ES cluster health summary, along with
+// the state of the local concurrent searches queue
+type ClusterHealth struct {
+	Status           string `json:"status"`
+	UnassignedShards int    `json:"unassigned_shards"`
+	PendingTasks     int    `json:"number_of_pending_tasks"`
+
+	QueuedSearches   int       `json:"-"`
+	RejectedSearches int       `json:"-"`
+	SearchQueueTime  QueueTime `json:"-"`
+}
+
+// QueueTime is the distribution of the recent search queue waits
+type QueueTime struct {
+	P50 time.Duration
+	P95 time.Duration
+	Max time.Duration
+}
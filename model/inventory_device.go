@@ -25,11 +25,34 @@ const (
 	AttrScopeIdentity  = "identity"
 	AttrScopeSystem    = "system"
 
+	AttrScopeConfiguration = scopeConfiguration
+	AttrScopeTags          = scopeTags
+
 	AttrNameID      = "id"
 	AttrNameGroup   = "group"
 	AttrNameStatus  = "status"
 	AttrNameUpdated = "updated_ts"
 	AttrNameCreated = "created_ts"
+
+	AttrNameCheckedIn = "check_in_time"
+
+	// the device open alerts summary, from devicemonitor
+	AttrNameAlertsCount    = "alerts_count"
+	AttrNameAlertsSeverity = "alerts_severity"
+
+	// the device deployments summary, from deployments; the failed
+	// deployments count is decayed with the times of the failures
+	AttrNameDeploymentsFailed    = "deployments_failed_30d"
+	AttrNameDeploymentsFailedTs  = "deployments_failed_ts"
+	AttrNameLastDeploymentStatus = "last_deployment_status"
+
+	// the device age since it was created in the inventory, refreshed daily
+	AttrNameAgeDays   = "device_age_days"
+	AttrNameAgeBucket = "device_age_bucket"
+
+	// the time the indexed device data went stale, as the inventory
+	// couldn't be reached to reindex it; cleared on the next reindex
+	AttrNameStaleSince = "stale_since"
 )
 
 type DeviceID string
@@ -41,6 +64,14 @@ type InvDeviceAttribute struct {
 	Description *string     `json:"description,omitempty" bson:",omitempty"`
 	Value       interface{} `json:"value" bson:",omitempty"`
 	Scope       string      `json:"scope" bson:",omitempty"`
+
+	// the attribute metadata, only returned on request
+	DisplayName string `json:"display_name,omitempty" bson:"-"`
+	Tag         string `json:"tag,omitempty" bson:"-"`
+
+	// Truncated marks a multi-valued attribute returned
+	// with only the first values, see TruncateAttributeValues
+	Truncated bool `json:"truncated,omitempty" bson:"-"`
 }
 
 // Device wrapper
@@ -52,14 +83,34 @@ type InvDevice struct {
 	Attributes DeviceAttributes `json:"attributes,omitempty" bson:"attributes,omitempty"`
 
 	//device's group name
-	Group GroupName `json:"-" bson:"group,omitempty"`
+	Group GroupName `json:"group,omitempty" bson:"group,omitempty"`
+
+	//device's authentication status
+	Status string `json:"status,omitempty" bson:"-"`
 
-	CreatedTs time.Time `json:"-" bson:"created_ts,omitempty"`
+	//Timestamp of the device creation, unknown for some devices
+	CreatedTs *time.Time `json:"created_ts,omitempty" bson:"created_ts,omitempty"`
 	//Timestamp of the last attribute update.
 	UpdatedTs time.Time `json:"updated_ts" bson:"updated_ts,omitempty"`
 
+	//Timestamp of the last device check-in
+	CheckInTime *time.Time `json:"check_in_time,omitempty" bson:"-"`
+
 	//device object revision
 	Revision uint `json:"-" bson:"revision,omitempty"`
+
+	//relevance score, only returned on request
+	Score *float64 `json:"score,omitempty" bson:"-"`
+
+	//number of devices collapsed into this one, see SearchParams.Collapse
+	CollapsedCount *int `json:"collapsed_count,omitempty" bson:"-"`
+
+	//filters matched by the device, see SearchParams.ExplainFilters
+	MatchedFilters []string `json:"matched_filters,omitempty" bson:"-"`
+
+	//the indexed data couldn't be refreshed from the inventory
+	//since the system/stale_since attribute time
+	Stale bool `json:"stale,omitempty" bson:"-"`
 }
 
 func (d *DeviceAttributes) UnmarshalJSON(b []byte) error {
@@ -75,3 +126,39 @@ func (d *DeviceAttributes) UnmarshalJSON(b []byte) error {
 
 	return nil
 }
+
+// Upsert replaces the attributes of the same scope and name,
+// and appends the others
+func (d *DeviceAttributes) Upsert(attrs ...InvDeviceAttribute) {
+	for _, attr := range attrs {
+		found := false
+		for i, a := range *d {
+			if a.Scope == attr.Scope && a.Name == attr.Name {
+				(*d)[i] = attr
+				found = true
+				break
+			}
+		}
+		if !found {
+			*d = append(*d, attr)
+		}
+	}
+}
+
+// TruncateAttributeValues keeps at most 'limit' values of the
+// multi-valued device attributes, marking the truncated ones;
+// a limit of 0 keeps all the values
+func TruncateAttributeValues(devs []InvDevice, limit int) {
+	if limit <= 0 {
+		return
+	}
+	for i := range devs {
+		for j := range devs[i].Attributes {
+			attr := &devs[i].Attributes[j]
+			if values, ok := attr.Value.([]interface{}); ok && len(values) > limit {
+				attr.Value = values[:limit]
+				attr.Truncated = true
+			}
+		}
+	}
+}
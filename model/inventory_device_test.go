@@ -0,0 +1,58 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateAttributeValues(t *testing.T) {
+	devs := []InvDevice{{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: "inventory", Name: "ipv4", Value: []interface{}{"a", "b", "c"}},
+			{Scope: "inventory", Name: "macs", Value: []interface{}{"a", "b"}},
+			{Scope: "inventory", Name: "type", Value: "qemux86-64"},
+		},
+	}}
+
+	TruncateAttributeValues(devs, 0)
+	assert.Len(t, devs[0].Attributes[0].Value, 3)
+
+	TruncateAttributeValues(devs, 2)
+	assert.Equal(t, DeviceAttributes{
+		{Scope: "inventory", Name: "ipv4", Value: []interface{}{"a", "b"}, Truncated: true},
+		{Scope: "inventory", Name: "macs", Value: []interface{}{"a", "b"}},
+		{Scope: "inventory", Name: "type", Value: "qemux86-64"},
+	}, devs[0].Attributes)
+}
+
+func TestDeviceAttributesUpsert(t *testing.T) {
+	attrs := DeviceAttributes{
+		{Scope: "inventory", Name: "mac", Value: "00:11"},
+		{Scope: "system", Name: "alerts_count", Value: float64(1)},
+	}
+	attrs.Upsert(
+		InvDeviceAttribute{Scope: "system", Name: "alerts_count", Value: float64(2)},
+		InvDeviceAttribute{Scope: "identity", Name: "mac", Value: "00:22"},
+	)
+	assert.Equal(t, DeviceAttributes{
+		{Scope: "inventory", Name: "mac", Value: "00:11"},
+		{Scope: "system", Name: "alerts_count", Value: float64(2)},
+		{Scope: "identity", Name: "mac", Value: "00:22"},
+	}, attrs)
+}
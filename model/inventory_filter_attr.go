@@ -17,4 +17,9 @@ type InvFilterAttr struct {
 	Scope string `json:"scope"`
 	Name  string `json:"name"`
 	Count int    `json:"count"`
+
+	// the attribute metadata, if any, see AttributeMetadata
+	DisplayName string `json:"display_name,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	Description string `json:"description,omitempty"`
 }
@@ -0,0 +1,38 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// MaintenanceReport reports the last run of the index maintenance
+type MaintenanceReport struct {
+	// the instance which ran the maintenance
+	Holder    string    `json:"holder"`
+	StartedAt time.Time `json:"started_at"`
+	// the run duration, in milliseconds
+	Duration int64 `json:"duration_ms"`
+
+	MergedIndices  int  `json:"merged_indices"`
+	FailedIndices  int  `json:"failed_indices"`
+	PurgedLookups  int  `json:"purged_lookups"`
+	LookupsFailure bool `json:"lookups_failure,omitempty"`
+
+	// the search contexts left open across the cluster after the
+	// maintenance, e.g. by the points in time not closed; unset if
+	// the cluster stats are unavailable
+	OpenContexts *int `json:"open_contexts,omitempty"`
+}
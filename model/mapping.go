@@ -0,0 +1,191 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+// attribute mapping dry-run statuses
+const (
+	MappingExisting = "existing"
+	MappingNew      = "new"
+	MappingRejected = "rejected"
+)
+
+const (
+	// DefaultTotalFieldsLimit is the ES default of the maximum
+	// number of fields of an index
+	DefaultTotalFieldsLimit = 1000
+
+	maxMappingDryRunAttributes = 1000
+)
+
+var (
+	validMappingScopes = []interface{}{
+		scopeInventory,
+		scopeIdentity,
+		scopeCustom,
+		scopeSystem,
+		scopeConfiguration,
+		scopeTags,
+	}
+	validMappingTypes = []interface{}{typeStr, typeNum}
+)
+
+// MappingDryRunParams lists the attributes to check against the
+// tenant's devices index mapping
+type MappingDryRunParams struct {
+	Attributes []MappingAttribute `json:"attributes"`
+}
+
+// MappingAttribute is an attribute name in a scope, indexed
+// either as a string (the default) or a number
+type MappingAttribute struct {
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+}
+
+// AttributeMapping reports how an attribute would be mapped:
+// to an existing field, to a new one, or rejected
+type AttributeMapping struct {
+	MappingAttribute
+	Field  string `json:"field"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (p MappingDryRunParams) Validate() error {
+	if len(p.Attributes) == 0 {
+		return errors.New("at least one attribute must be provided")
+	}
+	if len(p.Attributes) > maxMappingDryRunAttributes {
+		return errors.Errorf("at most %d attributes are allowed",
+			maxMappingDryRunAttributes)
+	}
+
+	for _, a := range p.Attributes {
+		err := validation.ValidateStruct(&a,
+			validation.Field(&a.Scope, validation.Required, validation.In(validMappingScopes...)),
+			validation.Field(&a.Name, validation.Required),
+			validation.Field(&a.Type, validation.In(validMappingTypes...)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CountMappingFields counts the fields of the index properties the way
+// the index total fields limit does: the leaf and the object fields,
+// with their properties, and the multi-fields (e.g. the analyzed ones)
+func CountMappingFields(props map[string]interface{}) int {
+	fields := 0
+	for _, prop := range props {
+		fields++
+		propM, ok := prop.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sub, ok := propM["properties"].(map[string]interface{}); ok {
+			fields += CountMappingFields(sub)
+		}
+		if sub, ok := propM["fields"].(map[string]interface{}); ok {
+			fields += CountMappingFields(sub)
+		}
+	}
+	return fields
+}
+
+// DryRunMapping maps the attributes to the index fields, given the existing
+// index properties and the total fields limit, without changing anything;
+// the new fields, with their analyzed subfields, are counted against the
+// limit in the attributes order
+func DryRunMapping(props map[string]interface{}, limit int, attrs []MappingAttribute,
+	s Settings) []AttributeMapping {
+	fields := CountMappingFields(props)
+	added := map[string]bool{}
+
+	ret := make([]AttributeMapping, len(attrs))
+	for i, a := range attrs {
+		if a.Type == "" {
+			a.Type = typeStr
+		}
+		typ := TypeStr
+		if a.Type == typeNum {
+			typ = TypeNum
+		}
+
+		m := AttributeMapping{
+			MappingAttribute: a,
+			Field:            ToAttr(a.Scope, a.Name, typ),
+		}
+		newFields := 1
+		if _, ok := analyzerSubfields[s.Analyzers[m.Field]]; ok {
+			newFields++
+		}
+		if _, ok := props[m.Field]; ok || added[m.Field] {
+			m.Status = MappingExisting
+		} else if fields+newFields > limit {
+			m.Status = MappingRejected
+			m.Reason = fmt.Sprintf("index total fields limit (%d) reached", limit)
+		} else {
+			m.Status = MappingNew
+			added[m.Field] = true
+			fields += newFields
+		}
+		ret[i] = m
+	}
+
+	return ret
+}
+
+// MappingReconciliation is the tenant's attribute mapping state
+// re-derived from the devices index mapping: the indexed attributes,
+// and the attribute metadata of attributes missing from the index
+type MappingReconciliation struct {
+	Attributes       int                 `json:"attributes"`
+	OrphanedMetadata []AttributeMetadata `json:"orphaned_metadata"`
+	Pruned           bool                `json:"pruned"`
+}
+
+// ReconcileMapping compares the attribute metadata with the
+// attributes of the index mapping properties
+func ReconcileMapping(props map[string]interface{}, metadata []AttributeMetadata) *MappingReconciliation {
+	indexed := map[string]bool{}
+	for field := range props {
+		scope, name, err := MaybeParseAttr(field)
+		if err != nil || name == "" {
+			continue
+		}
+		indexed[scope+"/"+Redot(name)] = true
+	}
+
+	ret := &MappingReconciliation{
+		Attributes:       len(indexed),
+		OrphanedMetadata: []AttributeMetadata{},
+	}
+	for _, meta := range metadata {
+		if !indexed[meta.Scope+"/"+meta.Name] {
+			ret.OrphanedMetadata = append(ret.OrphanedMetadata, meta)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	gosort "sort"
+)
+
+// MappingDiff compares the searchable attributes of two tenants: the
+// devices index mapping fields of the attributes missing in either of
+// the tenants, or mapped differently, e.g. as text in one and as
+// keyword in the other, making the same filter behave differently
+type MappingDiff struct {
+	TenantA      string             `json:"tenant_a"`
+	TenantB      string             `json:"tenant_b"`
+	CommonFields int                `json:"common_fields"`
+	Fields       []MappingFieldDiff `json:"fields"`
+}
+
+// MappingFieldDiff is a mapping field differing between the tenants;
+// the mapping is empty for the tenant the field is missing in
+type MappingFieldDiff struct {
+	Field     string `json:"field"`
+	Scope     string `json:"scope"`
+	Attribute string `json:"attribute"`
+	MappingA  string `json:"mapping_a,omitempty"`
+	MappingB  string `json:"mapping_b,omitempty"`
+}
+
+// DiffMappings compares the attribute fields of the devices index
+// mapping properties of tenants 'tidA' and 'tidB'; the fields which
+// are not attributes (e.g. the device ID) are the same for all the
+// tenants, and skipped
+func DiffMappings(tidA string, propsA map[string]interface{},
+	tidB string, propsB map[string]interface{}) *MappingDiff {
+	diff := &MappingDiff{
+		TenantA: tidA,
+		TenantB: tidB,
+		Fields:  []MappingFieldDiff{},
+	}
+
+	fields := map[string]bool{}
+	for field := range propsA {
+		fields[field] = true
+	}
+	for field := range propsB {
+		fields[field] = true
+	}
+
+	for field := range fields {
+		scope, name, err := MaybeParseAttr(field)
+		if err != nil || name == "" {
+			continue
+		}
+		mappingA := fieldMapping(propsA[field])
+		mappingB := fieldMapping(propsB[field])
+		if mappingA == mappingB {
+			diff.CommonFields++
+			continue
+		}
+		diff.Fields = append(diff.Fields, MappingFieldDiff{
+			Field:     field,
+			Scope:     scope,
+			Attribute: Redot(name),
+			MappingA:  mappingA,
+			MappingB:  mappingB,
+		})
+	}
+
+	gosort.Slice(diff.Fields, func(i, j int) bool {
+		return diff.Fields[i].Field < diff.Fields[j].Field
+	})
+
+	return diff
+}
+
+// fieldMapping describes the mapping of a field by its type and
+// the analyzer or the normalizer, e.g. "text/path_hierarchy"; the
+// description is empty for a missing field
+func fieldMapping(prop interface{}) string {
+	propM, ok := prop.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	mapping, _ := propM["type"].(string)
+	if mapping == "" {
+		mapping = "object"
+	}
+	for _, key := range []string{"analyzer", "normalizer"} {
+		if v, ok := propM[key].(string); ok {
+			mapping += "/" + v
+		}
+	}
+	return mapping
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffMappings(t *testing.T) {
+	keyword := map[string]interface{}{"type": "keyword"}
+	propsA := map[string]interface{}{
+		"id":                         keyword,
+		"inventory_device_type_str":  keyword,
+		"inventory_rootfs_path_str":  map[string]interface{}{"type": "text", "analyzer": "path"},
+		"inventory_mem_total_kB_num": map[string]interface{}{"type": "double"},
+	}
+	propsB := map[string]interface{}{
+		"id":                        keyword,
+		"inventory_device_type_str": keyword,
+		"inventory_rootfs_path_str": keyword,
+		"identity_mac_str":          keyword,
+	}
+
+	diff := DiffMappings("foo", propsA, "bar", propsB)
+	assert.Equal(t, &MappingDiff{
+		TenantA:      "foo",
+		TenantB:      "bar",
+		CommonFields: 1,
+		Fields: []MappingFieldDiff{
+			{
+				Field:     "identity_mac_str",
+				Scope:     "identity",
+				Attribute: "mac",
+				MappingB:  "keyword",
+			},
+			{
+				Field:     "inventory_mem_total_kB_num",
+				Scope:     "inventory",
+				Attribute: "mem_total_kB",
+				MappingA:  "double",
+			},
+			{
+				Field:     "inventory_rootfs_path_str",
+				Scope:     "inventory",
+				Attribute: "rootfs_path",
+				MappingA:  "text/path",
+				MappingB:  "keyword",
+			},
+		},
+	}, diff)
+}
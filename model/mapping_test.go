@@ -0,0 +1,93 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunMapping(t *testing.T) {
+	params := MappingDryRunParams{
+		Attributes: []MappingAttribute{
+			{Scope: "inventory", Name: "device_type"},
+			{Scope: "inventory", Name: "mem_total_kB", Type: "num"},
+			{Scope: "custom", Name: "site.id"},
+			{Scope: "custom", Name: "site.id"},
+			{Scope: "custom", Name: "region"},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	props := map[string]interface{}{
+		"id":                        nil,
+		"inventory_device_type_str": nil,
+	}
+	res := DryRunMapping(props, 4, params.Attributes, Settings{})
+
+	assert.Equal(t, MappingExisting, res[0].Status)
+	assert.Equal(t, "inventory_mem_total_kB_num", res[1].Field)
+	assert.Equal(t, MappingNew, res[1].Status)
+	assert.Equal(t, MappingNew, res[2].Status)
+	assert.Equal(t, MappingExisting, res[3].Status)
+	assert.Equal(t, MappingRejected, res[4].Status)
+	assert.NotEmpty(t, res[4].Reason)
+
+	// the analyzed subfield of region doesn't fit
+	analyzers := Analyzers{ToAttr("custom", "region", TypeStr): AnalyzerKeywordLowercase}
+	res = DryRunMapping(props, 5, params.Attributes, Settings{Analyzers: analyzers})
+	assert.Equal(t, MappingRejected, res[4].Status)
+
+	params.Attributes[0].Scope = "unknown"
+	assert.Error(t, params.Validate())
+}
+
+func TestCountMappingFields(t *testing.T) {
+	props := map[string]interface{}{
+		"id": map[string]interface{}{"type": "keyword"},
+		"identity_serial_no_str": map[string]interface{}{
+			"type": "keyword",
+			"fields": map[string]interface{}{
+				"lowercase": map[string]interface{}{"type": "keyword"},
+			},
+		},
+		"configuration": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"reported": map[string]interface{}{"type": "keyword"},
+				"desired":  map[string]interface{}{"type": "keyword"},
+			},
+		},
+	}
+	assert.Equal(t, 6, CountMappingFields(props))
+}
+
+func TestReconcileMapping(t *testing.T) {
+	props := map[string]interface{}{
+		"id":                        nil,
+		"inventory_device_type_str": nil,
+		ToAttr("custom", Dedot("site.id"), TypeStr): nil,
+	}
+	metadata := []AttributeMetadata{
+		{TenantID: "t1", Scope: "inventory", Name: "device_type", DisplayName: "Type"},
+		{TenantID: "t1", Scope: "custom", Name: "site.id", DisplayName: "Site"},
+		{TenantID: "t1", Scope: "custom", Name: "region", DisplayName: "Region"},
+	}
+
+	res := ReconcileMapping(props, metadata)
+	assert.Equal(t, 2, res.Attributes)
+	assert.Equal(t, []AttributeMetadata{metadata[2]}, res.OrphanedMetadata)
+	assert.False(t, res.Pruned)
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxNestedObjects is the maximum number of objects
+// indexed per nested attribute
+const maxNestedObjects = 100
+
+var (
+	ErrNotNestedAttribute = errors.New("attribute isn't indexed as nested objects")
+	ErrElemMatchRequired  = errors.New("filter supports only a list of " +
+		"{\"attribute\", \"type\", \"value\"} element filters")
+)
+
+// NestedAttributes is the set of the flat object attribute names
+// (see ToAttr) indexed as nested objects: each object is matched
+// on its own, so that a filter on several of its keys (e.g. the
+// name and the IP of a network interface) matches within a
+// single object, see the "$elem_match" filter
+type NestedAttributes map[string]bool
+
+// ParseNestedAttributes parses the nested attribute definitions
+// in the form "scope/name", e.g. "inventory/network_interfaces"
+func ParseNestedAttributes(defs []string) (NestedAttributes, error) {
+	ret := NestedAttributes{}
+	for _, def := range defs {
+		scopeName := strings.SplitN(def, "/", 2)
+		if len(scopeName) != 2 || scopeName[0] == "" || scopeName[1] == "" {
+			return nil, errors.Errorf("malformed nested attribute definition %q", def)
+		}
+		ret[ToAttr(scopeName[0], scopeName[1], TypeObj)] = true
+	}
+	return ret, nil
+}
+
+// IsNested tells if the attribute is indexed as nested objects
+func (n NestedAttributes) IsNested(scope, name string) bool {
+	return n[ToAttr(scope, name, TypeObj)]
+}
+
+// nest sets the objects of a nested attribute from the inventory
+// value: an object, a list of objects, or their JSON encoding;
+// the other attributes are left as they are, without objects
+func (n NestedAttributes) nest(attr *InventoryAttribute, val interface{}) {
+	if !n[ToAttr(attr.Scope, attr.Name, TypeObj)] {
+		attr.Objects = nil
+		return
+	}
+
+	var objects []map[string]interface{}
+	switch val := val.(type) {
+	case string:
+		objects = decodeObjects(val)
+	case []interface{}:
+		for _, v := range val {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				objects = append(objects, v)
+			case string:
+				objects = append(objects, decodeObjects(v)...)
+			}
+		}
+	case map[string]interface{}:
+		objects = []map[string]interface{}{val}
+	}
+	if len(objects) == 0 {
+		return
+	}
+
+	if len(objects) > maxNestedObjects {
+		objects = objects[:maxNestedObjects]
+	}
+	for i, obj := range objects {
+		objects[i] = flatObject(obj)
+	}
+	attr.SetObjects(objects)
+}
+
+// decodeObjects decodes a JSON object, or list of objects
+func decodeObjects(s string) []map[string]interface{} {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") {
+		var objects []map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &objects); err == nil {
+			return objects
+		}
+	} else if strings.HasPrefix(s, "{") {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &obj); err == nil {
+			return []map[string]interface{}{obj}
+		}
+	}
+	return nil
+}
+
+// flatObject keeps the scalar values of an object, and the lists
+// of them, dropping the deeper objects not to grow the mapping
+func flatObject(obj map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		switch v := v.(type) {
+		case string, float64, bool:
+			ret[k] = v
+		case []interface{}:
+			vals := make([]interface{}, 0, len(v))
+			for _, e := range v {
+				switch e.(type) {
+				case string, float64, bool:
+					vals = append(vals, e)
+				}
+			}
+			ret[k] = vals
+		}
+	}
+	return ret
+}
+
+// "$elem_match" - at least one object of a nested attribute
+// matches all the element filters, on the object keys, e.g.
+// [{"attribute": "name", "type": "$eq", "value": "eth0"}]
+type filterElemMatch struct {
+	path     string
+	elements []FilterPredicate
+}
+
+// elemMatchTypes are the filter types supported on the object keys
+var elemMatchTypes = map[string]bool{
+	"$eq":     true,
+	"$ne":     true,
+	"$in":     true,
+	"$nin":    true,
+	"$gt":     true,
+	"$gte":    true,
+	"$lt":     true,
+	"$lte":    true,
+	"$exists": true,
+	"$regex":  true,
+}
+
+func NewFilterElemMatch(fp FilterPredicate, s Settings) (*filterElemMatch, error) {
+	elements, err := elemMatchFilters(fp.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Nested.IsNested(fp.Scope, fp.Attribute) {
+		return nil, ErrNotNestedAttribute
+	}
+	// the values of the hashed attributes are matched hashed
+	strAttr := ToAttr(fp.Scope, fp.Attribute, TypeStr)
+	for i := range elements {
+		elements[i].Value = s.Redactions.redactFilterValue(strAttr, elements[i].Value)
+	}
+	return &filterElemMatch{
+		path:     ToAttr(fp.Scope, fp.Attribute, TypeObj),
+		elements: elements,
+	}, nil
+}
+
+// elemMatchFilters parses the element filters of an "$elem_match"
+func elemMatchFilters(val interface{}) ([]FilterPredicate, error) {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return nil, ErrElemMatchRequired
+	}
+	var elements []FilterPredicate
+	if err := json.Unmarshal(b, &elements); err != nil || len(elements) == 0 {
+		return nil, ErrElemMatchRequired
+	}
+	for _, e := range elements {
+		if e.Attribute == "" || !elemMatchTypes[e.Type] || e.Value == nil {
+			return nil, ErrElemMatchRequired
+		}
+		if arr, ok := e.Value.([]interface{}); ok && len(arr) == 0 {
+			return nil, ErrElemMatchRequired
+		}
+		_, isarr, err := e.ValueType()
+		if err != nil {
+			return nil, err
+		}
+		if isarr != (e.Type == "$in" || e.Type == "$nin") {
+			if isarr {
+				return nil, ErrArrayNotSupported
+			}
+			return nil, ErrArrayRequired
+		}
+	}
+	return elements, nil
+}
+
+func (f *filterElemMatch) AddTo(q Query) Query {
+	must := []interface{}{}
+	mustNot := []interface{}{}
+	for _, e := range f.elements {
+		field := f.path + "." + e.Attribute
+		switch e.Type {
+		case "$eq":
+			must = append(must, M{"term": M{field: e.Value}})
+		case "$ne":
+			mustNot = append(mustNot, M{"term": M{field: e.Value}})
+		case "$in":
+			must = append(must, M{"terms": M{field: e.Value}})
+		case "$nin":
+			mustNot = append(mustNot, M{"terms": M{field: e.Value}})
+		case "$gt", "$gte", "$lt", "$lte":
+			must = append(must, M{"range": M{field: M{e.Type[1:]: e.Value}}})
+		case "$exists":
+			exists := M{"exists": M{"field": field}}
+			if e.Value == true {
+				must = append(must, exists)
+			} else {
+				mustNot = append(mustNot, exists)
+			}
+		case "$regex":
+			must = append(must, M{"regexp": M{field: e.Value}})
+		}
+	}
+
+	return q.Must(M{
+		"nested": M{
+			"path": f.path,
+			"query": M{
+				"bool": M{
+					"must":     must,
+					"must_not": mustNot,
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNestedAttributes(t *testing.T) {
+	n, err := ParseNestedAttributes([]string{"inventory/network_interfaces"})
+	assert.NoError(t, err)
+	s := Settings{Nested: n}
+
+	dev, err := NewDeviceFromInv("tenant", &InvDevice{
+		ID: "dev-1",
+		Attributes: DeviceAttributes{
+			{Scope: "inventory", Name: "network_interfaces",
+				Value: `[{"name": "eth0", "ip": "10.0.0.1", "mtu": 1500, "opts": {"a": 1}},
+					{"name": "wlan0", "ip": "192.168.1.2"}]`},
+			// not nested, the objects aren't indexed
+			{Scope: "inventory", Name: "location", Value: map[string]interface{}{"lat": 1.0}},
+		},
+	}, s)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(dev)
+	assert.NoError(t, err)
+	var source map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &source))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "eth0", "ip": "10.0.0.1", "mtu": float64(1500)},
+		map[string]interface{}{"name": "wlan0", "ip": "192.168.1.2"},
+	}, source["inventory_network_interfaces_obj"])
+	assert.NotContains(t, source, "inventory_location_obj")
+
+	// the objects round trip from the ES source
+	esDev, err := NewDeviceFromEsSource(source)
+	assert.NoError(t, err)
+	assert.Len(t, esDev.InventoryAttributes[0].Objects, 2)
+
+	q, err := BuildQuery(SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "network_interfaces", Type: "$elem_match",
+				Value: []interface{}{
+					map[string]interface{}{"attribute": "name", "type": "$eq", "value": "eth0"},
+					map[string]interface{}{"attribute": "ip", "type": "$regex", "value": "10\\..*"},
+				}},
+		},
+	}, s)
+	assert.NoError(t, err)
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			Bool struct {
+				Must []struct {
+					Nested struct {
+						Path  string `json:"path"`
+						Query struct {
+							Bool struct {
+								Must []M `json:"must"`
+							} `json:"bool"`
+						} `json:"query"`
+					} `json:"nested"`
+				} `json:"must"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Query.Bool.Must, 1)
+	nested := res.Query.Bool.Must[0].Nested
+	assert.Equal(t, "inventory_network_interfaces_obj", nested.Path)
+	assert.Equal(t, []M{
+		{"term": map[string]interface{}{"inventory_network_interfaces_obj.name": "eth0"}},
+		{"regexp": map[string]interface{}{"inventory_network_interfaces_obj.ip": "10\\..*"}},
+	}, nested.Query.Bool.Must)
+
+	_, err = getFilterPart(FilterPredicate{
+		Scope: "inventory", Attribute: "location", Type: "$elem_match",
+		Value: []interface{}{
+			map[string]interface{}{"attribute": "lat", "type": "$gt", "value": 1.0},
+		},
+	}, s)
+	assert.Equal(t, ErrNotNestedAttribute, err)
+
+	_, err = getFilterPart(FilterPredicate{
+		Scope: "inventory", Attribute: "network_interfaces", Type: "$elem_match",
+		Value: "eth0",
+	}, s)
+	assert.Equal(t, ErrElemMatchRequired, err)
+
+	_, err = ParseNestedAttributes([]string{"network_interfaces"})
+	assert.Error(t, err)
+}
+
+func TestNestedAttributesRedacted(t *testing.T) {
+	n, err := ParseNestedAttributes([]string{"inventory/network_interfaces"})
+	assert.NoError(t, err)
+	r, err := ParseRedactions([]string{"inventory/network_interfaces:hash"}, testRedactionKey)
+	assert.NoError(t, err)
+	s := Settings{Nested: n, Redactions: r}
+
+	dev, err := NewDeviceFromInv("tenant", &InvDevice{
+		ID: "dev-1",
+		Attributes: DeviceAttributes{
+			{Scope: "inventory", Name: "network_interfaces",
+				Value: `[{"name": "eth0", "ips": ["10.0.0.1"], "mtu": 1500}]`},
+		},
+	}, s)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{
+		"name": r.redactString(RedactionHash, "eth0"),
+		"ips":  []interface{}{r.redactString(RedactionHash, "10.0.0.1")},
+		"mtu":  float64(1500),
+	}}, dev.InventoryAttributes[0].Objects)
+
+	q, err := BuildQuery(SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "network_interfaces", Type: "$elem_match",
+				Value: []interface{}{
+					map[string]interface{}{"attribute": "name", "type": "$eq", "value": "eth0"},
+				}},
+		},
+	}, s)
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), r.redactString(RedactionHash, "eth0"))
+	assert.NotContains(t, string(b), `"eth0"`)
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// normalizers applied to string attributes at index and query time
+const (
+	// NormalizerMAC lowercases MAC addresses, with ':' separators
+	NormalizerMAC = "mac"
+	// NormalizerIP formats IP addresses canonically (e.g. compressed IPv6)
+	NormalizerIP = "ip"
+)
+
+var (
+	macSeparators = strings.NewReplacer(":", "", "-", "", ".", "")
+
+	ErrUnknownNormalizer = errors.New("unknown normalizer")
+)
+
+// Normalizers maps flat string attribute names (see ToAttr)
+// to the normalizers applied to their values
+type Normalizers map[string]string
+
+// ParseNormalizers parses normalizer definitions in the form
+// "scope/name:normalizer", e.g. "identity/mac:mac"
+func ParseNormalizers(defs []string) (Normalizers, error) {
+	ret := Normalizers{}
+	for _, def := range defs {
+		attr := strings.SplitN(def, ":", 2)
+		if len(attr) != 2 {
+			return nil, errors.Errorf("malformed normalizer definition %q", def)
+		}
+		scopeName := strings.SplitN(attr[0], "/", 2)
+		if len(scopeName) != 2 || scopeName[0] == "" || scopeName[1] == "" {
+			return nil, errors.Errorf("malformed normalizer definition %q", def)
+		}
+		if attr[1] != NormalizerMAC && attr[1] != NormalizerIP {
+			return nil, errors.Wrap(ErrUnknownNormalizer, attr[1])
+		}
+		ret[ToAttr(scopeName[0], scopeName[1], TypeStr)] = attr[1]
+	}
+	return ret, nil
+}
+
+// normalize applies the configured normalizer to a string attribute
+func (n Normalizers) normalize(attr *InventoryAttribute) {
+	normalizer, ok := n[ToAttr(attr.Scope, attr.Name, TypeStr)]
+	if !ok || !attr.IsStr() {
+		return
+	}
+
+	vals := make([]string, len(attr.String))
+	for i, v := range attr.String {
+		vals[i] = normalizeString(normalizer, v)
+	}
+	attr.String = vals
+}
+
+// normalizeFilterValue normalizes the filter values,
+// so that they match the indexed values
+func (n Normalizers) normalizeFilterValue(attr string, val interface{}) interface{} {
+	normalizer, ok := n[attr]
+	if !ok {
+		return val
+	}
+
+	switch v := val.(type) {
+	case string:
+		return normalizeString(normalizer, v)
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, e := range v {
+			ret[i] = n.normalizeFilterValue(attr, e)
+		}
+		return ret
+	default:
+		return val
+	}
+}
+
+// normalizeString returns the normalized value,
+// or the value as is if it can't be parsed
+func normalizeString(normalizer, val string) string {
+	switch normalizer {
+	case NormalizerMAC:
+		hw, err := net.ParseMAC(val)
+		if err != nil {
+			// net.ParseMAC doesn't accept bare hex digits
+			hw, err = net.ParseMAC(insertMACSeparators(macSeparators.Replace(val)))
+			if err != nil {
+				return val
+			}
+		}
+		return hw.String()
+	case NormalizerIP:
+		ip := net.ParseIP(strings.TrimSpace(val))
+		if ip == nil {
+			return val
+		}
+		return ip.String()
+	default:
+		return val
+	}
+}
+
+func insertMACSeparators(hex string) string {
+	if len(hex)%2 != 0 {
+		return hex
+	}
+	parts := make([]string, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeString(t *testing.T) {
+	testCases := map[string]struct {
+		normalizer string
+		val        string
+		res        string
+	}{
+		"mac, uppercase dashes": {
+			normalizer: NormalizerMAC,
+			val:        "AA-BB-CC-00-11-22",
+			res:        "aa:bb:cc:00:11:22",
+		},
+		"mac, bare hex": {
+			normalizer: NormalizerMAC,
+			val:        "aabbcc001122",
+			res:        "aa:bb:cc:00:11:22",
+		},
+		"mac, cisco dots": {
+			normalizer: NormalizerMAC,
+			val:        "aabb.cc00.1122",
+			res:        "aa:bb:cc:00:11:22",
+		},
+		"mac, invalid": {
+			normalizer: NormalizerMAC,
+			val:        "not a mac",
+			res:        "not a mac",
+		},
+		"ipv6, expanded": {
+			normalizer: NormalizerIP,
+			val:        "2001:0DB8:0000:0000:0000:0000:0000:0001",
+			res:        "2001:db8::1",
+		},
+		"ipv4": {
+			normalizer: NormalizerIP,
+			val:        "10.0.0.1",
+			res:        "10.0.0.1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.res, normalizeString(tc.normalizer, tc.val))
+		})
+	}
+}
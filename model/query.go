@@ -17,6 +17,12 @@ package model
 import (
 	"encoding/json"
 	"errors"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -40,6 +46,17 @@ var (
 	ErrStrRequired       = errors.New("filter supports only string values")
 	ErrNumRequired       = errors.New("filter supports only numeric values")
 	ErrBoolRequired      = errors.New("filter supports only boolean values")
+	ErrCIDRRequired      = errors.New("filter supports only CIDR or IP values")
+	ErrNotIPAttribute    = errors.New("attribute isn't indexed as an IP address")
+	ErrSizeRequired      = errors.New("filter supports only a length, or a " +
+		"{\"$gte\", \"$gt\", \"$lte\", \"$lt\"} range of lengths")
+	ErrFuzzyRequired = errors.New("filter supports only a string, or a " +
+		"{\"value\", \"fuzziness\", \"prefix_length\"} object")
+	ErrDateRequired = errors.New("filter supports only a date, \"now\" date math, " +
+		"or a {\"date\", \"timezone\"} object, of a date attribute")
+	// ErrCollapseMultiValued is returned for the searches collapsed by
+	// an attribute some of the matching devices have many values of
+	ErrCollapseMultiValued = errors.New("collapse supports only single-valued attributes")
 )
 
 type M map[string]interface{}
@@ -52,6 +69,8 @@ type S []interface{}
 //     "bool": {
 //       "must": [...conditions...],
 //       "must_not": [...conditions...],
+//       "should": [...conditions...],
+//       "minimum_should_match": ...,
 //     }
 //   "sort": [...],
 //   "from": ...,
@@ -61,9 +80,13 @@ type S []interface{}
 type Query interface {
 	Must(condition interface{}) Query
 	MustNot(condition interface{}) Query
+	Should(condition interface{}) Query
+	MinimumShouldMatch(n int) Query
+	WithRandomScore(seed int64) Query
 	WithSort(sort interface{}) Query
 	WithPage(page, per_page int) Query
 	With(parts map[string]interface{}) Query
+	CountQuery() M
 
 	MarshalJSON() ([]byte, error)
 }
@@ -77,10 +100,17 @@ type QueryPart interface {
 type query struct {
 	must    []interface{}
 	mustNot []interface{}
+	should  []interface{}
 	sort    []interface{}
 	from    int
 	size    int
 
+	// minimum number of the should conditions to match, 1 if unset
+	minShould int
+
+	// seed of the random score replacing the relevance score, if set
+	randomSeed *int64
+
 	extra map[string]interface{}
 }
 
@@ -102,6 +132,21 @@ func (q *query) MustNot(condition interface{}) Query {
 	return q
 }
 
+func (q *query) Should(condition interface{}) Query {
+	q.should = append(q.should, condition)
+	return q
+}
+
+func (q *query) MinimumShouldMatch(n int) Query {
+	q.minShould = n
+	return q
+}
+
+func (q *query) WithRandomScore(seed int64) Query {
+	q.randomSeed = &seed
+	return q
+}
+
 func (q *query) WithSort(condition interface{}) Query {
 	q.sort = append(q.sort, condition)
 	return q
@@ -125,7 +170,8 @@ func (q *query) With(parts map[string]interface{}) Query {
 	return q
 }
 
-func (q *query) MarshalJSON() ([]byte, error) {
+// boolQuery returns the bool query of the conditions only
+func (q *query) boolQuery() M {
 	qbool := M{}
 
 	if q.must != nil {
@@ -136,11 +182,55 @@ func (q *query) MarshalJSON() ([]byte, error) {
 		qbool["must_not"] = q.mustNot
 	}
 
-	qjson := M{
+	// with must conditions present, ES doesn't require any of
+	// the should conditions to match unless told so
+	if q.should != nil {
+		qbool["should"] = q.should
+		minShould := q.minShould
+		if minShould == 0 {
+			minShould = 1
+		}
+		qbool["minimum_should_match"] = minShould
+	}
+
+	return M{
+		"bool": qbool,
+	}
+}
+
+// CountQuery returns the count request body, matching
+// the same devices as the query, post filter included
+func (q *query) CountQuery() M {
+	must := S{q.boolQuery()}
+	if postFilter, ok := q.extra["post_filter"]; ok {
+		must = append(must, postFilter)
+	}
+	return M{
 		"query": M{
-			"bool": qbool,
+			"bool": M{
+				"must": must,
+			},
 		},
 	}
+}
+
+func (q *query) MarshalJSON() ([]byte, error) {
+	qjson := M{
+		"query": q.boolQuery(),
+	}
+
+	if q.randomSeed != nil {
+		qjson["query"] = M{
+			"function_score": M{
+				"query": qjson["query"],
+				"random_score": M{
+					"seed":  *q.randomSeed,
+					"field": "_seq_no",
+				},
+				"boost_mode": "replace",
+			},
+		}
+	}
 
 	if q.sort != nil {
 		qjson["sort"] = q.sort
@@ -159,28 +249,52 @@ func (q *query) MarshalJSON() ([]byte, error) {
 }
 
 // filter factory
-func getFilterPart(pred FilterPredicate) (QueryPart, error) {
+func getFilterPart(pred FilterPredicate, s Settings) (QueryPart, error) {
+	if pred.Scope == ScopeAny {
+		return NewFilterAnyScope(pred, s)
+	}
+
+	// the system dates compare as dates, in a time zone
+	if isDateAttr(pred) {
+		switch pred.Type {
+		case "$gt", "$gte", "$lt", "$lte":
+			return NewFilterDateRange(pred, pred.Type[1:])
+		}
+	}
+
 	switch pred.Type {
 	case "$eq":
-		return NewFilterEq(pred)
+		return NewFilterEq(pred, s)
 	case "$ne":
-		return NewFilterNe(pred)
+		return NewFilterNe(pred, s)
 	case "$gt":
-		return NewFilterRange(pred, "gt")
+		return NewFilterRange(pred, s, "gt")
 	case "$gte":
-		return NewFilterRange(pred, "gte")
+		return NewFilterRange(pred, s, "gte")
 	case "$lt":
-		return NewFilterRange(pred, "lt")
+		return NewFilterRange(pred, s, "lt")
 	case "$lte":
-		return NewFilterRange(pred, "lte")
+		return NewFilterRange(pred, s, "lte")
 	case "$in":
-		return NewFilterIn(pred)
+		return NewFilterIn(pred, s)
 	case "$nin":
-		return NewFilterNin(pred)
+		return NewFilterNin(pred, s)
 	case "$exists":
-		return NewFilterExists(pred)
+		return NewFilterExists(pred, s)
+	case "$empty":
+		return NewFilterEmpty(pred, s)
 	case "$regex":
-		return NewFilterRegex(pred)
+		return NewFilterRegex(pred, s)
+	case "$cidr":
+		return NewFilterCIDR(pred, s)
+	case "$size":
+		return NewFilterSize(pred)
+	case "$fuzzy":
+		return NewFilterFuzzy(pred)
+	case "$on_day", "$in_week", "$in_month":
+		return NewFilterDateTrunc(pred)
+	case "$elem_match":
+		return NewFilterElemMatch(pred, s)
 	}
 
 	return nil, errors.New("filter type not supported")
@@ -190,10 +304,16 @@ func getFilterPart(pred FilterPredicate) (QueryPart, error) {
 type filter struct {
 	// computed attr name
 	attr string
-	val  interface{}
+	// analyzer of the attr, if any
+	analyzer string
+	// field to match values against, the attr
+	// or one of its analyzed subfields
+	match string
+	val   interface{}
 }
 
-func NewFilter(fp FilterPredicate, arrOpts ArrayOpts, typeOpts Type) (*filter, error) {
+func NewFilter(fp FilterPredicate, arrOpts ArrayOpts, typeOpts Type,
+	s Settings) (*filter, error) {
 	// inspect type to
 	// a) compose attribute name
 	// b) restrict inputs
@@ -234,8 +354,11 @@ func NewFilter(fp FilterPredicate, arrOpts ArrayOpts, typeOpts Type) (*filter, e
 	}
 
 	return &filter{
-		attr: attr,
-		val:  fp.Value,
+		attr:     attr,
+		analyzer: s.Analyzers[attr],
+		match:    s.Analyzers.analyzedAttr(attr),
+		val: s.Redactions.redactFilterValue(attr,
+			s.Normalizers.normalizeFilterValue(attr, fp.Value)),
 	}, nil
 }
 
@@ -244,8 +367,8 @@ type filterEq struct {
 	*filter
 }
 
-func NewFilterEq(fp FilterPredicate) (*filterEq, error) {
-	f, err := NewFilter(fp, ArrNotAllowed, TypeAny)
+func NewFilterEq(fp FilterPredicate, s Settings) (*filterEq, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeAny, s)
 	if err != nil {
 		return nil, err
 	}
@@ -256,9 +379,19 @@ func NewFilterEq(fp FilterPredicate) (*filterEq, error) {
 }
 
 func (f *filterEq) AddTo(q Query) Query {
+	// match would analyze the empty string away, so an
+	// exact empty string must be a term query
+	if f.val == "" {
+		return q.Must(M{
+			"term": M{
+				f.attr: "",
+			},
+		})
+	}
+
 	return q.Must(M{
 		"match": M{
-			f.attr: f.val,
+			f.match: f.val,
 		},
 	})
 }
@@ -268,8 +401,8 @@ type filterNe struct {
 	*filter
 }
 
-func NewFilterNe(fp FilterPredicate) (*filterNe, error) {
-	f, err := NewFilter(fp, ArrNotAllowed, TypeAny)
+func NewFilterNe(fp FilterPredicate, s Settings) (*filterNe, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeAny, s)
 	if err != nil {
 		return nil, err
 	}
@@ -282,7 +415,7 @@ func NewFilterNe(fp FilterPredicate) (*filterNe, error) {
 func (f *filterNe) AddTo(q Query) Query {
 	return q.MustNot(M{
 		"match": M{
-			f.attr: f.val,
+			f.match: f.val,
 		},
 	})
 }
@@ -292,17 +425,34 @@ type filterRegex struct {
 	*filter
 }
 
-func NewFilterRegex(fp FilterPredicate) (*filterRegex, error) {
-	f, err := NewFilter(fp, ArrNotAllowed, TypeStr)
+func NewFilterRegex(fp FilterPredicate, s Settings) (*filterRegex, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeStr, s)
 	if err != nil {
 		return nil, err
 	}
+	if f.analyzer == AnalyzerKeywordLowercase {
+		err := requireFeature(s.capabilities().CaseInsensitive, "case insensitive $regex")
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &filterRegex{
 		filter: f,
 	}, nil
 }
 
 func (f *filterRegex) AddTo(q Query) Query {
+	if f.analyzer == AnalyzerKeywordLowercase {
+		return q.Must(M{
+			"regexp": M{
+				f.attr: M{
+					"value":            f.val,
+					"case_insensitive": true,
+				},
+			},
+		})
+	}
+
 	return q.Must(M{
 		"regexp": M{
 			f.attr: f.val,
@@ -315,8 +465,8 @@ type filterIn struct {
 	*filter
 }
 
-func NewFilterIn(fp FilterPredicate) (*filterIn, error) {
-	f, err := NewFilter(fp, ArrRequired, TypeAny)
+func NewFilterIn(fp FilterPredicate, s Settings) (*filterIn, error) {
+	f, err := NewFilter(fp, ArrRequired, TypeAny, s)
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +478,7 @@ func NewFilterIn(fp FilterPredicate) (*filterIn, error) {
 func (f *filterIn) AddTo(q Query) Query {
 	return q.Must(M{
 		"terms": M{
-			f.attr: f.val,
+			f.match: f.val,
 		},
 	})
 }
@@ -338,8 +488,8 @@ type filterNin struct {
 	*filter
 }
 
-func NewFilterNin(fp FilterPredicate) (*filterNin, error) {
-	f, err := NewFilter(fp, ArrRequired, TypeAny)
+func NewFilterNin(fp FilterPredicate, s Settings) (*filterNin, error) {
+	f, err := NewFilter(fp, ArrRequired, TypeAny, s)
 	if err != nil {
 		return nil, err
 	}
@@ -352,7 +502,7 @@ func NewFilterNin(fp FilterPredicate) (*filterNin, error) {
 func (f *filterNin) AddTo(q Query) Query {
 	return q.MustNot(M{
 		"terms": M{
-			f.attr: f.val,
+			f.match: f.val,
 		},
 	})
 }
@@ -363,8 +513,8 @@ type filterExists struct {
 	fp FilterPredicate
 }
 
-func NewFilterExists(fp FilterPredicate) (*filterExists, error) {
-	f, err := NewFilter(fp, ArrNotAllowed, TypeBool)
+func NewFilterExists(fp FilterPredicate, s Settings) (*filterExists, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeBool, s)
 	if err != nil {
 		return nil, err
 	}
@@ -396,6 +546,393 @@ func (f *filterExists) AddTo(q Query) Query {
 		MustNot(M{"exists": M{"field": anum}})
 }
 
+// "$empty" - true: the attribute exists and is an empty string,
+// false: the attribute exists and is not an empty string;
+// note that ES doesn't index empty arrays or nulls, those
+// are matched by "$exists": false
+type filterEmpty struct {
+	*filter
+	fp FilterPredicate
+}
+
+func NewFilterEmpty(fp FilterPredicate, s Settings) (*filterEmpty, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeBool, s)
+	if err != nil {
+		return nil, err
+	}
+	return &filterEmpty{
+		filter: f,
+		fp:     fp,
+	}, nil
+}
+
+func (f *filterEmpty) AddTo(q Query) Query {
+	empty := f.fp.Value.(bool)
+	astr := ToAttr(f.fp.Scope, f.fp.Attribute, TypeStr)
+	anum := ToAttr(f.fp.Scope, f.fp.Attribute, TypeNum)
+
+	if empty {
+		return q.Must(M{"term": M{astr: ""}})
+	}
+
+	return q.
+		Must(M{
+			"bool": M{
+				"minimum_should_match": 1,
+				"should": S{
+					M{"exists": M{"field": astr}},
+					M{"exists": M{"field": anum}},
+				},
+			},
+		}).
+		MustNot(M{"term": M{astr: ""}})
+}
+
+// "$cidr"
+type filterCIDR struct {
+	*filter
+	ipAttr string
+}
+
+func NewFilterCIDR(fp FilterPredicate, s Settings) (*filterCIDR, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeStr, s)
+	if err != nil {
+		return nil, err
+	}
+
+	cidr := f.val.(string)
+	if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+		return nil, ErrCIDRRequired
+	}
+
+	ip, ok := s.Analyzers.ipAttr(f.attr)
+	if !ok {
+		return nil, ErrNotIPAttribute
+	}
+
+	return &filterCIDR{
+		filter: f,
+		ipAttr: ip,
+	}, nil
+}
+
+func (f *filterCIDR) AddTo(q Query) Query {
+	return q.Must(M{
+		"term": M{
+			f.ipAttr: f.val,
+		},
+	})
+}
+
+// "$size" - the number of values of an array attribute, either exact,
+// e.g. 3, or a range, e.g. {"$gt": 5}; counted from the doc values,
+// so that repeated values of string arrays count once
+type filterSize struct {
+	fields   []string
+	min, max int
+}
+
+// sizeScript sums the number of values of the typed fields
+const sizeScript = "int n = 0; " +
+	"for (f in params.fields) { if (doc.containsKey(f)) { n += doc[f].size(); } } " +
+	"return n >= params.min && n <= params.max;"
+
+func NewFilterSize(fp FilterPredicate) (*filterSize, error) {
+	f := &filterSize{
+		fields: []string{
+			ToAttr(fp.Scope, fp.Attribute, TypeStr),
+			ToAttr(fp.Scope, fp.Attribute, TypeNum),
+		},
+		min: 0,
+		max: math.MaxInt32,
+	}
+
+	switch val := fp.Value.(type) {
+	case float64:
+		if val < 0 || val != math.Trunc(val) {
+			return nil, ErrSizeRequired
+		}
+		f.min, f.max = int(val), int(val)
+	case map[string]interface{}:
+		if len(val) == 0 {
+			return nil, ErrSizeRequired
+		}
+		for op, v := range val {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return nil, ErrSizeRequired
+			}
+			switch op {
+			case "$gte":
+				f.min = int(n)
+			case "$gt":
+				f.min = int(n) + 1
+			case "$lte":
+				f.max = int(n)
+			case "$lt":
+				f.max = int(n) - 1
+			default:
+				return nil, ErrSizeRequired
+			}
+		}
+	default:
+		return nil, ErrSizeRequired
+	}
+
+	return f, nil
+}
+
+func (f *filterSize) AddTo(q Query) Query {
+	return q.Must(M{
+		"script": M{
+			"script": M{
+				"lang":   "painless",
+				"source": sizeScript,
+				"params": M{
+					"fields": f.fields,
+					"min":    f.min,
+					"max":    f.max,
+				},
+			},
+		},
+	})
+}
+
+// "$fuzzy" - string values within an edit distance, either a string,
+// matched with the "AUTO" fuzziness, or an object setting the
+// fuzziness (0, 1, 2 or "AUTO") and the number of leading
+// characters which must match exactly, e.g.
+// {"value": "SN-1234", "fuzziness": 1, "prefix_length": 3}
+type filterFuzzy struct {
+	attr         string
+	val          string
+	fuzziness    interface{}
+	prefixLength int
+}
+
+const (
+	defaultFuzziness = "AUTO"
+	maxFuzziness     = 2
+)
+
+func NewFilterFuzzy(fp FilterPredicate) (*filterFuzzy, error) {
+	f := &filterFuzzy{
+		attr:      ToAttr(fp.Scope, fp.Attribute, TypeStr),
+		fuzziness: defaultFuzziness,
+	}
+
+	switch val := fp.Value.(type) {
+	case string:
+		f.val = val
+	case map[string]interface{}:
+		for key, v := range val {
+			switch key {
+			case "value":
+				s, ok := v.(string)
+				if !ok {
+					return nil, ErrFuzzyRequired
+				}
+				f.val = s
+			case "fuzziness":
+				switch fuzziness := v.(type) {
+				case string:
+					if fuzziness != defaultFuzziness {
+						return nil, ErrFuzzyRequired
+					}
+				case float64:
+					if fuzziness < 0 || fuzziness > maxFuzziness ||
+						fuzziness != math.Trunc(fuzziness) {
+						return nil, ErrFuzzyRequired
+					}
+					f.fuzziness = int(fuzziness)
+				default:
+					return nil, ErrFuzzyRequired
+				}
+			case "prefix_length":
+				n, ok := v.(float64)
+				if !ok || n < 0 || n != math.Trunc(n) {
+					return nil, ErrFuzzyRequired
+				}
+				f.prefixLength = int(n)
+			default:
+				return nil, ErrFuzzyRequired
+			}
+		}
+	default:
+		return nil, ErrFuzzyRequired
+	}
+	if f.val == "" {
+		return nil, ErrFuzzyRequired
+	}
+
+	return f, nil
+}
+
+func (f *filterFuzzy) AddTo(q Query) Query {
+	return q.Must(M{
+		"fuzzy": M{
+			f.attr: M{
+				"value":          f.val,
+				"fuzziness":      f.fuzziness,
+				"prefix_length":  f.prefixLength,
+				"transpositions": true,
+			},
+		},
+	})
+}
+
+// "$on_day", "$in_week", "$in_month" - the system date attributes
+// (created_ts, updated_ts, check_in_time) within the day, the week
+// or the month of a date, e.g. "2021-06-14", or of "now" date math,
+// e.g. "now-1w" for the last week, rewritten to a range rounded to
+// the unit, in the 'timezone' (UTC by default) if the value is a
+// {"date": "now", "timezone": "Europe/Oslo"} object
+type filterDateTrunc struct {
+	field    string
+	date     string
+	unit     string
+	timezone string
+}
+
+var (
+	dateTruncUnits = map[string]string{
+		"$on_day":   "d",
+		"$in_week":  "w",
+		"$in_month": "M",
+	}
+
+	// "now" date math, with an optional rounding, e.g. "now-1d/d"
+	dateMathRegexp = regexp.MustCompile(`^now([+-][0-9]+[yMwdhHms])*(/[yMwdhHms])?$`)
+)
+
+func NewFilterDateTrunc(fp FilterPredicate) (*filterDateTrunc, error) {
+	field, ok := dateAttrs[fp.Attribute]
+	if !ok || fp.Scope != scopeSystem {
+		return nil, ErrDateRequired
+	}
+	date, timezone, err := parseDateValue(fp.Value)
+	if err != nil {
+		return nil, err
+	}
+	if !dateMathRegexp.MatchString(date) {
+		// anchor the date math to the date
+		date += "||"
+	} else if i := strings.Index(date, "/"); i >= 0 {
+		// the range is rounded to the unit already
+		date = date[:i]
+	}
+
+	return &filterDateTrunc{
+		field:    field,
+		date:     date,
+		unit:     dateTruncUnits[fp.Type],
+		timezone: timezone,
+	}, nil
+}
+
+// isDateAttr tells if the filter applies to a system date attribute
+func isDateAttr(fp FilterPredicate) bool {
+	_, ok := dateAttrs[fp.Attribute]
+	return ok && fp.Scope == scopeSystem
+}
+
+// parseDateValue parses a date filter value: a date, "now" date
+// math, or a {"date", "timezone"} object
+func parseDateValue(value interface{}) (date, timezone string, err error) {
+	switch val := value.(type) {
+	case string:
+		date = val
+	case map[string]interface{}:
+		for key, v := range val {
+			s, ok := v.(string)
+			if !ok {
+				return "", "", ErrDateRequired
+			}
+			switch key {
+			case "date":
+				date = s
+			case "timezone":
+				if !timezoneRegexp.MatchString(s) {
+					return "", "", ErrDateRequired
+				}
+				timezone = s
+			default:
+				return "", "", ErrDateRequired
+			}
+		}
+	default:
+		return "", "", ErrDateRequired
+	}
+
+	if !dateMathRegexp.MatchString(date) {
+		if _, err := parseDate(date); err != nil {
+			return "", "", ErrDateRequired
+		}
+	}
+	return date, timezone, nil
+}
+
+// parseDate parses a calendar date, or a RFC3339 timestamp
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func (f *filterDateTrunc) AddTo(q Query) Query {
+	rng := M{
+		"gte": f.date + "/" + f.unit,
+		"lt":  f.date + "+1" + f.unit + "/" + f.unit,
+	}
+	if f.timezone != "" {
+		rng["time_zone"] = f.timezone
+	}
+	return q.Must(M{
+		"range": M{
+			f.field: rng,
+		},
+	})
+}
+
+// "$gt", "$gte", "$lt", "$lte" on the system date attributes: the
+// value is a date, or "now" date math, in the 'timezone' (UTC by
+// default) if the value is a {"date", "timezone"} object, e.g.
+// {"date": "now/d", "timezone": "Europe/Oslo"} for the local day
+type filterDateRange struct {
+	field    string
+	op       string
+	date     string
+	timezone string
+}
+
+func NewFilterDateRange(fp FilterPredicate, op string) (*filterDateRange, error) {
+	date, timezone, err := parseDateValue(fp.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &filterDateRange{
+		field:    dateAttrs[fp.Attribute],
+		op:       op,
+		date:     date,
+		timezone: timezone,
+	}, nil
+}
+
+func (f *filterDateRange) AddTo(q Query) Query {
+	rng := M{
+		f.op: f.date,
+	}
+	if f.timezone != "" {
+		rng["time_zone"] = f.timezone
+	}
+	return q.Must(M{
+		"range": M{
+			f.field: rng,
+		},
+	})
+}
+
 // "$gt", "$gte", "$lt", "$lte"
 type filterRange struct {
 	*filter
@@ -404,8 +941,8 @@ type filterRange struct {
 	op string
 }
 
-func NewFilterRange(fp FilterPredicate, op string) (*filterRange, error) {
-	f, err := NewFilter(fp, ArrNotAllowed, TypeAny)
+func NewFilterRange(fp FilterPredicate, s Settings, op string) (*filterRange, error) {
+	f, err := NewFilter(fp, ArrNotAllowed, TypeAny, s)
 	if err != nil {
 		return nil, err
 	}
@@ -425,16 +962,97 @@ func (f *filterRange) AddTo(q Query) Query {
 	})
 }
 
+// filterAnyScope matches the attribute in any scope: the filter is
+// applied to each scope, and at least one must match; the negative
+// filters ("$ne", "$nin", "$exists": false) must hold in all scopes
+type filterAnyScope struct {
+	should S
+	scopes []string
+	negate bool
+}
+
+// scopes searched by the "*" scope filters
+var anyScopes = []string{
+	scopeInventory,
+	scopeIdentity,
+	scopeCustom,
+	scopeSystem,
+}
+
+func NewFilterAnyScope(fp FilterPredicate, s Settings) (*filterAnyScope, error) {
+	negate := false
+	switch fp.Type {
+	case "$ne":
+		fp.Type = "$eq"
+		negate = true
+	case "$nin":
+		fp.Type = "$in"
+		negate = true
+	case "$exists":
+		if exists, ok := fp.Value.(bool); ok && !exists {
+			fp.Value = true
+			negate = true
+		}
+	}
+
+	should := S{}
+	scopes := []string{}
+	for _, scope := range anyScopes {
+		fp.Scope = scope
+		part, err := getFilterPart(fp, s)
+		// only some scopes may index the attribute as an IP address
+		if err == ErrNotIPAttribute {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		q := part.AddTo(NewQuery()).(*query)
+		should = append(should, q.boolQuery())
+		scopes = append(scopes, scope)
+	}
+	if len(should) == 0 {
+		return nil, ErrNotIPAttribute
+	}
+
+	return &filterAnyScope{
+		should: should,
+		scopes: scopes,
+		negate: negate,
+	}, nil
+}
+
+// setNames names the scope clauses, see namedFilter
+func (f *filterAnyScope) setNames(name string) {
+	for i, clause := range f.should {
+		clause.(M)["bool"].(M)["_name"] = name + ":" + f.scopes[i]
+	}
+}
+
+func (f *filterAnyScope) AddTo(q Query) Query {
+	cond := M{
+		"bool": M{
+			"minimum_should_match": 1,
+			"should":               f.should,
+		},
+	}
+	if f.negate {
+		return q.MustNot(cond)
+	}
+	return q.Must(cond)
+}
+
 //
 type sort struct {
 	attrStr string
 	attrNum string
+	order   string
 }
 
 func NewSort(sc SortCriteria) *sort {
 	return &sort{
 		attrStr: ToAttr(sc.Scope, sc.Attribute, TypeStr),
 		attrNum: ToAttr(sc.Scope, sc.Attribute, TypeNum),
+		order:   sc.Order,
 	}
 }
 
@@ -443,12 +1061,14 @@ func (s *sort) AddTo(q Query) Query {
 		WithSort(
 			M{
 				s.attrStr: M{
+					"order":         s.order,
 					"unmapped_type": "keyword",
 				},
 			},
 		).WithSort(
 		M{
 			s.attrNum: M{
+				"order":         s.order,
 				"unmapped_type": "double",
 			},
 		},
@@ -457,6 +1077,115 @@ func (s *sort) AddTo(q Query) Query {
 	return q
 }
 
+// numericSort coerces the string values of the attribute to numbers,
+// with a script, as the numeric strings are indexed as keywords; the
+// numeric values are sorted along, and the non-numeric ones last
+type numericSort struct {
+	attrStr string
+	attrNum string
+	order   string
+}
+
+const numericSortScript = `
+if (doc.containsKey(params.num) && doc[params.num].size() > 0) {
+	return doc[params.num].value;
+}
+if (doc.containsKey(params.str) && doc[params.str].size() > 0) {
+	try {
+		return Double.parseDouble(doc[params.str].value.trim());
+	} catch (NumberFormatException e) {
+	}
+}
+return params.missing;`
+
+func NewNumericSort(sc SortCriteria) *numericSort {
+	return &numericSort{
+		attrStr: ToAttr(sc.Scope, sc.Attribute, TypeStr),
+		attrNum: ToAttr(sc.Scope, sc.Attribute, TypeNum),
+		order:   sc.Order,
+	}
+}
+
+func (s *numericSort) AddTo(q Query) Query {
+	missing := math.MaxFloat64
+	if s.order == "desc" {
+		missing = -math.MaxFloat64
+	}
+	q = q.WithSort(M{
+		"_script": M{
+			"type":  "number",
+			"order": s.order,
+			"script": M{
+				"lang":   "painless",
+				"source": numericSortScript,
+				"params": M{
+					"str":     s.attrStr,
+					"num":     s.attrNum,
+					"missing": missing,
+				},
+			},
+		},
+	})
+	// the non-numeric values, sorted last, are ordered as strings
+	return q.WithSort(M{
+		s.attrStr: M{
+			"order":         s.order,
+			"unmapped_type": "keyword",
+		},
+	})
+}
+
+//
+type scoreSort struct {
+	order string
+}
+
+func NewScoreSort(sc SortCriteria) *scoreSort {
+	return &scoreSort{
+		order: sc.Order,
+	}
+}
+
+func (s *scoreSort) AddTo(q Query) Query {
+	return q.WithSort(
+		M{
+			SortScore: M{
+				"order": s.order,
+			},
+		},
+	)
+}
+
+// idSort is the tiebreaker sort on the device ID, the same as the
+// document _id within a tenant index, but backed by doc values
+type idSort struct{}
+
+func NewIDSort() *idSort {
+	return &idSort{}
+}
+
+func (s *idSort) AddTo(q Query) Query {
+	return q.WithSort(
+		M{
+			"id": M{
+				"order": "asc",
+			},
+		},
+	)
+}
+
+// getSortPart returns the sort part for the criteria,
+// either relevance score or attribute based
+func getSortPart(sc SortCriteria) QueryPart {
+	if sc.Attribute == SortScore {
+		return NewScoreSort(sc)
+	}
+	if sc.Numeric {
+		return NewNumericSort(sc)
+	}
+	return NewSort(sc)
+}
+
 //
 type sel struct {
 	attrs []SelectAttribute
@@ -475,11 +1204,13 @@ func (s *sel) AddTo(q Query) Query {
 		fields = append(fields,
 			ToAttr(a.Scope, a.Attribute, TypeStr),
 			ToAttr(a.Scope, a.Attribute, TypeNum),
+			ToAttr(a.Scope, a.Attribute, TypeObj),
 		)
 	}
 
-	//always include a device id
-	fields = append(fields, "id")
+	//always include a device id, and the typed device fields
+	fields = append(fields, "id", "groupName", "status", "createdAt", "updatedAt",
+		ToAttr(scopeSystem, AttrNameStaleSince, TypeStr))
 
 	return q.With(map[string]interface{}{
 		"fields":  fields,
@@ -488,9 +1219,56 @@ func (s *sel) AddTo(q Query) Query {
 
 }
 
+// collapse returns one device per value of a single-valued string
+// attribute, with the count of the devices sharing the value
+type collapse struct {
+	attr string
+}
+
+const collapseInnerHits = "collapsed"
+
+func NewCollapse(attr SelectAttribute) *collapse {
+	return &collapse{
+		attr: ToAttr(attr.Scope, attr.Attribute, TypeStr),
+	}
+}
+
+func (c *collapse) AddTo(q Query) Query {
+	return q.With(M{
+		"collapse": M{
+			"field": c.attr,
+			"inner_hits": M{
+				"name":    collapseInnerHits,
+				"size":    0,
+				"_source": false,
+			},
+		},
+	})
+}
+
+// CollapsedCount extracts the count of the devices collapsed
+// into a search hit, if any
+func CollapsedCount(hit map[string]interface{}) (int, bool) {
+	innerHits, _ := hit["inner_hits"].(map[string]interface{})
+	collapsed, _ := innerHits[collapseInnerHits].(map[string]interface{})
+	hits, _ := collapsed["hits"].(map[string]interface{})
+	total, _ := hits["total"].(map[string]interface{})
+	count, ok := total["value"].(float64)
+	return int(count), ok
+}
+
 //
 type devIDsFilter struct {
 	devIDs []string
+	lookup *TermsLookup
+}
+
+// TermsLookup references the field of a stored document
+// holding the terms, fetched by ES when searching
+type TermsLookup struct {
+	Index string `json:"index"`
+	ID    string `json:"id"`
+	Path  string `json:"path"`
 }
 
 func NewDevIDsFilter(ids []string) *devIDsFilter {
@@ -499,7 +1277,22 @@ func NewDevIDsFilter(ids []string) *devIDsFilter {
 	}
 }
 
+// NewDevIDsLookupFilter matches the device IDs stored in the lookup
+// document, keeping the query small for thousands of IDs
+func NewDevIDsLookupFilter(lookup TermsLookup) *devIDsFilter {
+	return &devIDsFilter{
+		lookup: &lookup,
+	}
+}
+
 func (f *devIDsFilter) AddTo(q Query) Query {
+	if f.lookup != nil {
+		return q.Must(M{
+			"terms": M{
+				attrDeviceID: f.lookup,
+			},
+		})
+	}
 	return q.Must(M{
 		"terms": M{
 			attrDeviceID: f.devIDs,
@@ -507,23 +1300,87 @@ func (f *devIDsFilter) AddTo(q Query) Query {
 	})
 }
 
-func BuildQuery(parms SearchParams) (Query, error) {
+// FiltersQuery returns the bool query matching all the filters
+func FiltersQuery(filters []FilterPredicate, s Settings) (M, error) {
+	q := &query{}
+	for _, f := range filters {
+		fpart, err := getFilterPart(f, s)
+		if err != nil {
+			return nil, err
+		}
+		fpart.AddTo(q)
+	}
+	return q.boolQuery(), nil
+}
+
+func BuildQuery(parms SearchParams, s Settings) (Query, error) {
 	query := NewQuery()
 
-	for _, f := range parms.Filters {
-		fpart, err := getFilterPart(f)
+	for i, f := range parms.Filters {
+		// the facet filters go to the post filter
+		if isFacetFilter(parms.Facets, f) {
+			continue
+		}
+		fpart, err := getFilterPart(f, s)
 		if err != nil {
 			return nil, err
 		}
+		if parms.ExplainFilters {
+			fpart = NewNamedFilter(fpart, i)
+		}
 		query = fpart.AddTo(query)
 	}
 
-	for _, s := range parms.Sort {
-		sort := NewSort(s)
-		query = sort.AddTo(query)
+	// each excluded filter drops the devices it matches
+	for i, f := range parms.ExcludeFilters {
+		var name string
+		if parms.ExplainFilters {
+			name = namedExcludePrefix + strconv.Itoa(i)
+		}
+		cond, err := namedFiltersQuery(f, name, s)
+		if err != nil {
+			return nil, err
+		}
+		query = query.MustNot(cond)
+	}
+
+	for i, f := range parms.AnyFilters {
+		var name string
+		if parms.ExplainFilters {
+			name = namedAnyPrefix + strconv.Itoa(i)
+		}
+		cond, err := namedFiltersQuery(f, name, s)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Should(cond)
+	}
+	if len(parms.AnyFilters) > 0 {
+		query = query.MinimumShouldMatch(parms.MinimumShouldMatch)
+	}
+
+	if parms.Query != nil {
+		var err error
+		query, err = parms.Query.AddTo(query, s)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	query = query.WithPage(parms.Page, parms.PerPage)
+	for _, f := range parms.ScriptFilters {
+		query = NewScriptFilter(f).AddTo(query)
+	}
+
+	// a random sample of the devices, rather than a page
+	if parms.Sample > 0 {
+		query = NewSample(parms).AddTo(query)
+	} else {
+		var err error
+		query, err = sortAndPage(query, parms)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if len(parms.Attributes) > 0 {
 		sel := NewSelect(parms.Attributes)
@@ -535,6 +1392,63 @@ func BuildQuery(parms SearchParams) (Query, error) {
 		query = devs.AddTo(query)
 	}
 
+	if parms.Collapse != nil {
+		query = NewCollapse(*parms.Collapse).AddTo(query)
+	}
+
+	var facets *facets
+	if len(parms.Facets) > 0 {
+		var err error
+		facets, err = NewFacets(parms.Facets, parms.Filters, parms.ExplainFilters, s)
+		if err != nil {
+			return nil, err
+		}
+		query = facets.AddTo(query)
+	}
+
+	// the group and collapse counts share the aggregations with the facets
+	if parms.GroupCount != nil {
+		query = NewGroupCount(*parms.GroupCount, parms.GroupCountType, facets).AddTo(query)
+	}
+	if parms.Collapse != nil {
+		query = NewCollapseCount(*parms.Collapse, facets).AddTo(query)
+	}
+
+	return query, nil
+}
+
+// sortAndPage adds the sort criteria, and the page or cursor
+func sortAndPage(query Query, parms SearchParams) (Query, error) {
+	sorts := parms.Sort
+	if len(sorts) == 0 {
+		sorts = parms.DefaultSort
+	}
+	if len(sorts) == 0 {
+		query = NewScoreSort(SortCriteria{Order: "desc"}).AddTo(query)
+	}
+	for _, s := range sorts {
+		query = getSortPart(s).AddTo(query)
+	}
+	// devices sharing the sort values are ordered by ID,
+	// so that paging is stable
+	query = NewIDSort().AddTo(query)
+
+	// scores aren't computed when sorting by attributes only
+	if parms.WithScore && len(sorts) > 0 {
+		query = query.With(M{"track_scores": true})
+	}
+
+	if parms.Cursor != "" {
+		searchAfter, err := DecodeCursor(parms.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.With(M{"search_after": searchAfter}).
+			WithPage(1, parms.PerPage)
+	} else {
+		query = query.WithPage(parms.Page, parms.PerPage)
+	}
+
 	return query, nil
 }
 
@@ -0,0 +1,784 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildQueryScoreSort(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Sort: []SortCriteria{
+			{Attribute: SortScore, Order: "desc"},
+			{Scope: "inventory", Attribute: "device_type", Order: "asc"},
+		},
+		WithScore: true,
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"sort":[{"_score":{"order":"desc"}},{"inventory_device_type_str"`)
+	assert.Contains(t, string(b), `"track_scores":true`)
+
+	params.Sort[1].Scope = ""
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQueryNumericSort(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Sort: []SortCriteria{
+			{Scope: "inventory", Attribute: "mem_total_kB", Order: "desc", Numeric: true},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Sort []map[string]struct {
+			Type   string `json:"type"`
+			Order  string `json:"order"`
+			Script struct {
+				Params map[string]interface{} `json:"params"`
+			} `json:"script"`
+		} `json:"sort"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Sort, 3)
+	script := res.Sort[0]["_script"]
+	assert.Equal(t, "number", script.Type)
+	assert.Equal(t, "desc", script.Order)
+	assert.Equal(t, "inventory_mem_total_kB_str", script.Script.Params["str"])
+	assert.Equal(t, "inventory_mem_total_kB_num", script.Script.Params["num"])
+	assert.Equal(t, -math.MaxFloat64, script.Script.Params["missing"])
+	assert.Contains(t, res.Sort[1], "inventory_mem_total_kB_str")
+	assert.Contains(t, res.Sort[2], "id")
+
+	params.Sort = []SortCriteria{{Attribute: SortScore, Order: "desc", Numeric: true}}
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQueryCursor(t *testing.T) {
+	cursor, err := EncodeCursor([]interface{}{"qemux86-64", "dev-1"})
+	assert.NoError(t, err)
+
+	params := SearchParams{
+		Page:    3,
+		PerPage: 20,
+		Sort: []SortCriteria{
+			{Scope: "inventory", Attribute: "device_type", Order: "asc"},
+		},
+		Cursor: cursor,
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"id":{"order":"asc"}}]`)
+	assert.Contains(t, string(b), `"search_after":["qemux86-64","dev-1"]`)
+	assert.Contains(t, string(b), `"from":0`)
+
+	params.Cursor = "not a cursor"
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQueryCollapse(t *testing.T) {
+	params := SearchParams{
+		Page:     1,
+		PerPage:  20,
+		Collapse: &SelectAttribute{Scope: "inventory", Attribute: "site_id"},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"collapse":{"field":"inventory_site_id_str"`)
+	// the results, rather than the devices, are counted
+	assert.Contains(t, string(b),
+		`"collapse_count":{"cardinality":{"field":"inventory_site_id_str"}}`)
+
+	// along with a group count
+	params.GroupCount = &SelectAttribute{Scope: "inventory", Attribute: "device_type"}
+	q, err = BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"collapse_count":{`)
+	assert.Contains(t, string(b), `"group_count":{`)
+	params.GroupCount = nil
+
+	total, err := ParseCollapseCount(map[string]interface{}{
+		CollapseCountAggregation: map[string]interface{}{"value": float64(3)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	count, ok := CollapsedCount(map[string]interface{}{
+		"inner_hits": map[string]interface{}{
+			"collapsed": map[string]interface{}{
+				"hits": map[string]interface{}{
+					"total": map[string]interface{}{"value": float64(12)},
+				},
+			},
+		},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 12, count)
+
+	params.Facets = []AggregationTerm{{
+		Name: CollapseCountAggregation, Scope: "inventory", Attribute: "device_type", Limit: 10,
+	}}
+	assert.Error(t, params.Validate())
+	params.Facets = nil
+
+	params.Collapse.Attribute = ""
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQueryScriptFilter(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		ScriptFilters: []ScriptFilter{{
+			Source: "doc['inventory_mem_total_kB_num'].value > params.min * 2",
+			Params: map[string]interface{}{"min": float64(1024)},
+		}},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"script":{"script":{"lang":"painless","params":{"min":1024}`)
+
+	params.ScriptFilters[0].Source = "new java.io.File('/').exists()"
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQueryAnyScope(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: ScopeAny, Attribute: "hostname", Type: "$eq", Value: "raspberrypi"},
+			{Scope: ScopeAny, Attribute: "region", Type: "$ne", Value: "eu"},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			Bool struct {
+				Must    []M `json:"must"`
+				MustNot []M `json:"must_not"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Query.Bool.Must, 1)
+	assert.Len(t, res.Query.Bool.MustNot, 1)
+
+	must, _ := json.Marshal(res.Query.Bool.Must[0])
+	assert.JSONEq(t, `{"bool": {
+		"minimum_should_match": 1,
+		"should": [
+			{"bool": {"must": [{"match": {"inventory_hostname_str": "raspberrypi"}}]}},
+			{"bool": {"must": [{"match": {"identity_hostname_str": "raspberrypi"}}]}},
+			{"bool": {"must": [{"match": {"custom_hostname_str": "raspberrypi"}}]}},
+			{"bool": {"must": [{"match": {"system_hostname_str": "raspberrypi"}}]}}
+		]
+	}}`, string(must))
+	assert.Contains(t, string(b), `"must_not":[{"bool":{"minimum_should_match":1,"should":[{"bool":{"must":[{"match":{"inventory_region_str":"eu"}}]}}`)
+}
+
+func TestBuildQueryExcludeAnyFilters(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "identity", Attribute: "status", Type: "$eq", Value: "accepted"},
+		},
+		ExcludeFilters: []FilterPredicate{
+			{Scope: "system", Attribute: "group", Type: "$in", Value: []interface{}{"test", "dev"}},
+		},
+		AnyFilters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "rpi4"},
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "rpi3"},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			Bool struct {
+				Must               []M `json:"must"`
+				MustNot            []M `json:"must_not"`
+				Should             []M `json:"should"`
+				MinimumShouldMatch int `json:"minimum_should_match"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Query.Bool.Must, 1)
+	assert.Len(t, res.Query.Bool.MustNot, 1)
+	assert.Len(t, res.Query.Bool.Should, 2)
+	assert.Equal(t, 1, res.Query.Bool.MinimumShouldMatch)
+
+	mustNot, _ := json.Marshal(res.Query.Bool.MustNot[0])
+	assert.JSONEq(t, `{"bool": {"must": [
+		{"terms": {"system_group_str": ["test", "dev"]}}
+	]}}`, string(mustNot))
+
+	params.MinimumShouldMatch = 3
+	assert.Error(t, params.Validate())
+}
+
+func TestBuildQuerySize(t *testing.T) {
+	testCases := map[string]struct {
+		value interface{}
+		min   int
+		max   int
+		err   error
+	}{
+		"ok, exact": {
+			value: float64(2),
+			min:   2,
+			max:   2,
+		},
+		"ok, more than": {
+			value: map[string]interface{}{"$gt": float64(5)},
+			min:   6,
+			max:   math.MaxInt32,
+		},
+		"ok, range": {
+			value: map[string]interface{}{"$gte": float64(1), "$lt": float64(4)},
+			min:   1,
+			max:   3,
+		},
+		"error, negative": {
+			value: float64(-1),
+			err:   ErrSizeRequired,
+		},
+		"error, operator": {
+			value: map[string]interface{}{"$eq": float64(1)},
+			err:   ErrSizeRequired,
+		},
+		"error, string": {
+			value: "2",
+			err:   ErrSizeRequired,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params := SearchParams{
+				Page:    1,
+				PerPage: 20,
+				Filters: []FilterPredicate{{
+					Scope:     "inventory",
+					Attribute: "network_interfaces",
+					Type:      "$size",
+					Value:     tc.value,
+				}},
+			}
+			assert.NoError(t, params.Validate())
+
+			q, err := BuildQuery(params, Settings{})
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := json.Marshal(q)
+			assert.NoError(t, err)
+
+			var res struct {
+				Query struct {
+					Bool struct {
+						Must []struct {
+							Script struct {
+								Script struct {
+									Params struct {
+										Fields []string `json:"fields"`
+										Min    int      `json:"min"`
+										Max    int      `json:"max"`
+									} `json:"params"`
+								} `json:"script"`
+							} `json:"script"`
+						} `json:"must"`
+					} `json:"bool"`
+				} `json:"query"`
+			}
+			assert.NoError(t, json.Unmarshal(b, &res))
+			assert.Len(t, res.Query.Bool.Must, 1)
+			script := res.Query.Bool.Must[0].Script.Script.Params
+			assert.Equal(t, []string{
+				"inventory_network_interfaces_str",
+				"inventory_network_interfaces_num",
+			}, script.Fields)
+			assert.Equal(t, tc.min, script.Min)
+			assert.Equal(t, tc.max, script.Max)
+		})
+	}
+}
+
+func TestBuildQueryFuzzy(t *testing.T) {
+	testCases := map[string]struct {
+		value interface{}
+		fuzzy string
+		err   error
+	}{
+		"ok, string": {
+			value: "SN-1243",
+			fuzzy: `{"inventory_serial_number_str": {
+				"value": "SN-1243",
+				"fuzziness": "AUTO",
+				"prefix_length": 0,
+				"transpositions": true
+			}}`,
+		},
+		"ok, options": {
+			value: map[string]interface{}{
+				"value":         "SN-1243",
+				"fuzziness":     float64(1),
+				"prefix_length": float64(3),
+			},
+			fuzzy: `{"inventory_serial_number_str": {
+				"value": "SN-1243",
+				"fuzziness": 1,
+				"prefix_length": 3,
+				"transpositions": true
+			}}`,
+		},
+		"error, fuzziness": {
+			value: map[string]interface{}{
+				"value":     "SN-1243",
+				"fuzziness": float64(3),
+			},
+			err: ErrFuzzyRequired,
+		},
+		"error, no value": {
+			value: map[string]interface{}{"prefix_length": float64(3)},
+			err:   ErrFuzzyRequired,
+		},
+		"error, number": {
+			value: float64(1243),
+			err:   ErrFuzzyRequired,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params := SearchParams{
+				Page:    1,
+				PerPage: 20,
+				Filters: []FilterPredicate{{
+					Scope:     "inventory",
+					Attribute: "serial_number",
+					Type:      "$fuzzy",
+					Value:     tc.value,
+				}},
+			}
+			assert.NoError(t, params.Validate())
+
+			q, err := BuildQuery(params, Settings{})
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := json.Marshal(q)
+			assert.NoError(t, err)
+
+			var res struct {
+				Query struct {
+					Bool struct {
+						Must []struct {
+							Fuzzy json.RawMessage `json:"fuzzy"`
+						} `json:"must"`
+					} `json:"bool"`
+				} `json:"query"`
+			}
+			assert.NoError(t, json.Unmarshal(b, &res))
+			assert.Len(t, res.Query.Bool.Must, 1)
+			assert.JSONEq(t, tc.fuzzy, string(res.Query.Bool.Must[0].Fuzzy))
+		})
+	}
+}
+
+func TestBuildQueryDateTrunc(t *testing.T) {
+	testCases := map[string]struct {
+		attribute string
+		operator  string
+		value     interface{}
+		rng       string
+		err       error
+	}{
+		"ok, on day": {
+			attribute: "created_ts",
+			operator:  "$on_day",
+			value:     "2021-06-14",
+			rng: `{"createdAt": {
+				"gte": "2021-06-14||/d",
+				"lt": "2021-06-14||+1d/d"
+			}}`,
+		},
+		"ok, this week": {
+			attribute: "updated_ts",
+			operator:  "$in_week",
+			value:     "now",
+			rng: `{"updatedAt": {
+				"gte": "now/w",
+				"lt": "now+1w/w"
+			}}`,
+		},
+		"ok, last month, time zone": {
+			attribute: "created_ts",
+			operator:  "$in_month",
+			value: map[string]interface{}{
+				"date":     "now-1M",
+				"timezone": "Europe/Oslo",
+			},
+			rng: `{"createdAt": {
+				"gte": "now-1M/M",
+				"lt": "now-1M+1M/M",
+				"time_zone": "Europe/Oslo"
+			}}`,
+		},
+		"error, not a date attribute": {
+			attribute: "group",
+			operator:  "$on_day",
+			value:     "2021-06-14",
+			err:       ErrDateRequired,
+		},
+		"error, date": {
+			attribute: "created_ts",
+			operator:  "$on_day",
+			value:     "yesterday",
+			err:       ErrDateRequired,
+		},
+		"error, time zone": {
+			attribute: "created_ts",
+			operator:  "$in_week",
+			value: map[string]interface{}{
+				"date":     "now",
+				"timezone": "Mars Standard Time",
+			},
+			err: ErrDateRequired,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			params := SearchParams{
+				Page:    1,
+				PerPage: 20,
+				Filters: []FilterPredicate{{
+					Scope:     "system",
+					Attribute: tc.attribute,
+					Type:      tc.operator,
+					Value:     tc.value,
+				}},
+			}
+			assert.NoError(t, params.Validate())
+
+			q, err := BuildQuery(params, Settings{})
+			if tc.err != nil {
+				assert.Equal(t, tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := json.Marshal(q)
+			assert.NoError(t, err)
+
+			var res struct {
+				Query struct {
+					Bool struct {
+						Must []struct {
+							Range json.RawMessage `json:"range"`
+						} `json:"must"`
+					} `json:"bool"`
+				} `json:"query"`
+			}
+			assert.NoError(t, json.Unmarshal(b, &res))
+			assert.Len(t, res.Query.Bool.Must, 1)
+			assert.JSONEq(t, tc.rng, string(res.Query.Bool.Must[0].Range))
+		})
+	}
+}
+
+func TestBuildQueryFacets(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+			{Scope: "system", Attribute: "group", Type: "$eq", Value: "prod"},
+			{Scope: "identity", Attribute: "status", Type: "$eq", Value: "accepted"},
+		},
+		Facets: []AggregationTerm{
+			{Name: "group", Scope: "system", Attribute: "group"},
+			{Name: "device_type", Scope: "inventory", Attribute: "device_type"},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query      M `json:"query"`
+		PostFilter M `json:"post_filter"`
+		Aggs       M `json:"aggs"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+
+	query, _ := json.Marshal(res.Query)
+	assert.JSONEq(t, `{"bool": {"must": [
+		{"match": {"identity_status_str": "accepted"}}
+	]}}`, string(query))
+
+	postFilter, _ := json.Marshal(res.PostFilter)
+	assert.JSONEq(t, `{"bool": {"must": [
+		{"match": {"inventory_device_type_str": "qemux86-64"}},
+		{"match": {"system_group_str": "prod"}}
+	]}}`, string(postFilter))
+
+	// each facet is counted without its own filter
+	aggs, _ := json.Marshal(res.Aggs)
+	assert.JSONEq(t, `{
+		"group": {
+			"filter": {"bool": {"must": [{"match": {"inventory_device_type_str": "qemux86-64"}}]}},
+			"aggs": {"group": {"terms": {"field": "system_group_str", "size": 10}}}
+		},
+		"device_type": {
+			"filter": {"bool": {"must": [{"match": {"system_group_str": "prod"}}]}},
+			"aggs": {"device_type": {"terms": {"field": "inventory_device_type_str", "size": 10}}}
+		}
+	}`, string(aggs))
+
+	facets, err := ParseFacets(params.Facets, map[string]interface{}{
+		"group": map[string]interface{}{
+			"doc_count": 3.0,
+			"group": map[string]interface{}{
+				"buckets": []interface{}{
+					map[string]interface{}{"key": "prod", "doc_count": 2.0},
+					map[string]interface{}{"key": "test", "doc_count": 1.0},
+				},
+			},
+		},
+		"device_type": map[string]interface{}{
+			"doc_count": 2.0,
+			"device_type": map[string]interface{}{
+				"buckets": []interface{}{
+					map[string]interface{}{"key": "qemux86-64", "doc_count": 2.0},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, facets, 2)
+	assert.Equal(t, "group", facets[0].Name)
+	assert.Equal(t, 2, facets[0].Items[0].Count)
+	assert.Equal(t, "qemux86-64", facets[1].Items[0].Key)
+}
+
+func TestFiltersQuery(t *testing.T) {
+	q, err := FiltersQuery([]FilterPredicate{{
+		Scope:     AttrScopeSystem,
+		Attribute: AttrNameGroup,
+		Type:      "$in",
+		Value:     []interface{}{"prod", "test"},
+	}}, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"bool": {"must": [
+		{"terms": {"system_group_str": ["prod", "test"]}}
+	]}}`, string(b))
+}
+
+func TestSearchParamsFingerprint(t *testing.T) {
+	params := SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+			{Scope: "system", Attribute: "group", Type: "$in", Value: []interface{}{"prod"}},
+		},
+		Sort: []SortCriteria{
+			{Scope: "inventory", Attribute: "device_type", Order: "asc"},
+		},
+	}
+	other := SearchParams{
+		Filters: []FilterPredicate{
+			{Scope: "system", Attribute: "group", Type: "$in", Value: []interface{}{"test", "dev"}},
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "raspberrypi4"},
+		},
+		Sort: []SortCriteria{
+			{Scope: "inventory", Attribute: "device_type", Order: "asc"},
+		},
+		Page: 3,
+	}
+	assert.Equal(t, params.Fingerprint(), other.Fingerprint())
+
+	other.Sort[0].Order = "desc"
+	assert.NotEqual(t, params.Fingerprint(), other.Fingerprint())
+
+	// the query trees differing only by the values and the
+	// children order share a fingerprint too
+	params.Query = &QueryNode{Or: []QueryNode{
+		{Filter: &FilterPredicate{Scope: "inventory", Attribute: "artifact_name", Type: "$eq", Value: "v1"}},
+		{Not: &QueryNode{Filter: &FilterPredicate{Scope: "system", Attribute: "group", Type: "$eq", Value: "prod"}}},
+	}}
+	other.Sort[0].Order = "asc"
+	other.Query = &QueryNode{Or: []QueryNode{
+		{Not: &QueryNode{Filter: &FilterPredicate{Scope: "system", Attribute: "group", Type: "$eq", Value: "test"}}},
+		{Filter: &FilterPredicate{Scope: "inventory", Attribute: "artifact_name", Type: "$eq", Value: "v2"}},
+	}}
+	assert.Equal(t, params.Fingerprint(), other.Fingerprint())
+
+	other.Query.Or = other.Query.Or[:1]
+	assert.NotEqual(t, params.Fingerprint(), other.Fingerprint())
+}
+
+func TestQueryCountQuery(t *testing.T) {
+	q, err := BuildQuery(SearchParams{
+		Page:    2,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+		},
+	}, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q.CountQuery())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"query": {"bool": {"must": [
+		{"bool": {"must": [{"match": {"inventory_device_type_str": "qemux86-64"}}]}}
+	]}}}`, string(b))
+}
+
+func TestBuildQueryExplainFilters(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$ne", Value: "qemux86-64"},
+			{Scope: ScopeAny, Attribute: "hostname", Type: "$eq", Value: "raspberrypi"},
+		},
+		ExplainFilters: true,
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"bool":{"_name":"0","must_not":[{"match":{"inventory_device_type_str":"qemux86-64"}}]}}`)
+	assert.Contains(t, string(b), `{"bool":{"_name":"1:identity","must":[{"match":{"identity_hostname_str":"raspberrypi"}}]}}`)
+
+	assert.Equal(t, []string{"0", "1", "1:identity"}, MatchedFilters(map[string]interface{}{
+		"matched_queries": []interface{}{"0", "1", "1:identity"},
+	}))
+
+	params.AnyFilters = []FilterPredicate{
+		{Scope: "inventory", Attribute: "artifact_name", Type: "$eq", Value: "v1"},
+	}
+	params.ExcludeFilters = []FilterPredicate{
+		{Scope: "inventory", Attribute: "artifact_name", Type: "$eq", Value: "v0"},
+	}
+	params.Facets = []AggregationTerm{
+		{Name: "types", Scope: "inventory", Attribute: "device_type", Limit: 5},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err = BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `{"bool":{"_name":"any:0","must":[{"match":{"inventory_artifact_name_str":"v1"}}]}}`)
+	assert.Contains(t, string(b), `{"bool":{"_name":"exclude:0","must":[{"match":{"inventory_artifact_name_str":"v0"}}]}}`)
+	assert.Contains(t, string(b), `"post_filter":{"bool":{"must":[{"bool":{"_name":"0","must_not":[{"match":{"inventory_device_type_str":"qemux86-64"}}]}}]}}`)
+}
+
+func TestDevIDsLookupFilter(t *testing.T) {
+	q := NewDevIDsLookupFilter(TermsLookup{
+		Index: "reporting-device-ids-lookups",
+		ID:    "f00",
+		Path:  "ids",
+	}).AddTo(NewQuery())
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			Bool struct {
+				Must []json.RawMessage `json:"must"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Query.Bool.Must, 1)
+	assert.JSONEq(t, `{"terms": {"id": {
+		"index": "reporting-device-ids-lookups",
+		"id": "f00",
+		"path": "ids"
+	}}}`, string(res.Query.Bool.Must[0]))
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	maxQueryTreeDepth = 10
+	maxQueryTreeNodes = 100
+)
+
+// QueryNode is a node of the structured query, an alternative to the
+// flat filters: either a boolean node combining the child nodes
+// ("and", "or", "not"), or a leaf "filter" predicate; exactly one of
+// the fields must be set
+type QueryNode struct {
+	And    []QueryNode      `json:"and,omitempty"`
+	Or     []QueryNode      `json:"or,omitempty"`
+	Not    *QueryNode       `json:"not,omitempty"`
+	Filter *FilterPredicate `json:"filter,omitempty"`
+}
+
+func (n QueryNode) Validate() error {
+	nodes := 0
+	return n.validate(1, &nodes)
+}
+
+func (n QueryNode) validate(depth int, nodes *int) error {
+	if depth > maxQueryTreeDepth {
+		return errors.Errorf("query can't be nested deeper than %d levels",
+			maxQueryTreeDepth)
+	}
+	*nodes++
+	if *nodes > maxQueryTreeNodes {
+		return errors.Errorf("query can't have more than %d nodes",
+			maxQueryTreeNodes)
+	}
+
+	set := 0
+	for _, ok := range []bool{n.And != nil, n.Or != nil, n.Not != nil, n.Filter != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("query node must have exactly one of and, or, not, filter")
+	}
+
+	switch {
+	case n.Filter != nil:
+		return n.Filter.Validate()
+	case n.Not != nil:
+		return n.Not.validate(depth+1, nodes)
+	}
+
+	children := n.And
+	if n.Or != nil {
+		children = n.Or
+	}
+	if len(children) == 0 {
+		return errors.New("query and/or node must have at least one child")
+	}
+	for _, c := range children {
+		if err := c.validate(depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filters returns the filter predicates of the tree leaves, in order
+func (n *QueryNode) Filters() []*FilterPredicate {
+	switch {
+	case n.Filter != nil:
+		return []*FilterPredicate{n.Filter}
+	case n.Not != nil:
+		return n.Not.Filters()
+	}
+	filters := []*FilterPredicate{}
+	for i := range n.And {
+		filters = append(filters, n.And[i].Filters()...)
+	}
+	for i := range n.Or {
+		filters = append(filters, n.Or[i].Filters()...)
+	}
+	return filters
+}
+
+// AddTo adds the query tree as a single condition of the query
+func (n QueryNode) AddTo(q Query, s Settings) (Query, error) {
+	cond, err := n.condition(s)
+	if err != nil {
+		return nil, err
+	}
+	return q.Must(cond), nil
+}
+
+// condition translates the node to an ES bool query
+func (n QueryNode) condition(s Settings) (M, error) {
+	switch {
+	case n.Filter != nil:
+		return FiltersQuery([]FilterPredicate{*n.Filter}, s)
+	case n.Not != nil:
+		cond, err := n.Not.condition(s)
+		if err != nil {
+			return nil, err
+		}
+		return M{"bool": M{"must_not": []interface{}{cond}}}, nil
+	}
+
+	children := n.And
+	if n.Or != nil {
+		children = n.Or
+	}
+	conds := make([]interface{}, 0, len(children))
+	for _, c := range children {
+		cond, err := c.condition(s)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if n.Or != nil {
+		return M{"bool": M{
+			"should":               conds,
+			"minimum_should_match": 1,
+		}}, nil
+	}
+	return M{"bool": M{"must": conds}}, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryNode(t *testing.T) {
+	var params SearchParams
+	err := json.Unmarshal([]byte(`{
+		"page": 1,
+		"per_page": 20,
+		"query": {"and": [
+			{"filter": {"scope": "inventory", "attribute": "device_type",
+				"type": "$eq", "value": "qemux86-64"}},
+			{"not": {"or": [
+				{"filter": {"scope": "identity", "attribute": "status",
+					"type": "$eq", "value": "rejected"}},
+				{"filter": {"scope": "identity", "attribute": "status",
+					"type": "$eq", "value": "noauth"}}
+			]}}
+		]}
+	}`), &params)
+	assert.NoError(t, err)
+	assert.NoError(t, params.Validate())
+	assert.Len(t, params.Query.Filters(), 3)
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			Bool struct {
+				Must []struct {
+					Bool struct {
+						Must []json.RawMessage `json:"must"`
+					} `json:"bool"`
+				} `json:"must"`
+			} `json:"bool"`
+		} `json:"query"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Query.Bool.Must, 1)
+	and := res.Query.Bool.Must[0].Bool.Must
+	assert.Len(t, and, 2)
+	assert.Contains(t, string(and[0]), `"inventory_device_type_str":"qemux86-64"`)
+	assert.Contains(t, string(and[1]), `"must_not"`)
+	assert.Contains(t, string(and[1]), `"minimum_should_match":1`)
+	assert.Contains(t, string(and[1]), `"noauth"`)
+
+	testCases := map[string]string{
+		"error, no field":       `{}`,
+		"error, two fields":     `{"and": [], "or": []}`,
+		"error, empty and":      `{"and": []}`,
+		"error, invalid filter": `{"filter": {"scope": "inventory", "attribute": "a", "type": "$foo", "value": 1}}`,
+		"error, too deep": `{"not": {"not": {"not": {"not": {"not": {"not": {"not": {"not": {"not": {"not":
+			{"filter": {"scope": "inventory", "attribute": "a", "type": "$eq", "value": 1}}}}}}}}}}}}`,
+	}
+	for name, body := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var node QueryNode
+			assert.NoError(t, json.Unmarshal([]byte(body), &node))
+			assert.Error(t, node.Validate())
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// the quotas checked against the tenant stats
+const (
+	QuotaDevices       = "devices"
+	QuotaMappingFields = "mapping_fields"
+)
+
+// QuotaConfig configures the soft quota alerts: an alert is raised
+// when a tenant uses more than 'AlertShare' of a quota; MaxDevices
+// is the devices (documents) quota, 0 for none, while the mapping
+// fields quota is the devices index total fields limit
+type QuotaConfig struct {
+	MaxDevices int64
+	AlertShare float64
+}
+
+// QuotaAlert reports a tenant approaching a quota
+type QuotaAlert struct {
+	TenantID  string    `json:"tenant_id"`
+	Quota     string    `json:"quota"`
+	Usage     int64     `json:"usage"`
+	Limit     int64     `json:"limit"`
+	Share     float64   `json:"share"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Check returns the alerts of the quotas the tenant approaches
+func (c QuotaConfig) Check(stats TenantStats, ts time.Time) []QuotaAlert {
+	alerts := []QuotaAlert{}
+	for _, q := range []struct {
+		name         string
+		usage, limit int64
+	}{
+		{QuotaDevices, stats.DeviceCount, c.MaxDevices},
+		{QuotaMappingFields, int64(stats.MappingFields), int64(stats.MappingFieldsLimit)},
+	} {
+		if q.limit <= 0 {
+			continue
+		}
+		share := float64(q.usage) / float64(q.limit)
+		if share < c.AlertShare {
+			continue
+		}
+		alerts = append(alerts, QuotaAlert{
+			TenantID:  stats.TenantID,
+			Quota:     q.name,
+			Usage:     q.usage,
+			Limit:     q.limit,
+			Share:     share,
+			Timestamp: ts,
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaCheck(t *testing.T) {
+	ts := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	conf := QuotaConfig{MaxDevices: 1000, AlertShare: 0.8}
+
+	alerts := conf.Check(TenantStats{
+		TenantID:           "foo",
+		DeviceCount:        900,
+		MappingFields:      100,
+		MappingFieldsLimit: 1000,
+	}, ts)
+	assert.Equal(t, []QuotaAlert{{
+		TenantID:  "foo",
+		Quota:     QuotaDevices,
+		Usage:     900,
+		Limit:     1000,
+		Share:     0.9,
+		Timestamp: ts,
+	}}, alerts)
+
+	// no devices quota, the mapping fields limit reached
+	conf.MaxDevices = 0
+	alerts = conf.Check(TenantStats{
+		TenantID:           "foo",
+		DeviceCount:        900,
+		MappingFields:      1000,
+		MappingFieldsLimit: 1000,
+	}, ts)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, QuotaMappingFields, alerts[0].Quota)
+	assert.Equal(t, 1.0, alerts[0].Share)
+
+	assert.Empty(t, conf.Check(TenantStats{TenantID: "foo"}, ts))
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// redaction methods applied to string attributes at index time
+const (
+	// RedactionHash replaces the value with its HMAC-SHA256, keyed
+	// with the redaction key so that the common values can't be
+	// recovered by hashing them; exact matches still work since the
+	// filter values are hashed too
+	RedactionHash = "hash"
+	// RedactionMask masks all but the last few characters
+	RedactionMask = "mask"
+
+	maskVisible = 4
+	maskChar    = "*"
+)
+
+// MinRedactionKeyLength is the minimum length, in bytes, of the
+// hash redaction key, as long as the SHA256 output
+const MinRedactionKeyLength = 32
+
+var (
+	ErrUnknownRedaction     = errors.New("unknown redaction method")
+	ErrRedactionKeyRequired = errors.Errorf("the hash redaction requires a key "+
+		"of at least %d bytes", MinRedactionKeyLength)
+)
+
+// Redactions are the redaction methods applied to the flat string
+// attribute names (see ToAttr), and the key of the hash redaction;
+// the zero value redacts nothing
+type Redactions struct {
+	methods map[string]string
+	key     []byte
+}
+
+// ParseRedactions parses redaction definitions in the form
+// "scope/name:method", e.g. "inventory/geo-city:mask"; the hash
+// redaction requires the 'key', of at least MinRedactionKeyLength
+func ParseRedactions(defs []string, key string) (Redactions, error) {
+	ret := Redactions{
+		methods: map[string]string{},
+		key:     []byte(key),
+	}
+	for _, def := range defs {
+		attr := strings.SplitN(def, ":", 2)
+		if len(attr) != 2 {
+			return Redactions{}, errors.Errorf("malformed redaction definition %q", def)
+		}
+		scopeName := strings.SplitN(attr[0], "/", 2)
+		if len(scopeName) != 2 || scopeName[0] == "" || scopeName[1] == "" {
+			return Redactions{}, errors.Errorf("malformed redaction definition %q", def)
+		}
+		if attr[1] != RedactionHash && attr[1] != RedactionMask {
+			return Redactions{}, errors.Wrap(ErrUnknownRedaction, attr[1])
+		}
+		if attr[1] == RedactionHash && len(key) < MinRedactionKeyLength {
+			return Redactions{}, ErrRedactionKeyRequired
+		}
+		ret.methods[ToAttr(scopeName[0], scopeName[1], TypeStr)] = attr[1]
+	}
+	return ret, nil
+}
+
+// IsRedacted tells if the attribute is redacted at index time
+func (r Redactions) IsRedacted(scope, name string) bool {
+	_, ok := r.methods[ToAttr(scope, name, TypeStr)]
+	return ok
+}
+
+// Any tells if any attributes are redacted
+func (r Redactions) Any() bool {
+	return len(r.methods) > 0
+}
+
+// redact applies the configured redaction to a string attribute,
+// or to the string values of the objects of a nested attribute
+func (r Redactions) redact(attr *InventoryAttribute) {
+	method, ok := r.methods[ToAttr(attr.Scope, attr.Name, TypeStr)]
+	if !ok {
+		return
+	}
+	if attr.IsObj() {
+		for _, obj := range attr.Objects {
+			for k, v := range obj {
+				obj[k] = r.redactObjectValue(method, v)
+			}
+		}
+		return
+	}
+	if !attr.IsStr() {
+		return
+	}
+
+	vals := make([]string, len(attr.String))
+	for i, v := range attr.String {
+		vals[i] = r.redactString(method, v)
+	}
+	attr.String = vals
+}
+
+// redactFilterValue hashes the filter values of hashed attributes,
+// so that they match the indexed values
+func (r Redactions) redactFilterValue(attr string, val interface{}) interface{} {
+	if r.methods[attr] != RedactionHash {
+		return val
+	}
+
+	switch v := val.(type) {
+	case string:
+		return r.redactString(RedactionHash, v)
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, e := range v {
+			ret[i] = r.redactFilterValue(attr, e)
+		}
+		return ret
+	default:
+		return val
+	}
+}
+
+// redactObjectValue redacts a string object value,
+// or the strings of a list of values
+func (r Redactions) redactObjectValue(method string, val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		return r.redactString(method, v)
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, e := range v {
+			ret[i] = r.redactObjectValue(method, e)
+		}
+		return ret
+	default:
+		return val
+	}
+}
+
+func (r Redactions) redactString(method, val string) string {
+	switch method {
+	case RedactionHash:
+		mac := hmac.New(sha256.New, r.key)
+		_, _ = mac.Write([]byte(val))
+		return hex.EncodeToString(mac.Sum(nil))
+	case RedactionMask:
+		runes := []rune(val)
+		if len(runes) <= maskVisible {
+			return strings.Repeat(maskChar, len(runes))
+		}
+		return strings.Repeat(maskChar, len(runes)-maskVisible) +
+			string(runes[len(runes)-maskVisible:])
+	default:
+		return val
+	}
+}
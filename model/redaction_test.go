@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRedactionKey = "0123456789abcdef0123456789abcdef"
+
+func TestRedactions(t *testing.T) {
+	r, err := ParseRedactions([]string{
+		"inventory/geo-city:mask",
+		"custom/email:hash",
+	}, testRedactionKey)
+	assert.NoError(t, err)
+	s := Settings{Redactions: r}
+
+	dev, err := NewDeviceFromInv("tenant", &InvDevice{
+		ID: "dev-1",
+		Attributes: DeviceAttributes{
+			{Scope: "inventory", Name: "geo-city", Value: "Oslo-Sentrum"},
+			{Scope: "custom", Name: "email", Value: "user@example.com"},
+			{Scope: "inventory", Name: "device_type", Value: "qemux86-64"},
+		},
+	}, s)
+	assert.NoError(t, err)
+	assert.Equal(t, "********trum", dev.InventoryAttributes[0].GetString())
+	assert.Equal(t, r.redactString(RedactionHash, "user@example.com"),
+		dev.CustomAttributes[0].GetString())
+	assert.Equal(t, "qemux86-64", dev.InventoryAttributes[1].GetString())
+
+	q, err := BuildQuery(SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "custom", Attribute: "email", Type: "$eq", Value: "user@example.com"},
+		},
+	}, s)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), r.redactString(RedactionHash, "user@example.com"))
+	assert.NotContains(t, string(b), "user@example.com")
+
+	// the HMAC-SHA256 of the key, unlike the plain SHA256
+	assert.Equal(t, "6af73c4e2677574b4822fcfbd41a408258718a25e65b1c039fe2934576e8344b",
+		r.redactString(RedactionHash, "user@example.com"))
+	other, err := ParseRedactions([]string{"custom/email:hash"}, strings.Repeat("x", 32))
+	assert.NoError(t, err)
+	assert.NotEqual(t, r.redactString(RedactionHash, "user@example.com"),
+		other.redactString(RedactionHash, "user@example.com"))
+
+	_, err = ParseRedactions([]string{"custom/email:encrypt"}, testRedactionKey)
+	assert.Error(t, err)
+
+	_, err = ParseRedactions([]string{"custom/email:hash"}, "short")
+	assert.Equal(t, ErrRedactionKeyRequired, err)
+
+	// the mask doesn't need a key
+	_, err = ParseRedactions([]string{"inventory/geo-city:mask"}, "")
+	assert.NoError(t, err)
+}
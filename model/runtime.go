@@ -0,0 +1,150 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxRuntimeFields = 10
+	maxScriptLength  = 1024
+)
+
+var (
+	runtimeFieldTypes = map[string]bool{
+		"keyword": true,
+		"long":    true,
+		"double":  true,
+		"boolean": true,
+		"date":    true,
+	}
+
+	// painless identifiers allowed in runtime field and filter scripts:
+	// field access, emit, params, conditionals, and side effect free string
+	// and math helpers; no loops, no object creation, no other classes
+	scriptIdentifiers = map[string]bool{
+		"emit": true, "doc": true, "params": true, "value": true, "size": true,
+		"empty": true, "containsKey": true, "if": true, "else": true, "return": true,
+		"def": true, "String": true, "int": true, "long": true, "double": true,
+		"boolean": true, "true": true, "false": true, "null": true,
+		"length": true, "toLowerCase": true, "toUpperCase": true,
+		"substring": true, "contains": true, "startsWith": true,
+		"endsWith": true, "indexOf": true, "trim": true, "equals": true,
+		"Math": true, "abs": true, "floor": true, "ceil": true, "round": true,
+		"min": true, "max": true, "toInstant": true, "toEpochMilli": true,
+		"millis": true,
+	}
+
+	// string literals, numbers, identifiers, operators and punctuation
+	scriptToken = regexp.MustCompile(
+		`^(\s+|'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|[0-9]+(?:\.[0-9]+)?[LlDdFf]?|` +
+			`[A-Za-z_][A-Za-z0-9_]*|[-+*/%<>=!&|?:;,.()\[\]{}])`)
+	scriptIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// types a script may declare its local variables with
+	scriptLocalTypes = map[string]bool{
+		"def": true, "String": true, "int": true, "long": true,
+		"double": true, "boolean": true,
+	}
+)
+
+// validateRuntimeMappings checks the runtime field definitions of a raw
+// query: supported types, and scripts limited to allow-listed identifiers
+func validateRuntimeMappings(mappings interface{}) error {
+	mappingsM, ok := mappings.(map[string]interface{})
+	if !ok {
+		return errors.New("runtime mappings must be an object")
+	}
+	if len(mappingsM) > maxRuntimeFields {
+		return errors.Errorf("at most %d runtime fields are allowed", maxRuntimeFields)
+	}
+
+	for name, field := range mappingsM {
+		fieldM, ok := field.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("malformed runtime field %s", name)
+		}
+		for k := range fieldM {
+			if k != "type" && k != "script" {
+				return errors.Errorf("runtime field %s: key not allowed: %s", name, k)
+			}
+		}
+
+		typ, _ := fieldM["type"].(string)
+		if !runtimeFieldTypes[typ] {
+			return errors.Errorf("runtime field %s: type not allowed: %q", name, typ)
+		}
+
+		var source string
+		switch script := fieldM["script"].(type) {
+		case string:
+			source = script
+		case map[string]interface{}:
+			if len(script) != 1 {
+				return errors.Errorf("runtime field %s: only the script source is allowed", name)
+			}
+			source, _ = script["source"].(string)
+		}
+		if source == "" {
+			return errors.Errorf("runtime field %s: script source required", name)
+		}
+		if err := validateScript(source); err != nil {
+			return errors.Wrapf(err, "runtime field %s", name)
+		}
+	}
+
+	return nil
+}
+
+// validateScript restricts a painless script to allow-listed identifiers,
+// the named parameters (params.name), and the locals it declares before
+// use; a member access (x.name) is limited to the allow-listed identifiers
+func validateScript(source string) error {
+	if len(source) > maxScriptLength {
+		return errors.Errorf("script must not exceed %d characters", maxScriptLength)
+	}
+
+	locals := map[string]bool{}
+	// the last two tokens, whitespace skipped
+	var prev, prevPrev string
+	for rest := source; rest != ""; {
+		token := scriptToken.FindString(rest)
+		if token == "" {
+			return errors.Errorf("script syntax not allowed near %q", rest)
+		}
+		rest = rest[len(token):]
+		if strings.TrimSpace(token) == "" {
+			continue
+		}
+
+		if scriptIdentifier.MatchString(token) && !scriptIdentifiers[token] {
+			switch {
+			case prev == "." && prevPrev == "params":
+			case prev != "." && scriptLocalTypes[prev]:
+				locals[token] = true
+			case prev != "." && locals[token]:
+			default:
+				return errors.Errorf("script identifier not allowed: %s", token)
+			}
+		}
+		prevPrev, prev = prev, token
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateScript(t *testing.T) {
+	testCases := map[string]struct {
+		source string
+		err    bool
+	}{
+		"ok": {
+			source: "emit(doc['inventory_mem_total_kB_num'].value / 1024)",
+		},
+		"ok, params": {
+			source: "doc['inventory_mem_total_kB_num'].value > params.min_mem",
+		},
+		"ok, locals": {
+			source: "def m = doc['inventory_mem_total_kB_num'].value; " +
+				"String t = params.type; if (m > 0) { emit(t + m) }",
+		},
+		"error, local used before declared": {
+			source: "emit(m); def m = 1",
+			err:    true,
+		},
+		"error, member of params member": {
+			source: "params.type.getClass()",
+			err:    true,
+		},
+		"error, member named as a local": {
+			source: "def getClass = 1; emit(doc['a'].getClass())",
+			err:    true,
+		},
+		"error, class": {
+			source: "emit(System.getenv('HOME'))",
+			err:    true,
+		},
+		"error, loop": {
+			source: "while (true) { emit(1) }",
+			err:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateScript(tc.source)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxSampleSize = 1000
+
+func (sp SearchParams) validateSample() error {
+	if sp.Sample == 0 {
+		if sp.SampleSeed != nil {
+			return errors.New("seed requires a sample size")
+		}
+		return nil
+	}
+	if sp.Sample < 0 || sp.Sample > maxSampleSize {
+		return errors.Errorf("size must be between 1 and %d", maxSampleSize)
+	}
+	if len(sp.Sort) > 0 || sp.Cursor != "" || sp.Collapse != nil || len(sp.Facets) > 0 {
+		return errors.New("can't be combined with sort, cursor, collapse or facets")
+	}
+	return nil
+}
+
+// sample selects a random sample of the matching devices: the
+// relevance score is replaced by a random one, the devices sorted by
+// it; without a seed, every search returns a different sample
+type sample struct {
+	size int
+	seed int64
+}
+
+func NewSample(parms SearchParams) *sample {
+	seed := time.Now().UnixNano()
+	if parms.SampleSeed != nil {
+		seed = *parms.SampleSeed
+	}
+	return &sample{
+		size: parms.Sample,
+		seed: seed,
+	}
+}
+
+func (s *sample) AddTo(q Query) Query {
+	return q.WithRandomScore(s.seed).
+		WithSort(M{"_score": M{"order": "desc"}}).
+		WithPage(1, s.size)
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildQuerySample(t *testing.T) {
+	seed := int64(42)
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+		},
+		Attributes: []SelectAttribute{{Scope: "inventory", Attribute: "artifact_name"}},
+		Sample:     100,
+		SampleSeed: &seed,
+		// the configured default sort doesn't apply
+		DefaultSort: []SortCriteria{{Scope: "inventory", Attribute: "device_type", Order: "asc"}},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Query struct {
+			FunctionScore struct {
+				Query       M `json:"query"`
+				RandomScore struct {
+					Seed  int64  `json:"seed"`
+					Field string `json:"field"`
+				} `json:"random_score"`
+				BoostMode string `json:"boost_mode"`
+			} `json:"function_score"`
+		} `json:"query"`
+		Sort []M `json:"sort"`
+		From int `json:"from"`
+		Size int `json:"size"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Contains(t, res.Query.FunctionScore.Query, "bool")
+	assert.Equal(t, seed, res.Query.FunctionScore.RandomScore.Seed)
+	assert.Equal(t, "_seq_no", res.Query.FunctionScore.RandomScore.Field)
+	assert.Equal(t, "replace", res.Query.FunctionScore.BoostMode)
+	assert.Len(t, res.Sort, 1)
+	assert.Contains(t, res.Sort[0], "_score")
+	assert.Equal(t, 0, res.From)
+	assert.Equal(t, 100, res.Size)
+	assert.Contains(t, string(b), "inventory_artifact_name")
+
+	params.Cursor, _ = EncodeCursor([]interface{}{"foo"})
+	assert.Error(t, params.Validate())
+
+	params.Cursor = ""
+	params.Sample = maxSampleSize + 1
+	assert.Error(t, params.Validate())
+
+	params.Sample = 0
+	assert.Error(t, params.Validate())
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const maxScriptFilters = 5
+
+// ScriptFilter is a painless boolean expression over the device
+// fields, for comparisons the filter predicates can't express;
+// restricted to allow-listed identifiers, internal API only
+type ScriptFilter struct {
+	Source string                 `json:"source"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+func (f ScriptFilter) Validate() error {
+	err := validation.ValidateStruct(&f,
+		validation.Field(&f.Source, validation.Required))
+	if err != nil {
+		return err
+	}
+	return validateScript(f.Source)
+}
+
+func validateScriptFilters(filters []ScriptFilter) error {
+	if len(filters) > maxScriptFilters {
+		return errors.Errorf("at most %d script filters are allowed", maxScriptFilters)
+	}
+	for _, f := range filters {
+		if err := f.Validate(); err != nil {
+			return errors.Wrap(err, "invalid script filter")
+		}
+	}
+	return nil
+}
+
+//
+type scriptFilter struct {
+	source string
+	params map[string]interface{}
+}
+
+func NewScriptFilter(f ScriptFilter) *scriptFilter {
+	return &scriptFilter{
+		source: f.Source,
+		params: f.Params,
+	}
+}
+
+func (f *scriptFilter) AddTo(q Query) Query {
+	script := M{
+		"lang":   "painless",
+		"source": f.source,
+	}
+	if len(f.params) > 0 {
+		script["params"] = f.params
+	}
+
+	return q.Must(M{
+		"script": M{
+			"script": script,
+		},
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+// Settings are the deployment-wide attribute settings, and the
+// capabilities of the cluster, the devices are indexed and the
+// queries are built with; the zero value applies none of the
+// settings, and assumes all the features are supported
+type Settings struct {
+	Analyzers    Analyzers
+	Normalizers  Normalizers
+	Redactions   Redactions
+	Nested       NestedAttributes
+	Capabilities *Capabilities
+}
+
+func (s Settings) capabilities() *Capabilities {
+	if s.Capabilities == nil {
+		return allCapabilities
+	}
+	return s.Capabilities
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSort is the sort of the searches without an explicit one,
+// configured globally and overridden per tenant
+type DefaultSort struct {
+	Global  []SortCriteria
+	Tenants map[string][]SortCriteria
+}
+
+// ParseDefaultSort parses the global sort definitions in the form
+// "scope/attribute[:asc|desc]", e.g. "system/updated_ts:desc", and the
+// per-tenant ones, prefixed with "tenant_id:"; a tenant with several
+// definitions is sorted by them in order
+func ParseDefaultSort(global, tenants []string) (*DefaultSort, error) {
+	ret := &DefaultSort{
+		Tenants: map[string][]SortCriteria{},
+	}
+	for _, def := range global {
+		sc, err := parseSortCriteria(def)
+		if err != nil {
+			return nil, err
+		}
+		ret.Global = append(ret.Global, sc)
+	}
+	for _, def := range tenants {
+		parts := strings.SplitN(def, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("malformed tenant sort definition %q: "+
+				"expected \"tenant_id:scope/attribute[:order]\"", def)
+		}
+		sc, err := parseSortCriteria(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		ret.Tenants[parts[0]] = append(ret.Tenants[parts[0]], sc)
+	}
+	return ret, nil
+}
+
+func parseSortCriteria(def string) (SortCriteria, error) {
+	sc := SortCriteria{Order: "asc"}
+	attr := def
+	if i := strings.LastIndex(def, ":"); i >= 0 {
+		attr, sc.Order = def[:i], def[i+1:]
+	}
+	parts := strings.SplitN(attr, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" ||
+		(sc.Order != "asc" && sc.Order != "desc") {
+		return sc, errors.Errorf("malformed sort definition %q: "+
+			"expected \"scope/attribute[:asc|desc]\"", def)
+	}
+	sc.Scope, sc.Attribute = parts[0], parts[1]
+	return sc, nil
+}
+
+// For returns the default sort of tenant 'tid'
+func (d *DefaultSort) For(tid string) []SortCriteria {
+	sort, ok := d.Tenants[tid]
+	if !ok {
+		sort = d.Global
+	}
+	// a copy, the search params' criteria are rewritten, see ResolveAliases
+	return append([]SortCriteria(nil), sort...)
+}
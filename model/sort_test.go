@@ -0,0 +1,96 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDefaultSort(t *testing.T) {
+	sort, err := ParseDefaultSort(
+		[]string{"system/updated_ts:desc"},
+		[]string{"foo:inventory/device_type", "foo:system/group:desc"},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []SortCriteria{
+		{Scope: "system", Attribute: "updated_ts", Order: "desc"},
+	}, sort.For("bar"))
+	assert.Equal(t, []SortCriteria{
+		{Scope: "inventory", Attribute: "device_type", Order: "asc"},
+		{Scope: "system", Attribute: "group", Order: "desc"},
+	}, sort.For("foo"))
+
+	for _, def := range []string{"updated_ts", "system/updated_ts:up", "/updated_ts"} {
+		_, err = ParseDefaultSort([]string{def}, nil)
+		assert.Error(t, err, def)
+	}
+	_, err = ParseDefaultSort(nil, []string{":system/updated_ts"})
+	assert.Error(t, err)
+}
+
+func TestBuildQueryDefaultSort(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		DefaultSort: []SortCriteria{
+			{Scope: "system", Attribute: "updated_ts", Order: "desc"},
+		},
+	}
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Sort []M `json:"sort"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Sort, 3)
+	assert.Equal(t, "desc", res.Sort[0]["system_updated_ts_str"].(map[string]interface{})["order"])
+
+	// an explicit sort takes precedence
+	params.Sort = []SortCriteria{{Attribute: SortScore, Order: "desc"}}
+	q, err = BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err = json.Marshal(q)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Len(t, res.Sort, 2)
+	assert.Contains(t, res.Sort[0], SortScore)
+}
+
+func TestSortOrder(t *testing.T) {
+	q := NewSort(SortCriteria{Scope: "inventory", Attribute: "mac", Order: "desc"}).
+		AddTo(NewQuery())
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+
+	var res struct {
+		Sort []M `json:"sort"`
+	}
+	assert.NoError(t, json.Unmarshal(b, &res))
+	assert.Equal(t, []M{
+		{"inventory_mac_str": map[string]interface{}{
+			"order": "desc", "unmapped_type": "keyword",
+		}},
+		{"inventory_mac_num": map[string]interface{}{
+			"order": "desc", "unmapped_type": "double",
+		}},
+	}, res.Sort)
+}
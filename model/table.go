@@ -0,0 +1,140 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const maxTableColumns = 50
+
+// TableParams is a search returning the devices as rows of
+// the caller-specified columns, rather than attribute lists
+type TableParams struct {
+	SearchParams
+	Columns []TableColumn `json:"columns"`
+}
+
+// TableColumn selects the attribute of a column; the column
+// name defaults to the attribute name
+type TableColumn struct {
+	Name      string `json:"name,omitempty"`
+	Scope     string `json:"scope"`
+	Attribute string `json:"attribute"`
+}
+
+// TableHeader describes a column of the result rows, with the
+// type of its cells: "str", "num", or empty if no row has a value
+type TableHeader struct {
+	TableColumn
+	Type string `json:"type,omitempty"`
+}
+
+// TableRow is a device as the cells of the requested columns,
+// in the columns order; single values are unwrapped from their
+// arrays, and missing attributes are null cells
+type TableRow struct {
+	ID    DeviceID      `json:"id"`
+	Cells []interface{} `json:"cells"`
+}
+
+func (p TableParams) Validate() error {
+	if len(p.Columns) == 0 {
+		return errors.New("at least one column must be provided")
+	}
+	if len(p.Columns) > maxTableColumns {
+		return errors.Errorf("at most %d columns are allowed", maxTableColumns)
+	}
+	for _, col := range p.Columns {
+		err := validation.ValidateStruct(&col,
+			validation.Field(&col.Scope, validation.Required),
+			validation.Field(&col.Attribute, validation.Required))
+		if err != nil {
+			return err
+		}
+	}
+	return p.SearchParams.Validate()
+}
+
+// Selection sets the search attributes to the columns ones,
+// so that only those are fetched; the search resolves the
+// aliases of the selected attributes, see ResolveAliases
+func (p *TableParams) Selection() {
+	p.Attributes = make([]SelectAttribute, len(p.Columns))
+	for i, col := range p.Columns {
+		p.Attributes[i] = SelectAttribute{Scope: col.Scope, Attribute: col.Attribute}
+	}
+}
+
+// BuildTable projects the devices into rows of the columns, matching
+// the attributes of the devices against the selection, so that the
+// columns of aliased attributes get the values of the attributes they
+// stand for, see Selection
+func (p *TableParams) BuildTable(devs []InvDevice) ([]TableHeader, []TableRow) {
+	columns := p.Columns
+	headers := make([]TableHeader, len(columns))
+	for i, col := range columns {
+		headers[i].TableColumn = col
+		if headers[i].Name == "" {
+			headers[i].Name = col.Attribute
+		}
+	}
+
+	rows := make([]TableRow, len(devs))
+	for r, dev := range devs {
+		rows[r] = TableRow{
+			ID:    dev.ID,
+			Cells: make([]interface{}, len(columns)),
+		}
+		for _, attr := range dev.Attributes {
+			for i, sel := range p.Attributes {
+				if attr.Scope != sel.Scope || attr.Name != sel.Attribute {
+					continue
+				}
+				rows[r].Cells[i] = cellValue(attr.Value)
+				headers[i].Type = cellType(headers[i].Type, attr.Value)
+			}
+		}
+	}
+
+	return headers, rows
+}
+
+func cellValue(val interface{}) interface{} {
+	if vals, ok := val.([]interface{}); ok && len(vals) == 1 {
+		return vals[0]
+	}
+	return val
+}
+
+// cellType merges the type of a cell value into the column type,
+// any string value making the column a string one
+func cellType(typ string, val interface{}) string {
+	if typ == typeStr {
+		return typ
+	}
+	switch val := val.(type) {
+	case string:
+		return typeStr
+	case float64:
+		return typeNum
+	case []interface{}:
+		for _, v := range val {
+			typ = cellType(typ, v)
+		}
+	}
+	return typ
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTable(t *testing.T) {
+	columns := []TableColumn{
+		{Scope: scopeIdentity, Attribute: "mac", Name: "MAC"},
+		{Scope: scopeInventory, Attribute: "mem_total"},
+		{Scope: scopeInventory, Attribute: "network_interfaces"},
+		{Scope: scopeInventory, Attribute: "missing"},
+	}
+	devs := []InvDevice{
+		{
+			ID: "1",
+			Attributes: DeviceAttributes{
+				{Scope: scopeIdentity, Name: "mac", Value: []interface{}{"00:11"}},
+				{Scope: scopeInventory, Name: "mem_total", Value: []interface{}{float64(512)}},
+				{Scope: scopeInventory, Name: "network_interfaces",
+					Value: []interface{}{"eth0", "wlan0"}},
+				{Scope: scopeInventory, Name: "mac", Value: []interface{}{"ignored"}},
+			},
+		},
+		{
+			ID: "2",
+			Attributes: DeviceAttributes{
+				{Scope: scopeInventory, Name: "mem_total", Value: []interface{}{float64(1024)}},
+			},
+		},
+	}
+
+	params := TableParams{Columns: columns}
+	params.Selection()
+	headers, rows := params.BuildTable(devs)
+	assert.Equal(t, []TableHeader{
+		{TableColumn: columns[0], Type: typeStr},
+		{TableColumn: TableColumn{
+			Name: "mem_total", Scope: scopeInventory, Attribute: "mem_total"},
+			Type: typeNum},
+		{TableColumn: TableColumn{
+			Name: "network_interfaces", Scope: scopeInventory, Attribute: "network_interfaces"},
+			Type: typeStr},
+		{TableColumn: TableColumn{
+			Name: "missing", Scope: scopeInventory, Attribute: "missing"}},
+	}, headers)
+	assert.Equal(t, []TableRow{
+		{ID: "1", Cells: []interface{}{
+			"00:11", float64(512), []interface{}{"eth0", "wlan0"}, nil}},
+		{ID: "2", Cells: []interface{}{nil, float64(1024), nil, nil}},
+	}, rows)
+}
+
+func TestBuildTableAliases(t *testing.T) {
+	params := TableParams{
+		Columns: []TableColumn{
+			{Scope: scopeInventory, Attribute: "host"},
+			{Scope: scopeInventory, Attribute: "device_type", Name: "Type"},
+		},
+	}
+	params.Selection()
+	// as resolved by the search
+	params.ResolveAliases(NewAttributeAliases([]AttributeMetadata{
+		{Scope: scopeInventory, Name: "hostname", Aliases: []string{"host"}},
+	}))
+	assert.Equal(t, "hostname", params.Attributes[0].Attribute)
+
+	headers, rows := params.BuildTable([]InvDevice{{
+		ID: "1",
+		Attributes: DeviceAttributes{
+			{Scope: scopeInventory, Name: "hostname", Value: []interface{}{"rpi"}},
+			{Scope: scopeInventory, Name: "device_type", Value: []interface{}{"qemux86-64"}},
+		},
+	}})
+	assert.Equal(t, "host", headers[0].Name)
+	assert.Equal(t, "host", headers[0].Attribute)
+	assert.Equal(t, typeStr, headers[0].Type)
+	assert.Equal(t, []TableRow{
+		{ID: "1", Cells: []interface{}{"rpi", "qemux86-64"}},
+	}, rows)
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pkg/errors"
+)
+
+const maxDeviceTags = 20
+
+var ErrTooManyTagNames = errors.New("too many distinct tag names")
+
+var tagNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// DeviceTag is a user tag of a device, e.g. "needs-replacement",
+// with an optional value; indexed as a string attribute of the
+// "tags" scope, named after the tag
+type DeviceTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type DeviceTags []DeviceTag
+
+func (t DeviceTags) Validate() error {
+	if len(t) == 0 {
+		return errors.New("at least one tag must be provided")
+	}
+	if len(t) > maxDeviceTags {
+		return errors.Errorf("at most %d tags are allowed", maxDeviceTags)
+	}
+	names := map[string]bool{}
+	for _, tag := range t {
+		err := validation.ValidateStruct(&tag,
+			validation.Field(&tag.Name, validation.Required,
+				validation.Match(tagNameRegexp)))
+		if err != nil {
+			return err
+		}
+		if names[tag.Name] {
+			return errors.Errorf("duplicate tag: %s", tag.Name)
+		}
+		names[tag.Name] = true
+	}
+	return nil
+}
+
+// Doc returns the partial device document setting the tags
+func (t DeviceTags) Doc() M {
+	doc := make(M, len(t))
+	for _, tag := range t {
+		doc[ToAttr(scopeTags, tag.Name, TypeStr)] = []string{tag.Value}
+	}
+	return doc
+}
+
+// TagFields returns the devices index fields of the tags
+func TagFields(names []string) []string {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = ToAttr(scopeTags, name, TypeStr)
+	}
+	return fields
+}
+
+// CheckTagNames checks the tags don't raise the number of the distinct
+// tag names, mapped to the index properties 'props', over 'max'
+func CheckTagNames(props map[string]interface{}, tags DeviceTags, max int) error {
+	if max <= 0 {
+		return nil
+	}
+	names := 0
+	for field := range props {
+		if scope, name, _ := MaybeParseAttr(field); scope == scopeTags && name != "" {
+			names++
+		}
+	}
+	for _, field := range TagFields(tags.Names()) {
+		if _, ok := props[field]; !ok {
+			names++
+		}
+	}
+	if names > max {
+		return errors.Wrapf(ErrTooManyTagNames, "at most %d are allowed", max)
+	}
+	return nil
+}
+
+// Names returns the names of the tags
+func (t DeviceTags) Names() []string {
+	names := make([]string, len(t))
+	for i, tag := range t {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// Merge returns the tags with the 'tags' added, replacing the values of
+// the existing ones, and the tags named in 'removed' removed
+func (t DeviceTags) Merge(tags DeviceTags, removed []string) DeviceTags {
+	drop := map[string]bool{}
+	for _, name := range removed {
+		drop[name] = true
+	}
+	for _, tag := range tags {
+		drop[tag.Name] = true
+	}
+
+	ret := make(DeviceTags, 0, len(t)+len(tags))
+	for _, tag := range t {
+		if !drop[tag.Name] {
+			ret = append(ret, tag)
+		}
+	}
+	return append(ret, tags...)
+}
+
+// Tags returns the device tags
+func (d *Device) Tags() DeviceTags {
+	tags := make(DeviceTags, 0, len(d.TagsAttributes))
+	for _, a := range d.TagsAttributes {
+		tags = append(tags, DeviceTag{Name: a.Name, Value: a.GetString()})
+	}
+	return tags
+}
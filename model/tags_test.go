@@ -0,0 +1,102 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceTagsValidate(t *testing.T) {
+	testCases := map[string]struct {
+		tags DeviceTags
+		err  bool
+	}{
+		"ok": {
+			tags: DeviceTags{{Name: "needs-replacement"}, {Name: "site", Value: "oslo"}},
+		},
+		"error, empty": {
+			tags: DeviceTags{},
+			err:  true,
+		},
+		"error, name": {
+			tags: DeviceTags{{Name: "needs replacement"}},
+			err:  true,
+		},
+		"error, duplicate": {
+			tags: DeviceTags{{Name: "site", Value: "oslo"}, {Name: "site"}},
+			err:  true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.tags.Validate()
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDeviceTagsDoc(t *testing.T) {
+	tags := DeviceTags{{Name: "needs-replacement"}, {Name: "site", Value: "oslo"}}
+
+	b, err := json.Marshal(tags.Doc())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"tags_needs-replacement_str": [""],
+		"tags_site_str": ["oslo"]
+	}`, string(b))
+
+	dev, err := NewDeviceFromEsSource(map[string]interface{}{
+		"id":            "foo",
+		"tenantID":      "bar",
+		"tags_site_str": []interface{}{"oslo"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DeviceTags{{Name: "site", Value: "oslo"}}, dev.Tags())
+}
+
+func TestCheckTagNames(t *testing.T) {
+	props := map[string]interface{}{
+		"id":                    nil,
+		"inventory_site_str":    nil,
+		"tags_site_str":         nil,
+		"tags_needs-repair_str": nil,
+	}
+
+	assert.NoError(t, CheckTagNames(props, DeviceTags{{Name: "site"}, {Name: "rack"}}, 3))
+	assert.NoError(t, CheckTagNames(props, DeviceTags{{Name: "rack"}, {Name: "row"}}, 0))
+
+	err := CheckTagNames(props, DeviceTags{{Name: "rack"}, {Name: "row"}}, 3)
+	assert.Equal(t, ErrTooManyTagNames, errors.Cause(err))
+}
+
+func TestDeviceTagsMerge(t *testing.T) {
+	tags := DeviceTags{{Name: "site", Value: "oslo"}, {Name: "rack", Value: "1"}}
+
+	assert.Equal(t,
+		DeviceTags{{Name: "rack", Value: "1"}, {Name: "site", Value: "bergen"}},
+		tags.Merge(DeviceTags{{Name: "site", Value: "bergen"}}, nil))
+	assert.Equal(t,
+		DeviceTags{{Name: "site", Value: "oslo"}},
+		tags.Merge(nil, []string{"rack"}))
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+// the admin task types
+const (
+	// TaskReindex reindexes all the devices of the tenant
+	TaskReindex = "reindex"
+	// TaskPurgeDevices deletes the tenant's devices not updated
+	// in the last 'RetentionDays'
+	TaskPurgeDevices = "purge_devices"
+	// TaskOptimize force merges the tenant's devices index
+	TaskOptimize = "optimize"
+)
+
+// the task statuses
+const (
+	TaskStatusPending   = "pending"
+	TaskStatusRunning   = "running"
+	TaskStatusSucceeded = "succeeded"
+	TaskStatusFailed    = "failed"
+	TaskStatusCanceled  = "canceled"
+)
+
+var validTaskTypes = []interface{}{
+	TaskReindex,
+	TaskPurgeDevices,
+	TaskOptimize,
+}
+
+// TaskRequest describes a long-running admin operation
+type TaskRequest struct {
+	Type     string `json:"type"`
+	TenantID string `json:"tenant_id"`
+	// Service is the reindex trigger, inventory by default
+	Service       string `json:"service,omitempty"`
+	RetentionDays int    `json:"retention_days,omitempty"`
+}
+
+func (r TaskRequest) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Type, validation.Required, validation.In(validTaskTypes...)),
+		validation.Field(&r.TenantID, validation.Required),
+		validation.Field(&r.Service, validation.When(r.Type != TaskReindex,
+			validation.Empty)),
+		validation.Field(&r.RetentionDays, validation.When(r.Type == TaskPurgeDevices,
+			validation.Required, validation.Min(1)).Else(validation.Empty)))
+}
+
+// TaskProgress counts the items, e.g. the devices, processed by a task
+type TaskProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Task tracks the status and the progress of an admin operation
+type Task struct {
+	ID string `json:"id"`
+	TaskRequest
+	Status   string       `json:"status"`
+	Progress TaskProgress `json:"progress"`
+	Error    string       `json:"error,omitempty"`
+	// CancelRequested stops the task at the next progress update,
+	// whichever the service instance running it
+	CancelRequested bool       `json:"cancel_requested"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// NewTask returns a pending task for the request
+func NewTask(req TaskRequest) *Task {
+	now := time.Now().UTC()
+	return &Task{
+		ID:          uuid.New().String(),
+		TaskRequest: req,
+		Status:      TaskStatusPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Finished tells if the task completed, in any status
+func (t *Task) Finished() bool {
+	switch t.Status {
+	case TaskStatusSucceeded, TaskStatusFailed, TaskStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// Complete sets the final status of the task
+func (t *Task) Complete(status string, err error) {
+	now := time.Now().UTC()
+	t.Status = status
+	if err != nil {
+		t.Error = err.Error()
+	}
+	t.UpdatedAt = now
+	t.CompletedAt = &now
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRequestValidate(t *testing.T) {
+	testCases := map[string]struct {
+		req TaskRequest
+		err bool
+	}{
+		"ok, reindex": {
+			req: TaskRequest{Type: TaskReindex, TenantID: "foo", Service: "deviceauth"},
+		},
+		"ok, purge": {
+			req: TaskRequest{Type: TaskPurgeDevices, TenantID: "foo", RetentionDays: 30},
+		},
+		"ok, optimize": {
+			req: TaskRequest{Type: TaskOptimize, TenantID: "foo"},
+		},
+		"error, unknown type": {
+			req: TaskRequest{Type: "export", TenantID: "foo"},
+			err: true,
+		},
+		"error, no tenant": {
+			req: TaskRequest{Type: TaskOptimize},
+			err: true,
+		},
+		"error, purge without retention": {
+			req: TaskRequest{Type: TaskPurgeDevices, TenantID: "foo"},
+			err: true,
+		},
+		"error, retention of another type": {
+			req: TaskRequest{Type: TaskReindex, TenantID: "foo", RetentionDays: 30},
+			err: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.req.Validate()
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTaskComplete(t *testing.T) {
+	task := NewTask(TaskRequest{Type: TaskOptimize, TenantID: "foo"})
+	assert.Equal(t, TaskStatusPending, task.Status)
+	assert.False(t, task.Finished())
+
+	task.Complete(TaskStatusFailed, errors.New("boom"))
+	assert.True(t, task.Finished())
+	assert.Equal(t, "boom", task.Error)
+	assert.NotNil(t, task.CompletedAt)
+}
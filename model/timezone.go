@@ -0,0 +1,105 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"github.com/pkg/errors"
+)
+
+// date filter operators taking a time zone
+var dateOps = map[string]bool{
+	"$gt":       true,
+	"$gte":      true,
+	"$lt":       true,
+	"$lte":      true,
+	"$on_day":   true,
+	"$in_week":  true,
+	"$in_month": true,
+}
+
+func validateTimezone(tz string) error {
+	if tz != "" && !timezoneRegexp.MatchString(tz) {
+		return errors.Errorf("invalid time zone: %q", tz)
+	}
+	return nil
+}
+
+// ApplyTimezone sets the search time zone, if any, on the date
+// filters and the date histogram facets without an explicit one
+func (sp *SearchParams) ApplyTimezone() {
+	if sp.Timezone == "" {
+		return
+	}
+	filtersTimezone(sp.Filters, sp.Timezone)
+	filtersTimezone(sp.ExcludeFilters, sp.Timezone)
+	filtersTimezone(sp.AnyFilters, sp.Timezone)
+	if sp.Query != nil {
+		for _, f := range sp.Query.Filters() {
+			f.Value = dateTimezone(*f, sp.Timezone)
+		}
+	}
+	termsTimezone(sp.Facets, sp.Timezone)
+}
+
+// ApplyTimezone sets the aggregation time zone, if any, on the date
+// filters and the date histograms without an explicit one
+func (p *AggregateParams) ApplyTimezone() {
+	if p.Timezone == "" {
+		return
+	}
+	filtersTimezone(p.Filters, p.Timezone)
+	termsTimezone(p.Aggregations, p.Timezone)
+}
+
+func filtersTimezone(filters []FilterPredicate, tz string) {
+	for i := range filters {
+		filters[i].Value = dateTimezone(filters[i], tz)
+	}
+}
+
+// dateTimezone returns the value of a date filter in the time zone,
+// unless it has one already; the other filters' values are unchanged
+func dateTimezone(f FilterPredicate, tz string) interface{} {
+	if !dateOps[f.Type] || !isDateAttr(f) {
+		return f.Value
+	}
+	switch val := f.Value.(type) {
+	case string:
+		return map[string]interface{}{
+			"date":     val,
+			"timezone": tz,
+		}
+	case map[string]interface{}:
+		if _, ok := val["timezone"]; ok {
+			return val
+		}
+		ret := make(map[string]interface{}, len(val)+1)
+		for k, v := range val {
+			ret[k] = v
+		}
+		ret["timezone"] = tz
+		return ret
+	}
+	return f.Value
+}
+
+func termsTimezone(terms []AggregationTerm, tz string) {
+	for i := range terms {
+		if terms[i].Type == AggregationDateHistogram && terms[i].Timezone == "" {
+			terms[i].Timezone = tz
+		}
+		termsTimezone(terms[i].Aggregations, tz)
+	}
+}
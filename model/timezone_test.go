@@ -0,0 +1,97 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTimezone(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "system", Attribute: "created_ts", Type: "$on_day", Value: "now"},
+			{Scope: "system", Attribute: "updated_ts", Type: "$gte", Value: "2021-06-01"},
+			{Scope: "system", Attribute: "check_in_time", Type: "$lt", Value: map[string]interface{}{
+				"date": "now-1d", "timezone": "+02:00",
+			}},
+			{Scope: "inventory", Attribute: "device_type", Type: "$eq", Value: "qemux86-64"},
+		},
+		Facets: []AggregationTerm{{
+			Name: "created", Type: AggregationDateHistogram,
+			Scope: "system", Attribute: "created_ts", Interval: "day",
+		}},
+		Timezone: "Europe/Oslo",
+	}
+	assert.NoError(t, params.Validate())
+	params.ApplyTimezone()
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	s := string(b)
+
+	assert.Contains(t, s, `"createdAt":{"gte":"now/d","lt":"now+1d/d","time_zone":"Europe/Oslo"}`)
+	assert.Contains(t, s, `"updatedAt":{"gte":"2021-06-01","time_zone":"Europe/Oslo"}`)
+	// the explicit time zone is kept
+	assert.Contains(t, s, `"system_check_in_time_str":{"lt":"now-1d","time_zone":"+02:00"}`)
+	assert.Contains(t, s, `"inventory_device_type_str":"qemux86-64"`)
+	assert.Equal(t, "Europe/Oslo", params.Facets[0].Timezone)
+
+	params.Timezone = "Europe Oslo"
+	assert.Error(t, params.Validate())
+
+	params.Timezone = ""
+	params.Filters = []FilterPredicate{
+		{Scope: "system", Attribute: "created_ts", Type: "$gt", Value: "yesterday"},
+	}
+	_, err = BuildQuery(params, Settings{})
+	assert.EqualError(t, err, ErrDateRequired.Error())
+}
+
+func TestBuildQueryDateMathRounding(t *testing.T) {
+	params := SearchParams{
+		Page:    1,
+		PerPage: 20,
+		Filters: []FilterPredicate{
+			{Scope: "system", Attribute: "updated_ts", Type: "$gte", Value: "now/d"},
+			{Scope: "system", Attribute: "created_ts", Type: "$lt", Value: "now-1d/d"},
+			{Scope: "system", Attribute: "check_in_time", Type: "$on_day", Value: "now-1d/d"},
+		},
+	}
+	assert.NoError(t, params.Validate())
+
+	q, err := BuildQuery(params, Settings{})
+	assert.NoError(t, err)
+	b, err := json.Marshal(q)
+	assert.NoError(t, err)
+	s := string(b)
+
+	assert.Contains(t, s, `"updatedAt":{"gte":"now/d"}`)
+	assert.Contains(t, s, `"createdAt":{"lt":"now-1d/d"}`)
+	// the rounding is redundant with the day range
+	assert.Contains(t, s, `"system_check_in_time_str":{"gte":"now-1d/d","lt":"now-1d+1d/d"}`)
+
+	params.Filters = []FilterPredicate{
+		{Scope: "system", Attribute: "updated_ts", Type: "$gte", Value: "now/d/d"},
+	}
+	_, err = BuildQuery(params, Settings{})
+	assert.EqualError(t, err, ErrDateRequired.Error())
+}
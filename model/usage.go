@@ -0,0 +1,38 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"time"
+)
+
+// TenantUsage is the storage used by a tenant's devices index
+type TenantUsage struct {
+	TenantID       string `json:"tenant_id"`
+	DeviceCount    int64  `json:"device_count"`
+	StorageBytes   int64  `json:"storage_bytes"`
+	AttributeCount int    `json:"attribute_count"`
+}
+
+// TenantStats summarizes a tenant's devices index: the device count,
+// the last device update, and the number of the index mapping fields
+// used, out of the index total fields limit
+type TenantStats struct {
+	TenantID           string     `json:"tenant_id"`
+	DeviceCount        int64      `json:"device_count"`
+	LastUpdated        *time.Time `json:"last_updated,omitempty"`
+	MappingFields      int        `json:"mapping_fields"`
+	MappingFieldsLimit int        `json:"mapping_fields_limit"`
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// UpdateDevicesAge refreshes the age attributes of the devices
+// of all the tenants, as of 'now'
+func (s *store) UpdateDevicesAge(ctx context.Context, now time.Time) (int, error) {
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{s.naming.devicesPattern()},
+		Body:      esutil.NewJSONReader(model.BuildDeviceAgeUpdate(now)),
+		Conflicts: "proceed",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update the devices age")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, errors.New(fmt.Sprintf("failed to update the devices age, code %d",
+			res.StatusCode))
+	}
+
+	var updateRes struct {
+		Updated int `json:"updated"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&updateRes); err != nil {
+		return 0, err
+	}
+
+	return updateRes.Updated, nil
+}
+
+// UpdateDevicesDeploymentsFailed drops the deployment failures before
+// 'since' from the failed deployments counts of the devices of all the
+// tenants
+func (s *store) UpdateDevicesDeploymentsFailed(ctx context.Context, since time.Time) (int, error) {
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{s.naming.devicesPattern()},
+		Body:      esutil.NewJSONReader(model.BuildDeploymentsFailedUpdate(since)),
+		Conflicts: "proceed",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update the failed deployments")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, errors.New(fmt.Sprintf("failed to update the failed deployments, code %d",
+			res.StatusCode))
+	}
+
+	var updateRes struct {
+		Updated int `json:"updated"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&updateRes); err != nil {
+		return 0, err
+	}
+
+	return updateRes.Updated, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrAnomalyReportNotFound = errors.New("anomaly report not found")
+)
+
+// PutAnomalyReport replaces the latest anomaly report of the tenant
+func (s *store) PutAnomalyReport(ctx context.Context, report *model.AnomalyReport) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.anomalies(),
+		DocumentID: report.TenantID,
+		Body:       esutil.NewJSONReader(report),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store anomaly report")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store anomaly report, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetAnomalyReport returns the latest anomaly report of tenant 'tid'
+func (s *store) GetAnomalyReport(ctx context.Context, tid string) (*model.AnomalyReport, error) {
+	req := esapi.GetRequest{
+		Index:      s.naming.anomalies(),
+		DocumentID: tid,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get anomaly report")
+	}
+	defer res.Body.Close()
+
+	// the index doesn't exist until the first analysis
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrAnomalyReportNotFound
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get anomaly report, code %d", res.StatusCode))
+	}
+
+	var doc struct {
+		Source model.AnomalyReport `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse anomaly report")
+	}
+
+	return &doc.Source, nil
+}
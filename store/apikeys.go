@@ -0,0 +1,187 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+const maxAPIKeys = 100
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// CreateAPIKey stores the API key, using its hash as the document ID
+func (s *store) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.apiKeys(),
+		DocumentID: key.Hash,
+		Body:       esutil.NewJSONReader(key),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store API key")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store API key, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves the API key by the hash, nil if not found
+func (s *store) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	req := esapi.GetRequest{
+		Index:      s.naming.apiKeys(),
+		DocumentID: hash,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get API key")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		if res.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, errors.New(fmt.Sprintf("failed to get API key, code %d", res.StatusCode))
+	}
+
+	var getRes struct {
+		Source model.APIKey `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&getRes); err != nil {
+		return nil, err
+	}
+
+	return &getRes.Source, nil
+}
+
+// GetAPIKeys lists tenant 'tid' API keys, without the hashes
+func (s *store) GetAPIKeys(ctx context.Context, tid string) ([]model.APIKey, error) {
+	query := model.M{
+		"query": model.M{
+			"term": model.M{"tenant_id": tid},
+		},
+		"sort":    model.S{model.M{"created_at": "asc"}},
+		"size":    maxAPIKeys,
+		"_source": model.M{"excludes": model.S{"hash"}},
+	}
+
+	resp, err := s.client.Search(
+		s.client.Search.WithContext(ctx),
+		s.client.Search.WithIndex(s.naming.apiKeys()),
+		s.client.Search.WithBody(esutil.NewJSONReader(query)),
+		s.client.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get API keys")
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get API keys, code %d", resp.StatusCode))
+	}
+
+	var searchRes struct {
+		Hits struct {
+			Hits []struct {
+				Source model.APIKey `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchRes); err != nil {
+		return nil, err
+	}
+
+	keys := make([]model.APIKey, 0, len(searchRes.Hits.Hits))
+	for _, hit := range searchRes.Hits.Hits {
+		keys = append(keys, hit.Source)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey revokes tenant 'tid' API key 'id'
+func (s *store) DeleteAPIKey(ctx context.Context, tid, id string) error {
+	query := model.M{
+		"query": model.M{
+			"bool": model.M{
+				"filter": model.S{
+					model.M{"term": model.M{"tenant_id": tid}},
+					model.M{"term": model.M{"id": id}},
+				},
+			},
+		},
+	}
+
+	refresh := true
+	req := esapi.DeleteByQueryRequest{
+		Index:   []string{s.naming.apiKeys()},
+		Body:    esutil.NewJSONReader(query),
+		Refresh: &refresh,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete API key")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		if res.StatusCode == http.StatusNotFound {
+			return ErrAPIKeyNotFound
+		}
+		return errors.New(fmt.Sprintf("failed to delete API key, code %d", res.StatusCode))
+	}
+
+	var deleteRes struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&deleteRes); err != nil {
+		return err
+	}
+	if deleteRes.Deleted == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// apiKeysMappingUpdate adds the fields introduced after the
+// API keys index was created to its strict mapping
+func apiKeysMappingUpdate() model.M {
+	return model.M{
+		"properties": model.M{
+			"expires_at": model.M{"type": "date"},
+		},
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+const maxAttributesMetadata = 10000
+
+var (
+	ErrAttributeMetadataNotFound = errors.New("attribute metadata not found")
+)
+
+// PutAttributeMetadata creates or replaces the metadata of a tenant's attribute
+func (s *store) PutAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.attributes(),
+		DocumentID: meta.ID(),
+		Body:       esutil.NewJSONReader(meta),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store attribute metadata")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store attribute metadata, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetAttributesMetadata lists tenant 'tid' attribute metadata
+func (s *store) GetAttributesMetadata(ctx context.Context, tid string) ([]model.AttributeMetadata, error) {
+	query := model.M{
+		"query": model.M{
+			"term": model.M{"tenant_id": tid},
+		},
+		"size": maxAttributesMetadata,
+	}
+
+	resp, err := s.client.Search(
+		s.client.Search.WithContext(ctx),
+		s.client.Search.WithIndex(s.naming.attributes()),
+		s.client.Search.WithBody(esutil.NewJSONReader(query)),
+		s.client.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get attribute metadata")
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get attribute metadata, code %d", resp.StatusCode))
+	}
+
+	var searchRes struct {
+		Hits struct {
+			Hits []struct {
+				Source model.AttributeMetadata `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchRes); err != nil {
+		return nil, err
+	}
+
+	metadata := make([]model.AttributeMetadata, 0, len(searchRes.Hits.Hits))
+	for _, hit := range searchRes.Hits.Hits {
+		metadata = append(metadata, hit.Source)
+	}
+
+	return metadata, nil
+}
+
+// DeleteAttributeMetadata removes the metadata of a tenant's attribute
+func (s *store) DeleteAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error {
+	req := esapi.DeleteRequest{
+		Index:      s.naming.attributes(),
+		DocumentID: meta.ID(),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete attribute metadata")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrAttributeMetadataNotFound
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to delete attribute metadata, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// attributesMappingUpdate adds the fields introduced after the
+// attribute metadata index was created to its strict mapping
+func attributesMappingUpdate() model.M {
+	return model.M{
+		"properties": model.M{
+			"aliases": model.M{"type": "keyword"},
+		},
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrTemplateMissing  = errors.New("index template missing, run the migration")
+	ErrTemplateOutdated = errors.New("index template out of date, run the migration")
+	ErrRoutingMismatch  = errors.New("devices indices not routed by tenant as configured, " +
+		"the routing only applies at the index creation: revert the setting, " +
+		"or recreate the indices and reindex the devices")
+)
+
+// Check verifies the compatibility of the installed index template
+// with the current configuration, and the presence of the datastore
+// index templates; the ES connectivity and version are verified by
+// Init, which must be called first. The service
+// keeps its own data (API keys, attribute metadata, tasks, ...)
+// in the reporting indices of the same cluster, there's no other
+// datastore, nor message broker, to check
+func (s *store) Check(ctx context.Context) error {
+	if err := s.checkTemplate(ctx); err != nil {
+		return err
+	}
+	return s.checkDatastoreTemplates(ctx)
+}
+
+// checkDatastoreTemplates verifies the index templates of the
+// reporting datastore indices are installed
+func (s *store) checkDatastoreTemplates(ctx context.Context) error {
+	schema, err := s.schema()
+	if err != nil {
+		return err
+	}
+	for _, t := range schema.Templates {
+		if t.Name == s.naming.name(indexDevices) {
+			continue
+		}
+		req := esapi.IndicesExistsIndexTemplateRequest{
+			Name: t.Name,
+		}
+		res, err := req.Do(ctx, s.client)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check the %s index template", t.Name)
+		}
+		res.Body.Close()
+
+		if res.StatusCode == http.StatusNotFound {
+			return errors.Wrap(ErrTemplateMissing, t.Name)
+		} else if res.IsError() {
+			return errors.New(fmt.Sprintf("failed to check the %s index template, code %d",
+				t.Name, res.StatusCode))
+		}
+	}
+	return nil
+}
+
+// Capabilities returns the features supported by the cluster
+func (s *store) Capabilities() *model.Capabilities {
+	return s.capabilities
+}
+
+// detectCapabilities derives the supported features from the cluster
+// distribution and version, failing on the unsupported versions
+func (s *store) detectCapabilities(ctx context.Context) error {
+	res, err := s.client.Info(s.client.Info.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to Elasticsearch")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to get Elasticsearch info, code %d", res.StatusCode))
+	}
+
+	var info struct {
+		Version struct {
+			Distribution string `json:"distribution"`
+			Number       string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return errors.Wrap(err, "can't parse Elasticsearch info")
+	}
+
+	capabilities, err := model.NewCapabilities(info.Version.Distribution, info.Version.Number)
+	if err != nil {
+		return err
+	}
+	s.capabilities = capabilities
+
+	return nil
+}
+
+// checkRouting verifies the existing devices indices are routed by
+// tenant as configured; the routing is fixed at the index creation,
+// the documents indexed with another routing being unreachable
+func (s *store) checkRouting(ctx context.Context) error {
+	tenants, err := s.GetTenants(ctx)
+	if err != nil || len(tenants) == 0 {
+		return err
+	}
+
+	req := esapi.IndicesGetMappingRequest{
+		Index:      []string{s.naming.devicesPattern()},
+		FilterPath: []string{"*.mappings._routing.required"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the devices indices routing")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to get the devices indices routing, code %d",
+			res.StatusCode))
+	}
+
+	// the indices without the required routing are left out
+	var routing map[string]struct {
+		Mappings struct {
+			Routing struct {
+				Required bool `json:"required"`
+			} `json:"_routing"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&routing); err != nil {
+		return errors.Wrap(err, "can't parse the devices indices routing")
+	}
+
+	mismatched := 0
+	for _, tid := range tenants {
+		if routing[s.naming.devices(tid)].Mappings.Routing.Required != s.routingByTenant {
+			mismatched++
+		}
+	}
+	if mismatched > 0 {
+		return errors.Wrapf(ErrRoutingMismatch, "%d of %d indices", mismatched, len(tenants))
+	}
+
+	return nil
+}
+
+func (s *store) checkTemplate(ctx context.Context) error {
+	expected, err := s.devicesTemplate()
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndicesGetIndexTemplateRequest{
+		Name: []string{s.naming.name(indexDevices)},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the index template")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrTemplateMissing
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to get the index template, code %d", res.StatusCode))
+	}
+
+	var templates struct {
+		IndexTemplates []struct {
+			IndexTemplate struct {
+				Template struct {
+					Settings struct {
+						Index struct {
+							Shards   string `json:"number_of_shards"`
+							Replicas string `json:"number_of_replicas"`
+						} `json:"index"`
+					} `json:"settings"`
+					Mappings struct {
+						Routing         map[string]interface{}   `json:"_routing"`
+						DynamicTemplate []map[string]interface{} `json:"dynamic_templates"`
+					} `json:"mappings"`
+				} `json:"template"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&templates); err != nil {
+		return errors.Wrap(err, "can't parse the index template")
+	}
+	if len(templates.IndexTemplates) == 0 {
+		return ErrTemplateMissing
+	}
+	actual := templates.IndexTemplates[0].IndexTemplate.Template
+
+	if actual.Settings.Index.Shards != strconv.Itoa(s.shards) {
+		return errors.Wrapf(ErrTemplateOutdated, "number of shards %s, configured %d",
+			actual.Settings.Index.Shards, s.shards)
+	}
+	if actual.Settings.Index.Replicas != strconv.Itoa(s.replicas) {
+		return errors.Wrapf(ErrTemplateOutdated, "number of replicas %s, configured %d",
+			actual.Settings.Index.Replicas, s.replicas)
+	}
+	if (actual.Mappings.Routing["required"] == true) != s.routingByTenant {
+		return errors.Wrap(ErrTemplateOutdated, "routing by tenant mismatch")
+	}
+
+	mappings := expected["template"].(map[string]interface{})["mappings"].(map[string]interface{})
+	dynamic := mappings["dynamic_templates"].([]interface{})
+	if len(dynamic) != len(actual.Mappings.DynamicTemplate) {
+		return errors.Wrap(ErrTemplateOutdated, "dynamic templates mismatch")
+	}
+	for i, d := range dynamic {
+		for name := range d.(map[string]interface{}) {
+			if _, ok := actual.Mappings.DynamicTemplate[i][name]; !ok {
+				return errors.Wrapf(ErrTemplateOutdated, "dynamic template %s mismatch", name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRouting(t *testing.T) {
+	// the devices indices of 'foo', routed by tenant, and 'bar', not
+	indices := `[{"index": "devices-foo"}, {"index": "devices-bar"}]`
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			switch {
+			case strings.HasPrefix(r.URL.Path, "/_cat/indices"):
+				_, _ = w.Write([]byte(indices))
+			case strings.HasSuffix(r.URL.Path, "/_mapping"):
+				_, _ = w.Write([]byte(`{
+					"devices-foo": {"mappings": {"_routing": {"required": true}}}
+				}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer srv.Close()
+
+	client, err := es.NewClient(es.Config{
+		Addresses:            []string{srv.URL},
+		DisableRetry:         true,
+		UseResponseCheckOnly: true,
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	s := &store{client: client}
+	err = s.checkRouting(ctx)
+	assert.True(t, errors.Is(err, ErrRoutingMismatch))
+	assert.Contains(t, err.Error(), "1 of 2 indices")
+
+	s.routingByTenant = true
+	err = s.checkRouting(ctx)
+	assert.True(t, errors.Is(err, ErrRoutingMismatch))
+
+	indices = `[{"index": "devices-foo"}]`
+	assert.NoError(t, s.checkRouting(ctx))
+
+	// no indices yet, any routing goes
+	indices = `[]`
+	s.routingByTenant = false
+	assert.NoError(t, s.checkRouting(ctx))
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+const maxMappingConflicts = 1000
+
+var (
+	// the ES errors of a field value not matching the mapped type
+	conflictReasonRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`^failed to parse field \[([^\]]+)\]`),
+		regexp.MustCompile(`^mapper \[([^\]]+)\] cannot be changed from type`),
+	}
+	conflictErrorTypes = map[string]bool{
+		"mapper_parsing_exception":   true,
+		"illegal_argument_exception": true,
+	}
+)
+
+// MappingConflictError is returned when ES rejects a device document
+// because of the value of 'Field' not matching its mapped type
+type MappingConflictError struct {
+	Field  string
+	Reason string
+}
+
+func (e *MappingConflictError) Error() string {
+	return fmt.Sprintf("mapping conflict on field %s: %s", e.Field, e.Reason)
+}
+
+// writeError translates the ES error response of a device write,
+// detecting the mapping conflicts
+func writeError(op string, res *esapi.Response) error {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && err != io.EOF {
+		return errors.New(fmt.Sprintf("failed to %s, code %d", op, res.StatusCode))
+	}
+
+	if conflictErrorTypes[body.Error.Type] {
+		for _, re := range conflictReasonRegexps {
+			if m := re.FindStringSubmatch(body.Error.Reason); m != nil {
+				return &MappingConflictError{
+					Field:  m[1],
+					Reason: body.Error.Reason,
+				}
+			}
+		}
+	}
+
+	return errors.New(fmt.Sprintf("failed to %s, code %d: %s",
+		op, res.StatusCode, body.Error.Reason))
+}
+
+// PutMappingConflict records a quarantined attribute; the conflicts
+// are kept per tenant and field, the latest one replacing the previous
+func (s *store) PutMappingConflict(ctx context.Context, conflict *model.MappingConflict) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.mappingConflicts(),
+		DocumentID: conflict.TenantID + "/" + conflict.Field,
+		Body:       esutil.NewJSONReader(conflict),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store mapping conflict")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store mapping conflict, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetMappingConflicts returns the mapping conflicts of tenant 'tid',
+// the latest first
+func (s *store) GetMappingConflicts(ctx context.Context, tid string) ([]model.MappingConflict, error) {
+	query := model.M{
+		"query": model.M{
+			"term": model.M{"tenant_id": tid},
+		},
+		"sort": model.S{model.M{"timestamp": "desc"}},
+		"size": maxMappingConflicts,
+	}
+
+	resp, err := s.client.Search(
+		s.client.Search.WithContext(ctx),
+		s.client.Search.WithIndex(s.naming.mappingConflicts()),
+		s.client.Search.WithBody(esutil.NewJSONReader(query)),
+		s.client.Search.WithIgnoreUnavailable(true),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mapping conflicts")
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get mapping conflicts, code %d", resp.StatusCode))
+	}
+
+	var searchRes struct {
+		Hits struct {
+			Hits []struct {
+				Source model.MappingConflict `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchRes); err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]model.MappingConflict, 0, len(searchRes.Hits.Hits))
+	for _, hit := range searchRes.Hits.Hits {
+		conflicts = append(conflicts, hit.Source)
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/client/transport"
+)
+
+// how long the searches go to the replica cluster
+// before the primary one is tried again
+const failoverCooldown = 30 * time.Second
+
+// WithReplicaAddresses sets the addresses of a replica ES cluster,
+// holding the same indices (e.g. by cross-cluster replication); the
+// searches fail over to it while the primary cluster is unavailable,
+// the writes always go to the primary cluster
+func WithReplicaAddresses(addresses []string) StoreOption {
+	return func(s *store) {
+		s.replicaAddresses = addresses
+	}
+}
+
+func (s *store) initReplica() error {
+	if len(s.replicaAddresses) == 0 {
+		return nil
+	}
+	client, err := es.NewClient(es.Config{
+		Addresses: s.replicaAddresses,
+		Transport: transport.Wrap(http.DefaultTransport, s.faults),
+	})
+	if err != nil {
+		return errors.Wrap(err, "invalid Elasticsearch replica configuration")
+	}
+	s.replica = client
+	return nil
+}
+
+// read runs a read request on the primary cluster, or on the replica
+// cluster while the primary one is unavailable; 'do' is invoked once
+// per attempted cluster, so it must build a fresh request body
+func (s *store) read(ctx context.Context,
+	do func(client *es.Client) (*esapi.Response, error)) (*esapi.Response, error) {
+	if s.replica == nil {
+		return do(s.client)
+	}
+
+	if time.Now().UnixNano() >= atomic.LoadInt64(&s.primaryDownUntil) {
+		res, err := do(s.client)
+		if !unavailable(ctx, res, err) {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		log.FromContext(ctx).Warnf("primary Elasticsearch cluster unavailable, "+
+			"failing over the searches to the replica cluster for %s", failoverCooldown)
+		atomic.StoreInt64(&s.primaryDownUntil,
+			time.Now().Add(failoverCooldown).UnixNano())
+	}
+
+	return do(s.replica)
+}
+
+// unavailable tells if the request failed because of the cluster,
+// rather than because of the request itself
+func unavailable(ctx context.Context, res *esapi.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// clusterServer responds with the status stored in 'status',
+// counting the requests
+type clusterServer struct {
+	*httptest.Server
+	status   int32
+	requests int32
+}
+
+func newClusterServer(t *testing.T, status int) *clusterServer {
+	s := &clusterServer{status: int32(status)}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&s.requests, 1)
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+			_, _ = w.Write([]byte(`{}`))
+		}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *clusterServer) client(t *testing.T) *es.Client {
+	client, err := es.NewClient(es.Config{
+		Addresses:            []string{s.URL},
+		DisableRetry:         true,
+		UseResponseCheckOnly: true,
+	})
+	assert.NoError(t, err)
+	return client
+}
+
+func TestReadFailover(t *testing.T) {
+	primary := newClusterServer(t, http.StatusServiceUnavailable)
+	replica := newClusterServer(t, http.StatusOK)
+	s := &store{
+		client:  primary.client(t),
+		replica: replica.client(t),
+	}
+	ctx := context.Background()
+	read := func() {
+		res, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+			return esapi.CountRequest{}.Do(ctx, client)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	// the primary cluster fails, the search fails over
+	read()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primary.requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&replica.requests))
+
+	// the primary cluster isn't tried during the cooldown
+	read()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primary.requests))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&replica.requests))
+
+	// the primary cluster is tried again after the cooldown
+	atomic.StoreInt32(&primary.status, http.StatusOK)
+	atomic.StoreInt64(&s.primaryDownUntil, time.Now().Add(-time.Second).UnixNano())
+	read()
+	read()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&primary.requests))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&replica.requests))
+}
+
+func TestReadNoFailover(t *testing.T) {
+	// the request errors don't fail over
+	primary := newClusterServer(t, http.StatusBadRequest)
+	replica := newClusterServer(t, http.StatusOK)
+	s := &store{
+		client:  primary.client(t),
+		replica: replica.client(t),
+	}
+	ctx := context.Background()
+
+	res, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+		return esapi.CountRequest{}.Do(ctx, client)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	res.Body.Close()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&replica.requests))
+	assert.Zero(t, atomic.LoadInt64(&s.primaryDownUntil))
+}
+
+func TestReadFailoverUnreachable(t *testing.T) {
+	primary := newClusterServer(t, http.StatusOK)
+	replica := newClusterServer(t, http.StatusOK)
+	s := &store{
+		client:  primary.client(t),
+		replica: replica.client(t),
+	}
+	primary.Close()
+	ctx := context.Background()
+
+	res, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+		return esapi.CountRequest{}.Do(ctx, client)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&replica.requests))
+	assert.Greater(t, atomic.LoadInt64(&s.primaryDownUntil), time.Now().UnixNano())
+}
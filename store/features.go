@@ -0,0 +1,81 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// GetTenantFeatures returns the feature flags set for tenant 'tid',
+// nil if none
+func (s *store) GetTenantFeatures(ctx context.Context, tid string) (*model.TenantFeatures, error) {
+	req := esapi.GetRequest{
+		Index:      s.naming.tenantFeatures(),
+		DocumentID: tid,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tenant features")
+	}
+	defer res.Body.Close()
+
+	// the index doesn't exist until the first flag is set
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get tenant features, code %d", res.StatusCode))
+	}
+
+	var doc struct {
+		Source model.TenantFeatures `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse tenant features")
+	}
+
+	return &doc.Source, nil
+}
+
+// PutTenantFeatures stores the feature flags of a tenant,
+// replacing the previous ones
+func (s *store) PutTenantFeatures(ctx context.Context, features *model.TenantFeatures) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.tenantFeatures(),
+		DocumentID: features.TenantID,
+		Body:       esutil.NewJSONReader(features),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store tenant features")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store tenant features, code %d", res.StatusCode))
+	}
+
+	return nil
+}
@@ -14,6 +14,15 @@
 
 package store
 
+const (
+	defaultShards   = 1
+	defaultReplicas = 1
+)
+
+// the dynamic template mapping the *_version* attributes to the
+// 'version' field type, dropped if the cluster doesn't support it
+const dynamicTemplateVersions = "versions"
+
 const (
 	indexDevices         = "devices"
 	indexDevicesTemplate = `{
@@ -22,7 +31,21 @@ const (
 	"template": {
 		"settings": {
 			"number_of_shards": 1,
-			"number_of_replicas": 1
+			"number_of_replicas": 1,
+			"analysis": {
+				"normalizer": {
+					"keyword_lowercase": {
+						"type": "custom",
+						"filter": ["lowercase"]
+					}
+				},
+				"analyzer": {
+					"path_hierarchy": {
+						"type": "custom",
+						"tokenizer": "path_hierarchy"
+					}
+				}
+			}
 		},
 		"mappings": {
 			"dynamic": "true",
@@ -53,6 +76,50 @@ const (
 				}
 			},
 			"dynamic_templates": [
+				{
+					"check_in_time": {
+						"match": "system_check_in_time_str",
+						"mapping": {
+							"type": "date"
+						}
+					}
+				},
+				{
+					"nested_objects": {
+						"match": "*_obj",
+						"match_mapping_type": "object",
+						"mapping": {
+							"type": "nested"
+						}
+					}
+				},
+				{
+					"nested_strings": {
+						"path_match": "*_obj.*",
+						"match_mapping_type": "string",
+						"mapping": {
+							"type": "keyword"
+						}
+					}
+				},
+				{
+					"nested_longs": {
+						"path_match": "*_obj.*",
+						"match_mapping_type": "long",
+						"mapping": {
+							"type": "double"
+						}
+					}
+				},
+				{
+					"nested_doubles": {
+						"path_match": "*_obj.*",
+						"match_mapping_type": "double",
+						"mapping": {
+							"type": "double"
+						}
+					}
+				},
 				{
 					"versions": {
 						"match": "*_version*",
@@ -113,3 +180,302 @@ const (
 		}
 	}}`
 )
+
+const (
+	indexAPIKeys         = "reporting-apikeys"
+	indexAPIKeysTemplate = `{
+	"index_patterns": ["reporting-apikeys"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"id": {
+					"type": "keyword"
+				},
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"name": {
+					"type": "keyword"
+				},
+				"hash": {
+					"type": "keyword"
+				},
+				"created_at": {
+					"type": "date"
+				},
+				"expires_at": {
+					"type": "date"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexAttributes         = "reporting-attributes"
+	indexAttributesTemplate = `{
+	"index_patterns": ["reporting-attributes"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"scope": {
+					"type": "keyword"
+				},
+				"name": {
+					"type": "keyword"
+				},
+				"display_name": {
+					"type": "keyword"
+				},
+				"tag": {
+					"type": "keyword"
+				},
+				"description": {
+					"type": "text"
+				},
+				"aliases": {
+					"type": "keyword"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexAnomalies         = "reporting-anomalies"
+	indexAnomaliesTemplate = `{
+	"index_patterns": ["reporting-anomalies"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"created_at": {
+					"type": "date"
+				},
+				"total_count": {
+					"type": "long"
+				},
+				"findings": {
+					"type": "object",
+					"enabled": false
+				},
+				"distributions": {
+					"type": "object",
+					"enabled": false
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexMappingConflicts         = "reporting-mapping-conflicts"
+	indexMappingConflictsTemplate = `{
+	"index_patterns": ["reporting-mapping-conflicts"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"device_id": {
+					"type": "keyword"
+				},
+				"field": {
+					"type": "keyword"
+				},
+				"scope": {
+					"type": "keyword"
+				},
+				"attribute": {
+					"type": "keyword"
+				},
+				"reason": {
+					"type": "text"
+				},
+				"timestamp": {
+					"type": "date"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexDeviceIDsLookups         = "reporting-device-ids-lookups"
+	indexDeviceIDsLookupsTemplate = `{
+	"index_patterns": ["reporting-device-ids-lookups"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"ids": {
+					"type": "keyword",
+					"index": false,
+					"doc_values": false
+				},
+				"timestamp": {
+					"type": "date"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexTenantFeatures         = "reporting-tenant-features"
+	indexTenantFeaturesTemplate = `{
+	"index_patterns": ["reporting-tenant-features"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"flags": {
+					"type": "object",
+					"enabled": false
+				},
+				"updated_at": {
+					"type": "date"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexTasks         = "reporting-tasks"
+	indexTasksTemplate = `{
+	"index_patterns": ["reporting-tasks"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"id": {
+					"type": "keyword"
+				},
+				"type": {
+					"type": "keyword"
+				},
+				"tenant_id": {
+					"type": "keyword"
+				},
+				"service": {
+					"type": "keyword"
+				},
+				"retention_days": {
+					"type": "integer"
+				},
+				"status": {
+					"type": "keyword"
+				},
+				"progress": {
+					"properties": {
+						"done": {
+							"type": "long"
+						},
+						"total": {
+							"type": "long"
+						}
+					}
+				},
+				"error": {
+					"type": "text"
+				},
+				"cancel_requested": {
+					"type": "boolean"
+				},
+				"created_at": {
+					"type": "date"
+				},
+				"updated_at": {
+					"type": "date"
+				},
+				"completed_at": {
+					"type": "date"
+				}
+			}
+		}
+	}}`
+)
+
+const (
+	indexJobs         = "reporting-jobs"
+	indexJobsTemplate = `{
+	"index_patterns": ["reporting-jobs"],
+	"priority": 1,
+	"template": {
+		"settings": {
+			"number_of_shards": 1,
+			"number_of_replicas": 1
+		},
+		"mappings": {
+			"dynamic": "strict",
+			"properties": {
+				"slot": {
+					"type": "keyword"
+				},
+				"holder": {
+					"type": "keyword"
+				},
+				"updated_at": {
+					"type": "date",
+					"format": "epoch_millis"
+				},
+				"report": {
+					"type": "object",
+					"enabled": false
+				}
+			}
+		}
+	}}`
+)
@@ -0,0 +1,153 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// the claim script keeps the job document untouched if the slot was
+// already claimed, or if another holder claimed one within the 'ttl',
+// so that the job sticks to an instance as long as it runs it
+const claimJobRunScript = "" +
+	"if (ctx._source.slot == params.slot) { ctx.op = 'none'; return; } " +
+	"if (ctx._source.holder != null && ctx._source.holder != params.holder && " +
+	"params.now - ctx._source.updated_at < params.ttl) { ctx.op = 'none'; return; } " +
+	"ctx._source.slot = params.slot; " +
+	"ctx._source.holder = params.holder; " +
+	"ctx._source.updated_at = params.now;"
+
+// ClaimJobRun claims the run of the background 'job' for the time
+// 'slot' on behalf of the 'holder' instance, returning false if
+// another instance already claimed it, or holds the job, claiming its
+// runs, for less than 'ttl'; the job document is updated in place, so
+// that concurrent claims conflict and only one succeeds
+func (s *store) ClaimJobRun(ctx context.Context, job, slot, holder string,
+	ttl time.Duration) (bool, error) {
+	req := esapi.UpdateRequest{
+		Index:      s.naming.jobs(),
+		DocumentID: job,
+		Body: esutil.NewJSONReader(model.M{
+			"scripted_upsert": true,
+			"script": model.M{
+				"lang":   "painless",
+				"source": claimJobRunScript,
+				"params": model.M{
+					"slot":   slot,
+					"holder": holder,
+					"now":    time.Now().UnixNano() / int64(time.Millisecond),
+					"ttl":    ttl.Milliseconds(),
+				},
+			},
+			"upsert": model.M{},
+		}),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to claim the job run")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return false, nil
+	} else if res.IsError() {
+		return false, errors.New(fmt.Sprintf("failed to claim the job run, code %d",
+			res.StatusCode))
+	}
+
+	var updateRes struct {
+		Result string `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&updateRes); err != nil {
+		return false, err
+	}
+
+	return updateRes.Result != "noop", nil
+}
+
+// the job document holding the maintenance report
+const jobMaintenance = "maintenance"
+
+// PutMaintenanceReport stores the report of the last maintenance
+// run, in the maintenance job document
+func (s *store) PutMaintenanceReport(ctx context.Context, report *model.MaintenanceReport) error {
+	req := esapi.UpdateRequest{
+		Index:      s.naming.jobs(),
+		DocumentID: jobMaintenance,
+		Body: esutil.NewJSONReader(model.M{
+			"doc": model.M{
+				"report": report,
+			},
+			"doc_as_upsert": true,
+		}),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store the maintenance report")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store the maintenance report, code %d",
+			res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetMaintenanceReport returns the report of the last maintenance
+// run, or nil if the maintenance never ran
+func (s *store) GetMaintenanceReport(ctx context.Context) (*model.MaintenanceReport, error) {
+	req := esapi.GetRequest{
+		Index:      s.naming.jobs(),
+		DocumentID: jobMaintenance,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the maintenance report")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get the maintenance report, code %d",
+			res.StatusCode))
+	}
+
+	var getRes struct {
+		Source struct {
+			Report *model.MaintenanceReport `json:"report"`
+		} `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&getRes); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the maintenance report")
+	}
+
+	return getRes.Source.Report, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mendersoftware/go-lib-micro/log"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrSearchQueueFull = errors.New("too many concurrent searches, try again later")
+)
+
+// WithSearchConcurrency limits the number of the concurrent searches,
+// counts and multi searches to 'limit'; the ones over the limit wait for
+// a free slot in a queue of at most 'queue', for at most 'timeout', and
+// are rejected with ErrSearchQueueFull otherwise; a zero limit disables
+// the limiter
+func WithSearchConcurrency(limit, queue int, timeout time.Duration) StoreOption {
+	return func(s *store) {
+		if limit > 0 {
+			s.searchLimiter = newSearchLimiter(limit, queue, timeout)
+		}
+	}
+}
+
+// the number of the recent queue waits the queue time is reported over
+const queueWaitsWindow = 256
+
+// searchLimiter is a semaphore of the concurrent searches,
+// with a bounded wait queue
+type searchLimiter struct {
+	slots   chan struct{}
+	queue   chan struct{}
+	timeout time.Duration
+
+	// the searches rejected so far, and the waits of
+	// the recently queued ones, in a ring buffer
+	mu       sync.Mutex
+	rejected int
+	waits    []time.Duration
+	next     int
+}
+
+func newSearchLimiter(limit, queue int, timeout time.Duration) *searchLimiter {
+	return &searchLimiter{
+		slots:   make(chan struct{}, limit),
+		queue:   make(chan struct{}, queue),
+		timeout: timeout,
+		waits:   make([]time.Duration, 0, queueWaitsWindow),
+	}
+}
+
+// acquire takes a search slot, waiting in the queue if necessary;
+// the returned func releases the slot
+func (l *searchLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		l.reject()
+		return nil, ErrSearchQueueFull
+	}
+	defer func() { <-l.queue }()
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		wait := time.Since(start)
+		l.record(wait)
+		log.FromContext(ctx).Debugf("search queued for %s", wait)
+		return l.release, nil
+	case <-timer.C:
+		l.reject()
+		return nil, ErrSearchQueueFull
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *searchLimiter) release() {
+	<-l.slots
+}
+
+func (l *searchLimiter) reject() {
+	l.mu.Lock()
+	l.rejected++
+	l.mu.Unlock()
+}
+
+func (l *searchLimiter) record(wait time.Duration) {
+	l.mu.Lock()
+	if len(l.waits) < cap(l.waits) {
+		l.waits = append(l.waits, wait)
+	} else {
+		l.waits[l.next] = wait
+	}
+	l.next = (l.next + 1) % cap(l.waits)
+	l.mu.Unlock()
+}
+
+// stats reports the searches currently waiting in the queue, the ones
+// rejected so far, and the median, 95th percentile and maximum wait of
+// the recently queued ones
+func (l *searchLimiter) stats(health *model.ClusterHealth) {
+	if l == nil {
+		return
+	}
+	health.QueuedSearches = len(l.queue)
+
+	l.mu.Lock()
+	health.RejectedSearches = l.rejected
+	waits := make([]time.Duration, len(l.waits))
+	copy(waits, l.waits)
+	l.mu.Unlock()
+
+	if len(waits) == 0 {
+		return
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	health.SearchQueueTime = model.QueueTime{
+		P50: waits[len(waits)/2],
+		P95: waits[len(waits)*95/100],
+		Max: waits[len(waits)-1],
+	}
+}
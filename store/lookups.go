@@ -0,0 +1,126 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+const deviceIDsLookupPath = "ids"
+
+// PutDeviceIDsLookup stores the device IDs of a search in a document,
+// referenced by the search with a terms lookup instead of the inlined
+// IDs; the documents are addressed by the tenant and the IDs, so the
+// searches of the same IDs (e.g. the pages of a static group) share one,
+// and its timestamp is refreshed by each, so that a lookup in use isn't
+// purged, see PurgeDeviceIDsLookups
+func (s *store) PutDeviceIDsLookup(ctx context.Context, tid string, ids []string) (*model.TermsLookup, error) {
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(tid + "\n" + strings.Join(sorted, "\n")))
+	docID := hex.EncodeToString(h.Sum(nil))
+
+	now := time.Now().UTC()
+	retries := 3
+	req := esapi.UpdateRequest{
+		Index:      s.naming.deviceIDsLookups(),
+		DocumentID: docID,
+		Body: esutil.NewJSONReader(model.M{
+			"doc": model.M{
+				"timestamp": now,
+			},
+			"upsert": model.M{
+				"tenant_id":         tid,
+				deviceIDsLookupPath: sorted,
+				"timestamp":         now,
+			},
+		}),
+		RetryOnConflict: &retries,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to store device IDs lookup")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to store device IDs lookup, code %d", res.StatusCode))
+	}
+
+	return &model.TermsLookup{
+		Index: s.naming.deviceIDsLookups(),
+		ID:    docID,
+		Path:  deviceIDsLookupPath,
+	}, nil
+}
+
+// PurgeDeviceIDsLookups deletes the device IDs lookups not used
+// since 'before', returning the number of deleted documents; the
+// lookups refreshed during the purge are kept, as version conflicts
+func (s *store) PurgeDeviceIDsLookups(ctx context.Context, before time.Time) (int, error) {
+	query := model.M{
+		"query": model.M{
+			"range": model.M{
+				"timestamp": model.M{"lt": before},
+			},
+		},
+	}
+
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{s.naming.deviceIDsLookups()},
+		Body:      esutil.NewJSONReader(query),
+		Conflicts: "proceed",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge device IDs lookups")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		if res.StatusCode == http.StatusNotFound {
+			return 0, nil
+		}
+		return 0, errors.New(fmt.Sprintf("failed to purge device IDs lookups, code %d", res.StatusCode))
+	}
+
+	var deleteRes struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&deleteRes); err != nil {
+		return 0, err
+	}
+
+	return deleteRes.Deleted, nil
+}
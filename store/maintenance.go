@@ -0,0 +1,47 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/pkg/errors"
+)
+
+// ForceMergeDevices expunges the deleted documents of tenant 'tid'
+// devices index, reclaiming the space and speeding up the queries
+// of the frequently updated indices
+func (s *store) ForceMergeDevices(ctx context.Context, tid string) error {
+	expungeDeletes := true
+	req := esapi.IndicesForcemergeRequest{
+		Index:              []string{s.naming.devices(tid)},
+		OnlyExpungeDeletes: &expungeDeletes,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to force merge the devices index")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to force merge the devices index, tid %s, code %d",
+			tid, res.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"strings"
+)
+
+// indexNaming resolves the index and index template names, with an
+// optional prefix (e.g. the environment) and suffix (e.g. a version),
+// so that several deployments can safely share a cluster
+type indexNaming struct {
+	prefix string
+	suffix string
+}
+
+// name decorates a base index or template name
+func (n indexNaming) name(base string) string {
+	return n.prefix + base + n.suffix
+}
+
+// devices is the devices index of tenant 'tid'
+func (n indexNaming) devices(tid string) string {
+	return n.name(indexDevices) + "-" + tid
+}
+
+// devicesPattern matches the devices indices of all the tenants
+func (n indexNaming) devicesPattern() string {
+	return n.name(indexDevices) + "-*"
+}
+
+// tenant extracts the tenant ID from a devices index name
+func (n indexNaming) tenant(idx string) string {
+	return strings.TrimPrefix(idx, n.name(indexDevices)+"-")
+}
+
+func (n indexNaming) apiKeys() string {
+	return n.name(indexAPIKeys)
+}
+
+func (n indexNaming) attributes() string {
+	return n.name(indexAttributes)
+}
+
+func (n indexNaming) anomalies() string {
+	return n.name(indexAnomalies)
+}
+
+func (n indexNaming) mappingConflicts() string {
+	return n.name(indexMappingConflicts)
+}
+
+func (n indexNaming) deviceIDsLookups() string {
+	return n.name(indexDeviceIDsLookups)
+}
+
+func (n indexNaming) tenantFeatures() string {
+	return n.name(indexTenantFeatures)
+}
+
+func (n indexNaming) tasks() string {
+	return n.name(indexTasks)
+}
+
+func (n indexNaming) jobs() string {
+	return n.name(indexJobs)
+}
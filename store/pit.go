@@ -0,0 +1,163 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// OpenPointInTime opens a point in time of tenant 'tid' devices index,
+// kept alive for 'keepAlive' (e.g. "1m") between the searches, so that
+// iterating over the devices sees a consistent snapshot of the index;
+// the point in time lives on the primary cluster only, so neither it
+// nor its searches fail over to the replica
+func (s *store) OpenPointInTime(ctx context.Context, tid, keepAlive string) (string, error) {
+	req := esapi.OpenPointInTimeRequest{
+		Index:     []string{s.naming.devices(tid)},
+		KeepAlive: keepAlive,
+		Routing:   s.routing(tid),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open the point in time")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", errors.New(fmt.Sprintf("failed to open the point in time, tid %s, code %d",
+			tid, res.StatusCode))
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pit); err != nil {
+		return "", errors.Wrap(err, "can't parse the point in time")
+	}
+
+	return pit.ID, nil
+}
+
+// SearchPointInTime runs a query against the point in time set in its
+// "pit" clause; the response "pit_id" is the one to search next with
+func (s *store) SearchPointInTime(ctx context.Context, query interface{}) (model.M, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, err
+	}
+
+	release, err := s.searchLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// the index and the routing are the ones of the point in time
+	res, err := s.client.Search(
+		s.client.Search.WithContext(ctx),
+		s.client.Search.WithBody(&buf),
+		s.client.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(res.String())
+	}
+
+	var ret map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// ClosePointInTime releases the search contexts of the point in time
+// right away, instead of on its keep alive expiry; closing a point in
+// time already expired is not an error
+func (s *store) ClosePointInTime(ctx context.Context, id string) error {
+	req := esapi.ClosePointInTimeRequest{
+		Body: esutil.NewJSONReader(model.M{"id": id}),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to close the point in time")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return errors.New(fmt.Sprintf("failed to close the point in time, code %d",
+			res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetOpenSearchContexts returns the number of the search contexts open
+// across the cluster, incl. the ones of the points in time, which keep
+// the segments merged away from being released
+func (s *store) GetOpenSearchContexts(ctx context.Context) (int, error) {
+	req := esapi.NodesStatsRequest{
+		Metric:      []string{"indices"},
+		IndexMetric: []string{"search"},
+		FilterPath:  []string{"nodes.*.indices.search.open_contexts"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get the nodes stats")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, errors.New(fmt.Sprintf("failed to get the nodes stats, code %d",
+			res.StatusCode))
+	}
+
+	var stats struct {
+		Nodes map[string]struct {
+			Indices struct {
+				Search struct {
+					OpenContexts int `json:"open_contexts"`
+				} `json:"search"`
+			} `json:"indices"`
+		} `json:"nodes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		return 0, errors.Wrap(err, "can't parse the nodes stats")
+	}
+
+	total := 0
+	for _, n := range stats.Nodes {
+		total += n.Indices.Search.OpenContexts
+	}
+
+	return total, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// WithSearchPreference sets the ES search preference, e.g. "_local",
+// or a custom string routing the searches to the same shard copies
+func WithSearchPreference(preference string) StoreOption {
+	return func(s *store) {
+		s.preference = preference
+	}
+}
+
+// WithPreferredNodes prefers the shard copies on the nodes matching the
+// ES node filter, e.g. "zone:eu-west-1a" for the nodes of the local zone
+func WithPreferredNodes(filter string) StoreOption {
+	return func(s *store) {
+		s.preferredNodes = filter
+	}
+}
+
+// WithAdaptiveReplicaSelection toggles the cluster-wide adaptive replica
+// selection, routing the searches to the least loaded shard copies
+func WithAdaptiveReplicaSelection(enabled bool) StoreOption {
+	return func(s *store) {
+		s.adaptiveReplicaSelection = &enabled
+	}
+}
+
+// resolvePreference translates the preferred nodes filter to the
+// "_prefer_nodes" search preference; if no nodes match, the searches
+// fall back to the configured preference
+func (s *store) resolvePreference(ctx context.Context) error {
+	if s.preferredNodes == "" {
+		return nil
+	}
+
+	res, err := s.client.Nodes.Info(
+		s.client.Nodes.Info.WithContext(ctx),
+		s.client.Nodes.Info.WithNodeID(s.preferredNodes),
+		s.client.Nodes.Info.WithFilterPath("nodes.*.name"),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the nodes info")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to get the nodes info, code %d", res.StatusCode))
+	}
+
+	var info struct {
+		Nodes map[string]interface{} `json:"nodes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return errors.Wrap(err, "can't parse the nodes info")
+	}
+	if len(info.Nodes) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(info.Nodes))
+	for id := range info.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	s.preference = "_prefer_nodes:" + strings.Join(ids, ",")
+
+	return nil
+}
+
+// applyReplicaSelection updates the cluster adaptive replica
+// selection setting, if configured
+func (s *store) applyReplicaSelection(ctx context.Context) error {
+	if s.adaptiveReplicaSelection == nil {
+		return nil
+	}
+
+	body := model.M{
+		"persistent": model.M{
+			"cluster.routing.use_adaptive_replica_selection": *s.adaptiveReplicaSelection,
+		},
+	}
+
+	req := esapi.ClusterPutSettingsRequest{
+		Body: esutil.NewJSONReader(body),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to update the cluster settings")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to update the cluster settings, code %d", res.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// SchemaVersion is the version of the schema bundle format
+const SchemaVersion = 1
+
+var ErrSchemaVersion = errors.New("unsupported schema bundle version")
+
+// Schema bundles the index templates and the mapping updates of the
+// existing indices, applied by the migration; it's exported to apply
+// it to the clusters the service can't migrate itself, e.g. in the
+// air-gapped installs
+type Schema struct {
+	Version int `json:"version"`
+	// the cluster the templates are prepared for, e.g. "opensearch 2.11.0"
+	Target    string           `json:"target,omitempty"`
+	Templates []SchemaTemplate `json:"templates"`
+	Mappings  []SchemaMapping  `json:"mappings"`
+}
+
+// SchemaTemplate is a composable index template
+type SchemaTemplate struct {
+	Name string  `json:"name"`
+	Body model.M `json:"body"`
+}
+
+// SchemaMapping is a mapping update of an existing index;
+// the update is skipped if the index doesn't exist yet
+type SchemaMapping struct {
+	Index string  `json:"index"`
+	Body  model.M `json:"body"`
+}
+
+// ExportSchema prepares the schema of the store configured by the
+// options, for the cluster of the 'capabilities' (the supported
+// features of the connected cluster if nil), without connecting
+func ExportSchema(capabilities *model.Capabilities, opts ...StoreOption) (*Schema, error) {
+	s := &store{
+		shards:       defaultShards,
+		replicas:     defaultReplicas,
+		capabilities: capabilities,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s.schema()
+}
+
+func (s *store) schema() (*Schema, error) {
+	fixed := []struct {
+		name string
+		tmpl string
+	}{
+		{s.naming.apiKeys(), indexAPIKeysTemplate},
+		{s.naming.attributes(), indexAttributesTemplate},
+		{s.naming.anomalies(), indexAnomaliesTemplate},
+		{s.naming.mappingConflicts(), indexMappingConflictsTemplate},
+		{s.naming.deviceIDsLookups(), indexDeviceIDsLookupsTemplate},
+		{s.naming.tenantFeatures(), indexTenantFeaturesTemplate},
+		{s.naming.tasks(), indexTasksTemplate},
+		{s.naming.jobs(), indexJobsTemplate},
+	}
+
+	schema := &Schema{
+		Version: SchemaVersion,
+		Mappings: []SchemaMapping{
+			{Index: s.naming.attributes(), Body: attributesMappingUpdate()},
+			{Index: s.naming.apiKeys(), Body: apiKeysMappingUpdate()},
+		},
+	}
+	if s.capabilities != nil {
+		schema.Target = s.capabilities.Distribution + " " + s.capabilities.Version
+	}
+	devices, err := s.devicesTemplate()
+	if err != nil {
+		return nil, err
+	}
+	schema.Templates = append(schema.Templates, SchemaTemplate{
+		Name: s.naming.name(indexDevices),
+		Body: devices,
+	})
+	for _, t := range fixed {
+		body, err := fixedIndexTemplate(t.tmpl, t.name)
+		if err != nil {
+			return nil, err
+		}
+		schema.Templates = append(schema.Templates, SchemaTemplate{
+			Name: t.name,
+			Body: body,
+		})
+	}
+
+	return schema, nil
+}
+
+// ImportSchema applies an exported schema to the cluster
+func (s *store) ImportSchema(ctx context.Context, schema *Schema) error {
+	if schema.Version != SchemaVersion {
+		return errors.Wrapf(ErrSchemaVersion, "version %d", schema.Version)
+	}
+	return s.applySchema(ctx, schema)
+}
+
+func (s *store) applySchema(ctx context.Context, schema *Schema) error {
+	for _, t := range schema.Templates {
+		err := s.putIndexTemplate(ctx, t.Name, esutil.NewJSONReader(t.Body))
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range schema.Mappings {
+		if err := s.putMapping(ctx, m.Index, m.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *store) putMapping(ctx context.Context, index string, body model.M) error {
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{index},
+		Body:  esutil.NewJSONReader(body),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the %s mapping", index)
+	}
+	defer res.Body.Close()
+
+	// the index is created with the current template on first use
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to update the %s mapping, code %d",
+			index, res.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// SetDeviceStale marks the indexed device data stale since 'since',
+// or clears the marker if nil; the devices not indexed are skipped
+func (s *store) SetDeviceStale(ctx context.Context, tid, devID string, since *time.Time) error {
+	var value interface{}
+	if since != nil {
+		value = since.UTC().Format(time.RFC3339)
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      s.naming.devices(tid),
+		DocumentID: devID,
+		Body: esutil.NewJSONReader(model.M{
+			"doc": model.M{
+				model.ToAttr(model.AttrScopeSystem, model.AttrNameStaleSince,
+					model.TypeStr): value,
+			},
+		}),
+		Routing: s.routing(tid),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to mark the device stale")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to mark the device stale, code %d", res.StatusCode))
+	}
+
+	return nil
+}
@@ -19,8 +19,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	es "github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -31,6 +34,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mendersoftware/reporting/client/transport"
 	"github.com/mendersoftware/reporting/model"
 )
 
@@ -39,27 +43,105 @@ type Store interface {
 	BulkIndexDevices(ctx context.Context, devices []*model.Device) error
 
 	Search(ctx context.Context, query interface{}) (model.M, error)
+	MultiSearch(ctx context.Context, queries []interface{}) ([]model.M, error)
+	Count(ctx context.Context, query interface{}, terminateAfter int) (int, error)
 	GetDevice(ctx context.Context, tenant, devid string) (*model.Device, error)
 	UpdateDevice(ctx context.Context, tenantID, deviceID string, updateDev *model.Device) error
 	Migrate(ctx context.Context) error
+	ImportSchema(ctx context.Context, schema *Schema) error
 	GetDevIndex(ctx context.Context, tid string) (map[string]interface{}, error)
+	GetDevIndices(ctx context.Context) (map[string]map[string]interface{}, error)
+	DeleteDevicesUpdatedBefore(ctx context.Context, tid string, before time.Time) (int, error)
+	ApplySettings(ctx context.Context) error
+	Init(ctx context.Context) error
+	Check(ctx context.Context) error
+	Capabilities() *model.Capabilities
+	ClusterHealth(ctx context.Context) (*model.ClusterHealth, error)
+	GetTenants(ctx context.Context) ([]string, error)
+	GetStorageUsage(ctx context.Context, tid string) ([]model.TenantUsage, error)
+	GetLastUpdated(ctx context.Context) (map[string]time.Time, error)
+	ForceMergeDevices(ctx context.Context, tid string) error
+	OpenPointInTime(ctx context.Context, tid, keepAlive string) (string, error)
+	SearchPointInTime(ctx context.Context, query interface{}) (model.M, error)
+	ClosePointInTime(ctx context.Context, id string) error
+	GetOpenSearchContexts(ctx context.Context) (int, error)
+	UpdateDevicesAge(ctx context.Context, now time.Time) (int, error)
+	UpdateDevicesDeploymentsFailed(ctx context.Context, since time.Time) (int, error)
+	SetDeviceTags(ctx context.Context, tid, devID string, tags model.DeviceTags) error
+	RemoveDeviceTags(ctx context.Context, tid, devID string, names []string) error
+	SetDeviceStale(ctx context.Context, tid, devID string, since *time.Time) error
+
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	GetAPIKeys(ctx context.Context, tid string) ([]model.APIKey, error)
+	DeleteAPIKey(ctx context.Context, tid, id string) error
+
+	PutAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error
+	GetAttributesMetadata(ctx context.Context, tid string) ([]model.AttributeMetadata, error)
+	DeleteAttributeMetadata(ctx context.Context, meta *model.AttributeMetadata) error
+
+	PutAnomalyReport(ctx context.Context, report *model.AnomalyReport) error
+	GetAnomalyReport(ctx context.Context, tid string) (*model.AnomalyReport, error)
+
+	PutMappingConflict(ctx context.Context, conflict *model.MappingConflict) error
+	GetMappingConflicts(ctx context.Context, tid string) ([]model.MappingConflict, error)
+
+	PutDeviceIDsLookup(ctx context.Context, tid string, ids []string) (*model.TermsLookup, error)
+	PurgeDeviceIDsLookups(ctx context.Context, before time.Time) (int, error)
+
+	GetTenantFeatures(ctx context.Context, tid string) (*model.TenantFeatures, error)
+	PutTenantFeatures(ctx context.Context, features *model.TenantFeatures) error
+
+	PutTask(ctx context.Context, task *model.Task) error
+	UpdateTask(ctx context.Context, task *model.Task) (*model.Task, error)
+	RequestTaskCancel(ctx context.Context, id string, at time.Time) error
+	GetTask(ctx context.Context, id string) (*model.Task, error)
+
+	ClaimJobRun(ctx context.Context, job, slot, holder string, ttl time.Duration) (bool, error)
+	PutMaintenanceReport(ctx context.Context, report *model.MaintenanceReport) error
+	GetMaintenanceReport(ctx context.Context) (*model.MaintenanceReport, error)
 }
 
 type StoreOption func(*store)
 
 type store struct {
-	addresses []string
-	client    *es.Client
+	// unix nanoseconds until which the searches go to the replica;
+	// first, to be 64-bit aligned for the atomic operations on 32-bit
+	// platforms
+	primaryDownUntil int64
+
+	addresses       []string
+	analyzers       model.Analyzers
+	shards          int
+	replicas        int
+	routingByTenant bool
+	naming          indexNaming
+	client          *es.Client
+
+	preference               string
+	preferredNodes           string
+	adaptiveReplicaSelection *bool
+
+	replicaAddresses []string
+	replica          *es.Client
+
+	searchLimiter *searchLimiter
+	capabilities  *model.Capabilities
+	faults        transport.Faults
 }
 
 func NewStore(opts ...StoreOption) (Store, error) {
-	store := &store{}
+	store := &store{
+		shards:   defaultShards,
+		replicas: defaultReplicas,
+	}
 	for _, opt := range opts {
 		opt(store)
 	}
 
 	cfg := es.Config{
 		Addresses: store.addresses,
+		Transport: transport.Wrap(http.DefaultTransport, store.faults),
 	}
 	esClient, err := es.NewClient(cfg)
 	if err != nil {
@@ -72,14 +154,31 @@ func NewStore(opts ...StoreOption) (Store, error) {
 	}
 
 	store.client = esClient
+	if err := store.initReplica(); err != nil {
+		return nil, err
+	}
 	return store, nil
 }
 
+// Init detects the cluster capabilities, verifies the routing of the
+// existing devices indices, and resolves the preferred nodes; it must
+// be called once, before the store is used
+func (s *store) Init(ctx context.Context) error {
+	if err := s.detectCapabilities(ctx); err != nil {
+		return err
+	}
+	if err := s.checkRouting(ctx); err != nil {
+		return err
+	}
+	return s.resolvePreference(ctx)
+}
+
 func (s *store) IndexDevice(ctx context.Context, device *model.Device) error {
 	req := esapi.IndexRequest{
-		Index:      devIdx(device.GetTenantID()),
+		Index:      s.naming.devices(device.GetTenantID()),
 		DocumentID: device.GetID(),
 		Body:       esutil.NewJSONReader(device),
+		Routing:    s.routing(device.GetTenantID()),
 	}
 
 	res, err := req.Do(ctx, s.client)
@@ -88,6 +187,10 @@ func (s *store) IndexDevice(ctx context.Context, device *model.Device) error {
 	}
 	defer res.Body.Close()
 
+	if res.IsError() {
+		return writeError("index device", res)
+	}
+
 	return nil
 }
 
@@ -96,8 +199,9 @@ type bulkAction struct {
 }
 
 type bulkActionIndex struct {
-	ID    string `json:"_id"`
-	Index string `json:"_index"`
+	ID      string `json:"_id"`
+	Index   string `json:"_index"`
+	Routing string `json:"routing,omitempty"`
 }
 
 func (s *store) BulkIndexDevices(ctx context.Context, devices []*model.Device) error {
@@ -105,8 +209,9 @@ func (s *store) BulkIndexDevices(ctx context.Context, devices []*model.Device) e
 	for _, device := range devices {
 		actionJSON, err := json.Marshal(bulkAction{
 			Index: &bulkActionIndex{
-				ID:    device.GetID(),
-				Index: devIdx(device.GetTenantID()),
+				ID:      device.GetID(),
+				Index:   s.naming.devices(device.GetTenantID()),
+				Routing: s.routing(device.GetTenantID()),
 			},
 		})
 		if err != nil {
@@ -128,13 +233,60 @@ func (s *store) BulkIndexDevices(ctx context.Context, devices []*model.Device) e
 	}
 	defer res.Body.Close()
 
-	return nil
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to bulk index, code %d", res.StatusCode))
+	}
+	return parseBulkResponse(res.Body, len(devices))
+}
+
+// BulkIndexError reports the devices of a bulk request that failed to index
+type BulkIndexError struct {
+	Failed int
+	Total  int
+}
+
+func (e *BulkIndexError) Error() string {
+	return fmt.Sprintf("failed to bulk index %d of %d devices", e.Failed, e.Total)
+}
+
+// parseBulkResponse counts the failed items of a bulk response
+func parseBulkResponse(body io.Reader, total int) error {
+	var bulkRes struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&bulkRes); err != nil {
+		return errors.Wrap(err, "can't parse the bulk response")
+	}
+	if !bulkRes.Errors {
+		return nil
+	}
+
+	failed := 0
+	for _, item := range bulkRes.Items {
+		for _, action := range item {
+			if action.Status >= http.StatusBadRequest {
+				failed++
+			}
+		}
+	}
+	return &BulkIndexError{Failed: failed, Total: total}
 }
 
 func (s *store) Migrate(ctx context.Context) error {
+	schema, err := s.schema()
+	if err != nil {
+		return err
+	}
+	return s.applySchema(ctx, schema)
+}
+
+func (s *store) putIndexTemplate(ctx context.Context, name string, body io.Reader) error {
 	req := esapi.IndicesPutIndexTemplateRequest{
-		Name: indexDevices,
-		Body: strings.NewReader(indexDevicesTemplate),
+		Name: name,
+		Body: body,
 	}
 
 	res, err := req.Do(ctx, s.client)
@@ -144,7 +296,7 @@ func (s *store) Migrate(ctx context.Context) error {
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return errors.New("failed to set up the index template")
+		return errors.New("failed to set up the index template " + name)
 	}
 
 	return nil
@@ -164,17 +316,29 @@ func (s *store) Search(ctx context.Context, query interface{}) (model.M, error)
 
 	id := identity.FromContext(ctx)
 
-	resp, err := s.client.Search(
-		s.client.Search.WithContext(ctx),
-		s.client.Search.WithIndex("devices-"+id.Tenant),
-		s.client.Search.WithBody(&buf),
-		s.client.Search.WithTrackTotalHits(true),
-	)
-	defer resp.Body.Close()
+	release, err := s.searchLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
+	resp, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+		opts := []func(*esapi.SearchRequest){
+			client.Search.WithContext(ctx),
+			client.Search.WithIndex(s.naming.devices(id.Tenant)),
+			client.Search.WithBody(bytes.NewReader(buf.Bytes())),
+			client.Search.WithTrackTotalHits(true),
+			client.Search.WithRouting(s.routingList(id.Tenant)...),
+		}
+		if s.preference != "" {
+			opts = append(opts, client.Search.WithPreference(s.preference))
+		}
+		return client.Search(opts...)
+	})
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.IsError() {
 		return nil, errors.New(resp.String())
@@ -187,14 +351,128 @@ func (s *store) Search(ctx context.Context, query interface{}) (model.M, error)
 
 	return ret, nil
 }
+
+// Count returns the number of devices matching the query; with a positive
+// 'terminateAfter' each shard stops counting upon reaching it, so that
+// e.g. checking for any matches is cheap
+func (s *store) Count(ctx context.Context, query interface{}, terminateAfter int) (int, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return 0, err
+	}
+
+	id := identity.FromContext(ctx)
+
+	release, err := s.searchLimiter.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	resp, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+		opts := []func(*esapi.CountRequest){
+			client.Count.WithContext(ctx),
+			client.Count.WithIndex(s.naming.devices(id.Tenant)),
+			client.Count.WithBody(bytes.NewReader(buf.Bytes())),
+			client.Count.WithRouting(s.routingList(id.Tenant)...),
+		}
+		if terminateAfter > 0 {
+			opts = append(opts, client.Count.WithTerminateAfter(terminateAfter))
+		}
+		if s.preference != "" {
+			opts = append(opts, client.Count.WithPreference(s.preference))
+		}
+		return client.Count(opts...)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count devices")
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return 0, errors.New(resp.String())
+	}
+
+	var ret struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return 0, errors.Wrap(err, "failed to parse the count response")
+	}
+
+	return ret.Count, nil
+}
+
+// MultiSearch executes the queries in a single request, returns the
+// responses in the same order; failed queries have an 'error' key
+func (s *store) MultiSearch(ctx context.Context, queries []interface{}) ([]model.M, error) {
+	id := identity.FromContext(ctx)
+
+	header := model.M{
+		"index": s.naming.devices(id.Tenant),
+	}
+	if routing := s.routing(id.Tenant); routing != "" {
+		header["routing"] = routing
+	}
+	if s.preference != "" {
+		header["preference"] = s.preference
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, query := range queries {
+		if err := enc.Encode(header); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(query); err != nil {
+			return nil, err
+		}
+	}
+
+	// a multi search takes one slot, as ES runs its searches
+	// with a bounded concurrency of its own
+	release, err := s.searchLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := s.read(ctx, func(client *es.Client) (*esapi.Response, error) {
+		return client.Msearch(bytes.NewReader(buf.Bytes()),
+			client.Msearch.WithContext(ctx),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, errors.New(resp.String())
+	}
+
+	var ret struct {
+		Responses []model.M `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return nil, err
+	}
+	if len(ret.Responses) != len(queries) {
+		return nil, errors.New("unexpected number of multi search responses")
+	}
+
+	return ret.Responses, nil
+}
+
 func (s *store) GetDevice(ctx context.Context, tenant, devid string) (*model.Device, error) {
 	//l := log.FromContext(ctx)
 
 	id := identity.FromContext(ctx)
 
 	req := esapi.GetRequest{
-		Index:      devIdx(id.Tenant),
+		Index:      s.naming.devices(id.Tenant),
 		DocumentID: devid,
+		Routing:    s.routing(id.Tenant),
 	}
 
 	res, err := req.Do(ctx, s.client)
@@ -237,9 +515,10 @@ func (s *store) UpdateDevice(ctx context.Context, tenantID, deviceID string, upd
 
 	// DocumentType is _doc by default
 	req := esapi.UpdateRequest{
-		Index:      devIdx(id.Tenant),
+		Index:      s.naming.devices(id.Tenant),
 		DocumentID: deviceID,
 		Body:       esutil.NewJSONReader(body),
+		Routing:    s.routing(id.Tenant),
 	}
 
 	res, err := req.Do(ctx, s.client)
@@ -249,20 +528,17 @@ func (s *store) UpdateDevice(ctx context.Context, tenantID, deviceID string, upd
 
 	defer res.Body.Close()
 
+	if res.IsError() {
+		return writeError("update device in ES", res)
+	}
+
 	var esbody map[string]interface{}
 	if err := json.NewDecoder(res.Body).Decode(&esbody); err != nil {
 		return err
 	}
 	l.Debugf("ES update response %v", esbody)
 
-	switch {
-	case err != nil:
-		return errors.Wrap(err, "failed to update device in ES")
-	case res.IsError():
-		return errors.New(fmt.Sprintf("failed to update device in ES, code %d", res.StatusCode))
-	default:
-		return nil
-	}
+	return nil
 }
 
 // GetDevIndex retrieves the "devices-" index definition for tenant 'tid'
@@ -270,7 +546,7 @@ func (s *store) UpdateDevice(ctx context.Context, tenantID, deviceID string, upd
 // see: https://www.elastic.co/guide/en/elasticsearch/reference/current/indices-get-index.html
 func (s *store) GetDevIndex(ctx context.Context, tid string) (map[string]interface{}, error) {
 	l := log.FromContext(ctx)
-	idx := devIdx(tid)
+	idx := s.naming.devices(tid)
 
 	req := esapi.IndicesGetRequest{
 		Index: []string{idx},
@@ -306,13 +582,306 @@ func (s *store) GetDevIndex(ctx context.Context, tid string) (map[string]interfa
 	return indexM, nil
 }
 
+// GetDevIndices returns the devices indices definitions of all the
+// tenants, by the tenant ID, in one request
+func (s *store) GetDevIndices(ctx context.Context) (map[string]map[string]interface{}, error) {
+	req := esapi.IndicesGetRequest{
+		Index:      []string{s.naming.devicesPattern()},
+		FilterPath: []string{"*.mappings", "*.settings.index.mapping"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get devices indices from store")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get devices indices from store, code %d",
+			res.StatusCode))
+	}
+
+	var indexRes map[string]map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&indexRes); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]map[string]interface{}, len(indexRes))
+	for idx, index := range indexRes {
+		ret[s.naming.tenant(idx)] = index
+	}
+
+	return ret, nil
+}
+
+// DeleteDevicesUpdatedBefore removes tenant 'tid' devices which were last updated
+// before the given time, returns the number of deleted devices
+func (s *store) DeleteDevicesUpdatedBefore(ctx context.Context, tid string, before time.Time) (int, error) {
+	query := model.M{
+		"query": model.M{
+			"range": model.M{
+				"updatedAt": model.M{
+					"lt": before,
+				},
+			},
+		},
+	}
+
+	req := esapi.DeleteByQueryRequest{
+		Index:     []string{s.naming.devices(tid)},
+		Body:      esutil.NewJSONReader(query),
+		Conflicts: "proceed",
+		Routing:   s.routingList(tid),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("failed to delete devices, tid %s", tid))
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		if res.StatusCode == http.StatusNotFound {
+			return 0, nil
+		}
+		return 0, errors.New(fmt.Sprintf("failed to delete devices, tid %s, code %d", tid, res.StatusCode))
+	}
+
+	var deleteRes struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&deleteRes); err != nil {
+		return 0, err
+	}
+
+	return deleteRes.Deleted, nil
+}
+
+// devicesTemplate prepares the "devices" index template, with the
+// configured attribute analyzers taking precedence over the generic
+// dynamic templates
+func (s *store) devicesTemplate() (model.M, error) {
+	var template model.M
+	if err := json.Unmarshal([]byte(indexDevicesTemplate), &template); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the index template")
+	}
+	template["index_patterns"] = []string{s.naming.devicesPattern()}
+
+	settings := template["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	settings["number_of_shards"] = s.shards
+	settings["number_of_replicas"] = s.replicas
+
+	mappings := template["template"].(map[string]interface{})["mappings"].(map[string]interface{})
+	if s.routingByTenant {
+		mappings["_routing"] = model.M{"required": true}
+	}
+
+	dynamic := mappings["dynamic_templates"].([]interface{})
+	if s.capabilities != nil && !s.capabilities.VersionFields {
+		dynamic = withoutDynamicTemplate(dynamic, dynamicTemplateVersions)
+		mappings["dynamic_templates"] = dynamic
+	}
+
+	if len(s.analyzers) == 0 {
+		return template, nil
+	}
+
+	attrs := make([]string, 0, len(s.analyzers))
+	for attr := range s.analyzers {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	analyzed := make([]interface{}, 0, len(attrs)+len(dynamic))
+	for _, attr := range attrs {
+		analyzed = append(analyzed, map[string]interface{}{
+			"analyzed_" + attr: model.M{
+				"match":   attr,
+				"mapping": s.analyzers.Mapping(attr),
+			},
+		})
+	}
+	mappings["dynamic_templates"] = append(analyzed, dynamic...)
+
+	return template, nil
+}
+
+// withoutDynamicTemplate drops the named dynamic template
+func withoutDynamicTemplate(dynamic []interface{}, name string) []interface{} {
+	ret := make([]interface{}, 0, len(dynamic))
+	for _, d := range dynamic {
+		if _, ok := d.(map[string]interface{})[name]; !ok {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// fixedIndexTemplate prepares the index template 'tmpl' of a single,
+// fixed named index 'name'
+func fixedIndexTemplate(tmpl, name string) (model.M, error) {
+	var template model.M
+	if err := json.Unmarshal([]byte(tmpl), &template); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the index template")
+	}
+	template["index_patterns"] = []string{name}
+
+	return template, nil
+}
+
+// ClusterHealth returns the ES cluster status, shard allocation and pending tasks
+func (s *store) ClusterHealth(ctx context.Context) (*model.ClusterHealth, error) {
+	res, err := s.client.Cluster.Health(s.client.Cluster.Health.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the Elasticsearch cluster health")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get the Elasticsearch cluster health, code %d", res.StatusCode))
+	}
+
+	var health model.ClusterHealth
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		return nil, errors.Wrap(err, "can't parse the Elasticsearch cluster health")
+	}
+	s.searchLimiter.stats(&health)
+
+	return &health, nil
+}
+
+// GetTenants lists the tenants having a devices index
+func (s *store) GetTenants(ctx context.Context) ([]string, error) {
+	req := esapi.CatIndicesRequest{
+		Index:  []string{s.naming.devicesPattern()},
+		Format: "json",
+		H:      []string{"index"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the devices indices")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to list the devices indices, code %d", res.StatusCode))
+	}
+
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, err
+	}
+
+	tenants := make([]string, 0, len(indices))
+	for _, idx := range indices {
+		tenants = append(tenants, s.naming.tenant(idx.Index))
+	}
+
+	return tenants, nil
+}
+
+// ApplySettings updates the index template, and the dynamic
+// settings (replica count) of the existing devices indices;
+// the number of shards only applies to newly created indices
+func (s *store) ApplySettings(ctx context.Context) error {
+	if err := s.Migrate(ctx); err != nil {
+		return err
+	}
+	if err := s.applyReplicaSelection(ctx); err != nil {
+		return err
+	}
+
+	body := model.M{
+		"index": model.M{
+			"number_of_replicas": s.replicas,
+		},
+	}
+
+	req := esapi.IndicesPutSettingsRequest{
+		Index: []string{s.naming.devicesPattern()},
+		Body:  esutil.NewJSONReader(body),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to update the indices settings")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to update the indices settings, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// routing returns the document routing key for tenant 'tid',
+// empty if routing by tenant is disabled
+func (s *store) routing(tid string) string {
+	if s.routingByTenant {
+		return tid
+	}
+	return ""
+}
+
+func (s *store) routingList(tid string) []string {
+	if s.routingByTenant {
+		return []string{tid}
+	}
+	return nil
+}
+
 func WithServerAddresses(addresses []string) StoreOption {
 	return func(s *store) {
 		s.addresses = addresses
 	}
 }
 
-// devIdx prepares "devices" index name for tenant tid
-func devIdx(tid string) string {
-	return indexDevices + "-" + tid
+// WithFaults injects the faults into the Elasticsearch requests,
+// see transport.Faults
+func WithFaults(faults transport.Faults) StoreOption {
+	return func(s *store) {
+		s.faults = faults
+	}
+}
+
+// WithIndexPrefix prefixes the index and index template names
+func WithIndexPrefix(prefix string) StoreOption {
+	return func(s *store) {
+		s.naming.prefix = prefix
+	}
+}
+
+// WithIndexSuffix suffixes the index and index template names
+func WithIndexSuffix(suffix string) StoreOption {
+	return func(s *store) {
+		s.naming.suffix = suffix
+	}
+}
+
+func WithShards(shards int) StoreOption {
+	return func(s *store) {
+		s.shards = shards
+	}
+}
+
+func WithReplicas(replicas int) StoreOption {
+	return func(s *store) {
+		s.replicas = replicas
+	}
+}
+
+func WithRoutingByTenant(routingByTenant bool) StoreOption {
+	return func(s *store) {
+		s.routingByTenant = routingByTenant
+	}
+}
+
+func WithAttributeAnalyzers(analyzers model.Analyzers) StoreOption {
+	return func(s *store) {
+		s.analyzers = analyzers
+	}
 }
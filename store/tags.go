@@ -0,0 +1,80 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrDeviceNotFound = errors.New("device not found")
+)
+
+// removeFieldsScript removes the fields from the document source
+const removeFieldsScript = "for (f in params.fields) { ctx._source.remove(f) }"
+
+// SetDeviceTags adds the tags to the device, replacing the values
+// of the existing ones; the tags are searchable on return
+func (s *store) SetDeviceTags(ctx context.Context, tid, devID string, tags model.DeviceTags) error {
+	return s.updateTags(ctx, tid, devID, model.M{
+		"doc": tags.Doc(),
+	})
+}
+
+// RemoveDeviceTags removes the named tags from the device;
+// the tags are no longer searchable on return
+func (s *store) RemoveDeviceTags(ctx context.Context, tid, devID string, names []string) error {
+	return s.updateTags(ctx, tid, devID, model.M{
+		"script": model.M{
+			"lang":   "painless",
+			"source": removeFieldsScript,
+			"params": model.M{
+				"fields": model.TagFields(names),
+			},
+		},
+	})
+}
+
+func (s *store) updateTags(ctx context.Context, tid, devID string, body model.M) error {
+	req := esapi.UpdateRequest{
+		Index:      s.naming.devices(tid),
+		DocumentID: devID,
+		Body:       esutil.NewJSONReader(body),
+		Routing:    s.routing(tid),
+		Refresh:    "wait_for",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to update device tags")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrDeviceNotFound
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to update device tags, code %d", res.StatusCode))
+	}
+
+	return nil
+}
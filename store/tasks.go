@@ -0,0 +1,167 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+var (
+	ErrTaskNotFound = errors.New("task not found")
+)
+
+// PutTask stores the new task; the later changes are partial
+// updates, see UpdateTask and RequestTaskCancel
+func (s *store) PutTask(ctx context.Context, task *model.Task) error {
+	req := esapi.IndexRequest{
+		Index:      s.naming.tasks(),
+		DocumentID: task.ID,
+		Body:       esutil.NewJSONReader(task),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to store task")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to store task, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// UpdateTask updates the stored task status and progress, with a
+// partial update leaving the cancellation request as it is, and
+// returns the updated task, the cancellation request included
+func (s *store) UpdateTask(ctx context.Context, task *model.Task) (*model.Task, error) {
+	doc := model.M{
+		"status":     task.Status,
+		"progress":   task.Progress,
+		"updated_at": task.UpdatedAt,
+	}
+	if task.Error != "" {
+		doc["error"] = task.Error
+	}
+	if task.CompletedAt != nil {
+		doc["completed_at"] = task.CompletedAt
+	}
+
+	retries := 3
+	req := esapi.UpdateRequest{
+		Index:           s.naming.tasks(),
+		DocumentID:      task.ID,
+		Body:            esutil.NewJSONReader(model.M{"doc": doc}),
+		Refresh:         "true",
+		RetryOnConflict: &retries,
+		Source:          []string{"true"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update task")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrTaskNotFound
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to update task, code %d", res.StatusCode))
+	}
+
+	var updated struct {
+		Get struct {
+			Source model.Task `json:"_source"`
+		} `json:"get"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&updated); err != nil {
+		return nil, errors.Wrap(err, "failed to parse task")
+	}
+
+	return &updated.Get.Source, nil
+}
+
+// RequestTaskCancel flags the task cancellation request, with a
+// partial update leaving the status and progress as they are
+func (s *store) RequestTaskCancel(ctx context.Context, id string, at time.Time) error {
+	retries := 3
+	req := esapi.UpdateRequest{
+		Index:      s.naming.tasks(),
+		DocumentID: id,
+		Body: esutil.NewJSONReader(model.M{
+			"doc": model.M{
+				"cancel_requested": true,
+				"updated_at":       at,
+			},
+		}),
+		Refresh:         "true",
+		RetryOnConflict: &retries,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel task")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrTaskNotFound
+	} else if res.IsError() {
+		return errors.New(fmt.Sprintf("failed to cancel task, code %d", res.StatusCode))
+	}
+
+	return nil
+}
+
+// GetTask retrieves the task by ID
+func (s *store) GetTask(ctx context.Context, id string) (*model.Task, error) {
+	req := esapi.GetRequest{
+		Index:      s.naming.tasks(),
+		DocumentID: id,
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get task")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrTaskNotFound
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get task, code %d", res.StatusCode))
+	}
+
+	var doc struct {
+		Source model.Task `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse task")
+	}
+
+	return &doc.Source, nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+	"github.com/pkg/errors"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+// GetStorageUsage returns the device count and the storage size,
+// replicas included, of the devices index of tenant 'tid', or of all
+// the tenants if 'tid' is empty
+func (s *store) GetStorageUsage(ctx context.Context, tid string) ([]model.TenantUsage, error) {
+	index := s.naming.devicesPattern()
+	if tid != "" {
+		index = s.naming.devices(tid)
+	}
+
+	req := esapi.IndicesStatsRequest{
+		Index:  []string{index},
+		Metric: []string{"docs", "store"},
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the index stats")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return []model.TenantUsage{}, nil
+	} else if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get the index stats, code %d", res.StatusCode))
+	}
+
+	var stats struct {
+		Indices map[string]struct {
+			Total struct {
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"total"`
+		} `json:"indices"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		return nil, errors.Wrap(err, "can't parse the index stats")
+	}
+
+	counts, err := s.countDevices(ctx, index, len(stats.Indices))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]model.TenantUsage, 0, len(stats.Indices))
+	for idx, st := range stats.Indices {
+		ret = append(ret, model.TenantUsage{
+			TenantID:     s.naming.tenant(idx),
+			DeviceCount:  counts[idx],
+			StorageBytes: st.Total.Store.SizeInBytes,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].TenantID < ret[j].TenantID
+	})
+
+	return ret, nil
+}
+
+// countDevices returns the device count of each of the 'n' indices
+// matching 'index', in one request; the stats docs count includes the
+// nested documents too, so the top-level documents are counted instead
+func (s *store) countDevices(ctx context.Context, index string, n int) (map[string]int64, error) {
+	if n == 0 {
+		return map[string]int64{}, nil
+	}
+
+	query := model.M{
+		"size": 0,
+		"aggs": model.M{
+			"indices": model.M{
+				"terms": model.M{
+					"field": "_index",
+					"size":  n,
+				},
+			},
+		},
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{index},
+		Body:  esutil.NewJSONReader(query),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count the devices")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to count the devices, code %d",
+			res.StatusCode))
+	}
+
+	var counts struct {
+		Aggregations struct {
+			Indices struct {
+				Buckets []struct {
+					Key      string `json:"key"`
+					DocCount int64  `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"indices"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&counts); err != nil {
+		return nil, errors.Wrap(err, "can't parse the devices count")
+	}
+
+	ret := make(map[string]int64, len(counts.Aggregations.Indices.Buckets))
+	for _, b := range counts.Aggregations.Indices.Buckets {
+		ret[b.Key] = b.DocCount
+	}
+
+	return ret, nil
+}
+
+// the maximum number of tenants reported by GetLastUpdated
+const maxTenantsActivity = 10000
+
+// GetLastUpdated returns the time of the latest device update
+// of each tenant, from all the devices indices at once
+func (s *store) GetLastUpdated(ctx context.Context) (map[string]time.Time, error) {
+	query := model.M{
+		"size": 0,
+		"aggs": model.M{
+			"indices": model.M{
+				"terms": model.M{
+					"field": "_index",
+					"size":  maxTenantsActivity,
+				},
+				"aggs": model.M{
+					"last_updated": model.M{
+						"max": model.M{"field": "updatedAt"},
+					},
+				},
+			},
+		},
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{s.naming.devicesPattern()},
+		Body:  esutil.NewJSONReader(query),
+	}
+
+	res, err := req.Do(ctx, s.client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the tenants activity")
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, errors.New(fmt.Sprintf("failed to get the tenants activity, code %d",
+			res.StatusCode))
+	}
+
+	var activity struct {
+		Aggregations struct {
+			Indices struct {
+				Buckets []struct {
+					Key         string `json:"key"`
+					LastUpdated struct {
+						Value *float64 `json:"value"`
+					} `json:"last_updated"`
+				} `json:"buckets"`
+			} `json:"indices"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&activity); err != nil {
+		return nil, errors.Wrap(err, "can't parse the tenants activity")
+	}
+
+	ret := make(map[string]time.Time, len(activity.Aggregations.Indices.Buckets))
+	for _, b := range activity.Aggregations.Indices.Buckets {
+		if b.LastUpdated.Value == nil {
+			continue
+		}
+		ms := int64(*b.LastUpdated.Value)
+		ret[s.naming.tenant(b.Key)] = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 Northern.tech AS
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	es "github.com/elastic/go-elasticsearch/v7"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mendersoftware/reporting/model"
+)
+
+func TestGetStorageUsage(t *testing.T) {
+	// the stats docs count includes the nested documents
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Elastic-Product", "Elasticsearch")
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/_stats/docs,store"):
+				_, _ = w.Write([]byte(`{"indices": {
+					"devices-foo": {
+						"primaries": {"docs": {"count": 120}},
+						"total": {"store": {"size_in_bytes": 2048}}
+					},
+					"devices-bar": {
+						"primaries": {"docs": {"count": 0}},
+						"total": {"store": {"size_in_bytes": 512}}
+					}
+				}}`))
+			case strings.HasSuffix(r.URL.Path, "/_search"):
+				_, _ = w.Write([]byte(`{"aggregations": {"indices": {"buckets": [
+					{"key": "devices-foo", "doc_count": 40}
+				]}}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer srv.Close()
+
+	client, err := es.NewClient(es.Config{
+		Addresses:            []string{srv.URL},
+		DisableRetry:         true,
+		UseResponseCheckOnly: true,
+	})
+	assert.NoError(t, err)
+	s := &store{client: client}
+
+	usage, err := s.GetStorageUsage(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TenantUsage{
+		{TenantID: "bar", DeviceCount: 0, StorageBytes: 512},
+		{TenantID: "foo", DeviceCount: 40, StorageBytes: 2048},
+	}, usage)
+}